@@ -5,6 +5,7 @@ go 1.26.1
 require (
 	github.com/Azure/go-amqp v1.6.0
 	github.com/apache/cassandra-gocql-driver/v2 v2.1.1
+	github.com/apache/thrift v0.22.0
 	github.com/aws/aws-sdk-go-v2 v1.41.6
 	github.com/aws/aws-sdk-go-v2/config v1.32.16
 	github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager v0.1.18
@@ -12,6 +13,7 @@ require (
 	github.com/fraugster/parquet-go v0.12.0
 	github.com/google/uuid v1.6.0
 	github.com/hashicorp/golang-lru/v2 v2.0.7
+	github.com/klauspost/compress v1.18.0
 	github.com/pkg/sftp v1.13.10
 	github.com/prometheus/client_golang v1.23.2
 	github.com/sethvargo/go-envconfig v1.3.0
@@ -40,7 +42,6 @@ require (
 )
 
 require (
-	github.com/apache/thrift v0.22.0 // indirect
 	github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.7.9 // indirect
 	github.com/aws/aws-sdk-go-v2/credentials v1.19.15 // indirect
 	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.18.22 // indirect
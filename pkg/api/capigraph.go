@@ -8,8 +8,10 @@ import (
 	"time"
 
 	"github.com/capillariesio/capillaries/pkg/capigraph"
+	"github.com/capillariesio/capillaries/pkg/custom/pivot"
 	"github.com/capillariesio/capillaries/pkg/custom/pycalc"
 	"github.com/capillariesio/capillaries/pkg/custom/taganddenormalize"
+	"github.com/capillariesio/capillaries/pkg/custom/unpivot"
 	"github.com/capillariesio/capillaries/pkg/sc"
 	"github.com/capillariesio/capillaries/pkg/wfmodel"
 )
@@ -94,6 +96,17 @@ const CapillariesIcons100x100 = `
       d="M133.97 242.65l1.98 22.2c-5.54 2.29-12.5 3.44-20.87 3.44-8.37 0-15.09-.88-20.16-2.65-5.06-1.76-9.05-4.53-11.95-8.32-2.91-3.79-4.94-8.24-6.08-13.35-1.15-5.11-1.72-11.4-1.72-18.89s.57-13.81 1.72-18.96c1.14-5.16 3.17-9.63 6.08-13.42 5.63-7.31 15.99-10.96 31.05-10.96 3.35 0 7.29.33 11.82.99 4.54.66 7.91 1.47 10.11 2.44l-3.96 20.22c-5.73-1.23-10.97-1.85-15.72-1.85-4.76 0-8.07.44-9.92 1.32-1.84.88-2.77 2.64-2.77 5.29v34.62c3.43.7 6.91 1.05 10.44 1.05 7.49 0 14.14-1.05 19.95-3.17zm11.9 22.2l3.69-21.8c8.11 2.03 15.4 3.04 21.87 3.04 6.48 0 11.7-.27 15.66-.79v-6.61l-11.89-1.06c-10.75-.97-18.13-3.54-22.13-7.73-4.01-4.18-6.02-10.37-6.02-18.56 0-11.28 2.45-19.03 7.34-23.26s13.19-6.34 24.91-6.34 22.28 1.1 31.71 3.3l-3.3 21.14c-8.19-1.32-14.76-1.98-19.69-1.98-4.93 0-9.12.22-12.56.66v6.48l9.52.92c11.54 1.15 19.51 3.9 23.92 8.26 4.4 4.36 6.6 10.42 6.6 18.17 0 5.55-.74 10.24-2.24 14.07-1.5 3.83-3.28 6.74-5.35 8.72-2.07 1.99-5 3.5-8.79 4.56-3.79 1.06-7.11 1.7-9.98 1.92-2.86.22-6.67.33-11.43.33-11.45 0-22.07-1.15-31.84-3.44zm128.57-81.13h27.88l-20.48 82.59h-38.59l-20.48-82.59h27.88l11.24 52.46h1.19l11.36-52.46z" />
   </g>
 </g>
+<g id="icon-jsonl">
+  <g transform="scale(0.193,0.193) translate(11,3)">
+    <path fill-rule="nonzero"
+      d="M117.91 0h201.68c3.93 0 7.44 1.83 9.72 4.67l114.28 123.67c2.21 2.37 3.27 5.4 3.27 8.41l.06 310c0 35.43-29.4 64.81-64.8 64.81H117.91c-35.57 0-64.81-29.24-64.81-64.81V64.8C53.1 29.13 82.23 0 117.91 0zM325.5 37.15v52.94c2.4 31.34 23.57 42.99 52.93 43.5l36.16-.04-89.09-96.4zm96.5 121.3l-43.77-.04c-42.59-.68-74.12-21.97-77.54-66.54l-.09-66.95H117.91c-21.93 0-39.89 17.96-39.89 39.88v381.95c0 21.82 18.07 39.89 39.89 39.89h264.21c21.71 0 39.88-18.15 39.88-39.89v-288.3z" />
+  </g>
+  <g transform="scale(0.25) translate(12,60)">
+    <path fill="none" stroke="#000000" stroke-width="18" stroke-linecap="round" stroke-linejoin="round"
+      d="M150 180c-22 0-30 8-30 26v22c0 12-6 18-18 18c12 0 18 6 18 18v22c0 18 8 26 30 26M250 180c22 0 30 8 30 26v22c0 12 6 18 18 18c-12 0-18 6-18 18v22c0 18-8 26-30 26" />
+    <circle cx="200" cy="246" r="12" />
+  </g>
+</g>
 <g id="icon-parquet" transform="scale(0.193,0.193) translate(11,3)">
   <path fill-rule="nonzero"
     d="M117.91 0h201.68c3.93 0 7.44 1.83 9.72 4.67l114.28 123.67c2.21 2.37 3.27 5.4 3.27 8.41l.06 310c0 35.43-29.4 64.81-64.8 64.81H117.91c-35.57 0-64.81-29.24-64.81-64.81V64.8C53.1 29.13 82.23 0 117.91 0zM325.5 37.15v52.94c2.4 31.34 23.57 42.99 52.93 43.5l36.16-.04-89.09-96.4zm96.5 121.3l-43.77-.04c-42.59-.68-74.12-21.97-77.54-66.54l-.09-66.95H117.91c-21.93 0-39.89 17.96-39.89 39.88v381.95c0 21.82 18.07 39.89 39.89 39.89h264.21c21.71 0 39.88-18.15 39.88-39.89v-288.3z" />
@@ -172,6 +185,17 @@ func nodeTypeDescription(node *sc.ScriptNodeDef) string {
 				"Table created: %s",
 			node.TableReader.TableName,
 			node.TableCreator.Name)
+	case sc.NodeTypeTableUnionTable:
+		sourceTableNames := make([]string, len(node.UnionReader.Tables))
+		for i := 0; i < len(node.UnionReader.Tables); i++ {
+			sourceTableNames[i] = node.UnionReader.Tables[i].TableName
+		}
+		return fmt.Sprintf(
+			"Processor: write union of tables to table\n"+
+				"Source tables: %s\n"+
+				"Table created: %s",
+			strings.Join(sourceTableNames, ", "),
+			node.TableCreator.Name)
 	case sc.NodeTypeTableLookupTable:
 		return fmt.Sprintf(
 			"Processor: %s join with lookup table, group: %t\n"+
@@ -181,6 +205,22 @@ func nodeTypeDescription(node *sc.ScriptNodeDef) string {
 			node.Lookup.IsGroup,
 			node.Lookup.IndexName,
 			node.TableCreator.Name)
+	case sc.NodeTypeTableWindowTable:
+		return fmt.Sprintf(
+			"Processor: window functions over table partitions\n"+
+				"Partition index: %s\n"+
+				"Order: %s\n"+
+				"Table created: %s",
+			node.Window.IndexName,
+			node.Window.RawOrder,
+			node.TableCreator.Name)
+	case sc.NodeTypeTableDqTable:
+		return fmt.Sprintf(
+			"Processor: check data quality rules\n"+
+				"Rules: %s\n"+
+				"Quarantine table created: %s",
+			strings.Join(node.DataQuality.RuleNames, ", "),
+			node.TableCreator.Name)
 	case sc.NodeTypeTableFile:
 		return fmt.Sprintf(
 			"Processor: read from table into files\n"+
@@ -208,6 +248,20 @@ func nodeTypeDescription(node *sc.ScriptNodeDef) string {
 					"Table created: %s",
 				tagCriteriaUrl,
 				node.TableCreator.Name)
+		case pivot.ProcessorPivotName:
+			return fmt.Sprintf(
+				"Processor: pivot\n"+
+					"Pivot key: %s\n"+
+					"Table created: %s",
+				node.CustomProcessor.(*pivot.PivotProcessorDef).RawKey,
+				node.TableCreator.Name)
+		case unpivot.ProcessorUnpivotName:
+			return fmt.Sprintf(
+				"Processor: unpivot\n"+
+					"Columns: %d\n"+
+					"Table created: %s",
+				len(node.CustomProcessor.(*unpivot.UnpivotProcessorDef).ColumnNames),
+				node.TableCreator.Name)
 		default:
 			return "Custom processor: unknown"
 		}
@@ -232,7 +286,7 @@ func nodeTypeIcon(node *sc.ScriptNodeDef) string {
 	switch node.Type {
 	case sc.NodeTypeFileTable:
 		return "icon-database-table-read"
-	case sc.NodeTypeTableTable:
+	case sc.NodeTypeTableTable, sc.NodeTypeTableUnionTable, sc.NodeTypeTableWindowTable:
 		return "icon-database-table-copy"
 	case sc.NodeTypeTableLookupTable:
 		return "icon-database-table-join"
@@ -242,6 +296,8 @@ func nodeTypeIcon(node *sc.ScriptNodeDef) string {
 			return "icon-csv"
 		case sc.CreatorFileTypeParquet:
 			return "icon-parquet"
+		case sc.CreatorFileTypeJsonl:
+			return "icon-jsonl"
 		default:
 			return ""
 		}
@@ -251,11 +307,15 @@ func nodeTypeIcon(node *sc.ScriptNodeDef) string {
 			return "icon-database-table-py"
 		case taganddenormalize.ProcessorTagAndDenormalizeName:
 			return "icon-database-table-tag"
+		case pivot.ProcessorPivotName, unpivot.ProcessorUnpivotName:
+			return "icon-database-table-copy"
 		default:
 			return ""
 		}
 	case sc.NodeTypeDistinctTable:
 		return "icon-database-table-distinct"
+	case sc.NodeTypeTableDqTable:
+		return "icon-database-table-copy"
 	default:
 		return ""
 	}
@@ -336,16 +396,13 @@ func populateNodeDefs(scriptDef *sc.ScriptDef, nodeStringColorMap map[string]int
 	return nodeDefs, nodeNameMap
 }
 
-func populateTableReaderNodeDefPriIn(scriptDef *sc.ScriptDef, nodeDefs []capigraph.NodeDef, allUsedFields sc.FieldRefs, node *sc.ScriptNodeDef, nodeIdx int16, nodeNameMap map[string]int16, showIdx bool, showFields bool) {
-	parentNode := scriptDef.TableCreatorNodeMap[node.TableReader.TableName]
-	parentNodeIdx := nodeNameMap[parentNode.Name]
-	nodeDefs[nodeIdx].PriIn.SrcId = parentNodeIdx
+func tableReaderEdgeText(tableReader *sc.TableReaderDef, allUsedFields sc.FieldRefs, showIdx bool, showFields bool) string {
 	sb := strings.Builder{}
 	if showIdx {
-		if node.TableReader.ExpectedBatchesTotal > 1 {
-			fmt.Fprintf(&sb, "%s\n(%d batches)", node.TableReader.TableName, node.TableReader.ExpectedBatchesTotal)
+		if tableReader.ExpectedBatchesTotal > 1 {
+			fmt.Fprintf(&sb, "%s\n(%d batches)", tableReader.TableName, tableReader.ExpectedBatchesTotal)
 		} else {
-			fmt.Fprintf(&sb, "%s\n(no parallelism)", node.TableReader.TableName)
+			fmt.Fprintf(&sb, "%s\n(no parallelism)", tableReader.TableName)
 		}
 	}
 	if showFields {
@@ -372,8 +429,30 @@ func populateTableReaderNodeDefPriIn(scriptDef *sc.ScriptDef, nodeDefs []capigra
 			}
 		}
 	}
-	nodeDefs[nodeIdx].PriIn.Text = sb.String()
+	return sb.String()
+}
 
+func populateTableReaderNodeDefPriIn(scriptDef *sc.ScriptDef, nodeDefs []capigraph.NodeDef, allUsedFields sc.FieldRefs, node *sc.ScriptNodeDef, nodeIdx int16, nodeNameMap map[string]int16, showIdx bool, showFields bool) {
+	parentNode := scriptDef.TableCreatorNodeMap[node.TableReader.TableName]
+	parentNodeIdx := nodeNameMap[parentNode.Name]
+	nodeDefs[nodeIdx].PriIn.SrcId = parentNodeIdx
+	nodeDefs[nodeIdx].PriIn.Text = tableReaderEdgeText(&node.TableReader, allUsedFields, showIdx, showFields)
+}
+
+// First union source table is the primary parent, the rest are secondary
+func populateUnionTableReaderNodeDefIns(scriptDef *sc.ScriptDef, nodeDefs []capigraph.NodeDef, allUsedFields sc.FieldRefs, node *sc.ScriptNodeDef, nodeIdx int16, nodeNameMap map[string]int16, showIdx bool, showFields bool) {
+	for i := 0; i < len(node.UnionReader.Tables); i++ {
+		tableReader := &node.UnionReader.Tables[i]
+		parentNode := scriptDef.TableCreatorNodeMap[tableReader.TableName]
+		parentNodeIdx := nodeNameMap[parentNode.Name]
+		edgeText := tableReaderEdgeText(tableReader, allUsedFields, showIdx, showFields)
+		if i == 0 {
+			nodeDefs[nodeIdx].PriIn.SrcId = parentNodeIdx
+			nodeDefs[nodeIdx].PriIn.Text = edgeText
+		} else {
+			nodeDefs[nodeIdx].SecIn = append(nodeDefs[nodeIdx].SecIn, capigraph.EdgeDef{SrcId: parentNodeIdx, Text: edgeText})
+		}
+	}
 }
 
 func populateLookupNodeDefSecIn(scriptDef *sc.ScriptDef, nodeDefs []capigraph.NodeDef, allUsedFields sc.FieldRefs, node *sc.ScriptNodeDef, nodeIdx int16, nodeNameMap map[string]int16, showIdx bool, showFields bool) {
@@ -423,6 +502,8 @@ func populateDirectParentsAndLookups(scriptDef *sc.ScriptDef, nodeDefs []capigra
 
 		if node.HasTableReader() {
 			populateTableReaderNodeDefPriIn(scriptDef, nodeDefs, allUsedFields, node, nodeIdx, nodeNameMap, showIdx, showFields)
+		} else if node.HasUnionTableReader() {
+			populateUnionTableReaderNodeDefIns(scriptDef, nodeDefs, allUsedFields, node, nodeIdx, nodeNameMap, showIdx, showFields)
 		}
 		if node.HasLookup() {
 			populateLookupNodeDefSecIn(scriptDef, nodeDefs, allUsedFields, node, nodeIdx, nodeNameMap, showIdx, showFields)
@@ -50,6 +50,8 @@ func GetTablesCql(script *sc.ScriptDef, keyspace string, runId int16, startNodeN
 	fmt.Fprintf(&sb, "%s\n", db.GetCreateTableCql(reflect.TypeOf(wfmodel.RunHistoryEvent{}), keyspace, wfmodel.TableNameRunHistory))
 	fmt.Fprintf(&sb, "%s\n", db.GetCreateTableCql(reflect.TypeOf(wfmodel.RunProperties{}), keyspace, wfmodel.TableNameRunProperties))
 	fmt.Fprintf(&sb, "%s\n", db.GetCreateTableCql(reflect.TypeOf(wfmodel.RunCounter{}), keyspace, wfmodel.TableNameRunCounter))
+	fmt.Fprintf(&sb, "%s\n", db.GetCreateTableCql(reflect.TypeOf(wfmodel.FileLedgerEntry{}), keyspace, wfmodel.TableNameFileLedger))
+	fmt.Fprintf(&sb, "%s\n", db.GetCreateTableCql(reflect.TypeOf(wfmodel.FinalizeClaim{}), keyspace, wfmodel.TableNameFinalizeClaim))
 	qb := cql.QueryBuilder{}
 	fmt.Fprintf(&sb, "%s\n", qb.Keyspace(keyspace).Write("ks", keyspace).Write("last_run", 0).InsertUnpreparedQuery(wfmodel.TableNameRunCounter, cql.IfNotExistsLwt))
 
@@ -117,6 +117,34 @@ func drawTableReader(node *sc.ScriptNodeDef, showIdx bool, showFields bool, arro
 	return b.String()
 }
 
+func drawUnionTableReader(node *sc.ScriptNodeDef, showIdx bool, showFields bool, arrowFontSize int, allUsedFields sc.FieldRefs) string {
+	var b strings.Builder
+	for i := 0; i < len(node.UnionReader.Tables); i++ {
+		tableReader := &node.UnionReader.Tables[i]
+		sb := strings.Builder{}
+		if showIdx {
+			if tableReader.ExpectedBatchesTotal > 1 {
+				fmt.Fprintf(&sb, "%s (%d batches)", tableReader.TableName, tableReader.ExpectedBatchesTotal)
+			} else {
+				fmt.Fprintf(&sb, "%s (no parallelism)", tableReader.TableName)
+			}
+		}
+		if showFields {
+			if showIdx {
+				sb.WriteString("\\l")
+			}
+			for j := 0; j < len(allUsedFields); j++ {
+				if allUsedFields[j].TableName == sc.ReaderAlias {
+					sb.WriteString(allUsedFields[j].FieldName)
+					sb.WriteString("\\l")
+				}
+			}
+		}
+		fmt.Fprintf(&b, "\"%s\" -> \"%s\" [style=solid, fontsize=\"%d\", label=\"%s\"];\n", tableReader.TableName, node.GetTargetName(), arrowFontSize, sb.String())
+	}
+	return b.String()
+}
+
 func drawTableCreator(node *sc.ScriptNodeDef, recordFontSize int, penWidth string, fillColor string) string {
 	if node.HasLookup() {
 		return fmt.Sprintf("\"%s\" [shape=record, penwidth=\"%s\", fontsize=\"%d\", fillcolor=\"%s\", style=\"filled\", label=\"{%s|creates table:\\n%s|group:%t, join:%s}\", tooltip=\"%s\"];\n",
@@ -164,6 +192,8 @@ func GetDotDiagram(scriptDef *sc.ScriptDef, showIdx bool, showFields bool, nodeC
 
 		if node.HasTableReader() {
 			b.WriteString(drawTableReader(node, showIdx, showFields, arrowFontSize, recordFontSize, allUsedFields, penWidth, fillColor, urlEscaper))
+		} else if node.HasUnionTableReader() {
+			b.WriteString(drawUnionTableReader(node, showIdx, showFields, arrowFontSize, allUsedFields))
 		}
 
 		if node.HasTableCreator() {
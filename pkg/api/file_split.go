@@ -0,0 +1,49 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/capillariesio/capillaries/pkg/xfer"
+)
+
+// Used by StartRun and Toolbelt: batch intervals for the node, reading sizes of files that are split into byte ranges
+func GetNodeBatchIntervals(logger *l.CapiLogger, envConfig *env.EnvConfig, node *sc.ScriptNodeDef, resolvedFileUrls map[string][]string) ([][]int64, error) {
+	logger.PushF("api.GetNodeBatchIntervals")
+	defer logger.PopF()
+
+	node = NodeWithResolvedFileUrls(node, resolvedFileUrls)
+	if !node.HasFileReader() || !node.FileReader.IsSplit() {
+		return node.GetTokenIntervalsByNumberOfBatches()
+	}
+
+	fileSizes := make([]int64, len(node.FileReader.SrcFileUrls))
+	for i, fileUrl := range node.FileReader.SrcFileUrls {
+		u, err := url.Parse(fileUrl)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse file url %s: %s", fileUrl, err.Error())
+		}
+		// We need to read from an offset without downloading the whole file
+		if u.Scheme == xfer.UrlSchemeSftp {
+			return nil, fmt.Errorf("node %s cannot split file %s: sftp files cannot be read from an offset, remove split_size_bytes", node.Name, fileUrl)
+		}
+		if compression := node.FileReader.GetCompression(fileUrl); compression != sc.CompressionNone {
+			return nil, fmt.Errorf("node %s cannot split file %s: %s compressed files cannot be read from an offset, remove split_size_bytes", node.Name, fileUrl, compression)
+		}
+		fileStat, err := xfer.StatFile(fileUrl, envConfig.CaPath, envConfig.PrivateKeys)
+		if err != nil {
+			return nil, fmt.Errorf("node %s cannot split file %s: %s", node.Name, fileUrl, err.Error())
+		}
+		fileSizes[i] = fileStat.Size
+	}
+
+	intervals, err := node.FileReader.GetSplitIntervals(fileSizes)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %s", node.Name, err.Error())
+	}
+	logger.Info("node %s reads %d files in %d batches of up to %d bytes", node.Name, len(fileSizes), len(intervals), node.FileReader.Csv.SplitSizeBytes)
+	return intervals, nil
+}
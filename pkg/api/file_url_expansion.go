@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/gocqlshims"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/capillariesio/capillaries/pkg/wfdb"
+	"github.com/capillariesio/capillaries/pkg/wfmodel"
+	"github.com/capillariesio/capillaries/pkg/xfer"
+)
+
+// Expands url patterns used by file readers of affected nodes, returns node->urls map.
+// Only nodes that resolve their file lists at run start are included.
+func expandFileReaderUrls(logger *l.CapiLogger, script *sc.ScriptDef, affectedNodes []string, privateKeys map[string]string) (map[string][]string, error) {
+	logger.PushF("api.expandFileReaderUrls")
+	defer logger.PopF()
+
+	resolvedFileUrls := map[string][]string{}
+	for _, nodeName := range affectedNodes {
+		node, ok := script.ScriptNodes[nodeName]
+		if !ok || !node.HasFileReader() || !node.FileReader.IsResolvedAtRunStart() {
+			continue
+		}
+		urls := make([]string, 0, len(node.FileReader.SrcFileUrls))
+		seenUrls := map[string]struct{}{}
+		for _, fileUrl := range node.FileReader.SrcFileUrls {
+			expandedUrls := []string{fileUrl}
+			if xfer.IsUrlPattern(fileUrl) {
+				var err error
+				if expandedUrls, err = xfer.ExpandUrlPattern(fileUrl, privateKeys); err != nil {
+					return nil, fmt.Errorf("cannot expand file urls for node %s: %s", nodeName, err.Error())
+				}
+			}
+			// Overlapping patterns should not make us read the same file twice
+			for _, expandedUrl := range expandedUrls {
+				if _, ok := seenUrls[expandedUrl]; !ok {
+					seenUrls[expandedUrl] = struct{}{}
+					urls = append(urls, expandedUrl)
+				}
+			}
+		}
+		logger.Info("expanded file urls for node %s to %d files", nodeName, len(urls))
+		resolvedFileUrls[nodeName] = urls
+	}
+	return resolvedFileUrls, nil
+}
+
+// Used by StartRun and Toolbelt: expands url patterns and, for incremental readers, skips files ingested by earlier runs.
+// Returns node->urls map for nodes that resolve their file lists at run start.
+func ResolveFileReaderUrls(logger *l.CapiLogger, envConfig *env.EnvConfig, cqlSession gocqlshims.Session, keyspace string, script *sc.ScriptDef, affectedNodes []string) (map[string][]string, error) {
+	resolvedFileUrls, err := expandFileReaderUrls(logger, script, affectedNodes, envConfig.PrivateKeys)
+	if err != nil {
+		return nil, err
+	}
+	if err := skipIngestedFileUrls(logger, envConfig, cqlSession, keyspace, script, resolvedFileUrls); err != nil {
+		return nil, err
+	}
+	return resolvedFileUrls, nil
+}
+
+// Returns the node itself, or its copy that reads resolved files if the node resolves them at run start
+func NodeWithResolvedFileUrls(node *sc.ScriptNodeDef, resolvedFileUrls map[string][]string) *sc.ScriptNodeDef {
+	urls, ok := resolvedFileUrls[node.Name]
+	if !ok {
+		return node
+	}
+	return node.WithResolvedFileUrls(urls)
+}
+
+// Daemon side: use the list of files resolved at run start, so reruns read the same files
+func resolveCtxNodeFileUrls(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext) error {
+	logger.PushF("api.resolveCtxNodeFileUrls")
+	defer logger.PopF()
+
+	if !pCtx.CurrentScriptNode.HasFileReader() || !pCtx.CurrentScriptNode.FileReader.IsResolvedAtRunStart() {
+		return nil
+	}
+
+	runPropertiesFields := []string{"run_id", "resolved_file_urls"}
+	runPropsRow, err := wfdb.GetRunProperties(pCtx.CqlSession, pCtx.Msg.DataKeyspace, pCtx.Msg.RunId, runPropertiesFields)
+	if err != nil {
+		return err
+	}
+
+	runProps, err := wfmodel.NewRunPropertiesFromMap(runPropsRow, runPropertiesFields)
+	if err != nil {
+		return err
+	}
+
+	urls, err := runProps.GetResolvedFileUrls(pCtx.CurrentScriptNode.Name)
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		return errors.New("resolved file url list is empty")
+	}
+
+	pCtx.CurrentScriptNode = pCtx.CurrentScriptNode.WithResolvedFileUrls(urls)
+	return nil
+}
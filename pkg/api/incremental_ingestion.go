@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/gocqlshims"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/capillariesio/capillaries/pkg/wfdb"
+	"github.com/capillariesio/capillaries/pkg/xfer"
+)
+
+// For incremental file readers, leaves only files that are new or changed since they were ingested by earlier runs
+func skipIngestedFileUrls(logger *l.CapiLogger, envConfig *env.EnvConfig, cqlSession gocqlshims.Session, keyspace string, script *sc.ScriptDef, resolvedFileUrls map[string][]string) error {
+	logger.PushF("api.skipIngestedFileUrls")
+	defer logger.PopF()
+
+	incrementalNodes := make([]string, 0)
+	for nodeName := range resolvedFileUrls {
+		if script.ScriptNodes[nodeName].FileReader.Incremental {
+			incrementalNodes = append(incrementalNodes, nodeName)
+		}
+	}
+	if len(incrementalNodes) == 0 {
+		return nil
+	}
+
+	ledger, err := wfdb.GetFileLedger(cqlSession, keyspace, incrementalNodes)
+	if err != nil {
+		return err
+	}
+
+	for _, nodeName := range incrementalNodes {
+		newUrls := make([]string, 0, len(resolvedFileUrls[nodeName]))
+		for _, fileUrl := range resolvedFileUrls[nodeName] {
+			fileStat, err := statIncrementalFile(envConfig, fileUrl)
+			if err != nil {
+				return fmt.Errorf("cannot check if node %s has ingested %s: %s", nodeName, fileUrl, err.Error())
+			}
+			if ledger.IsNewOrChanged(nodeName, fileUrl, fileStat.Size, fileStat.Version) {
+				newUrls = append(newUrls, fileUrl)
+			}
+		}
+		logger.Info("incremental node %s: %d new or changed files out of %d", nodeName, len(newUrls), len(resolvedFileUrls[nodeName]))
+		// No files - no batches, StartRun marks such node complete right away
+		resolvedFileUrls[nodeName] = newUrls
+	}
+	return nil
+}
+
+// Daemon side: remember what the source file looked like before reading it,
+// so the ledger records the version that was actually ingested. Returns nil for non-incremental nodes.
+func statIncrementalSrcFile(envConfig *env.EnvConfig, pCtx *ctx.MessageProcessingContext) (*xfer.FileStat, error) {
+	node := pCtx.CurrentScriptNode
+	if !node.HasFileReader() || !node.FileReader.Incremental {
+		return nil, nil
+	}
+	srcFileIdx := int(pCtx.Msg.FirstToken)
+	if srcFileIdx < 0 || srcFileIdx >= len(node.FileReader.SrcFileUrls) {
+		return nil, fmt.Errorf("cannot find src file with index %d while there are only %d source files available", srcFileIdx, len(node.FileReader.SrcFileUrls))
+	}
+	return statIncrementalFile(envConfig, node.FileReader.SrcFileUrls[srcFileIdx])
+}
+
+// Without a version, we cannot tell a changed file from an ingested one
+func statIncrementalFile(envConfig *env.EnvConfig, fileUrl string) (*xfer.FileStat, error) {
+	fileStat, err := xfer.StatFile(fileUrl, envConfig.CaPath, envConfig.PrivateKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(fileStat.Version) == 0 {
+		return nil, fmt.Errorf("cannot get version of %s: server returned neither ETag nor Last-Modified", fileUrl)
+	}
+	return fileStat, nil
+}
+
+func writeIncrementalSrcFileLedgerEntry(pCtx *ctx.MessageProcessingContext, srcFileStat *xfer.FileStat) error {
+	if srcFileStat == nil {
+		return nil
+	}
+	fileUrl := pCtx.CurrentScriptNode.FileReader.SrcFileUrls[int(pCtx.Msg.FirstToken)]
+	return wfdb.WriteFileLedgerEntry(pCtx.CqlSession, &pCtx.Msg, fileUrl, srcFileStat.Size, srcFileStat.Version)
+}
@@ -23,8 +23,21 @@ func checkDependencyNodesReady(logger *l.CapiLogger, pCtx *ctx.MessageProcessing
 	logger.PushF("api.checkDependencyNodesReady")
 	defer logger.PopF()
 
+	// Union reader batches read from different source tables, so readiness (and source run id) is tracked per source table
+	unionTableReaderIdx := -1
+	if pCtx.CurrentScriptNode.HasUnionTableReader() {
+		var err error
+		unionTableReaderIdx, err = pCtx.CurrentScriptNode.UnionReader.GetTableReaderIdxByBatchIdx(int(pCtx.Msg.BatchIdx))
+		if err != nil {
+			return sc.NodeNone, 0, 0, -1, -1, err
+		}
+	}
+
 	// Before reading state db, check our cache
 	nodeDependencyReadynessCacheKey := pCtx.Msg.FullNodeId()
+	if unionTableReaderIdx >= 0 {
+		nodeDependencyReadynessCacheKey = fmt.Sprintf("%s/%d", nodeDependencyReadynessCacheKey, unionTableReaderIdx)
+	}
 	if NodeDependencyReadynessCache != nil {
 		cachedState, ok := NodeDependencyReadynessCache.Get(nodeDependencyReadynessCacheKey)
 		if ok {
@@ -51,35 +64,43 @@ func checkDependencyNodesReady(logger *l.CapiLogger, pCtx *ctx.MessageProcessing
 		NodeDependencyReadynessMissCounter.Inc()
 	}
 
-	depNodeNames := make([]string, 2)
-	depNodeCount := 0
+	depNodeNames := make([]string, 0, 2)
+	readerDepNodeIdx := 0
 	if pCtx.CurrentScriptNode.HasTableReader() {
 		tableToReadFrom := pCtx.CurrentScriptNode.TableReader.TableName
 		nodeToReadFrom, ok := pCtx.Script.TableCreatorNodeMap[tableToReadFrom]
 		if !ok {
 			return sc.NodeNone, 0, 0, -1, -1, fmt.Errorf("cannot find the node that creates reader table [%s]", tableToReadFrom)
 		}
-		depNodeNames[depNodeCount] = nodeToReadFrom.Name
-		depNodeCount++
+		depNodeNames = append(depNodeNames, nodeToReadFrom.Name)
+	} else if pCtx.CurrentScriptNode.HasUnionTableReader() {
+		// Wait for all source nodes, but use the run id of the one this batch reads from
+		for _, tableReader := range pCtx.CurrentScriptNode.UnionReader.Tables {
+			nodeToReadFrom, ok := pCtx.Script.TableCreatorNodeMap[tableReader.TableName]
+			if !ok {
+				return sc.NodeNone, 0, 0, -1, -1, fmt.Errorf("cannot find the node that creates union reader table [%s]", tableReader.TableName)
+			}
+			depNodeNames = append(depNodeNames, nodeToReadFrom.Name)
+		}
+		readerDepNodeIdx = unionTableReaderIdx
 	}
+	lookupDepNodeIdx := -1
 	if pCtx.CurrentScriptNode.HasLookup() {
 		tableToReadFrom := pCtx.CurrentScriptNode.Lookup.TableCreator.Name
 		nodeToReadFrom, ok := pCtx.Script.TableCreatorNodeMap[tableToReadFrom]
 		if !ok {
 			return sc.NodeNone, 0, 0, -1, -1, fmt.Errorf("cannot find the node that creates lookup table [%s]", tableToReadFrom)
 		}
-		depNodeNames[depNodeCount] = nodeToReadFrom.Name
-		depNodeCount++
+		lookupDepNodeIdx = len(depNodeNames)
+		depNodeNames = append(depNodeNames, nodeToReadFrom.Name)
 	}
 
-	if depNodeCount == 0 {
+	if len(depNodeNames) == 0 {
 		return sc.NodeGo, 0, 0, -1, -1, nil
 	}
 
 	startTime := time.Now()
 
-	depNodeNames = depNodeNames[:depNodeCount]
-
 	// { nodeReader: [{run1, RunComplete, NodeSuccess}], nodeLookup: [{run2, RunStopped, NodeSuccess}, {run3, RunComplete, NodeSuccess}]
 	nodeRunStatusMap, err := wfdb.BuildDependencyNodeRunStatusMap(logger, pCtx, depNodeNames)
 	if err != nil {
@@ -102,24 +123,25 @@ func checkDependencyNodesReady(logger *l.CapiLogger, pCtx *ctx.MessageProcessing
 		logger.DebugCtx(pCtx, "target node %s, dep node %s returned %s, matched rule %d", pCtx.Msg.TargetNodeName, depNodeName, dependencyNodeCmds[nodeIdx], matchedRuleIndexes[nodeIdx])
 	}
 
-	// depNodeNames can have size 1 or 2. If 2, we are guaranteed that [0] is the reader, and [1] is the lookup,
+	// depNodeNames contains the reader (or all union readers) first, and the lookup last,
 	// see pCtx.CurrentScriptNode.HasTableReader() and pCtx.CurrentScriptNode.HasLookup() above
-	finalCmd := dependencyNodeCmds[0]
-	finalRunIdReader := dependencyRunIds[0]
-	finalRunIdLookup := int16(0)
-	matchedRuleIdxReader := matchedRuleIndexes[0]
-	matchedRuleIdxLookup := -1
-	if len(dependencyNodeCmds) == 2 {
-		finalRunIdLookup = dependencyRunIds[1]
-		matchedRuleIdxLookup = matchedRuleIndexes[1]
-		if dependencyNodeCmds[0] == sc.NodeNogo || dependencyNodeCmds[1] == sc.NodeNogo {
+	finalCmd := sc.NodeGo
+	for _, depNodeCmd := range dependencyNodeCmds {
+		if depNodeCmd == sc.NodeNogo {
 			finalCmd = sc.NodeNogo
-		} else if dependencyNodeCmds[0] == sc.NodeWait || dependencyNodeCmds[1] == sc.NodeWait {
+			break
+		} else if depNodeCmd == sc.NodeWait {
 			finalCmd = sc.NodeWait
-		} else {
-			finalCmd = sc.NodeGo
 		}
 	}
+	finalRunIdReader := dependencyRunIds[readerDepNodeIdx]
+	finalRunIdLookup := int16(0)
+	matchedRuleIdxReader := matchedRuleIndexes[readerDepNodeIdx]
+	matchedRuleIdxLookup := -1
+	if lookupDepNodeIdx >= 0 {
+		finalRunIdLookup = dependencyRunIds[lookupDepNodeIdx]
+		matchedRuleIdxLookup = matchedRuleIndexes[lookupDepNodeIdx]
+	}
 
 	if finalCmd == sc.NodeNogo || finalCmd == sc.NodeGo {
 		logger.InfoCtx(pCtx, "checked all dependency nodes for %s, commands are %v, run ids are %v, finalCmd is %s", pCtx.Msg.TargetNodeName, dependencyNodeCmds, dependencyRunIds, finalCmd)
@@ -137,7 +159,50 @@ func checkDependencyNodesReady(logger *l.CapiLogger, pCtx *ctx.MessageProcessing
 	return finalCmd, finalRunIdReader, finalRunIdLookup, matchedRuleIdxReader, matchedRuleIdxLookup, nil
 }
 
-func updateNodeStatusFromBatches(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext) (wfmodel.NodeBatchStatusType, error) {
+// Node success may be reported again by a late or redelivered message after the node was finalized
+func isNodeMarkedSuccessful(pCtx *ctx.MessageProcessingContext) (bool, error) {
+	rows, err := wfdb.GetNodeHistoryForRuns(pCtx.CqlSession, pCtx.Msg.DataKeyspace, []int16{pCtx.Msg.RunId}, []string{pCtx.Msg.TargetNodeName})
+	if err != nil {
+		return false, err
+	}
+	events, err := wfmodel.NodeHistoryRowsToEvents(rows)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range events {
+		if e.Status == wfmodel.NodeBatchSuccess {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Returns false if another batch holds the finalize claim: that batch merges the files and sets node status,
+// the caller must leave node status alone
+func finalizeNode(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext) (bool, error) {
+	if !pCtx.CurrentScriptNode.HasFileCreator() || !pCtx.CurrentScriptNode.FileCreator.HasFinalize() {
+		return true, nil
+	}
+	alreadyMarked, err := isNodeMarkedSuccessful(pCtx)
+	if err != nil {
+		return false, err
+	}
+	if alreadyMarked {
+		logger.InfoCtx(pCtx, "node %d/%s already marked successful, not finalizing again", pCtx.Msg.RunId, pCtx.Msg.TargetNodeName)
+		return true, nil
+	}
+	isClaimed, err := wfdb.ClaimNodeFinalize(pCtx.CqlSession, &pCtx.Msg)
+	if err != nil {
+		return false, err
+	}
+	if !isClaimed {
+		logger.InfoCtx(pCtx, "node %d/%s is finalized by another batch, not finalizing", pCtx.Msg.RunId, pCtx.Msg.TargetNodeName)
+		return false, nil
+	}
+	return true, proc.FinalizeFileCreator(envConfig, logger, pCtx)
+}
+
+func updateNodeStatusFromBatches(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext) (wfmodel.NodeBatchStatusType, error) {
 	logger.PushF("wf.updateNodeStatusFromBatches")
 	defer logger.PopF()
 
@@ -168,6 +233,21 @@ func updateNodeStatusFromBatches(logger *l.CapiLogger, pCtx *ctx.MessageProcessi
 			return wfmodel.NodeBatchNone, fmt.Errorf("unexpected totalNodeStatus %v by batch %d /%d", totalNodeStatus, pCtx.Msg.BatchIdx, pCtx.Msg.BatchesTotal)
 		}
 
+		if totalNodeStatus == wfmodel.NodeBatchSuccess {
+			isFinalizer, err := finalizeNode(envConfig, logger, pCtx)
+			if err != nil {
+				if db.IsDbConnError(err) {
+					return wfmodel.NodeBatchNone, err
+				}
+				logger.ErrorCtx(pCtx, "node %d/%s finalize failed: %s", pCtx.Msg.RunId, pCtx.Msg.TargetNodeName, err.Error())
+				totalNodeStatus = wfmodel.NodeBatchFail
+				comment = fmt.Sprintf("marked failed by batch %d / %d - all batches ok, finalize failed: %s", pCtx.Msg.BatchIdx, pCtx.Msg.BatchesTotal, err.Error())
+			} else if !isFinalizer {
+				// The claim holder marks the node when the merge is over
+				return wfmodel.NodeBatchNone, nil
+			}
+		}
+
 		err := wfdb.SetNodeStatus(pCtx.CqlSession, &pCtx.Msg, totalNodeStatus, comment)
 		if err != nil {
 			return wfmodel.NodeBatchNone, err
@@ -219,11 +299,11 @@ func updateRunStatusFromNodes(logger *l.CapiLogger, pCtx *ctx.MessageProcessingC
 	return nil
 }
 
-func refreshNodeAndRunStatus(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext) error {
+func refreshNodeAndRunStatus(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext) error {
 	logger.PushF("wf.refreshNodeAndRunStatus")
 	defer logger.PopF()
 
-	_, err := updateNodeStatusFromBatches(logger, pCtx)
+	_, err := updateNodeStatusFromBatches(envConfig, logger, pCtx)
 	if err != nil {
 		logger.ErrorCtx(pCtx, "cannot refresh run/node status: %s", err.Error())
 		return err
@@ -272,7 +352,7 @@ const (
 	FurtherProcessingRetry
 )
 
-func checkRunStatus(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, msg *wfmodel.Message, runStatus wfmodel.RunStatusType) FurtherProcessingCmd {
+func checkRunStatus(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, msg *wfmodel.Message, runStatus wfmodel.RunStatusType) FurtherProcessingCmd {
 	switch runStatus {
 	case wfmodel.RunNone:
 		comment := fmt.Sprintf("run history status for batch %s is empty, looks like this run %d was never started, will ack with error", msg.FullBatchId(), pCtx.Msg.RunId)
@@ -280,7 +360,7 @@ func checkRunStatus(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, ms
 		if err := wfdb.SetBatchStatus(logger, pCtx, wfmodel.NodeBatchFail, comment); err != nil && db.IsDbConnError(err) {
 			return FurtherProcessingRetry
 		}
-		if err := refreshNodeAndRunStatus(logger, pCtx); err != nil && db.IsDbConnError(err) {
+		if err := refreshNodeAndRunStatus(envConfig, logger, pCtx); err != nil && db.IsDbConnError(err) {
 			return FurtherProcessingRetry
 		}
 		// Unexpected non-db error, embrace the problem, do not retry
@@ -296,7 +376,7 @@ func checkRunStatus(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, ms
 			// Unexpected non-db error, embrace the problem, do not retry
 			return FurtherProcessingAck
 		}
-		if err := refreshNodeAndRunStatus(logger, pCtx); err != nil {
+		if err := refreshNodeAndRunStatus(envConfig, logger, pCtx); err != nil {
 			logger.ErrorCtx(pCtx, "%s, cannot refresh status: %s", comment, err.Error())
 			if db.IsDbConnError(err) {
 				return FurtherProcessingRetry
@@ -318,11 +398,11 @@ func checkRunStatus(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, ms
 	}
 }
 
-func checkLastBatchStatus(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, msg *wfmodel.Message, lastBatchStatus wfmodel.NodeBatchStatusType, lastBatchTs time.Time) FurtherProcessingCmd {
+func checkLastBatchStatus(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, msg *wfmodel.Message, lastBatchStatus wfmodel.NodeBatchStatusType, lastBatchTs time.Time) FurtherProcessingCmd {
 	switch lastBatchStatus {
 	case wfmodel.NodeBatchFail, wfmodel.NodeBatchSuccess:
 		logger.WarnCtx(pCtx, "will not process batch %s, it has been already processed (processor crashed after processing it and before marking as success/fail?) with status %d(%s)", msg.FullBatchId(), lastBatchStatus, wfmodel.NodeBatchStatusToString(lastBatchStatus))
-		if err := refreshNodeAndRunStatus(logger, pCtx); err != nil && db.IsDbConnError(err) {
+		if err := refreshNodeAndRunStatus(envConfig, logger, pCtx); err != nil && db.IsDbConnError(err) {
 			return FurtherProcessingRetry
 		}
 		return FurtherProcessingAck
@@ -377,7 +457,7 @@ func checkLastBatchStatus(logger *l.CapiLogger, pCtx *ctx.MessageProcessingConte
 	}
 }
 
-func checkDependencyNogoOrWait(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, nodeReady sc.ReadyToRunNodeCmdType, matchedRuleIdxReader int, matchedRuleIdxLookup int) FurtherProcessingCmd {
+func checkDependencyNogoOrWait(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, nodeReady sc.ReadyToRunNodeCmdType, matchedRuleIdxReader int, matchedRuleIdxLookup int) FurtherProcessingCmd {
 	switch nodeReady {
 	case sc.NodeNogo:
 		comment := fmt.Sprintf("nogo, rules (%d,%d), some dependency nodes for %s are in bad state, or runs executing dependency nodes were stopped/invalidated, will not run this node; for details, check rules in dependency_policies and previous runs history", matchedRuleIdxReader, matchedRuleIdxLookup, pCtx.Msg.FullBatchId())
@@ -388,7 +468,7 @@ func checkDependencyNogoOrWait(logger *l.CapiLogger, pCtx *ctx.MessageProcessing
 			}
 			return FurtherProcessingAck
 		}
-		if err := refreshNodeAndRunStatus(logger, pCtx); err != nil && db.IsDbConnError(err) {
+		if err := refreshNodeAndRunStatus(envConfig, logger, pCtx); err != nil && db.IsDbConnError(err) {
 			return FurtherProcessingRetry
 		}
 		return FurtherProcessingAck
@@ -444,7 +524,7 @@ func ProcessDataBatchMsg(envConfig *env.EnvConfig, logger *l.CapiLogger, msg *wf
 	}
 
 	// Check current run is valid
-	furtherProcCmd := checkRunStatus(logger, pCtx, msg, runStatus)
+	furtherProcCmd := checkRunStatus(envConfig, logger, pCtx, msg, runStatus)
 	switch furtherProcCmd {
 	case FurtherProcessingRetry:
 		return mq.AcknowledgerCmdRetry
@@ -468,6 +548,15 @@ func ProcessDataBatchMsg(envConfig *env.EnvConfig, logger *l.CapiLogger, msg *wf
 		return mq.AcknowledgerCmdAck
 	}
 
+	// File reader url patterns were expanded at run start
+	if err := resolveCtxNodeFileUrls(logger, pCtx); err != nil {
+		logger.ErrorCtx(pCtx, "cannot resolve file urls for batch %s: %s", msg.FullBatchId(), err.Error())
+		if db.IsDbConnError(err) {
+			return mq.AcknowledgerCmdRetry
+		}
+		return mq.AcknowledgerCmdAck
+	}
+
 	logger.DebugCtx(pCtx, "started processing batch %s", msg.FullBatchId())
 
 	fields := []string{"ts", "status"}
@@ -485,7 +574,7 @@ func ProcessDataBatchMsg(envConfig *env.EnvConfig, logger *l.CapiLogger, msg *wf
 	}
 
 	// Check if this run/node/batch has been handled already
-	furtherProcCmd = checkLastBatchStatus(logger, pCtx, msg, lastBatchStatus, lastBatchTs)
+	furtherProcCmd = checkLastBatchStatus(envConfig, logger, pCtx, msg, lastBatchStatus, lastBatchTs)
 	switch furtherProcCmd {
 	case FurtherProcessingRetry:
 		return mq.AcknowledgerCmdRetry
@@ -518,7 +607,7 @@ func ProcessDataBatchMsg(envConfig *env.EnvConfig, logger *l.CapiLogger, msg *wf
 		return mq.AcknowledgerCmdAck
 	}
 
-	furtherProcCmd = checkDependencyNogoOrWait(logger, pCtx, nodeReady, matchedRuleIdxReader, matchedRuleIdxLookup)
+	furtherProcCmd = checkDependencyNogoOrWait(envConfig, logger, pCtx, nodeReady, matchedRuleIdxReader, matchedRuleIdxLookup)
 	switch furtherProcCmd {
 	case FurtherProcessingRetry:
 		return mq.AcknowledgerCmdRetry
@@ -547,7 +636,12 @@ func ProcessDataBatchMsg(envConfig *env.EnvConfig, logger *l.CapiLogger, msg *wf
 		return mq.AcknowledgerCmdAck
 	}
 
-	batchStatus, batchStats, batchErr := proc.CallAppropriateProcessorForBatch(envConfig, logger, pCtx, readerNodeRunId, lookupNodeRunId)
+	var batchStatus wfmodel.NodeBatchStatusType
+	var batchStats proc.BatchStats
+	srcFileStat, batchErr := statIncrementalSrcFile(envConfig, pCtx)
+	if batchErr == nil {
+		batchStatus, batchStats, batchErr = proc.CallAppropriateProcessorForBatch(envConfig, logger, pCtx, readerNodeRunId, lookupNodeRunId)
+	}
 
 	// TODO: test only!!!
 	// if pCtx.BatchInfo.TargetNodeName == "order_item_date_inner" && pCtx.BatchInfo.BatchIdx == 3 {
@@ -573,6 +667,14 @@ func ProcessDataBatchMsg(envConfig *env.EnvConfig, logger *l.CapiLogger, msg *wf
 		// Here: batch was processed with some non-db error
 	} else {
 		logger.InfoCtx(pCtx, "safeProcessBatch: success")
+		// Before marking the batch complete: if this fails, the batch is retried and the file is ingested again
+		if err := writeIncrementalSrcFileLedgerEntry(pCtx, srcFileStat); err != nil {
+			logger.ErrorCtx(pCtx, "safeProcessBatch: %s", err.Error())
+			if db.IsDbConnError(err) {
+				return mq.AcknowledgerCmdRetry
+			}
+			return mq.AcknowledgerCmdAck
+		}
 		if err := wfdb.SetBatchStatus(logger, pCtx, batchStatus, batchStats.ToString()); err != nil {
 			if db.IsDbConnError(err) {
 				return mq.AcknowledgerCmdRetry
@@ -581,7 +683,7 @@ func ProcessDataBatchMsg(envConfig *env.EnvConfig, logger *l.CapiLogger, msg *wf
 		}
 	}
 
-	if err := refreshNodeAndRunStatus(logger, pCtx); err != nil {
+	if err := refreshNodeAndRunStatus(envConfig, logger, pCtx); err != nil {
 		if db.IsDbConnError(err) {
 			return mq.AcknowledgerCmdRetry
 		}
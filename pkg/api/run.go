@@ -67,9 +67,15 @@ func StartRun(envConfig *env.EnvConfig, logger *l.CapiLogger, mqSender mq.MqProd
 	}
 	logger.Info("incremented run_id to %d", runId)
 
-	// Write affected nodes
+	// Expand file reader url patterns and skip ingested files once, all batches and reruns of this run will read the same files
 	affectedNodes := script.GetAffectedNodes(startNodes)
-	if err := wfdb.WriteRunProperties(cqlSession, keyspace, runId, startNodes, affectedNodes, scriptFilePath, paramsFilePath, desc); err != nil {
+	resolvedFileUrls, err := ResolveFileReaderUrls(logger, envConfig, cqlSession, keyspace, script, affectedNodes)
+	if err != nil {
+		return 0, err
+	}
+
+	// Write affected nodes
+	if err := wfdb.WriteRunProperties(cqlSession, keyspace, runId, startNodes, affectedNodes, scriptFilePath, paramsFilePath, desc, resolvedFileUrls); err != nil {
 		return 0, err
 	}
 
@@ -108,15 +114,21 @@ func StartRun(envConfig *env.EnvConfig, logger *l.CapiLogger, mqSender mq.MqProd
 	logger.Info("created %d tables [%s] in %.2fs, creating messages to send for run %d...", len(tableNames), strings.Join(tableNames, ","), time.Since(createTablesStartTime).Seconds(), runId)
 
 	allMsgs := make([]*wfmodel.Message, 0)
+	noBatchNodes := make([]string, 0)
 	for _, affectedNodeName := range affectedNodes {
 		affectedNode, ok := script.ScriptNodes[affectedNodeName]
 		if !ok {
 			return 0, fmt.Errorf("cannot find node to start with: %s in the script %s", affectedNodeName, scriptFilePath)
 		}
-		intervals, err := affectedNode.GetTokenIntervalsByNumberOfBatches()
+		intervals, err := GetNodeBatchIntervals(logger, envConfig, affectedNode, resolvedFileUrls)
 		if err != nil {
 			return 0, err
 		}
+		if len(intervals) == 0 {
+			// Incremental file reader without new or changed files
+			noBatchNodes = append(noBatchNodes, affectedNodeName)
+			continue
+		}
 		msgs := make([]*wfmodel.Message, len(intervals))
 		for msgIdx := 0; msgIdx < len(intervals); msgIdx++ {
 			msgs[msgIdx] = &wfmodel.Message{
@@ -139,6 +151,21 @@ func StartRun(envConfig *env.EnvConfig, logger *l.CapiLogger, mqSender mq.MqProd
 		return 0, err
 	}
 
+	// Nobody will ever process a batch of these nodes, mark them complete so dependency policies see them as done
+	for _, nodeName := range noBatchNodes {
+		nodeMsg := &wfmodel.Message{DataKeyspace: keyspace, RunId: runId, TargetNodeName: nodeName}
+		if err := wfdb.SetNodeStatus(cqlSession, nodeMsg, wfmodel.NodeBatchSuccess, "no new or changed files to ingest"); err != nil {
+			return 0, err
+		}
+		logger.Info("node %s has no batches to run, marked complete", nodeName)
+	}
+	if len(allMsgs) == 0 {
+		if err := wfdb.SetRunStatus(cqlSession, keyspace, runId, wfmodel.RunComplete, "api.StartRun: no batches to run"); err != nil {
+			return 0, err
+		}
+		return runId, nil
+	}
+
 	logger.Info("sending %d messages for run %d...", len(allMsgs), runId)
 	sendMsgStartTime := time.Now()
 
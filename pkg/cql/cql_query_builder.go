@@ -49,6 +49,8 @@ Helper used in query builder
 */
 func valueToString(value any, quotePolicy QuotePolicyType) string {
 	switch v := value.(type) {
+	case nil:
+		return "null"
 	case string:
 		if quotePolicy == ForceUnquote {
 			return strings.ReplaceAll(v, "'", "''")
@@ -67,14 +69,18 @@ func valueToString(value any, quotePolicy QuotePolicyType) string {
 func valueToCqlParam(value any) any {
 	switch v := value.(type) {
 	case decimal.Decimal:
-		f, _ := v.Float64()
-		scaled := int64(math.Round(f * 100))
-		return inf.NewDec(scaled, 2)
+		return DecimalToCqlParam(v, 2) // decimal2, callers convert decimal(p,s) values themselves
 	default:
 		return v
 	}
 }
 
+// Set it to Cassandra-accepted value, not decimal.Decimal: https://github.com/gocql/gocql/issues/1578
+func DecimalToCqlParam(value decimal.Decimal, scale int32) *inf.Dec {
+	rounded := value.Round(scale)
+	return inf.NewDecBig(rounded.Coefficient(), inf.Scale(-rounded.Exponent()))
+}
+
 type queryBuilderColumnDefs struct {
 	Columns [256]string
 	Types   [256]string
@@ -83,7 +89,7 @@ type queryBuilderColumnDefs struct {
 
 func (cd *queryBuilderColumnDefs) add(column string, fieldType evalcapi.TableFieldType) {
 	cd.Columns[cd.Len] = column
-	switch fieldType {
+	switch evalcapi.FieldTypeKind(fieldType) {
 	case evalcapi.FieldTypeInt:
 		cd.Types[cd.Len] = "BIGINT" // 64-bit int
 	case evalcapi.FieldTypeDecimal2:
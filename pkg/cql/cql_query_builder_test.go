@@ -19,6 +19,14 @@ func TestValueToCqlParam(t *testing.T) {
 
 	// small round down
 	assert.Equal(t, "0.03", valueToCqlParam(decimal.NewFromFloat(0.0345)).(*inf.Dec).String())
+
+	// null
+	assert.Nil(t, valueToCqlParam(nil))
+
+	// decimal(p,s) keeps its scale, even for whole numbers
+	assert.Equal(t, "1.500000", DecimalToCqlParam(decimal.NewFromFloat(1.5), 6).String())
+	assert.Equal(t, "-0.1234568", DecimalToCqlParam(decimal.RequireFromString("-0.12345678"), 7).String())
+	assert.Equal(t, "12345678901234567890123.45", DecimalToCqlParam(decimal.RequireFromString("12345678901234567890123.454"), 2).String())
 }
 
 func TestInsertRunParams(t *testing.T) {
@@ -42,6 +50,13 @@ func TestInsert(t *testing.T) {
 	assert.Equal(t, fmt.Sprintf(qTemplate, "_00123"), qb.insertRunUnpreparedQuery("table1", 123, IfNotExistsLwt))
 }
 
+func TestInsertNull(t *testing.T) {
+	qb := (&QueryBuilder{}).
+		Write("col1", "val1").
+		Write("col2", nil)
+	assert.Equal(t, "INSERT INTO table1_00123 ( col1, col2 ) VALUES ( 'val1', null ) IF NOT EXISTS;", qb.insertRunUnpreparedQuery("table1", 123, IfNotExistsLwt))
+}
+
 func TestDropKeyspace(t *testing.T) {
 	assert.Equal(t, "DROP KEYSPACE IF EXISTS aaa", (&QueryBuilder{}).Keyspace("aaa").DropKeyspace())
 }
@@ -85,13 +100,14 @@ func TestUpdate(t *testing.T) {
 }
 
 func TestCreateRun(t *testing.T) {
-	const qTemplate string = "CREATE TABLE IF NOT EXISTS table1%s ( col_int BIGINT, col_bool BOOLEAN, col_string TEXT, col_datetime TIMESTAMP, col_decimal2 DECIMAL, col_float DOUBLE, PRIMARY KEY((col_int, col_decimal2), col_bool, col_float)) WITH PROPERTIES BLA;"
+	const qTemplate string = "CREATE TABLE IF NOT EXISTS table1%s ( col_int BIGINT, col_bool BOOLEAN, col_string TEXT, col_datetime TIMESTAMP, col_decimal2 DECIMAL, col_decimal DECIMAL, col_float DOUBLE, PRIMARY KEY((col_int, col_decimal2), col_bool, col_float)) WITH PROPERTIES BLA;"
 	qb := (&QueryBuilder{}).
 		ColumnDef("col_int", evalcapi.FieldTypeInt).
 		ColumnDef("col_bool", evalcapi.FieldTypeBool).
 		ColumnDef("col_string", evalcapi.FieldTypeString).
 		ColumnDef("col_datetime", evalcapi.FieldTypeDateTime).
 		ColumnDef("col_decimal2", evalcapi.FieldTypeDecimal2).
+		ColumnDef("col_decimal", evalcapi.DecimalFieldType(18, 6)).
 		ColumnDef("col_float", evalcapi.FieldTypeFloat).
 		PartitionKey("col_int", "col_decimal2").
 		ClusteringKey("col_bool", "col_float")
@@ -0,0 +1,4 @@
+/*
+Package pivot contains definition of the custom processor pivot
+*/
+package pivot
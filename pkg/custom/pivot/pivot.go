@@ -0,0 +1,336 @@
+package pivot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/capillariesio/capillaries/pkg/proc"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/shopspring/decimal"
+)
+
+const ProcessorPivotName string = "pivot"
+
+// Pivot column: aggregate expression evaluated over rows that have this key value
+type PivotColumnDef struct {
+	KeyValue         string                  `json:"key_value"`
+	RawExpression    string                  `json:"expression"`
+	Type             evalcapi.TableFieldType `json:"type"`
+	ParsedExpression ast.Expr
+	AggFuncType      eval.AggFuncType
+	AggFuncArgs      []ast.Expr
+	KeyString        string // KeyValue formatted by key_type, compared against formatted key values
+}
+
+// All processor settings, stored in Capillaries script, root values coming from node
+type PivotProcessorDef struct {
+	IndexName           string                     `json:"index_name"` // Non-unique index of the source table, rows with the same key are pivoted together
+	RawKey              string                     `json:"key"`
+	KeyType             evalcapi.TableFieldType    `json:"key_type"` // Default string
+	RawGroupBy          []string                   `json:"group_by"`
+	Columns             map[string]*PivotColumnDef `json:"columns"`
+	ParsedKey           ast.Expr
+	ParsedGroupBy       []ast.Expr
+	UsedInColumnsFields sc.FieldRefs
+}
+
+func (procDef *PivotProcessorDef) GetFieldRefs() *sc.FieldRefs {
+	fieldRefs := make(sc.FieldRefs, 0, len(procDef.Columns))
+	for colName, colDef := range procDef.Columns {
+		fieldRefs = append(fieldRefs, sc.FieldRef{
+			TableName: sc.CustomProcessorAlias,
+			FieldName: colName,
+			FieldType: colDef.Type})
+	}
+	return &fieldRefs
+}
+
+func (procDef *PivotProcessorDef) GetUsedInTargetExpressionsFields() *sc.FieldRefs {
+	return &procDef.UsedInColumnsFields
+}
+
+func (procDef *PivotProcessorDef) Deserialize(raw json.RawMessage, _ json.RawMessage, scriptType sc.ScriptType, _ string, _ map[string]string) error {
+	var err error
+	switch scriptType {
+	case sc.ScriptJson:
+		if err = json.Unmarshal(raw, procDef); err != nil {
+			return fmt.Errorf("cannot unmarshal pivot processor def json: %s", err.Error())
+		}
+	case sc.ScriptYaml:
+		if err = json.Unmarshal(raw, procDef); err != nil {
+			return fmt.Errorf("cannot unmarshal pivot processor def yaml: %s", err.Error())
+		}
+	default:
+		return errors.New("cannot unmarshal pivot processor def: json or yaml expected")
+	}
+
+	foundErrors := make([]string, 0)
+
+	if len(procDef.IndexName) == 0 {
+		return errors.New("pivot index_name cannot be empty")
+	}
+
+	if len(strings.TrimSpace(procDef.RawKey)) == 0 {
+		return errors.New("pivot key expression cannot be empty")
+	}
+	if procDef.ParsedKey, err = sc.ParseRawGolangExpressionStringAndHarvestFieldRefs(procDef.RawKey, &procDef.UsedInColumnsFields); err != nil {
+		foundErrors = append(foundErrors, fmt.Sprintf("cannot parse pivot key expression [%s]: [%s]", procDef.RawKey, err.Error()))
+	}
+	if len(procDef.KeyType) == 0 {
+		procDef.KeyType = evalcapi.FieldTypeString
+	} else if !evalcapi.IsValidFieldType(procDef.KeyType) {
+		return fmt.Errorf("invalid pivot key_type [%s]", procDef.KeyType)
+	}
+
+	if len(procDef.RawGroupBy) == 0 {
+		return errors.New("pivot group_by cannot be empty")
+	}
+	procDef.ParsedGroupBy = make([]ast.Expr, len(procDef.RawGroupBy))
+	for i, rawExp := range procDef.RawGroupBy {
+		if procDef.ParsedGroupBy[i], err = sc.ParseRawGolangExpressionStringAndHarvestFieldRefs(rawExp, &procDef.UsedInColumnsFields); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot parse pivot group_by expression [%s]: [%s]", rawExp, err.Error()))
+			continue
+		}
+		v := sc.AggFinderVisitor{}
+		ast.Walk(&v, procDef.ParsedGroupBy[i])
+		if v.Error != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot use agg functions in pivot group_by expression [%s]: [%s]", rawExp, v.Error.Error()))
+		}
+	}
+
+	if len(procDef.Columns) == 0 {
+		return errors.New("pivot columns cannot be empty")
+	}
+	for colName, colDef := range procDef.Columns {
+		if !evalcapi.IsValidFieldType(colDef.Type) {
+			foundErrors = append(foundErrors, fmt.Sprintf("invalid pivot column %s type [%s]", colName, colDef.Type))
+		}
+		if len(colDef.KeyValue) == 0 {
+			foundErrors = append(foundErrors, fmt.Sprintf("pivot column %s key_value cannot be empty", colName))
+		} else if colDef.KeyString, err = parseAndFormatKeyValue(colDef.KeyValue, procDef.KeyType); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot read pivot column %s key_value [%s] as %s: [%s]", colName, colDef.KeyValue, procDef.KeyType, err.Error()))
+		}
+		if colDef.ParsedExpression, err = sc.ParseRawGolangExpressionStringAndHarvestFieldRefs(colDef.RawExpression, &procDef.UsedInColumnsFields); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot parse pivot column %s expression [%s]: [%s]", colName, colDef.RawExpression, err.Error()))
+			continue
+		}
+		var aggEnabled eval.AggEnabledType
+		aggEnabled, colDef.AggFuncType, colDef.AggFuncArgs = eval.DetectRootAggFunc(colDef.ParsedExpression)
+		if aggEnabled != eval.AggFuncEnabled {
+			foundErrors = append(foundErrors, fmt.Sprintf("pivot column %s expression [%s] must be an aggregate function call", colName, colDef.RawExpression))
+		}
+	}
+
+	// Later on, checkFieldUsageInCustomProcessor() will verify all fields from procDef.UsedInColumnsFields are valid reader fields
+
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
+
+func (procDef *PivotProcessorDef) GetPartitionIndexName() string {
+	return procDef.IndexName
+}
+
+// Rows of one group must have the same index key, so each index component must be a plain group_by field
+func (procDef *PivotProcessorDef) CheckPartitionIndex(idxDef *sc.IdxDef) error {
+	groupByFields := map[string]struct{}{}
+	for _, groupByExp := range procDef.ParsedGroupBy {
+		if selExp, ok := groupByExp.(*ast.SelectorExpr); ok {
+			if ident, ok := selExp.X.(*ast.Ident); ok && ident.Name == sc.ReaderAlias {
+				groupByFields[selExp.Sel.Name] = struct{}{}
+			}
+		}
+	}
+	for _, component := range idxDef.Components {
+		if _, ok := groupByFields[component.FieldName]; !ok {
+			return fmt.Errorf("pivot index [%s] component %s is not a group_by field, rows of the same group may get different index keys", procDef.IndexName, component.FieldName)
+		}
+	}
+	return nil
+}
+
+// Key values are compared formatted by key_type, so decimal key_value "1.50" matches 1.5, and datetime key_value
+// matches regardless of the time zone it was written in
+func formatKeyValue(val any, keyType evalcapi.TableFieldType) (string, error) {
+	switch evalcapi.FieldTypeKind(keyType) {
+	case evalcapi.FieldTypeString:
+		if v, ok := val.(string); ok {
+			return v, nil
+		}
+	case evalcapi.FieldTypeInt:
+		if v, ok := val.(int64); ok {
+			return strconv.FormatInt(v, 10), nil
+		}
+	case evalcapi.FieldTypeFloat:
+		if v, ok := val.(float64); ok {
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+	case evalcapi.FieldTypeDecimal2:
+		if v, ok := val.(decimal.Decimal); ok {
+			return v.StringFixed(evalcapi.DecimalFieldTypeScale(keyType)), nil
+		}
+	case evalcapi.FieldTypeBool:
+		if v, ok := val.(bool); ok {
+			return strconv.FormatBool(v), nil
+		}
+	case evalcapi.FieldTypeDateTime:
+		if v, ok := val.(time.Time); ok {
+			return v.UTC().Format(sc.CassandraDatetimeFormat), nil
+		}
+	}
+	return "", fmt.Errorf("expected %s value, got %T", keyType, val)
+}
+
+func parseAndFormatKeyValue(s string, keyType evalcapi.TableFieldType) (string, error) {
+	var val any
+	var err error
+	switch evalcapi.FieldTypeKind(keyType) {
+	case evalcapi.FieldTypeString:
+		val = s
+	case evalcapi.FieldTypeInt:
+		val, err = strconv.ParseInt(s, 10, 64)
+	case evalcapi.FieldTypeFloat:
+		val, err = strconv.ParseFloat(s, 64)
+	case evalcapi.FieldTypeDecimal2:
+		val, err = decimal.NewFromString(s)
+	case evalcapi.FieldTypeBool:
+		val, err = strconv.ParseBool(s)
+	case evalcapi.FieldTypeDateTime:
+		val, err = time.Parse(sc.CassandraDatetimeFormat, s)
+	}
+	if err != nil {
+		return "", err
+	}
+	return formatKeyValue(val, keyType)
+}
+
+// Length-prefixed values with their types: group_by values ["a b","c"] and ["a","b c"] make different keys
+func buildGroupKey(groupVals []any) string {
+	var b strings.Builder
+	for _, val := range groupVals {
+		valString := fmt.Sprintf("%v", val)
+		fmt.Fprintf(&b, "%T:%d:%s;", val, len(valString), valString)
+	}
+	return b.String()
+}
+
+const pivotFlushBufferSize int = 1000
+
+type pivotGroup struct {
+	readerVars map[string]any
+	eCtxMap    map[string]*eval.EvalCtx
+}
+
+// Key and group_by values are only compared, never stored, so decimals are not rounded:
+// rounding to some fixed scale would merge groups that differ in the extra digits
+func evalPlain(exp ast.Expr, vars eval.VarValuesMap) (any, error) {
+	eCtx := eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, vars)
+	return eCtx.Eval(exp)
+}
+
+// Rows are aggregated within the rowset: the rowset contains whole index partitions,
+// so all rows of a group are here
+func (procDef *PivotProcessorDef) pivot(rsIn *proc.Rowset, flushVarsArray func(varsArray []eval.VarValuesMap, varsArrayCount int) error) error {
+	groups := map[string]*pivotGroup{}
+	groupKeys := make([]string, 0)
+
+	for rowIdx := 0; rowIdx < rsIn.RowCount; rowIdx++ {
+		vars := eval.VarValuesMap{}
+		if err := rsIn.ExportToVars(rowIdx, vars); err != nil {
+			return err
+		}
+
+		keyVal, err := evalPlain(procDef.ParsedKey, vars)
+		if err != nil {
+			return fmt.Errorf("cannot evaluate pivot key expression [%s]: [%s]", procDef.RawKey, err.Error())
+		}
+		// Null key matches no column
+		var keyString string
+		if keyVal != nil {
+			if keyString, err = formatKeyValue(keyVal, procDef.KeyType); err != nil {
+				return fmt.Errorf("cannot format pivot key expression [%s] value: [%s]", procDef.RawKey, err.Error())
+			}
+		}
+
+		groupVals := make([]any, len(procDef.ParsedGroupBy))
+		for i, groupByExp := range procDef.ParsedGroupBy {
+			if groupVals[i], err = evalPlain(groupByExp, vars); err != nil {
+				return fmt.Errorf("cannot evaluate pivot group_by expression [%s]: [%s]", procDef.RawGroupBy[i], err.Error())
+			}
+		}
+		groupKey := buildGroupKey(groupVals)
+
+		group, ok := groups[groupKey]
+		if !ok {
+			group = &pivotGroup{readerVars: map[string]any{}, eCtxMap: map[string]*eval.EvalCtx{}}
+			for fieldName, fieldVal := range vars[sc.ReaderAlias] {
+				group.readerVars[fieldName] = fieldVal
+			}
+			groups[groupKey] = group
+			groupKeys = append(groupKeys, groupKey)
+		}
+
+		for colName, colDef := range procDef.Columns {
+			if keyVal == nil || colDef.KeyString != keyString {
+				continue
+			}
+			// Agg ctx is created on the first row with this key value, so columns without rows get the default value
+			if _, ok := group.eCtxMap[colName]; !ok {
+				eCtx, err := eval.NewAggEvalCtx(colDef.AggFuncType, colDef.AggFuncArgs, evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, nil)
+				if err != nil {
+					return fmt.Errorf("cannot initialize ctx for pivot column %s: %s", colName, err.Error())
+				}
+				eCtx.SetRoundDec(evalcapi.DecimalFieldTypeScale(colDef.Type))
+				group.eCtxMap[colName] = eCtx
+			}
+			group.eCtxMap[colName].SetVars(vars)
+			if _, err := group.eCtxMap[colName].Eval(colDef.ParsedExpression); err != nil {
+				return fmt.Errorf("cannot evaluate pivot column %s expression [%s]: [%s]", colName, colDef.RawExpression, err.Error())
+			}
+		}
+	}
+
+	varsArray := make([]eval.VarValuesMap, pivotFlushBufferSize)
+	varsArrayCount := 0
+	for _, groupKey := range groupKeys {
+		group := groups[groupKey]
+		procVars := map[string]any{}
+		for colName, colDef := range procDef.Columns {
+			finalValue := sc.GetDefaultFieldTypeValue(colDef.Type)
+			if eCtx, ok := group.eCtxMap[colName]; ok {
+				finalValue = eCtx.GetSafeValue(finalValue)
+			}
+			if err := sc.CheckValueType(finalValue, colDef.Type); err != nil {
+				return fmt.Errorf("invalid pivot column %s type: [%s]", colName, err.Error())
+			}
+			procVars[colName] = finalValue
+		}
+		varsArray[varsArrayCount] = eval.VarValuesMap{sc.CustomProcessorAlias: procVars, sc.ReaderAlias: group.readerVars}
+		varsArrayCount++
+
+		if varsArrayCount == len(varsArray) {
+			if err := flushVarsArray(varsArray, varsArrayCount); err != nil {
+				return fmt.Errorf("error flushing vars array of size %d: %s", varsArrayCount, err.Error())
+			}
+			varsArray = make([]eval.VarValuesMap, pivotFlushBufferSize)
+			varsArrayCount = 0
+		}
+	}
+
+	if varsArrayCount > 0 {
+		if err := flushVarsArray(varsArray, varsArrayCount); err != nil {
+			return fmt.Errorf("error flushing leftovers vars array of size %d: %s", varsArrayCount, err.Error())
+		}
+	}
+
+	return nil
+}
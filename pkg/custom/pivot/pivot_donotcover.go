@@ -0,0 +1,12 @@
+package pivot
+
+import (
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/proc"
+)
+
+func (procDef *PivotProcessorDef) Run(_ *l.CapiLogger, _ *ctx.MessageProcessingContext, rsIn *proc.Rowset, flushVarsArray func(varsArray []eval.VarValuesMap, varsArrayCount int) error) error {
+	return procDef.pivot(rsIn, flushVarsArray)
+}
@@ -0,0 +1,263 @@
+package pivot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/capillariesio/capillaries/pkg/proc"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type PivotTestProcessorDefFactory struct {
+}
+
+func (f *PivotTestProcessorDefFactory) Create(processorType string) (sc.CustomProcessorDef, bool) {
+	switch processorType {
+	case ProcessorPivotName:
+		return &PivotProcessorDef{}, true
+	default:
+		return nil, false
+	}
+}
+
+const scriptJson string = `
+{
+	"nodes": {
+		"read_sales": {
+			"type": "file_table",
+			"r": {
+				"urls": ["sales.csv"],
+				"csv":{
+					"hdr_line_idx": 0,
+					"first_data_line_idx": 1
+				},
+				"columns": {
+					"col_store": {
+						"csv":{
+							"col_idx": 0
+						},
+						"col_type": "string"
+					},
+					"col_month": {
+						"csv":{
+							"col_idx": 1
+						},
+						"col_type": "string"
+					},
+					"col_amount": {
+						"csv":{
+							"col_idx": 2,
+							"col_format": "%f"
+						},
+						"col_type": "decimal2"
+					}
+				}
+			},
+			"w": {
+				"name": "sales",
+				"indexes": {
+					"idx_sales_store": "non_unique(store)"
+				},
+				"fields": {
+					"store": {
+						"expression": "r.col_store",
+						"type": "string"
+					},
+					"month": {
+						"expression": "r.col_month",
+						"type": "string"
+					},
+					"amount": {
+						"expression": "r.col_amount",
+						"type": "decimal2"
+					}
+				}
+			}
+		},
+		"pivot_sales": {
+			"type": "table_custom_tfm_table",
+			"custom_proc_type": "pivot",
+			"r": {
+				"table": "sales"
+			},
+			"p": {
+				"index_name": "idx_sales_store",
+				"key": "r.month",
+				"group_by": ["r.store"],
+				"columns": {
+					"jan_sales": {"key_value": "jan", "expression": "sum(r.amount)", "type": "decimal2"},
+					"feb_sales": {"key_value": "feb", "expression": "sum(r.amount)", "type": "decimal2"},
+					"jan_count": {"key_value": "jan", "expression": "count()", "type": "int"}
+				}
+			},
+			"w": {
+				"name": "pivoted_sales",
+				"fields": {
+					"store": {
+						"expression": "r.store",
+						"type": "string"
+					},
+					"jan_sales": {
+						"expression": "p.jan_sales",
+						"type": "decimal2"
+					},
+					"feb_sales": {
+						"expression": "p.feb_sales",
+						"type": "decimal2"
+					},
+					"jan_count": {
+						"expression": "p.jan_count",
+						"type": "int"
+					}
+				}
+			}
+		}
+	},
+	"dependency_policies": {
+		"current_active_first_stopped_nogo":` + sc.DefaultPolicyCheckerConfJson +
+	`
+	}
+}`
+
+func deserialize(script string) (*sc.ScriptDef, error) {
+	scriptDef := &sc.ScriptDef{}
+	err := scriptDef.Deserialize([]byte(script), sc.ScriptJson, &PivotTestProcessorDefFactory{}, map[string]json.RawMessage{"pivot": {}}, "", nil)
+	return scriptDef, err
+}
+
+func newSalesRowset(t *testing.T, data [][]string) *proc.Rowset {
+	rs := proc.NewRowsetFromFieldRefs(sc.FieldRefs{
+		{TableName: "r", FieldName: "store", FieldType: evalcapi.FieldTypeString},
+		{TableName: "r", FieldName: "month", FieldType: evalcapi.FieldTypeString},
+		{TableName: "r", FieldName: "amount", FieldType: evalcapi.FieldTypeDecimal2},
+	})
+	assert.Nil(t, rs.InitRows(len(data)))
+	for i, row := range data {
+		store := row[0]
+		month := row[1]
+		amount := decimal.RequireFromString(row[2])
+		(*rs.Rows[i])[0] = &store
+		(*rs.Rows[i])[1] = &month
+		(*rs.Rows[i])[2] = &amount
+		rs.RowCount++
+	}
+	return rs
+}
+
+func TestPivotRun(t *testing.T) {
+	scriptDef, err := deserialize(scriptJson)
+	assert.Nil(t, err)
+
+	pivotProcessor, ok := scriptDef.ScriptNodes["pivot_sales"].CustomProcessor.(*PivotProcessorDef)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(*pivotProcessor.GetFieldRefs()))
+
+	rs := newSalesRowset(t, [][]string{{"s1", "jan", "10"}, {"s2", "feb", "7"}, {"s1", "feb", "3"}, {"s1", "jan", "5.5"}, {"s1", "mar", "100"}})
+
+	var results []eval.VarValuesMap
+	var resultCount int
+	flushVarsArray := func(varsArray []eval.VarValuesMap, varsArrayCount int) error {
+		results = varsArray
+		resultCount = varsArrayCount
+		return nil
+	}
+
+	assert.Nil(t, pivotProcessor.pivot(rs, flushVarsArray))
+
+	// One row per group, in the order groups were first seen
+	assert.Equal(t, 2, resultCount)
+	assert.Equal(t, "s1", results[0]["r"]["store"])
+	assert.Equal(t, decimal.RequireFromString("15.5").String(), results[0]["p"]["jan_sales"].(decimal.Decimal).String())
+	assert.Equal(t, decimal.RequireFromString("3").String(), results[0]["p"]["feb_sales"].(decimal.Decimal).String())
+	assert.Equal(t, int64(2), results[0]["p"]["jan_count"])
+	assert.Equal(t, "s2", results[1]["r"]["store"])
+	assert.Equal(t, decimal.RequireFromString("0").String(), results[1]["p"]["jan_sales"].(decimal.Decimal).String())
+	assert.Equal(t, decimal.RequireFromString("7").String(), results[1]["p"]["feb_sales"].(decimal.Decimal).String())
+	assert.Equal(t, int64(0), results[1]["p"]["jan_count"])
+}
+
+func TestPivotGroupValuesWithSpaces(t *testing.T) {
+	scriptDef, err := deserialize(strings.Replace(scriptJson, `"group_by": ["r.store"],`, `"group_by": ["r.store", "r.month"],`, 1))
+	assert.Nil(t, err)
+	pivotProcessor := scriptDef.ScriptNodes["pivot_sales"].CustomProcessor.(*PivotProcessorDef)
+
+	// Both groups would print as [a b c] with %v
+	rs := newSalesRowset(t, [][]string{{"a b", "c", "1"}, {"a", "b c", "2"}, {"a b", "c", "4"}})
+
+	resultCount := 0
+	flushVarsArray := func(_ []eval.VarValuesMap, varsArrayCount int) error {
+		resultCount += varsArrayCount
+		return nil
+	}
+	assert.Nil(t, pivotProcessor.pivot(rs, flushVarsArray))
+	assert.Equal(t, 2, resultCount)
+
+	assert.NotEqual(t, buildGroupKey([]any{"a b", "c"}), buildGroupKey([]any{"a", "b c"}))
+	assert.NotEqual(t, buildGroupKey([]any{"1"}), buildGroupKey([]any{int64(1)}))
+}
+
+func TestPivotKeyType(t *testing.T) {
+	keyString, err := parseAndFormatKeyValue("1.50", evalcapi.FieldTypeDecimal2)
+	assert.Nil(t, err)
+	rowKeyString, _ := formatKeyValue(decimal.RequireFromString("1.5"), evalcapi.FieldTypeDecimal2)
+	assert.Equal(t, keyString, rowKeyString)
+
+	keyString, err = parseAndFormatKeyValue("2024-01-31T10:00:00.000+02:00", evalcapi.FieldTypeDateTime)
+	assert.Nil(t, err)
+	rowKeyString, _ = formatKeyValue(time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC), evalcapi.FieldTypeDateTime)
+	assert.Equal(t, keyString, rowKeyString)
+
+	_, err = formatKeyValue("jan", evalcapi.FieldTypeInt)
+	assert.Equal(t, "expected int value, got string", err.Error())
+
+	_, err = deserialize(strings.Replace(scriptJson, `"key": "r.month",`, `"key": "r.month", "key_type": "int",`, 1))
+	assert.Contains(t, err.Error(), "cannot read pivot column jan_sales key_value [jan] as int")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"key": "r.month",`, `"key": "r.month", "key_type": "integer",`, 1))
+	assert.Contains(t, err.Error(), "invalid pivot key_type [integer]")
+}
+
+func TestPivotDeserializeFailures(t *testing.T) {
+	_, err := deserialize(strings.Replace(scriptJson, `"expression": "sum(r.amount)", "type": "decimal2"}`, `"expression": "r.amount", "type": "decimal2"}`, 1))
+	assert.Contains(t, err.Error(), "pivot column jan_sales expression [r.amount] must be an aggregate function call")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"expression": "count()", "type": "int"`, `"expression": "count()", "type": "integer"`, 1))
+	assert.Contains(t, err.Error(), "invalid pivot column jan_count type [integer]")
+
+	_, err = deserialize(strings.Replace(scriptJson, `{"key_value": "jan", "expression": "count()"`, `{"expression": "count()"`, 1))
+	assert.Contains(t, err.Error(), "pivot column jan_count key_value cannot be empty")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"key": "r.month",`, ``, 1))
+	assert.Contains(t, err.Error(), "pivot key expression cannot be empty")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"group_by": ["r.store"],`, ``, 1))
+	assert.Contains(t, err.Error(), "pivot group_by cannot be empty")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"group_by": ["r.store"],`, `"group_by": ["max(r.store)"],`, 1))
+	assert.Contains(t, err.Error(), "cannot use agg functions in pivot group_by expression [max(r.store)]")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"index_name": "idx_sales_store",`, ``, 1))
+	assert.Contains(t, err.Error(), "pivot index_name cannot be empty")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"index_name": "idx_sales_store",`, `"index_name": "idx_bad",`, 1))
+	assert.Contains(t, err.Error(), "cannot find the node that creates index [idx_bad]")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"non_unique(store)"`, `"unique(store)"`, 1))
+	assert.Contains(t, err.Error(), "partition index [idx_sales_store] must be non-unique")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"non_unique(store)"`, `"non_unique(store,month)"`, 1))
+	assert.Contains(t, err.Error(), "pivot index [idx_sales_store] component month is not a group_by field")
+
+	// Exercise checkFieldUsageInCustomProcessor() error code path
+	_, err = deserialize(strings.Replace(scriptJson, `"key": "r.month"`, `"key": "r.bad_month"`, 1))
+	assert.Contains(t, err.Error(), "unknown field r.bad_month")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"expression": "p.jan_count"`, `"expression": "p.bad_count"`, 1))
+	assert.Contains(t, err.Error(), "unknown field p.bad_count")
+}
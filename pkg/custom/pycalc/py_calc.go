@@ -214,7 +214,7 @@ func valueToPythonExpr(val any) string {
 }
 
 func pythonResultToRowsetValue(fieldRef *sc.FieldRef, fieldValue any) (any, error) {
-	switch fieldRef.FieldType {
+	switch evalcapi.FieldTypeKind(fieldRef.FieldType) {
 	case evalcapi.FieldTypeString:
 		finalVal, ok := fieldValue.(string)
 		if !ok {
@@ -245,7 +245,7 @@ func pythonResultToRowsetValue(fieldRef *sc.FieldRef, fieldValue any) (any, erro
 		if !ok {
 			return nil, fmt.Errorf("decimal %s, unexpected type %T(%v)", fieldRef.FieldName, fieldValue, fieldValue)
 		}
-		finalDecVal := decimal.NewFromFloat(finalVal).Round(2)
+		finalDecVal := decimal.NewFromFloat(finalVal).Round(evalcapi.DecimalFieldTypeScale(fieldRef.FieldType))
 		return finalDecVal, nil
 	case evalcapi.FieldTypeDateTime:
 		finalVal, ok := fieldValue.(string)
@@ -0,0 +1,4 @@
+/*
+Package unpivot contains definition of the custom processor unpivot
+*/
+package unpivot
@@ -0,0 +1,150 @@
+package unpivot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strings"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/capillariesio/capillaries/pkg/proc"
+	"github.com/capillariesio/capillaries/pkg/sc"
+)
+
+const ProcessorUnpivotName string = "unpivot"
+
+// All processor settings, stored in Capillaries script, root values coming from node
+type UnpivotProcessorDef struct {
+	NameFieldName       string                  `json:"name_field_name"`
+	ValueFieldName      string                  `json:"value_field_name"`
+	ValueType           evalcapi.TableFieldType `json:"value_type"`
+	RawColumns          map[string]string       `json:"columns"`
+	ParsedColumns       map[string]ast.Expr
+	ColumnNames         []string // Sorted, so output rows for each input row always come in the same order
+	UsedInColumnsFields sc.FieldRefs
+}
+
+func (procDef *UnpivotProcessorDef) GetFieldRefs() *sc.FieldRefs {
+	return &sc.FieldRefs{
+		{
+			TableName: sc.CustomProcessorAlias,
+			FieldName: procDef.NameFieldName,
+			FieldType: evalcapi.FieldTypeString},
+		{
+			TableName: sc.CustomProcessorAlias,
+			FieldName: procDef.ValueFieldName,
+			FieldType: procDef.ValueType}}
+}
+
+func (procDef *UnpivotProcessorDef) GetUsedInTargetExpressionsFields() *sc.FieldRefs {
+	return &procDef.UsedInColumnsFields
+}
+
+func (procDef *UnpivotProcessorDef) Deserialize(raw json.RawMessage, _ json.RawMessage, scriptType sc.ScriptType, _ string, _ map[string]string) error {
+	var err error
+	switch scriptType {
+	case sc.ScriptJson:
+		if err = json.Unmarshal(raw, procDef); err != nil {
+			return fmt.Errorf("cannot unmarshal unpivot processor def json: %s", err.Error())
+		}
+	case sc.ScriptYaml:
+		if err = json.Unmarshal(raw, procDef); err != nil {
+			return fmt.Errorf("cannot unmarshal unpivot processor def yaml: %s", err.Error())
+		}
+	default:
+		return errors.New("cannot unmarshal unpivot processor def: json or yaml expected")
+	}
+
+	if len(procDef.NameFieldName) == 0 || len(procDef.ValueFieldName) == 0 {
+		return errors.New("unpivot name_field_name and value_field_name cannot be empty")
+	}
+	if procDef.NameFieldName == procDef.ValueFieldName {
+		return fmt.Errorf("unpivot name_field_name and value_field_name cannot be the same, %s specified", procDef.NameFieldName)
+	}
+	if !evalcapi.IsValidFieldType(procDef.ValueType) {
+		return fmt.Errorf("invalid unpivot value_type [%s]", procDef.ValueType)
+	}
+	if len(procDef.RawColumns) == 0 {
+		return errors.New("unpivot columns cannot be empty")
+	}
+
+	foundErrors := make([]string, 0)
+	procDef.ParsedColumns = map[string]ast.Expr{}
+	procDef.ColumnNames = make([]string, 0, len(procDef.RawColumns))
+
+	for colName, rawExp := range procDef.RawColumns {
+		procDef.ColumnNames = append(procDef.ColumnNames, colName)
+		if procDef.ParsedColumns[colName], err = sc.ParseRawGolangExpressionStringAndHarvestFieldRefs(rawExp, &procDef.UsedInColumnsFields); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot parse unpivot column %s expression [%s]: [%s]", colName, rawExp, err.Error()))
+			continue
+		}
+		v := sc.AggFinderVisitor{}
+		ast.Walk(&v, procDef.ParsedColumns[colName])
+		if v.Error != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot use agg functions in unpivot column %s expression [%s]: [%s]", colName, rawExp, v.Error.Error()))
+		}
+	}
+	sort.Strings(procDef.ColumnNames)
+
+	// Later on, checkFieldUsageInCustomProcessor() will verify all fields from procDef.UsedInColumnsFields are valid reader fields
+
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
+
+const unpivotFlushBufferSize int = 1000
+
+func (procDef *UnpivotProcessorDef) unpivot(rsIn *proc.Rowset, flushVarsArray func(varsArray []eval.VarValuesMap, varsArrayCount int) error) error {
+	varsArray := make([]eval.VarValuesMap, unpivotFlushBufferSize)
+	varsArrayCount := 0
+
+	for rowIdx := 0; rowIdx < rsIn.RowCount; rowIdx++ {
+		vars := eval.VarValuesMap{}
+		if err := rsIn.ExportToVars(rowIdx, vars); err != nil {
+			return err
+		}
+
+		for _, colName := range procDef.ColumnNames {
+			eCtx := eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, vars)
+			eCtx.SetRoundDec(evalcapi.DecimalFieldTypeScale(procDef.ValueType))
+			valVolatile, err := eCtx.Eval(procDef.ParsedColumns[colName])
+			if err != nil {
+				return fmt.Errorf("cannot evaluate expression for unpivot column %s: [%s]", colName, err.Error())
+			}
+			if err := sc.CheckValueType(valVolatile, procDef.ValueType); err != nil {
+				return fmt.Errorf("invalid unpivot column %s type: [%s]", colName, err.Error())
+			}
+
+			varsArray[varsArrayCount] = eval.VarValuesMap{}
+			// Write name and value
+			varsArray[varsArrayCount][sc.CustomProcessorAlias] = map[string]any{procDef.NameFieldName: colName, procDef.ValueFieldName: valVolatile}
+			// Write r values
+			varsArray[varsArrayCount][sc.ReaderAlias] = map[string]any{}
+			for fieldName, fieldVal := range vars[sc.ReaderAlias] {
+				varsArray[varsArrayCount][sc.ReaderAlias][fieldName] = fieldVal
+			}
+			varsArrayCount++
+
+			if varsArrayCount == len(varsArray) {
+				if err = flushVarsArray(varsArray, varsArrayCount); err != nil {
+					return fmt.Errorf("error flushing vars array of size %d: %s", varsArrayCount, err.Error())
+				}
+				varsArray = make([]eval.VarValuesMap, unpivotFlushBufferSize)
+				varsArrayCount = 0
+			}
+		}
+	}
+
+	if varsArrayCount > 0 {
+		if err := flushVarsArray(varsArray, varsArrayCount); err != nil {
+			return fmt.Errorf("error flushing leftovers vars array of size %d: %s", varsArrayCount, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+package unpivot
+
+import (
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/proc"
+)
+
+func (procDef *UnpivotProcessorDef) Run(_ *l.CapiLogger, _ *ctx.MessageProcessingContext, rsIn *proc.Rowset, flushVarsArray func(varsArray []eval.VarValuesMap, varsArrayCount int) error) error {
+	return procDef.unpivot(rsIn, flushVarsArray)
+}
@@ -0,0 +1,190 @@
+package unpivot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/capillariesio/capillaries/pkg/proc"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type UnpivotTestProcessorDefFactory struct {
+}
+
+func (f *UnpivotTestProcessorDefFactory) Create(processorType string) (sc.CustomProcessorDef, bool) {
+	switch processorType {
+	case ProcessorUnpivotName:
+		return &UnpivotProcessorDef{}, true
+	default:
+		return nil, false
+	}
+}
+
+const scriptJson string = `
+{
+	"nodes": {
+		"read_sales": {
+			"type": "file_table",
+			"r": {
+				"urls": ["sales.csv"],
+				"csv":{
+					"hdr_line_idx": 0,
+					"first_data_line_idx": 1
+				},
+				"columns": {
+					"col_store": {
+						"csv":{
+							"col_idx": 0
+						},
+						"col_type": "string"
+					},
+					"col_q1": {
+						"csv":{
+							"col_idx": 1,
+							"col_format": "%f"
+						},
+						"col_type": "decimal2"
+					},
+					"col_q2": {
+						"csv":{
+							"col_idx": 2,
+							"col_format": "%f"
+						},
+						"col_type": "decimal2"
+					}
+				}
+			},
+			"w": {
+				"name": "sales",
+				"fields": {
+					"store": {
+						"expression": "r.col_store",
+						"type": "string"
+					},
+					"q1": {
+						"expression": "r.col_q1",
+						"type": "decimal2"
+					},
+					"q2": {
+						"expression": "r.col_q2",
+						"type": "decimal2"
+					}
+				}
+			}
+		},
+		"unpivot_sales": {
+			"type": "table_custom_tfm_table",
+			"custom_proc_type": "unpivot",
+			"r": {
+				"table": "sales"
+			},
+			"p": {
+				"name_field_name": "quarter",
+				"value_field_name": "sales",
+				"value_type": "decimal2",
+				"columns": {
+					"q2": "r.q2",
+					"q1": "r.q1"
+				}
+			},
+			"w": {
+				"name": "unpivoted_sales",
+				"fields": {
+					"store": {
+						"expression": "r.store",
+						"type": "string"
+					},
+					"quarter": {
+						"expression": "p.quarter",
+						"type": "string"
+					},
+					"sales": {
+						"expression": "p.sales",
+						"type": "decimal2"
+					}
+				}
+			}
+		}
+	},
+	"dependency_policies": {
+		"current_active_first_stopped_nogo":` + sc.DefaultPolicyCheckerConfJson +
+	`
+	}
+}`
+
+func deserialize(script string) (*sc.ScriptDef, error) {
+	scriptDef := &sc.ScriptDef{}
+	err := scriptDef.Deserialize([]byte(script), sc.ScriptJson, &UnpivotTestProcessorDefFactory{}, map[string]json.RawMessage{"unpivot": {}}, "", nil)
+	return scriptDef, err
+}
+
+func TestUnpivotRun(t *testing.T) {
+	scriptDef, err := deserialize(scriptJson)
+	assert.Nil(t, err)
+
+	unpivotProcessor, ok := scriptDef.ScriptNodes["unpivot_sales"].CustomProcessor.(*UnpivotProcessorDef)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"q1", "q2"}, unpivotProcessor.ColumnNames)
+
+	rs := proc.NewRowsetFromFieldRefs(sc.FieldRefs{
+		{TableName: "r", FieldName: "store", FieldType: evalcapi.FieldTypeString},
+		{TableName: "r", FieldName: "q1", FieldType: evalcapi.FieldTypeDecimal2},
+		{TableName: "r", FieldName: "q2", FieldType: evalcapi.FieldTypeDecimal2},
+	})
+	assert.Nil(t, rs.InitRows(1))
+	store := "s1"
+	q1 := decimal.NewFromFloat(1.5)
+	q2 := decimal.NewFromFloat(2.5)
+	(*rs.Rows[0])[0] = &store
+	(*rs.Rows[0])[1] = &q1
+	(*rs.Rows[0])[2] = &q2
+	rs.RowCount++
+
+	var results []eval.VarValuesMap
+	var resultCount int
+	flushVarsArray := func(varsArray []eval.VarValuesMap, varsArrayCount int) error {
+		results = varsArray
+		resultCount = varsArrayCount
+		return nil
+	}
+
+	assert.Nil(t, unpivotProcessor.unpivot(rs, flushVarsArray))
+	assert.Equal(t, 2, resultCount)
+	assert.Equal(t, "s1", results[0]["r"]["store"])
+	assert.Equal(t, "q1", results[0]["p"]["quarter"])
+	assert.Equal(t, q1, results[0]["p"]["sales"])
+	assert.Equal(t, "s1", results[1]["r"]["store"])
+	assert.Equal(t, "q2", results[1]["p"]["quarter"])
+	assert.Equal(t, q2, results[1]["p"]["sales"])
+
+	// Bad type
+	unpivotProcessor.ValueType = evalcapi.FieldTypeInt
+	err = unpivotProcessor.unpivot(rs, flushVarsArray)
+	assert.Contains(t, err.Error(), "invalid unpivot column q1 type")
+}
+
+func TestUnpivotDeserializeFailures(t *testing.T) {
+	_, err := deserialize(strings.Replace(scriptJson, `"value_type": "decimal2"`, `"value_type": "money"`, 1))
+	assert.Contains(t, err.Error(), "invalid unpivot value_type [money]")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"value_field_name": "sales"`, `"value_field_name": "quarter"`, 1))
+	assert.Contains(t, err.Error(), "unpivot name_field_name and value_field_name cannot be the same, quarter specified")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"name_field_name": "quarter",`, ``, 1))
+	assert.Contains(t, err.Error(), "unpivot name_field_name and value_field_name cannot be empty")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"q2": "r.q2",`, `"q2": "sum(r.q2)",`, 1))
+	assert.Contains(t, err.Error(), "cannot use agg functions in unpivot column q2 expression [sum(r.q2)]")
+
+	_, err = deserialize(strings.Replace(scriptJson, `"q2": "r.q2",`, `"q2": "[",`, 1))
+	assert.Contains(t, err.Error(), "cannot parse unpivot column q2 expression")
+
+	// Exercise checkFieldUsageInCustomProcessor() error code path
+	_, err = deserialize(strings.Replace(scriptJson, `"q2": "r.q2",`, `"q2": "r.q3",`, 1))
+	assert.Contains(t, err.Error(), "unknown field r.q3")
+}
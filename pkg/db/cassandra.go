@@ -261,6 +261,12 @@ func NewGocqlSession(envConfig *env.EnvConfig, keyspace string, createKeyspace C
 			if err = createWfTable(genericSession, keyspace, reflect.TypeOf(wfmodel.RunCounter{}), wfmodel.TableNameRunCounter); err != nil {
 				return nil, cassandraEngine, err
 			}
+			if err = createWfTable(genericSession, keyspace, reflect.TypeOf(wfmodel.FileLedgerEntry{}), wfmodel.TableNameFileLedger); err != nil {
+				return nil, cassandraEngine, err
+			}
+			if err = createWfTable(genericSession, keyspace, reflect.TypeOf(wfmodel.FinalizeClaim{}), wfmodel.TableNameFinalizeClaim); err != nil {
+				return nil, cassandraEngine, err
+			}
 
 			if cassandraEngine == CassandraEngineAmazonKeyspaces {
 				if checkTableErr := VerifyAmazonKeyspacesTablesReady(genericSession, keyspace, []string{
@@ -268,7 +274,9 @@ func NewGocqlSession(envConfig *env.EnvConfig, keyspace string, createKeyspace C
 					wfmodel.TableNameNodeHistory,
 					wfmodel.TableNameRunHistory,
 					wfmodel.TableNameRunProperties,
-					wfmodel.TableNameRunCounter}); checkTableErr != nil {
+					wfmodel.TableNameRunCounter,
+					wfmodel.TableNameFileLedger,
+					wfmodel.TableNameFinalizeClaim}); checkTableErr != nil {
 					return nil, cassandraEngine, checkTableErr
 				}
 			}
@@ -316,6 +324,12 @@ func NewSession(envConfig *env.EnvConfig, keyspace string, createKeyspace Create
 				if err = createWfTable(testGocqlmemSession, keyspace, reflect.TypeOf(wfmodel.RunCounter{}), wfmodel.TableNameRunCounter); err != nil {
 					return nil, CassandraEngineCassandra, err
 				}
+				if err = createWfTable(testGocqlmemSession, keyspace, reflect.TypeOf(wfmodel.FileLedgerEntry{}), wfmodel.TableNameFileLedger); err != nil {
+					return nil, CassandraEngineCassandra, err
+				}
+				if err = createWfTable(testGocqlmemSession, keyspace, reflect.TypeOf(wfmodel.FinalizeClaim{}), wfmodel.TableNameFinalizeClaim); err != nil {
+					return nil, CassandraEngineCassandra, err
+				}
 				qb := cql.QueryBuilder{}
 				qb.
 					Keyspace(keyspace).
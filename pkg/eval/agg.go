@@ -15,19 +15,28 @@ import (
 type AggFuncType string
 
 const (
-	AggStringAgg   AggFuncType = "string_agg"
-	AggStringAggIf AggFuncType = "string_agg_if"
-	AggSum         AggFuncType = "sum"
-	AggSumIf       AggFuncType = "sum_if"
-	AggCount       AggFuncType = "count"
-	AggCountIf     AggFuncType = "count_if"
-	AggAvg         AggFuncType = "avg"
-	AggAvgIf       AggFuncType = "avg_if"
-	AggMin         AggFuncType = "min"
-	AggMinIf       AggFuncType = "min_if"
-	AggMax         AggFuncType = "max"
-	AggMaxIf       AggFuncType = "max_if"
-	AggUnknown     AggFuncType = "unknown"
+	AggStringAgg     AggFuncType = "string_agg"
+	AggStringAggIf   AggFuncType = "string_agg_if"
+	AggSum           AggFuncType = "sum"
+	AggSumIf         AggFuncType = "sum_if"
+	AggCount         AggFuncType = "count"
+	AggCountIf       AggFuncType = "count_if"
+	AggAvg           AggFuncType = "avg"
+	AggAvgIf         AggFuncType = "avg_if"
+	AggMin           AggFuncType = "min"
+	AggMinIf         AggFuncType = "min_if"
+	AggMax           AggFuncType = "max"
+	AggMaxIf         AggFuncType = "max_if"
+	AggCountDistinct AggFuncType = "count_distinct"
+	AggStdDev        AggFuncType = "stddev"
+	AggVariance      AggFuncType = "variance"
+	AggMedian        AggFuncType = "median"
+	AggPercentile    AggFuncType = "percentile"
+	AggFirst         AggFuncType = "first"
+	AggLast          AggFuncType = "last"
+	AggBoolAnd       AggFuncType = "bool_and"
+	AggBoolOr        AggFuncType = "bool_or"
+	AggUnknown       AggFuncType = "unknown"
 )
 
 func StringToAggFunc(testString string) AggFuncType {
@@ -56,6 +65,24 @@ func StringToAggFunc(testString string) AggFuncType {
 		return AggMax
 	case string(AggMaxIf):
 		return AggMaxIf
+	case string(AggCountDistinct):
+		return AggCountDistinct
+	case string(AggStdDev):
+		return AggStdDev
+	case string(AggVariance):
+		return AggVariance
+	case string(AggMedian):
+		return AggMedian
+	case string(AggPercentile):
+		return AggPercentile
+	case string(AggFirst):
+		return AggFirst
+	case string(AggLast):
+		return AggLast
+	case string(AggBoolAnd):
+		return AggBoolAnd
+	case string(AggBoolOr):
+		return AggBoolOr
 	default:
 		return AggUnknown
 	}
@@ -94,15 +121,57 @@ type StringAggCollector struct {
 	Separator string
 }
 
+// Keys are standard-typed values, decimals and times use their own key types, see countDistinctKey()
+type CountDistinctCollector struct {
+	Values map[any]struct{}
+}
+
+// Welford's online algorithm: no catastrophic cancellation of sum(x^2)-sum(x)^2
+type VarianceCollector struct {
+	Count int64
+	Mean  float64
+	M2    float64
+}
+
+type PercentileMode string
+
+const (
+	PercentileModeExact   PercentileMode = "exact"
+	PercentileModeTDigest PercentileMode = "tdigest"
+)
+
+// Exact mode keeps all values, tdigest mode keeps a bounded number of centroids
+type PercentileCollector struct {
+	Fraction float64
+	Mode     PercentileMode
+	Ints     []int64
+	Floats   []float64
+	Decs     []decimal.Decimal
+	Digest   *TDigest
+}
+
+type FirstLastCollector struct {
+	Value any
+	Order any
+	Count int64
+}
+
+type BoolCollector struct {
+	Bool  bool
+	Count int64
+}
+
 // Internal data type used for agg calculations only
 type AggDataType string
 
 const (
-	AggTypeUnknown AggDataType = "unknown"
-	AggTypeInt     AggDataType = "int"
-	AggTypeFloat   AggDataType = "float"
-	AggTypeDec     AggDataType = "decimal"
-	AggTypeString  AggDataType = "string"
+	AggTypeUnknown  AggDataType = "unknown"
+	AggTypeInt      AggDataType = "int"
+	AggTypeFloat    AggDataType = "float"
+	AggTypeDec      AggDataType = "decimal"
+	AggTypeString   AggDataType = "string"
+	AggTypeBool     AggDataType = "bool"
+	AggTypeDateTime AggDataType = "datetime"
 )
 
 func (eCtx *EvalCtx) checkAgg(funcName string, callExp *ast.CallExpr, aggFunc AggFuncType) error {
@@ -120,8 +189,25 @@ func (eCtx *EvalCtx) checkAgg(funcName string, callExp *ast.CallExpr, aggFunc Ag
 	return nil
 }
 
+// Null condition does not apply, like in SQL WHERE
+// Aggregates skip null values, like in SQL: a row with a null value arg does not change the result.
+// The if part of *_if functions is not a value arg, see checkIf()
+func isNullAggArg(aggFunc AggFuncType, args []any) bool {
+	switch aggFunc {
+	case AggCount, AggCountIf:
+		return false
+	case AggFirst, AggLast:
+		// Both value and order
+		return len(args) == 2 && (args[0] == nil || args[1] == nil)
+	default:
+		return len(args) > 0 && args[0] == nil
+	}
+}
+
 func checkIf(funcName string, boolArg any) (bool, error) {
 	switch typedArg := boolArg.(type) {
+	case nil:
+		return false, nil
 	case bool:
 		return typedArg, nil
 
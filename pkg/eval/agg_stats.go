@@ -0,0 +1,397 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Aggregates that need more than a running total: count_distinct, stddev/variance, median/percentile, first/last, bool_and/bool_or.
+// None of them has an _if variant.
+
+// Adopts the data type of the first value and rejects values of other types, like sum/avg/min/max do
+func (eCtx *EvalCtx) checkAggType(funcName string, aggType AggDataType, val any) error {
+	if eCtx.aggType == AggTypeUnknown {
+		eCtx.aggType = aggType
+	} else if eCtx.aggType != aggType {
+		return fmt.Errorf("cannot evaluate %s(), it started with type %s, now got %s value %v", funcName, eCtx.aggType, aggType, val)
+	}
+	return nil
+}
+
+// Numbers are cast to int64/float64/decimal, so int and int64 values are of the same type here
+func stdTypedAggValue(funcName string, arg any) (any, AggDataType, error) {
+	switch typedArg := arg.(type) {
+	case string:
+		return typedArg, AggTypeString, nil
+	case bool:
+		return typedArg, AggTypeBool, nil
+	case time.Time:
+		return typedArg, AggTypeDateTime, nil
+	default:
+		stdTypedArg, err := castNumberToStandardType(arg)
+		if err != nil {
+			return nil, AggTypeUnknown, fmt.Errorf("cannot evaluate %s(), unexpected argument %v of unsupported type %T", funcName, arg, arg)
+		}
+		switch stdTypedArg.(type) {
+		case int64:
+			return stdTypedArg, AggTypeInt, nil
+		case float64:
+			return stdTypedArg, AggTypeFloat, nil
+		default:
+			return stdTypedArg, AggTypeDec, nil
+		}
+	}
+}
+
+type countDistinctDecKey string
+type countDistinctTimeKey int64
+
+// decimal.Decimal holds a pointer and time.Time holds a location, so neither can be a map key as is.
+// 1.50 and 1.5 are the same decimal, two times in different zones are the same if they are the same instant.
+func countDistinctKey(val any) any {
+	switch typedVal := val.(type) {
+	case decimal.Decimal:
+		return countDistinctDecKey(typedVal.String())
+	case time.Time:
+		return countDistinctTimeKey(typedVal.UnixNano())
+	default:
+		return val
+	}
+}
+
+func (eCtx *EvalCtx) CallAggCountDistinct(callExp *ast.CallExpr, args []any) (any, error) {
+	funcName := "count_distinct"
+	if err := eCtx.checkAgg(funcName, callExp, AggCountDistinct); err != nil {
+		return nil, err
+	}
+	if err := CheckArgs(funcName, 1, len(args)); err != nil {
+		return nil, err
+	}
+	val, aggType, err := stdTypedAggValue(funcName, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := eCtx.checkAggType(funcName, aggType, args[0]); err != nil {
+		return nil, err
+	}
+	eCtx.countDistinctCollector.Values[countDistinctKey(val)] = struct{}{}
+	return int64(len(eCtx.countDistinctCollector.Values)), nil
+}
+
+func aggNumberToFloat(funcName string, val any) (float64, error) {
+	switch typedVal := val.(type) {
+	case int64:
+		return float64(typedVal), nil
+	case float64:
+		return typedVal, nil
+	case decimal.Decimal:
+		return typedVal.InexactFloat64(), nil
+	default:
+		return 0.0, fmt.Errorf("cannot evaluate %s(), unexpected argument %v of unsupported type %T", funcName, val, val)
+	}
+}
+
+func (eCtx *EvalCtx) callAggVarianceInternal(funcName string, callExp *ast.CallExpr, aggFunc AggFuncType, args []any) (any, error) {
+	if err := eCtx.checkAgg(funcName, callExp, aggFunc); err != nil {
+		return nil, err
+	}
+	if err := CheckArgs(funcName, 1, len(args)); err != nil {
+		return nil, err
+	}
+	val, aggType, err := stdTypedAggValue(funcName, args[0])
+	if err != nil {
+		return nil, err
+	}
+	floatVal, err := aggNumberToFloat(funcName, val)
+	if err != nil {
+		return nil, err
+	}
+	if err := eCtx.checkAggType(funcName, aggType, args[0]); err != nil {
+		return nil, err
+	}
+
+	c := &eCtx.varianceCollector
+	c.Count++
+	delta := floatVal - c.Mean
+	c.Mean += delta / float64(c.Count)
+	c.M2 += delta * (floatVal - c.Mean)
+
+	// Sample variance, zero until there are two values
+	variance := 0.0
+	if c.Count > 1 {
+		variance = c.M2 / float64(c.Count-1)
+	}
+	if aggFunc == AggStdDev {
+		return math.Sqrt(variance), nil
+	}
+	return variance, nil
+}
+
+func (eCtx *EvalCtx) CallAggStdDev(callExp *ast.CallExpr, args []any) (any, error) {
+	return eCtx.callAggVarianceInternal("stddev", callExp, AggStdDev, args)
+}
+
+func (eCtx *EvalCtx) CallAggVariance(callExp *ast.CallExpr, args []any) (any, error) {
+	return eCtx.callAggVarianceInternal("variance", callExp, AggVariance, args)
+}
+
+// Fraction and mode are constant, so they are parsed once, when the context is created:
+// median(expr[, mode]) and percentile(expr, fraction[, mode]), mode is "exact" (default) or "tdigest"
+func getAggPercentileArgs(aggFuncType AggFuncType, aggFuncArgs []ast.Expr) (float64, PercentileMode, error) {
+	fraction := 0.5
+	modeArgIdx := 1
+	if aggFuncType == AggMedian {
+		if len(aggFuncArgs) != 1 && len(aggFuncArgs) != 2 {
+			return 0.0, "", fmt.Errorf("%s must have one or two parameters", aggFuncType)
+		}
+	} else {
+		if len(aggFuncArgs) != 2 && len(aggFuncArgs) != 3 {
+			return 0.0, "", fmt.Errorf("%s must have two or three parameters", aggFuncType)
+		}
+		fractionLit, ok := aggFuncArgs[1].(*ast.BasicLit)
+		if !ok || (fractionLit.Kind != token.FLOAT && fractionLit.Kind != token.INT) {
+			return 0.0, "", errors.New("percentile second parameter must be a constant number")
+		}
+		var err error
+		fraction, err = strconv.ParseFloat(fractionLit.Value, 64)
+		if err != nil || fraction < 0.0 || fraction > 1.0 {
+			return 0.0, "", fmt.Errorf("percentile second parameter must be between 0 and 1, got %s", fractionLit.Value)
+		}
+		modeArgIdx = 2
+	}
+
+	if len(aggFuncArgs) <= modeArgIdx {
+		return fraction, PercentileModeExact, nil
+	}
+	modeLit, ok := aggFuncArgs[modeArgIdx].(*ast.BasicLit)
+	if !ok || modeLit.Kind != token.STRING {
+		return 0.0, "", fmt.Errorf("%s mode must be a constant string", aggFuncType)
+	}
+	mode := PercentileMode(strings.Trim(modeLit.Value, "\"`"))
+	if mode != PercentileModeExact && mode != PercentileModeTDigest {
+		return 0.0, "", fmt.Errorf("%s mode must be %s or %s, got %s", aggFuncType, PercentileModeExact, PercentileModeTDigest, mode)
+	}
+	return fraction, mode, nil
+}
+
+// Sorting on every row would make exact mode quadratic, so the percentile is computed in GetValue/GetSafeValue.
+// Until then, the eval result is the latest value: it has the right type, and that is all the script validation needs.
+func (eCtx *EvalCtx) callAggPercentileInternal(funcName string, callExp *ast.CallExpr, aggFunc AggFuncType, args []any) (any, error) {
+	if err := eCtx.checkAgg(funcName, callExp, aggFunc); err != nil {
+		return nil, err
+	}
+	if len(args) < 1 {
+		return nil, fmt.Errorf("cannot evaluate %s(), requires at least 1 arg, %d supplied", funcName, len(args))
+	}
+	val, aggType, err := stdTypedAggValue(funcName, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := aggNumberToFloat(funcName, val); err != nil {
+		return nil, err
+	}
+	if err := eCtx.checkAggType(funcName, aggType, args[0]); err != nil {
+		return nil, err
+	}
+
+	c := &eCtx.percentileCollector
+	switch typedVal := val.(type) {
+	case int64:
+		if c.Mode == PercentileModeTDigest {
+			c.Digest.Add(float64(typedVal))
+		} else {
+			c.Ints = append(c.Ints, typedVal)
+		}
+	case float64:
+		if c.Mode == PercentileModeTDigest {
+			c.Digest.Add(typedVal)
+		} else {
+			c.Floats = append(c.Floats, typedVal)
+		}
+	case decimal.Decimal:
+		if c.Mode == PercentileModeTDigest {
+			c.Digest.Add(typedVal.InexactFloat64())
+		} else {
+			c.Decs = append(c.Decs, typedVal)
+		}
+	}
+	return val, nil
+}
+
+func (eCtx *EvalCtx) CallAggMedian(callExp *ast.CallExpr, args []any) (any, error) {
+	return eCtx.callAggPercentileInternal("median", callExp, AggMedian, args)
+}
+
+func (eCtx *EvalCtx) CallAggPercentile(callExp *ast.CallExpr, args []any) (any, error) {
+	return eCtx.callAggPercentileInternal("percentile", callExp, AggPercentile, args)
+}
+
+// Linear interpolation between the two closest ranks. Int percentiles are truncated, like int avg.
+func (eCtx *EvalCtx) percentileValue() any {
+	c := &eCtx.percentileCollector
+	if c.Mode == PercentileModeTDigest {
+		if c.Digest.Count() == 0 {
+			return nil
+		}
+		floatVal := c.Digest.Quantile(c.Fraction)
+		switch eCtx.aggType {
+		case AggTypeInt:
+			return int64(floatVal)
+		case AggTypeDec:
+			decVal := decimal.NewFromFloat(floatVal)
+			if eCtx.roundDec >= 0 {
+				decVal = decVal.Round(eCtx.roundDec)
+			}
+			return decVal
+		default:
+			return floatVal
+		}
+	}
+
+	switch eCtx.aggType {
+	case AggTypeInt:
+		if len(c.Ints) == 0 {
+			return nil
+		}
+		sort.Slice(c.Ints, func(i, j int) bool { return c.Ints[i] < c.Ints[j] })
+		lo, hi, frac := percentileRanks(c.Fraction, len(c.Ints))
+		return c.Ints[lo] + int64(float64(c.Ints[hi]-c.Ints[lo])*frac)
+	case AggTypeFloat:
+		if len(c.Floats) == 0 {
+			return nil
+		}
+		sort.Float64s(c.Floats)
+		lo, hi, frac := percentileRanks(c.Fraction, len(c.Floats))
+		return c.Floats[lo] + (c.Floats[hi]-c.Floats[lo])*frac
+	case AggTypeDec:
+		if len(c.Decs) == 0 {
+			return nil
+		}
+		sort.Slice(c.Decs, func(i, j int) bool { return c.Decs[i].LessThan(c.Decs[j]) })
+		lo, hi, frac := percentileRanks(c.Fraction, len(c.Decs))
+		decVal := c.Decs[lo].Add(c.Decs[hi].Sub(c.Decs[lo]).Mul(decimal.NewFromFloat(frac)))
+		if eCtx.roundDec >= 0 {
+			decVal = decVal.Round(eCtx.roundDec)
+		}
+		return decVal
+	default:
+		return nil
+	}
+}
+
+func percentileRanks(fraction float64, count int) (int, int, float64) {
+	rank := fraction * float64(count-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return lo, hi, rank - float64(lo)
+}
+
+// Returns -1, 0, 1. Both values are expected to be of the same std type, checkAggType takes care of it.
+func compareAggOrderValues(left any, right any) int {
+	switch typedLeft := left.(type) {
+	case int64:
+		typedRight := right.(int64)
+		if typedLeft < typedRight {
+			return -1
+		} else if typedLeft > typedRight {
+			return 1
+		}
+		return 0
+	case float64:
+		typedRight := right.(float64)
+		if typedLeft < typedRight {
+			return -1
+		} else if typedLeft > typedRight {
+			return 1
+		}
+		return 0
+	case decimal.Decimal:
+		return typedLeft.Cmp(right.(decimal.Decimal))
+	case string:
+		return strings.Compare(typedLeft, right.(string))
+	case time.Time:
+		return typedLeft.Compare(right.(time.Time))
+	default:
+		return 0
+	}
+}
+
+// first(expr, order) returns expr of the row with the smallest order, last(expr, order) - with the largest one.
+// On ties, first() keeps the earliest row seen and last() keeps the latest row seen.
+func (eCtx *EvalCtx) callAggFirstLastInternal(funcName string, callExp *ast.CallExpr, aggFunc AggFuncType, args []any) (any, error) {
+	if err := eCtx.checkAgg(funcName, callExp, aggFunc); err != nil {
+		return nil, err
+	}
+	if err := CheckArgs(funcName, 2, len(args)); err != nil {
+		return nil, err
+	}
+	order, aggType, err := stdTypedAggValue(funcName, args[1])
+	if err != nil {
+		return nil, err
+	}
+	if aggType == AggTypeBool {
+		return nil, fmt.Errorf("cannot evaluate %s(), cannot order by bool value %v", funcName, args[1])
+	}
+	if err := eCtx.checkAggType(funcName, aggType, args[1]); err != nil {
+		return nil, err
+	}
+
+	c := &eCtx.firstLastCollector
+	if c.Count == 0 ||
+		aggFunc == AggFirst && compareAggOrderValues(order, c.Order) < 0 ||
+		aggFunc == AggLast && compareAggOrderValues(order, c.Order) >= 0 {
+		c.Value = args[0]
+		c.Order = order
+	}
+	c.Count++
+	return c.Value, nil
+}
+
+func (eCtx *EvalCtx) CallAggFirst(callExp *ast.CallExpr, args []any) (any, error) {
+	return eCtx.callAggFirstLastInternal("first", callExp, AggFirst, args)
+}
+
+func (eCtx *EvalCtx) CallAggLast(callExp *ast.CallExpr, args []any) (any, error) {
+	return eCtx.callAggFirstLastInternal("last", callExp, AggLast, args)
+}
+
+func (eCtx *EvalCtx) callAggBoolInternal(funcName string, callExp *ast.CallExpr, aggFunc AggFuncType, args []any) (any, error) {
+	if err := eCtx.checkAgg(funcName, callExp, aggFunc); err != nil {
+		return nil, err
+	}
+	if err := CheckArgs(funcName, 1, len(args)); err != nil {
+		return nil, err
+	}
+	boolArg, ok := args[0].(bool)
+	if !ok {
+		return nil, fmt.Errorf("cannot evaluate %s(), unexpected argument %v of unsupported type %T", funcName, args[0], args[0])
+	}
+
+	c := &eCtx.boolCollector
+	if c.Count == 0 {
+		c.Bool = boolArg
+	} else if aggFunc == AggBoolAnd {
+		c.Bool = c.Bool && boolArg
+	} else {
+		c.Bool = c.Bool || boolArg
+	}
+	c.Count++
+	return c.Bool, nil
+}
+
+func (eCtx *EvalCtx) CallAggBoolAnd(callExp *ast.CallExpr, args []any) (any, error) {
+	return eCtx.callAggBoolInternal("bool_and", callExp, AggBoolAnd, args)
+}
+
+func (eCtx *EvalCtx) CallAggBoolOr(callExp *ast.CallExpr, args []any) (any, error) {
+	return eCtx.callAggBoolInternal("bool_or", callExp, AggBoolOr, args)
+}
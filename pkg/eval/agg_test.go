@@ -78,6 +78,9 @@ func TestDetectRootArgFunc(t *testing.T) {
 	assertFuncTypeAndArgs(t, `min(t1.fieldFloat)`, AggFuncEnabled, AggMin, 1)
 	assertFuncTypeAndArgs(t, `max(t1.fieldFloat)`, AggFuncEnabled, AggMax, 1)
 	assertFuncTypeAndArgs(t, `count()`, AggFuncEnabled, AggCount, 0)
+	assertFuncTypeAndArgs(t, `count_distinct(t1.fieldStr)`, AggFuncEnabled, AggCountDistinct, 1)
+	assertFuncTypeAndArgs(t, `percentile(t1.fieldFloat, 0.9, "tdigest")`, AggFuncEnabled, AggPercentile, 3)
+	assertFuncTypeAndArgs(t, `last(t1.fieldStr, t1.fieldInt)`, AggFuncEnabled, AggLast, 2)
 	assertFuncTypeAndArgs(t, `some_func(t1.fieldFloat)`, AggFuncDisabled, AggUnknown, 0)
 }
 
@@ -423,6 +426,26 @@ func TestNoAggRows(t *testing.T) {
 	eCtx, _ = NewAggEvalCtx(aggFuncType, aggFuncArgs, nil, nil, varValuesMap)
 	assert.Equal(t, nil, eCtx.GetValue())
 	assert.Equal(t, int64(35), eCtx.GetSafeValue(int64(35)))
+
+	exp, _ = parser.ParseExpr("count_distinct(t1.fieldInt)")
+	_, aggFuncType, aggFuncArgs = DetectRootAggFunc(exp)
+	eCtx, _ = NewAggEvalCtx(aggFuncType, aggFuncArgs, nil, nil, varValuesMap)
+	assert.Equal(t, int64(0), eCtx.GetValue())
+	assert.Equal(t, int64(0), eCtx.GetSafeValue(int64(35)))
+
+	exp, _ = parser.ParseExpr("stddev(t1.fieldInt)")
+	_, aggFuncType, aggFuncArgs = DetectRootAggFunc(exp)
+	eCtx, _ = NewAggEvalCtx(aggFuncType, aggFuncArgs, nil, nil, varValuesMap)
+	assert.Equal(t, 0.0, eCtx.GetValue())
+	assert.Equal(t, 0.0, eCtx.GetSafeValue(35.0))
+
+	exp, _ = parser.ParseExpr("median(t1.fieldInt)")
+	_, aggFuncType, aggFuncArgs = DetectRootAggFunc(exp)
+	eCtx, _ = NewAggEvalCtx(aggFuncType, aggFuncArgs, nil, nil, varValuesMap)
+	assert.Equal(t, nil, eCtx.GetValue())
+	assert.Equal(t, int64(35), eCtx.GetSafeValue(int64(35)))
+	_, _ = eCtx.Eval(exp)
+	assert.Equal(t, int64(0), eCtx.GetSafeValue(int64(35)))
 }
 
 func TestNoVars(t *testing.T) {
@@ -712,3 +735,241 @@ func TestAggOverflow(t *testing.T) {
 	_, _ = eCtx.Eval(exp)
 	assert.Equal(t, int64(math.MaxInt64/2+2), eCtx.GetValue())
 }
+
+// Evaluates the agg expression once for each row of t1 values, returns GetValue()
+func evalAggRows(t *testing.T, expression string, rows []map[string]any) (any, error) {
+	exp, err := parser.ParseExpr(expression)
+	assert.Nil(t, err)
+	_, aggFuncType, aggFuncArgs := DetectRootAggFunc(exp)
+	constants := map[string]any{"true": true, "false": false}
+	eCtx, err := NewAggEvalCtx(aggFuncType, aggFuncArgs, nil, constants, nil)
+	if err != nil {
+		return nil, err
+	}
+	eCtx.SetRoundDec(2)
+	for _, row := range rows {
+		eCtx.SetVars(VarValuesMap{"t1": row})
+		if _, err := eCtx.Eval(exp); err != nil {
+			return nil, err
+		}
+	}
+	return eCtx.GetValue(), nil
+}
+
+func fieldRows(fieldName string, vals ...any) []map[string]any {
+	rows := make([]map[string]any, len(vals))
+	for i, val := range vals {
+		rows[i] = map[string]any{fieldName: val}
+	}
+	return rows
+}
+
+func TestCountDistinct(t *testing.T) {
+	r, err := evalAggRows(t, "count_distinct(t1.f)", fieldRows("f", "a", "b", "a", "c"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), r)
+
+	r, _ = evalAggRows(t, "count_distinct(t1.f)", fieldRows("f", 1, int64(1), 2))
+	assert.Equal(t, int64(2), r)
+
+	r, _ = evalAggRows(t, "count_distinct(t1.f)", fieldRows("f", decimal.RequireFromString("1.50"), decimal.RequireFromString("1.5"), decimal.NewFromInt(2)))
+	assert.Equal(t, int64(2), r)
+
+	utcTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r, _ = evalAggRows(t, "count_distinct(t1.f)", fieldRows("f", utcTime, utcTime.In(time.FixedZone("EST", -5*3600)), utcTime.Add(time.Hour)))
+	assert.Equal(t, int64(2), r)
+
+	r, _ = evalAggRows(t, "count_distinct(t1.f)", fieldRows("f", true, false, true))
+	assert.Equal(t, int64(2), r)
+
+	r, _ = evalAggRows(t, "count_distinct(t1.f)", fieldRows("f"))
+	assert.Equal(t, int64(0), r)
+
+	_, err = evalAggRows(t, "count_distinct(t1.f)", fieldRows("f", "a", 1))
+	assert.Contains(t, err.Error(), "cannot evaluate count_distinct(), it started with type string, now got int value 1")
+}
+
+func TestStdDevVariance(t *testing.T) {
+	r, err := evalAggRows(t, "variance(t1.f)", fieldRows("f", 2, 4, 4, 4, 5, 5, 7, 9))
+	assert.Nil(t, err)
+	assert.InDelta(t, 32.0/7.0, r, 1e-12)
+
+	r, _ = evalAggRows(t, "stddev(t1.f)", fieldRows("f", 2.0, 4.0, 4.0, 4.0, 5.0, 5.0, 7.0, 9.0))
+	assert.InDelta(t, math.Sqrt(32.0/7.0), r, 1e-12)
+
+	r, _ = evalAggRows(t, "stddev(t1.f)", fieldRows("f", decimal.NewFromInt(10), decimal.NewFromInt(20)))
+	assert.InDelta(t, math.Sqrt(50.0), r, 1e-12)
+
+	// Welford does not lose precision on large values with small spread
+	r, _ = evalAggRows(t, "variance(t1.f)", fieldRows("f", 1e9+4, 1e9+7, 1e9+13, 1e9+16))
+	assert.InDelta(t, 30.0, r, 1e-6)
+
+	r, _ = evalAggRows(t, "stddev(t1.f)", fieldRows("f", 5))
+	assert.Equal(t, 0.0, r)
+
+	r, _ = evalAggRows(t, "variance(t1.f)", fieldRows("f"))
+	assert.Equal(t, 0.0, r)
+
+	_, err = evalAggRows(t, "stddev(t1.f)", fieldRows("f", "a"))
+	assert.Contains(t, err.Error(), "cannot evaluate stddev(), unexpected argument a of unsupported type string")
+}
+
+func TestPercentile(t *testing.T) {
+	r, err := evalAggRows(t, "median(t1.f)", fieldRows("f", 5, 1, 3))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), r)
+
+	// Int interpolation is truncated
+	r, _ = evalAggRows(t, "median(t1.f)", fieldRows("f", 4, 1, 2, 3))
+	assert.Equal(t, int64(2), r)
+
+	r, _ = evalAggRows(t, "median(t1.f)", fieldRows("f", 4.0, 1.0, 2.0, 3.0))
+	assert.Equal(t, 2.5, r)
+
+	r, _ = evalAggRows(t, "percentile(t1.f, 0.9)", fieldRows("f", 10.0, 20.0, 30.0, 40.0, 50.0))
+	assert.InDelta(t, 46.0, r, 1e-12)
+
+	r, _ = evalAggRows(t, "percentile(t1.f, 1)", fieldRows("f", 10.0, 50.0, 30.0))
+	assert.Equal(t, 50.0, r)
+
+	r, _ = evalAggRows(t, "percentile(t1.f, 0.25)", fieldRows("f", decimal.NewFromInt(1), decimal.NewFromInt(2), decimal.NewFromInt(4)))
+	assert.Equal(t, "1.5", r.(decimal.Decimal).String())
+
+	r, _ = evalAggRows(t, "median(t1.f)", fieldRows("f"))
+	assert.Nil(t, r)
+
+	// t-digest is exact for small sets and close for large ones
+	r, _ = evalAggRows(t, `median(t1.f, "tdigest")`, fieldRows("f", 5.0, 1.0, 3.0))
+	assert.Equal(t, 3.0, r)
+
+	vals := make([]any, 100000)
+	for i := range vals {
+		vals[i] = int64((i * 7919) % 100000)
+	}
+	r, _ = evalAggRows(t, `percentile(t1.f, 0.99, "tdigest")`, fieldRows("f", vals...))
+	assert.InDelta(t, 98999, r, 100)
+	r, _ = evalAggRows(t, `percentile(t1.f, 0.99, "exact")`, fieldRows("f", vals...))
+	assert.Equal(t, int64(98999), r)
+
+	_, err = evalAggRows(t, "percentile(t1.f)", fieldRows("f", 1.0))
+	assert.Contains(t, err.Error(), "percentile must have two or three parameters")
+	_, err = evalAggRows(t, "percentile(t1.f, t1.p)", fieldRows("f", 1.0))
+	assert.Contains(t, err.Error(), "percentile second parameter must be a constant number")
+	_, err = evalAggRows(t, "percentile(t1.f, 1.5)", fieldRows("f", 1.0))
+	assert.Contains(t, err.Error(), "percentile second parameter must be between 0 and 1, got 1.5")
+	_, err = evalAggRows(t, `median(t1.f, "approx")`, fieldRows("f", 1.0))
+	assert.Contains(t, err.Error(), "median mode must be exact or tdigest, got approx")
+	_, err = evalAggRows(t, "median(t1.f, 1, 2)", fieldRows("f", 1.0))
+	assert.Contains(t, err.Error(), "median must have one or two parameters")
+	_, err = evalAggRows(t, "median(t1.f)", fieldRows("f", true))
+	assert.Contains(t, err.Error(), "cannot evaluate median(), unexpected argument true of unsupported type bool")
+}
+
+func TestFirstLast(t *testing.T) {
+	rows := []map[string]any{
+		{"v": "b", "o": int64(2)},
+		{"v": "a", "o": int64(1)},
+		{"v": "a2", "o": int64(1)},
+		{"v": "c", "o": int64(3)},
+		{"v": "c2", "o": int64(3)},
+	}
+	r, err := evalAggRows(t, "first(t1.v, t1.o)", rows)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", r)
+
+	r, _ = evalAggRows(t, "last(t1.v, t1.o)", rows)
+	assert.Equal(t, "c2", r)
+
+	day1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows = []map[string]any{
+		{"v": 10.5, "o": day1.AddDate(0, 0, 1)},
+		{"v": 20.5, "o": day1},
+	}
+	r, _ = evalAggRows(t, "first(t1.v, t1.o)", rows)
+	assert.Equal(t, 20.5, r)
+
+	r, _ = evalAggRows(t, "last(t1.v, t1.o)", fieldRows("v"))
+	assert.Nil(t, r)
+
+	_, err = evalAggRows(t, "first(t1.v, t1.o)", []map[string]any{{"v": 1, "o": "x"}, {"v": 2, "o": 1}})
+	assert.Contains(t, err.Error(), "cannot evaluate first(), it started with type string, now got int value 1")
+
+	_, err = evalAggRows(t, "last(t1.v, t1.o)", []map[string]any{{"v": 1, "o": true}})
+	assert.Contains(t, err.Error(), "cannot evaluate last(), cannot order by bool value true")
+
+	_, err = evalAggRows(t, "last(t1.v)", []map[string]any{{"v": 1}})
+	assert.Contains(t, err.Error(), "cannot evaluate last(), requires 2 args, 1 supplied")
+}
+
+func TestBoolAndOr(t *testing.T) {
+	r, err := evalAggRows(t, "bool_and(t1.f)", fieldRows("f", true, true))
+	assert.Nil(t, err)
+	assert.Equal(t, true, r)
+
+	r, _ = evalAggRows(t, "bool_and(t1.f > 1)", fieldRows("f", 2, 1, 3))
+	assert.Equal(t, false, r)
+
+	r, _ = evalAggRows(t, "bool_or(t1.f > 2)", fieldRows("f", 2, 1, 3))
+	assert.Equal(t, true, r)
+
+	r, _ = evalAggRows(t, "bool_or(t1.f)", fieldRows("f", false))
+	assert.Equal(t, false, r)
+
+	r, _ = evalAggRows(t, "bool_or(t1.f)", fieldRows("f"))
+	assert.Nil(t, r)
+
+	_, err = evalAggRows(t, "bool_and(t1.f)", fieldRows("f", 1))
+	assert.Contains(t, err.Error(), "cannot evaluate bool_and(), unexpected argument 1 of unsupported type int")
+}
+
+func TestAggSkipNull(t *testing.T) {
+	r, err := evalAggRows(t, "sum(t1.f)", fieldRows("f", int64(1), nil, int64(2), nil))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), r)
+
+	r, _ = evalAggRows(t, "avg(t1.f)", fieldRows("f", nil, 1.0, 2.0))
+	assert.Equal(t, 1.5, r)
+
+	r, _ = evalAggRows(t, "min(t1.f)", fieldRows("f", "b", nil, "a", nil))
+	assert.Equal(t, "a", r)
+
+	r, _ = evalAggRows(t, "max(t1.f + 1)", fieldRows("f", int64(1), nil))
+	assert.Equal(t, int64(2), r)
+
+	r, _ = evalAggRows(t, "string_agg(t1.f, \",\")", fieldRows("f", "a", nil, "b"))
+	assert.Equal(t, "a,b", r)
+
+	r, _ = evalAggRows(t, "count_if(t1.f > 1)", fieldRows("f", int64(2), nil, int64(3)))
+	assert.Equal(t, int64(2), r)
+
+	r, _ = evalAggRows(t, "count_if(t1.f)", fieldRows("f", true, nil))
+	assert.Equal(t, int64(1), r)
+
+	r, _ = evalAggRows(t, "count_distinct(t1.f)", fieldRows("f", nil, "a", nil))
+	assert.Equal(t, int64(1), r)
+
+	r, _ = evalAggRows(t, "median(t1.f)", fieldRows("f", int64(1), nil, int64(3)))
+	assert.Equal(t, int64(2), r)
+
+	r, _ = evalAggRows(t, "first(t1.v, t1.o)", []map[string]any{{"v": "a", "o": nil}, {"v": nil, "o": int64(1)}, {"v": "b", "o": int64(2)}})
+	assert.Equal(t, "b", r)
+
+	r, _ = evalAggRows(t, "bool_and(t1.f)", fieldRows("f", true, nil))
+	assert.Equal(t, true, r)
+
+	// Only nulls: same as no rows
+	r, _ = evalAggRows(t, "sum(t1.f)", fieldRows("f", nil, nil))
+	assert.Equal(t, int64(0), r)
+
+	r, _ = evalAggRows(t, "min(t1.f)", fieldRows("f", nil))
+	assert.Nil(t, r)
+
+	r, _ = evalAggRows(t, "percentile(t1.f, 0.5)", fieldRows("f", nil))
+	assert.Nil(t, r)
+
+	// Agg usage is still checked
+	exp, _ := parser.ParseExpr("sum(t1.f)")
+	eCtx := NewPlainEvalCtx(nil, nil, VarValuesMap{"t1": {"f": nil}})
+	_, err = eCtx.Eval(exp)
+	assert.Contains(t, err.Error(), "cannot evaluate sum(), context aggregate not enabled")
+}
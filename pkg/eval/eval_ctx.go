@@ -72,20 +72,26 @@ func (vars *VarValuesMap) Names() string {
 }
 
 type EvalCtx struct {
-	aggFunc            AggFuncType
-	aggType            AggDataType
-	aggCallExp         *ast.CallExpr
-	count              int64
-	stringAggCollector StringAggCollector
-	sumCollector       SumCollector
-	avgCollector       AvgCollector
-	minCollector       MinCollector
-	maxCollector       MaxCollector
-	value              any
-	aggEnabled         AggEnabledType
+	aggFunc                AggFuncType
+	aggType                AggDataType
+	aggCallExp             *ast.CallExpr
+	count                  int64
+	stringAggCollector     StringAggCollector
+	sumCollector           SumCollector
+	avgCollector           AvgCollector
+	minCollector           MinCollector
+	maxCollector           MaxCollector
+	countDistinctCollector CountDistinctCollector
+	varianceCollector      VarianceCollector
+	percentileCollector    PercentileCollector
+	firstLastCollector     FirstLastCollector
+	boolCollector          BoolCollector
+	value                  any
+	aggValue               any // Last value returned by the agg function, kept for rows with null args
+	aggEnabled             AggEnabledType
 	// If >=0, round all intermediate dec calculations to this number of decimal digits.
-	// This approach does not work for: avg(decimal2*decimal4) because it is not clear how to round decimal2*decimal4
-	// Users have to stick to one precision, unfortunately.
+	// Callers pass the scale of the target field (2 for decimal2, s for decimal(p,s)), so decimal(18,6)*decimal2
+	// written to a decimal(18,6) field keeps 6 digits. Mixing scales within one expression still rounds to the target one.
 	roundDec int32
 	// Provided by caller
 	evalFunctions map[string]EvalFunction
@@ -105,22 +111,41 @@ func (eCtx *EvalCtx) SetRoundDec(roundDec int32) {
 	eCtx.roundDec = roundDec
 }
 
+// Aggregates that return zero, not nil, when there were no rows
+func (eCtx *EvalCtx) isAggWithZeroDefault() bool {
+	if eCtx.aggEnabled != AggFuncEnabled {
+		return false
+	}
+	switch eCtx.aggFunc {
+	case AggCount, AggCountIf, AggSum, AggSumIf, AggAvg, AggAvgIf, AggCountDistinct, AggStdDev, AggVariance:
+		return true
+	default:
+		return false
+	}
+}
+
 func (eCtx *EvalCtx) GetValue() any {
-	if eCtx.aggEnabled == AggFuncEnabled && (eCtx.aggFunc == AggCount || eCtx.aggFunc == AggCountIf || eCtx.aggFunc == AggSum || eCtx.aggFunc == AggSumIf || eCtx.aggFunc == AggAvg || eCtx.aggFunc == AggAvgIf) && eCtx.value == nil {
+	if eCtx.isAggWithZeroDefault() && eCtx.value == nil {
+		if eCtx.aggFunc == AggStdDev || eCtx.aggFunc == AggVariance {
+			return float64(0.0)
+		}
 		return int64(0)
 	}
+	if eCtx.aggEnabled == AggFuncEnabled && (eCtx.aggFunc == AggMedian || eCtx.aggFunc == AggPercentile) && eCtx.value != nil {
+		return eCtx.percentileValue()
+	}
 	return eCtx.value
 }
 
 func (eCtx *EvalCtx) GetSafeValue(defaultValue any) any {
-	if eCtx.aggEnabled == AggFuncEnabled && (eCtx.aggFunc == AggCount || eCtx.aggFunc == AggCountIf || eCtx.aggFunc == AggSum || eCtx.aggFunc == AggSumIf || eCtx.aggFunc == AggAvg || eCtx.aggFunc == AggAvgIf) {
+	if eCtx.isAggWithZeroDefault() {
 		return eCtx.GetValue()
 	}
 	if eCtx.value == nil {
 		return defaultValue
 	}
 
-	return eCtx.value
+	return eCtx.GetValue()
 }
 
 // Not ready to make these limits/defaults public
@@ -169,15 +194,17 @@ func defaultBigint() *big.Int {
 
 func newPlainEvalCtxInternal(aggEnabled AggEnabledType) *EvalCtx {
 	return &EvalCtx{
-		aggFunc:            AggUnknown,
-		aggType:            AggTypeUnknown,
-		aggEnabled:         aggEnabled,
-		stringAggCollector: StringAggCollector{Separator: "", Sb: strings.Builder{}},
-		sumCollector:       SumCollector{Dec: defaultDecimal()},
-		avgCollector:       AvgCollector{Dec: defaultDecimal(), Int: defaultBigint()},
-		minCollector:       MinCollector{Int: maxSupportedInt, Float: maxSupportedFloat, Dec: maxSupportedDecimal(), Str: ""},
-		maxCollector:       MaxCollector{Int: minSupportedInt, Float: minSupportedFloat, Dec: minSupportedDecimal(), Str: ""},
-		roundDec:           -1,
+		aggFunc:                AggUnknown,
+		aggType:                AggTypeUnknown,
+		aggEnabled:             aggEnabled,
+		stringAggCollector:     StringAggCollector{Separator: "", Sb: strings.Builder{}},
+		sumCollector:           SumCollector{Dec: defaultDecimal()},
+		avgCollector:           AvgCollector{Dec: defaultDecimal(), Int: defaultBigint()},
+		minCollector:           MinCollector{Int: maxSupportedInt, Float: maxSupportedFloat, Dec: maxSupportedDecimal(), Str: ""},
+		maxCollector:           MaxCollector{Int: minSupportedInt, Float: minSupportedFloat, Dec: minSupportedDecimal(), Str: ""},
+		countDistinctCollector: CountDistinctCollector{Values: map[any]struct{}{}},
+		percentileCollector:    PercentileCollector{Fraction: 0.5, Mode: PercentileModeExact},
+		roundDec:               -1,
 	}
 }
 
@@ -206,6 +233,17 @@ func NewAggEvalCtx(aggFuncType AggFuncType, aggFuncArgs []ast.Expr, functions ma
 		}
 		eCtx.aggType = AggTypeString
 	}
+
+	if aggFuncType == AggMedian || aggFuncType == AggPercentile {
+		var percentileErr error
+		eCtx.percentileCollector.Fraction, eCtx.percentileCollector.Mode, percentileErr = getAggPercentileArgs(aggFuncType, aggFuncArgs)
+		if percentileErr != nil {
+			return nil, percentileErr
+		}
+		if eCtx.percentileCollector.Mode == PercentileModeTDigest {
+			eCtx.percentileCollector.Digest = NewTDigest()
+		}
+	}
 	return eCtx, nil
 }
 
@@ -503,12 +541,16 @@ func (eCtx *EvalCtx) EvalBinaryByteSliceToByteSlice(valLeftVolatile any, op toke
 	return false, nil
 }
 
-func (eCtx *EvalCtx) EvalUnaryBoolNot(exp ast.Expr) (bool, error) {
+func (eCtx *EvalCtx) EvalUnaryBoolNot(exp ast.Expr) (any, error) {
 	valVolatile, err := eCtx.Eval(exp)
 	if err != nil {
 		return false, err
 	}
 
+	if valVolatile == nil {
+		return nil, nil
+	}
+
 	val, ok := valVolatile.(bool)
 	if !ok {
 		return false, fmt.Errorf("cannot evaluate unary bool not expression with %T on the right", valVolatile)
@@ -524,6 +566,8 @@ func (eCtx *EvalCtx) EvalUnaryMinus(exp ast.Expr) (any, error) {
 	}
 
 	switch typedVal := valVolatile.(type) {
+	case nil:
+		return nil, nil
 	case int:
 		return int64(-typedVal), nil
 	case int16:
@@ -593,6 +637,14 @@ func (eCtx *EvalCtx) EvalBinaryStringToBool(valLeftVolatile any, op token.Token,
 
 func (eCtx *EvalCtx) EvalFunc(callExp *ast.CallExpr, funcName string, args []any) (any, error) {
 	var err error
+	if aggFunc := StringToAggFunc(funcName); aggFunc != AggUnknown && isNullAggArg(aggFunc, args) {
+		// Evaluating args has overwritten eCtx.value, restore the agg value
+		if err := eCtx.checkAgg(funcName, callExp, eCtx.aggFunc); err != nil {
+			return nil, err
+		}
+		eCtx.value = eCtx.aggValue
+		return eCtx.value, nil
+	}
 	switch funcName {
 	// Aggregate functions, this is part of evalcore
 	case "string_agg":
@@ -619,6 +671,24 @@ func (eCtx *EvalCtx) EvalFunc(callExp *ast.CallExpr, funcName string, args []any
 		eCtx.value, err = eCtx.CallAggMinIf(callExp, args)
 	case "max_if":
 		eCtx.value, err = eCtx.CallAggMaxIf(callExp, args)
+	case "count_distinct":
+		eCtx.value, err = eCtx.CallAggCountDistinct(callExp, args)
+	case "stddev":
+		eCtx.value, err = eCtx.CallAggStdDev(callExp, args)
+	case "variance":
+		eCtx.value, err = eCtx.CallAggVariance(callExp, args)
+	case "median":
+		eCtx.value, err = eCtx.CallAggMedian(callExp, args)
+	case "percentile":
+		eCtx.value, err = eCtx.CallAggPercentile(callExp, args)
+	case "first":
+		eCtx.value, err = eCtx.CallAggFirst(callExp, args)
+	case "last":
+		eCtx.value, err = eCtx.CallAggLast(callExp, args)
+	case "bool_and":
+		eCtx.value, err = eCtx.CallAggBoolAnd(callExp, args)
+	case "bool_or":
+		eCtx.value, err = eCtx.CallAggBoolOr(callExp, args)
 
 	default:
 		// Caller-provided functions
@@ -629,10 +699,18 @@ func (eCtx *EvalCtx) EvalFunc(callExp *ast.CallExpr, funcName string, args []any
 		}
 		return nil, fmt.Errorf("cannot evaluate unsupported func '%s'", funcName)
 	}
+	if err == nil {
+		eCtx.aggValue = eCtx.value
+	}
 	return eCtx.value, err
 }
 
 func (eCtx *EvalCtx) evalBinaryArithmeticExp(valLeftVolatile any, exp *ast.BinaryExpr, valRightVolatile any) (any, error) {
+	if valLeftVolatile == nil || valRightVolatile == nil {
+		// Null in, null out
+		eCtx.value = nil
+		return eCtx.value, nil
+	}
 	switch valLeftVolatile.(type) {
 	case string:
 		var err error
@@ -660,7 +738,32 @@ func (eCtx *EvalCtx) evalBinaryArithmeticExp(valLeftVolatile any, exp *ast.Binar
 	}
 }
 
+// Three-valued logic, as in SQL: false && null is false, true || null is true, other combinations with null are null
+func (eCtx *EvalCtx) evalNullBinaryBool(valLeftVolatile any, op token.Token, valRightVolatile any) (any, error) {
+	for _, valVolatile := range []any{valLeftVolatile, valRightVolatile} {
+		if valVolatile == nil {
+			continue
+		}
+		val, ok := valVolatile.(bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot evaluate binary bool expression '%v(%T) %v %v(%T)', invalid arg", valLeftVolatile, valLeftVolatile, op, valRightVolatile, valRightVolatile)
+		}
+		if op == token.LAND && !val {
+			return false, nil
+		}
+		if op == token.LOR && val {
+			return true, nil
+		}
+	}
+	return nil, nil
+}
+
 func (eCtx *EvalCtx) evalBinaryBoolToBoolExp(valLeftVolatile any, exp *ast.BinaryExpr, valRightVolatile any) (any, error) {
+	if valLeftVolatile == nil || valRightVolatile == nil {
+		var err error
+		eCtx.value, err = eCtx.evalNullBinaryBool(valLeftVolatile, exp.Op, valRightVolatile)
+		return eCtx.value, err
+	}
 	switch valLeftTyped := valLeftVolatile.(type) {
 	case bool:
 		var err error
@@ -671,6 +774,7 @@ func (eCtx *EvalCtx) evalBinaryBoolToBoolExp(valLeftVolatile any, exp *ast.Binar
 	}
 }
 func (eCtx *EvalCtx) evalBinaryCompareExp(valLeftVolatile any, exp *ast.BinaryExpr, valRightVolatile any) (any, error) {
+	// Unlike arithmetic and logical ops, comparisons with null are never null: use is_null() if SQL semantics is needed
 	if (valLeftVolatile == nil && valRightVolatile != nil) || (valLeftVolatile != nil && valRightVolatile == nil) {
 		// Cannot be compared, NEQ returns true, all other ops return false
 		eCtx.value = (exp.Op == token.NEQ)
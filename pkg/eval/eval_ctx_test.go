@@ -192,6 +192,29 @@ func TestBool(t *testing.T) {
 	assertEvalError(t, "1 || true", "cannot perform binary op || against int64 left", varValuesMap)
 }
 
+func TestNull(t *testing.T) {
+	varValuesMap := VarValuesMap{"t1": {"n": nil, "i": int64(1), "b": true, "s": "a"}}
+	assertEqual(t, `t1.n + 1`, nil, varValuesMap)
+	assertEqual(t, `t1.i * t1.n`, nil, varValuesMap)
+	assertEqual(t, `t1.s + t1.n`, nil, varValuesMap)
+	assertEqual(t, `-t1.n`, nil, varValuesMap)
+	assertEqual(t, `!t1.n`, nil, varValuesMap)
+
+	// Three-valued logic
+	assertEqual(t, `t1.n && false`, false, varValuesMap)
+	assertEqual(t, `true && t1.n`, nil, varValuesMap)
+	assertEqual(t, `t1.n || t1.b`, true, varValuesMap)
+	assertEqual(t, `false || t1.n`, nil, varValuesMap)
+	assertEqual(t, `t1.n || t1.n`, nil, varValuesMap)
+	assertEvalError(t, "t1.n || 1", "cannot evaluate binary bool expression '<nil>(<nil>) || 1(int64)', invalid arg", varValuesMap)
+
+	// Comparisons are never null
+	assertEqual(t, `t1.n == t1.n`, true, varValuesMap)
+	assertEqual(t, `t1.n != 1`, true, varValuesMap)
+	assertEqual(t, `t1.n > 1`, false, varValuesMap)
+	assertEqual(t, `t1.n + 1 == t1.n`, true, varValuesMap)
+}
+
 func TestUnaryMinus(t *testing.T) {
 	varValuesMap := VarValuesMap{
 		"t1": {
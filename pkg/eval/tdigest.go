@@ -0,0 +1,118 @@
+package eval
+
+import (
+	"math"
+	"sort"
+)
+
+// Merging t-digest (Dunning, Ertl): values are buffered and periodically merged into centroids.
+// Centroids near the tails are kept small, so extreme percentiles stay accurate,
+// memory is bounded by compression regardless of the number of values.
+
+const tDigestCompression float64 = 100
+
+type tDigestCentroid struct {
+	mean   float64
+	weight float64
+}
+
+type TDigest struct {
+	centroids   []tDigestCentroid
+	buffer      []tDigestCentroid
+	totalWeight float64
+	min         float64
+	max         float64
+}
+
+func NewTDigest() *TDigest {
+	return &TDigest{
+		centroids: make([]tDigestCentroid, 0),
+		buffer:    make([]tDigestCentroid, 0, int(tDigestCompression)*5),
+		min:       math.Inf(1),
+		max:       math.Inf(-1),
+	}
+}
+
+func (td *TDigest) Count() int64 {
+	return int64(td.totalWeight + float64(len(td.buffer)))
+}
+
+func (td *TDigest) Add(val float64) {
+	if val < td.min {
+		td.min = val
+	}
+	if val > td.max {
+		td.max = val
+	}
+	td.buffer = append(td.buffer, tDigestCentroid{mean: val, weight: 1})
+	if len(td.buffer) == cap(td.buffer) {
+		td.merge()
+	}
+}
+
+func (td *TDigest) merge() {
+	if len(td.buffer) == 0 {
+		return
+	}
+	all := append(td.centroids, td.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+	td.totalWeight += float64(len(td.buffer))
+	td.buffer = td.buffer[:0]
+
+	merged := make([]tDigestCentroid, 0, len(td.centroids)+1)
+	cur := all[0]
+	weightBefore := 0.0
+	for _, c := range all[1:] {
+		proposedWeight := cur.weight + c.weight
+		q := (weightBefore + proposedWeight/2) / td.totalWeight
+		maxWeight := 4 * td.totalWeight * q * (1 - q) / tDigestCompression
+		if proposedWeight <= maxWeight {
+			cur.mean += (c.mean - cur.mean) * c.weight / proposedWeight
+			cur.weight = proposedWeight
+		} else {
+			weightBefore += cur.weight
+			merged = append(merged, cur)
+			cur = c
+		}
+	}
+	td.centroids = append(merged, cur)
+}
+
+// Interpolates between centroid centers, and between the extreme centroids and exact min/max.
+// Ranks are scaled to (n-1) like in exact mode, so both modes agree while every centroid holds one value.
+func (td *TDigest) Quantile(q float64) float64 {
+	td.merge()
+	if len(td.centroids) == 0 {
+		return 0.0
+	}
+	if len(td.centroids) == 1 || q <= 0 {
+		if q >= 1 {
+			return td.max
+		}
+		if q <= 0 {
+			return td.min
+		}
+		return td.centroids[0].mean
+	}
+	if q >= 1 {
+		return td.max
+	}
+
+	target := q*(td.totalWeight-1) + 0.5
+	first := td.centroids[0]
+	if target < first.weight/2 {
+		return td.min + (first.mean-td.min)*target/(first.weight/2)
+	}
+	weightSoFar := first.weight / 2
+	for i := 1; i < len(td.centroids); i++ {
+		prev := td.centroids[i-1]
+		c := td.centroids[i]
+		step := (prev.weight + c.weight) / 2
+		if target < weightSoFar+step {
+			return prev.mean + (c.mean-prev.mean)*(target-weightSoFar)/step
+		}
+		weightSoFar += step
+	}
+	last := td.centroids[len(td.centroids)-1]
+	return last.mean + (td.max-last.mean)*(target-weightSoFar)/(last.weight/2)
+}
@@ -0,0 +1,38 @@
+package evalcapi
+
+import "sync"
+
+// Function args parsed or compiled from strings (regex patterns, zone names, holiday lists) are usually
+// literals or script params, so an expression parses them once, not for every row.
+// Values built from field values are not cached after the cache is full.
+const parsedArgCacheMaxSize int = 1000
+
+type parsedArgCache[T any] struct {
+	mutex sync.RWMutex
+	items map[string]T
+}
+
+func newParsedArgCache[T any]() *parsedArgCache[T] {
+	return &parsedArgCache[T]{items: map[string]T{}}
+}
+
+func (c *parsedArgCache[T]) get(key string, parse func(string) (T, error)) (T, error) {
+	c.mutex.RLock()
+	item, ok := c.items[key]
+	c.mutex.RUnlock()
+	if ok {
+		return item, nil
+	}
+
+	item, err := parse(key)
+	if err != nil {
+		return item, err
+	}
+
+	c.mutex.Lock()
+	if len(c.items) < parsedArgCacheMaxSize {
+		c.items[key] = item
+	}
+	c.mutex.Unlock()
+	return item, nil
+}
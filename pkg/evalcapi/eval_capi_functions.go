@@ -3,29 +3,98 @@ package evalcapi
 import "github.com/capillariesio/capillaries/pkg/eval"
 
 var CapillariesEvalFunctions = map[string]eval.EvalFunction{
-	"math.Sqrt":          callMathSqrt,
-	"math.Round":         callMathRound,
-	"len":                callLen,
-	"string":             callString,
-	"float":              callFloat,
-	"int":                callInt,
-	"decimal2":           callDecimal2,
-	"int.iif":            callIntIif,
-	"float.iif":          callFloatIif,
-	"decimal2.iif":       callDecimal2Iif,
-	"string.iif":         callStringIif,
-	"time.iif":           callTimeIif,
-	"time.Parse":         callTimeParse,
-	"time.Format":        callTimeFormat,
-	"time.Date":          callTimeDate,
-	"time.Now":           callTimeNow,
-	"time.Unix":          callTimeUnix,
-	"time.UnixMilli":     callTimeUnixMilli,
-	"time.DiffMilli":     callTimeDiffMilli,
-	"time.Before":        callTimeBefore,
-	"time.After":         callTimeAfter,
-	"time.FixedZone":     callTimeFixedZone,
-	"re.MatchString":     callReMatchString,
-	"strings.ReplaceAll": callStringsReplaceAll,
-	"fmt.Sprintf":        callFmtSprintf,
+	"math.Sqrt":                callMathSqrt,
+	"math.Round":               callMathRound,
+	"len":                      callLen,
+	"string":                   callString,
+	"float":                    callFloat,
+	"int":                      callInt,
+	"decimal2":                 callDecimal2,
+	"int.iif":                  callIntIif,
+	"float.iif":                callFloatIif,
+	"decimal2.iif":             callDecimal2Iif,
+	"string.iif":               callStringIif,
+	"time.iif":                 callTimeIif,
+	"time.Parse":               callTimeParse,
+	"time.Format":              callTimeFormat,
+	"time.Date":                callTimeDate,
+	"time.Now":                 callTimeNow,
+	"time.Unix":                callTimeUnix,
+	"time.UnixMilli":           callTimeUnixMilli,
+	"time.DiffMilli":           callTimeDiffMilli,
+	"time.Before":              callTimeBefore,
+	"time.After":               callTimeAfter,
+	"time.FixedZone":           callTimeFixedZone,
+	"time.AddDate":             callTimeAddDate,
+	"time.Add":                 callTimeAdd,
+	"time.Truncate":            callTimeTruncate,
+	"time.Year":                callTimeYear,
+	"time.Month":               callTimeMonth,
+	"time.Day":                 callTimeDay,
+	"time.Weekday":             callTimeWeekday,
+	"time.YearDay":             callTimeYearDay,
+	"time.Quarter":             callTimeQuarter,
+	"time.EndOfMonth":          callTimeEndOfMonth,
+	"time.In":                  callTimeIn,
+	"time.BusinessDaysBetween": callTimeBusinessDaysBetween,
+	"re.MatchString":           callReMatchString,
+	"re.FindString":            callReFindString,
+	"re.ReplaceAllString":      callReReplaceAllString,
+	"re.FindStringSubmatch":    callReFindStringSubmatch,
+	"strings.ReplaceAll":       callStringsReplaceAll,
+	"strings.ToUpper":          callStringsToUpper,
+	"strings.ToLower":          callStringsToLower,
+	"strings.TrimSpace":        callStringsTrimSpace,
+	"strings.Trim":             callStringsTrim,
+	"strings.HasPrefix":        callStringsHasPrefix,
+	"strings.HasSuffix":        callStringsHasSuffix,
+	"strings.Contains":         callStringsContains,
+	"strings.Index":            callStringsIndex,
+	"strings.Split":            callStringsSplit,
+	"strings.Repeat":           callStringsRepeat,
+	"strings.Title":            callStringsTitle,
+	"substr":                   callSubstr,
+	"fmt.Sprintf":              callFmtSprintf,
+	"is_null":                  callIsNull,
+	"coalesce":                 callCoalesce,
+}
+
+// Arg and return types of a function, so script validation can report misuse with a clear message
+type EvalFunctionSignature struct {
+	ArgTypes         []TableFieldType // FieldTypeUnknown accepts any type
+	ReturnType       TableFieldType
+	OptionalArgCount int // Trailing ArgTypes that can be omitted
+}
+
+var CapillariesEvalFunctionSignatures = map[string]EvalFunctionSignature{
+	"re.MatchString":           {[]TableFieldType{FieldTypeString, FieldTypeString}, FieldTypeBool, 0},
+	"re.FindString":            {[]TableFieldType{FieldTypeString, FieldTypeString}, FieldTypeString, 0},
+	"re.ReplaceAllString":      {[]TableFieldType{FieldTypeString, FieldTypeString, FieldTypeString}, FieldTypeString, 0},
+	"re.FindStringSubmatch":    {[]TableFieldType{FieldTypeString, FieldTypeString, FieldTypeInt}, FieldTypeString, 0},
+	"strings.ReplaceAll":       {[]TableFieldType{FieldTypeString, FieldTypeString, FieldTypeString}, FieldTypeString, 0},
+	"strings.ToUpper":          {[]TableFieldType{FieldTypeString}, FieldTypeString, 0},
+	"strings.ToLower":          {[]TableFieldType{FieldTypeString}, FieldTypeString, 0},
+	"strings.TrimSpace":        {[]TableFieldType{FieldTypeString}, FieldTypeString, 0},
+	"strings.Trim":             {[]TableFieldType{FieldTypeString, FieldTypeString}, FieldTypeString, 0},
+	"strings.HasPrefix":        {[]TableFieldType{FieldTypeString, FieldTypeString}, FieldTypeBool, 0},
+	"strings.HasSuffix":        {[]TableFieldType{FieldTypeString, FieldTypeString}, FieldTypeBool, 0},
+	"strings.Contains":         {[]TableFieldType{FieldTypeString, FieldTypeString}, FieldTypeBool, 0},
+	"strings.Index":            {[]TableFieldType{FieldTypeString, FieldTypeString}, FieldTypeInt, 0},
+	"strings.Split":            {[]TableFieldType{FieldTypeString, FieldTypeString, FieldTypeInt}, FieldTypeString, 0},
+	"strings.Repeat":           {[]TableFieldType{FieldTypeString, FieldTypeInt}, FieldTypeString, 0},
+	"strings.Title":            {[]TableFieldType{FieldTypeString}, FieldTypeString, 0},
+	"substr":                   {[]TableFieldType{FieldTypeString, FieldTypeInt, FieldTypeInt}, FieldTypeString, 0},
+	"time.AddDate":             {[]TableFieldType{FieldTypeDateTime, FieldTypeInt, FieldTypeInt, FieldTypeInt}, FieldTypeDateTime, 0},
+	"time.Add":                 {[]TableFieldType{FieldTypeDateTime, FieldTypeString}, FieldTypeDateTime, 0},
+	"time.Truncate":            {[]TableFieldType{FieldTypeDateTime, FieldTypeString}, FieldTypeDateTime, 0},
+	"time.Year":                {[]TableFieldType{FieldTypeDateTime}, FieldTypeInt, 0},
+	"time.Month":               {[]TableFieldType{FieldTypeDateTime}, FieldTypeInt, 0},
+	"time.Day":                 {[]TableFieldType{FieldTypeDateTime}, FieldTypeInt, 0},
+	"time.Weekday":             {[]TableFieldType{FieldTypeDateTime}, FieldTypeInt, 0},
+	"time.YearDay":             {[]TableFieldType{FieldTypeDateTime}, FieldTypeInt, 0},
+	"time.Quarter":             {[]TableFieldType{FieldTypeDateTime}, FieldTypeInt, 0},
+	"time.EndOfMonth":          {[]TableFieldType{FieldTypeDateTime}, FieldTypeDateTime, 0},
+	"time.In":                  {[]TableFieldType{FieldTypeDateTime, FieldTypeString}, FieldTypeDateTime, 0},
+	"time.BusinessDaysBetween": {[]TableFieldType{FieldTypeDateTime, FieldTypeDateTime, FieldTypeString}, FieldTypeInt, 1},
+	"is_null":                  {[]TableFieldType{FieldTypeUnknown}, FieldTypeBool, 0},
 }
@@ -1,5 +1,11 @@
 package evalcapi
 
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
 type TableFieldType string
 
 const (
@@ -12,11 +18,57 @@ const (
 	FieldTypeUnknown  TableFieldType = "unknown"
 )
 
+// decimal(p,s): up to p digits, s of them after the point, like SQL DECIMAL(p,s). Key string is sign+(p-s)digit+point+s.
+// 38 is the Parquet and Cassandra driver comfort zone: 16-byte unscaled values.
+const MaxDecimalPrecision int32 = 38
+
+var reDecimalFieldType = regexp.MustCompile(`^decimal\(([0-9]{1,2}),([0-9]{1,2})\)$`)
+
+func DecimalFieldType(precision int32, scale int32) TableFieldType {
+	return TableFieldType(fmt.Sprintf("decimal(%d,%d)", precision, scale))
+}
+
+// Returns precision and scale of a valid decimal(p,s) type, decimal2 is not one of them
+func ParseDecimalFieldType(fieldType TableFieldType) (int32, int32, bool) {
+	match := reDecimalFieldType.FindStringSubmatch(string(fieldType))
+	if match == nil {
+		return 0, 0, false
+	}
+	precision, _ := strconv.Atoi(match[1])
+	scale, _ := strconv.Atoi(match[2])
+	if precision < 1 || int32(precision) > MaxDecimalPrecision || scale > precision {
+		return 0, 0, false
+	}
+	return int32(precision), int32(scale), true
+}
+
+// decimal2 and decimal(p,s) share Go type decimal.Decimal and Cassandra DECIMAL
+func IsDecimalFieldType(fieldType TableFieldType) bool {
+	return FieldTypeKind(fieldType) == FieldTypeDecimal2
+}
+
+// Number of digits after the point for decimal2 and decimal(p,s) values
+func DecimalFieldTypeScale(fieldType TableFieldType) int32 {
+	if _, scale, ok := ParseDecimalFieldType(fieldType); ok {
+		return scale
+	}
+	return 2
+}
+
+// Maps decimal(p,s) to decimal2, so code that only cares about the Go type can switch on field type constants
+func FieldTypeKind(fieldType TableFieldType) TableFieldType {
+	if _, _, ok := ParseDecimalFieldType(fieldType); ok {
+		return FieldTypeDecimal2
+	}
+	return fieldType
+}
+
 func IsValidFieldType(fieldType TableFieldType) bool {
 	return fieldType == FieldTypeString ||
 		fieldType == FieldTypeInt ||
 		fieldType == FieldTypeFloat ||
 		fieldType == FieldTypeBool ||
 		fieldType == FieldTypeDecimal2 ||
-		fieldType == FieldTypeDateTime
+		fieldType == FieldTypeDateTime ||
+		IsDecimalFieldType(fieldType)
 }
@@ -0,0 +1,34 @@
+package evalcapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecimalFieldType(t *testing.T) {
+	precision, scale, ok := ParseDecimalFieldType("decimal(18,6)")
+	assert.True(t, ok)
+	assert.Equal(t, int32(18), precision)
+	assert.Equal(t, int32(6), scale)
+	assert.Equal(t, TableFieldType("decimal(18,6)"), DecimalFieldType(18, 6))
+
+	_, _, ok = ParseDecimalFieldType(DecimalFieldType(MaxDecimalPrecision, 0))
+	assert.True(t, ok)
+
+	for _, fieldType := range []TableFieldType{"decimal2", "decimal(0,0)", "decimal(39,2)", "decimal(4,5)", "decimal(18, 6)", "decimal(18)", "decimal"} {
+		_, _, ok = ParseDecimalFieldType(fieldType)
+		assert.False(t, ok, string(fieldType))
+	}
+
+	assert.True(t, IsValidFieldType("decimal(38,10)"))
+	assert.False(t, IsValidFieldType("decimal(4,5)"))
+	assert.True(t, IsDecimalFieldType(FieldTypeDecimal2))
+	assert.True(t, IsDecimalFieldType("decimal(10,0)"))
+	assert.False(t, IsDecimalFieldType(FieldTypeFloat))
+
+	assert.Equal(t, FieldTypeDecimal2, FieldTypeKind("decimal(10,4)"))
+	assert.Equal(t, FieldTypeInt, FieldTypeKind(FieldTypeInt))
+	assert.Equal(t, int32(4), DecimalFieldTypeScale("decimal(10,4)"))
+	assert.Equal(t, int32(2), DecimalFieldTypeScale(FieldTypeDecimal2))
+}
@@ -20,84 +20,42 @@ func callLen(args []any) (any, error) {
 	return len(argString), nil
 }
 
-func callIntIif(args []any) (any, error) {
+// Null condition picks the false branch, like SQL CASE. The branch that is not picked may be null,
+// so int.iif(is_null(r.a), 0, r.a) works
+func callIif[T any](funcName string, args []any) (any, error) {
 	if err := eval.CheckArgs("iif", 3, len(args)); err != nil {
 		return nil, err
 	}
 	arg0, ok0 := args[0].(bool)
-	arg1, ok1 := args[1].(int64)
-	arg2, ok2 := args[2].(int64)
-	if !ok0 || !ok1 || !ok2 {
-		return nil, fmt.Errorf("cannot evaluate int.Iif(), invalid args %v", args)
+	_, ok1 := args[1].(T)
+	_, ok2 := args[2].(T)
+	if !ok0 && args[0] != nil || !ok1 && args[1] != nil || !ok2 && args[2] != nil {
+		return nil, fmt.Errorf("cannot evaluate %s(), invalid args %v", funcName, args)
 	}
 	if arg0 {
-		return arg1, nil
+		return args[1], nil
 	}
-	return arg2, nil
+	return args[2], nil
+}
+
+func callIntIif(args []any) (any, error) {
+	return callIif[int64]("int.Iif", args)
 }
 
 func callFloatIif(args []any) (any, error) {
-	if err := eval.CheckArgs("iif", 3, len(args)); err != nil {
-		return nil, err
-	}
-	arg0, ok0 := args[0].(bool)
-	arg1, ok1 := args[1].(float64)
-	arg2, ok2 := args[2].(float64)
-	if !ok0 || !ok1 || !ok2 {
-		return nil, fmt.Errorf("cannot evaluate float.Iif(), invalid args %v", args)
-	}
-	if arg0 {
-		return arg1, nil
-	}
-	return arg2, nil
+	return callIif[float64]("float.Iif", args)
 }
 
 func callDecimal2Iif(args []any) (any, error) {
-	if err := eval.CheckArgs("iif", 3, len(args)); err != nil {
-		return nil, err
-	}
-	arg0, ok0 := args[0].(bool)
-	arg1, ok1 := args[1].(decimal.Decimal)
-	arg2, ok2 := args[2].(decimal.Decimal)
-	if !ok0 || !ok1 || !ok2 {
-		return nil, fmt.Errorf("cannot evaluate decimal2.Iif(), invalid args %v", args)
-	}
-	if arg0 {
-		return arg1, nil
-	}
-	return arg2, nil
+	return callIif[decimal.Decimal]("decimal2.Iif", args)
 }
 
 func callStringIif(args []any) (any, error) {
-	if err := eval.CheckArgs("iif", 3, len(args)); err != nil {
-		return nil, err
-	}
-	arg0, ok0 := args[0].(bool)
-	arg1, ok1 := args[1].(string)
-	arg2, ok2 := args[2].(string)
-	if !ok0 || !ok1 || !ok2 {
-		return nil, fmt.Errorf("cannot evaluate string.Iif(), invalid args %v", args)
-	}
-	if arg0 {
-		return arg1, nil
-	}
-	return arg2, nil
+	return callIif[string]("string.Iif", args)
 }
 
 func callTimeIif(args []any) (any, error) {
-	if err := eval.CheckArgs("iif", 3, len(args)); err != nil {
-		return nil, err
-	}
-	arg0, ok0 := args[0].(bool)
-	arg1, ok1 := args[1].(time.Time)
-	arg2, ok2 := args[2].(time.Time)
-	if !ok0 || !ok1 || !ok2 {
-		return nil, fmt.Errorf("cannot evaluate time.Iif(), invalid args %v", args)
-	}
-	if arg0 {
-		return arg1, nil
-	}
-	return arg2, nil
+	return callIif[time.Time]("time.Iif", args)
 }
 
 func callMathSqrt(args []any) (any, error) {
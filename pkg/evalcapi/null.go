@@ -0,0 +1,58 @@
+package evalcapi
+
+import (
+	"fmt"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+)
+
+// Functions that accept null args, all other functions return null if any arg is null
+var nullAwareFunctions = map[string]struct{}{
+	"is_null":      {},
+	"coalesce":     {},
+	"int.iif":      {},
+	"float.iif":    {},
+	"decimal2.iif": {},
+	"string.iif":   {},
+	"time.iif":     {},
+}
+
+func propagateNull(f eval.EvalFunction) eval.EvalFunction {
+	return func(args []any) (any, error) {
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+		}
+		return f(args)
+	}
+}
+
+func init() {
+	for funcName, f := range CapillariesEvalFunctions {
+		if _, ok := nullAwareFunctions[funcName]; !ok {
+			CapillariesEvalFunctions[funcName] = propagateNull(f)
+		}
+	}
+}
+
+func callIsNull(args []any) (any, error) {
+	if err := eval.CheckArgs("is_null", 1, len(args)); err != nil {
+		return nil, err
+	}
+	return args[0] == nil, nil
+}
+
+// First non-null arg, or null if all args are null. Args are not required to have the same type,
+// but the result is checked against the field type anyways.
+func callCoalesce(args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("cannot evaluate coalesce(), requires at least 1 arg, 0 supplied")
+	}
+	for _, arg := range args {
+		if arg != nil {
+			return arg, nil
+		}
+	}
+	return nil, nil
+}
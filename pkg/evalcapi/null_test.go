@@ -0,0 +1,38 @@
+package evalcapi
+
+import (
+	"testing"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/shopspring/decimal"
+)
+
+func TestNullFunctions(t *testing.T) {
+	varValuesMap := eval.VarValuesMap{"t": {"n": nil, "i": int64(1), "s": "a"}}
+	assertEqual(t, `is_null(t.n)`, true, varValuesMap)
+	assertEqual(t, `is_null(t.i)`, false, varValuesMap)
+	assertEqual(t, `is_null(t.n + 1)`, true, varValuesMap)
+	assertEvalError(t, `is_null(t.n, t.i)`, "cannot evaluate is_null(), requires 1 args, 2 supplied", varValuesMap)
+
+	assertEqual(t, `coalesce(t.n, t.i)`, int64(1), varValuesMap)
+	assertEqual(t, `coalesce(t.i, 2)`, int64(1), varValuesMap)
+	assertEqual(t, `coalesce(t.n, t.n)`, nil, varValuesMap)
+	assertEqual(t, `coalesce(t.n, t.n, "b")`, "b", varValuesMap)
+	assertEvalError(t, `coalesce()`, "cannot evaluate coalesce(), requires at least 1 arg, 0 supplied", varValuesMap)
+}
+
+func TestNullPropagation(t *testing.T) {
+	varValuesMap := eval.VarValuesMap{"t": {"n": nil, "i": int64(1), "s": "a"}}
+	assertEqual(t, `len(t.n)`, nil, varValuesMap)
+	assertEqual(t, `strings.ToUpper(t.n)`, nil, varValuesMap)
+	assertEqual(t, `decimal2(t.n)`, nil, varValuesMap)
+	assertEqual(t, `strings.ReplaceAll(t.s, t.n, "b")`, nil, varValuesMap)
+	assertEqual(t, `coalesce(strings.ToUpper(t.n), t.s)`, "a", varValuesMap)
+
+	assertEqual(t, `int.iif(is_null(t.n), 0, t.n)`, int64(0), varValuesMap)
+	assertEqual(t, `int.iif(t.n > 0, t.n, 2)`, int64(2), varValuesMap)
+	assertEqual(t, `int.iif(t.n, 1, 2)`, int64(2), varValuesMap)
+	assertEqual(t, `int.iif(true, t.n, 2)`, nil, varValuesMap)
+	assertEqual(t, `decimal2.iif(t.i > 0, decimal2(t.i), t.n)`, decimal.NewFromInt(1), varValuesMap)
+	assertEvalError(t, `string.iif(true, t.n, 2)`, "cannot evaluate string.Iif(), invalid args [true <nil> 2]", varValuesMap)
+}
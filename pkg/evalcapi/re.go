@@ -7,6 +7,12 @@ import (
 	"github.com/capillariesio/capillaries/pkg/eval"
 )
 
+var reCache = newParsedArgCache[*regexp.Regexp]()
+
+func compileCachedRegexp(pattern string) (*regexp.Regexp, error) {
+	return reCache.get(pattern, regexp.Compile)
+}
+
 func callReMatchString(args []any) (any, error) {
 	if err := eval.CheckArgs("re.MatchString", 2, len(args)); err != nil {
 		return nil, err
@@ -16,5 +22,69 @@ func callReMatchString(args []any) (any, error) {
 	if !ok0 || !ok1 {
 		return nil, fmt.Errorf("cannot convert re.MatchString() args %v and %v to string", args[0], args[1])
 	}
-	return regexp.MatchString(argString0, argString1)
+	re, err := compileCachedRegexp(argString0)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(argString1), nil
+}
+
+// Empty string if there is no match
+func callReFindString(args []any) (any, error) {
+	if err := eval.CheckArgs("re.FindString", 2, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string) // Pattern
+	arg1, ok1 := args[1].(string)
+	if !ok0 || !ok1 {
+		return nil, fmt.Errorf("cannot evaluate re.FindString(), invalid args %v", args)
+	}
+	re, err := compileCachedRegexp(arg0)
+	if err != nil {
+		return nil, err
+	}
+	return re.FindString(arg1), nil
+}
+
+// re.ReplaceAllString(pattern, src, repl), repl may reference groups as $1 or ${name}
+func callReReplaceAllString(args []any) (any, error) {
+	if err := eval.CheckArgs("re.ReplaceAllString", 3, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string) // Pattern
+	arg1, ok1 := args[1].(string) // Src
+	arg2, ok2 := args[2].(string) // Repl
+	if !ok0 || !ok1 || !ok2 {
+		return nil, fmt.Errorf("cannot evaluate re.ReplaceAllString(), invalid args %v", args)
+	}
+	re, err := compileCachedRegexp(arg0)
+	if err != nil {
+		return nil, err
+	}
+	return re.ReplaceAllString(arg1, arg2), nil
+}
+
+// Unlike Go FindStringSubmatch, returns n-th submatch (0 is the whole match), or empty string if there is no match or no such group
+func callReFindStringSubmatch(args []any) (any, error) {
+	if err := eval.CheckArgs("re.FindStringSubmatch", 3, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string) // Pattern
+	arg1, ok1 := args[1].(string)
+	arg2, ok2 := argToInt(args[2])
+	if !ok0 || !ok1 || !ok2 {
+		return nil, fmt.Errorf("cannot evaluate re.FindStringSubmatch(), invalid args %v", args)
+	}
+	re, err := compileCachedRegexp(arg0)
+	if err != nil {
+		return nil, err
+	}
+	if arg2 < 0 || arg2 > re.NumSubexp() {
+		return nil, fmt.Errorf("cannot evaluate re.FindStringSubmatch(), pattern %s has no group %d", arg0, arg2)
+	}
+	submatches := re.FindStringSubmatch(arg1)
+	if submatches == nil {
+		return "", nil
+	}
+	return submatches[arg2], nil
 }
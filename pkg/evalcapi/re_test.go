@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestReFunctions(t *testing.T) {
@@ -32,5 +33,26 @@ func TestReFunctions(t *testing.T) {
 
 	assertEvalError(t, `re.MatchString("a")`, "cannot evaluate re.MatchString(), requires 2 args, 1 supplied", vars)
 	assertEvalError(t, `re.MatchString("a",1)`, "cannot convert re.MatchString() args a and 1 to string", vars)
-
+	assertEvalError(t, "re.MatchString(`(a`, `a`)", "missing closing )", vars)
+
+	vars = eval.VarValuesMap{"r": map[string]any{"phone": "tel: (415) 555-0100 ext 12"}}
+	assertEqual(t, "re.FindString(`\\d{3}-\\d{4}`, r.phone)", "555-0100", vars)
+	assertEqual(t, "re.FindString(`\\d{5}`, r.phone)", "", vars)
+	assertEqual(t, "re.ReplaceAllString(`[^\\d]`, r.phone, ``)", "415555010012", vars)
+	assertEqual(t, "re.ReplaceAllString(`\\((\\d+)\\) (\\d+)-(\\d+)`, r.phone, `$1.$2.$3`)", "tel: 415.555.0100 ext 12", vars)
+	assertEqual(t, "re.FindStringSubmatch(`\\((\\d+)\\)`, r.phone, 1)", "415", vars)
+	assertEqual(t, "re.FindStringSubmatch(`\\((\\d+)\\)`, r.phone, 0)", "(415)", vars)
+	assertEqual(t, "re.FindStringSubmatch(`ext (\\d+)|fax (\\d+)`, r.phone, 2)", "", vars)
+	assertEqual(t, "re.FindStringSubmatch(`x(\\d+)`, r.phone, 1)", "", vars)
+	assertEvalError(t, "re.FindStringSubmatch(`x(\\d+)`, r.phone, 2)", "cannot evaluate re.FindStringSubmatch(), pattern x(\\d+) has no group 2", vars)
+	assertEvalError(t, "re.FindString(`x`, 1)", "cannot evaluate re.FindString(), invalid args [x 1]", vars)
+	assertEvalError(t, "re.ReplaceAllString(`x`, r.phone)", "cannot evaluate re.ReplaceAllString(), requires 3 args, 2 supplied", vars)
+
+	// Compiled once
+	assertEqual(t, "re.FindString(`\\d{3}-\\d{4}`, r.phone)", "555-0100", vars)
+	cachedRe, ok := reCache.items[`\d{3}-\d{4}`]
+	assert.True(t, ok)
+	compiledRe, err := compileCachedRegexp(`\d{3}-\d{4}`)
+	assert.Nil(t, err)
+	assert.True(t, cachedRe == compiledRe)
 }
@@ -3,10 +3,23 @@ package evalcapi
 import (
 	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/capillariesio/capillaries/pkg/eval"
 )
 
+// Script int literals and int fields are int64, len() returns int
+func argToInt(arg any) (int, bool) {
+	switch typedArg := arg.(type) {
+	case int64:
+		return int(typedArg), true
+	case int:
+		return typedArg, true
+	default:
+		return 0, false
+	}
+}
+
 func callStringsReplaceAll(args []any) (any, error) {
 	if err := eval.CheckArgs("strings.ReplaceAll", 3, len(args)); err != nil {
 		return nil, err
@@ -19,3 +32,165 @@ func callStringsReplaceAll(args []any) (any, error) {
 	}
 	return strings.ReplaceAll(argString0, argString1, argString2), nil
 }
+
+func callStringToString(funcName string, f func(string) string, args []any) (any, error) {
+	if err := eval.CheckArgs(funcName, 1, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string)
+	if !ok0 {
+		return nil, fmt.Errorf("cannot evaluate %s(), invalid args %v", funcName, args)
+	}
+	return f(arg0), nil
+}
+
+func callStringsToUpper(args []any) (any, error) {
+	return callStringToString("strings.ToUpper", strings.ToUpper, args)
+}
+
+func callStringsToLower(args []any) (any, error) {
+	return callStringToString("strings.ToLower", strings.ToLower, args)
+}
+
+func callStringsTrimSpace(args []any) (any, error) {
+	return callStringToString("strings.TrimSpace", strings.TrimSpace, args)
+}
+
+// Go strings.Title is deprecated, this is its word boundary rule (anything but letters, digits and underscore),
+// except apostrophe does not start a new word: "it's" becomes "It's", not "It'S"
+func titleCase(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	prev := ' '
+	for _, r := range s {
+		if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && prev != '_' && prev != '\'' {
+			sb.WriteRune(unicode.ToTitle(r))
+		} else {
+			sb.WriteRune(r)
+		}
+		prev = r
+	}
+	return sb.String()
+}
+
+func callStringsTitle(args []any) (any, error) {
+	return callStringToString("strings.Title", titleCase, args)
+}
+
+func callStringsStringToBool(funcName string, f func(string, string) bool, args []any) (any, error) {
+	if err := eval.CheckArgs(funcName, 2, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string)
+	arg1, ok1 := args[1].(string)
+	if !ok0 || !ok1 {
+		return nil, fmt.Errorf("cannot evaluate %s(), invalid args %v", funcName, args)
+	}
+	return f(arg0, arg1), nil
+}
+
+func callStringsHasPrefix(args []any) (any, error) {
+	return callStringsStringToBool("strings.HasPrefix", strings.HasPrefix, args)
+}
+
+func callStringsHasSuffix(args []any) (any, error) {
+	return callStringsStringToBool("strings.HasSuffix", strings.HasSuffix, args)
+}
+
+func callStringsContains(args []any) (any, error) {
+	return callStringsStringToBool("strings.Contains", strings.Contains, args)
+}
+
+func callStringsTrim(args []any) (any, error) {
+	if err := eval.CheckArgs("strings.Trim", 2, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string)
+	arg1, ok1 := args[1].(string) // Cutset
+	if !ok0 || !ok1 {
+		return nil, fmt.Errorf("cannot evaluate strings.Trim(), invalid args %v", args)
+	}
+	return strings.Trim(arg0, arg1), nil
+}
+
+// Byte index, as in Go, -1 if not found
+func callStringsIndex(args []any) (any, error) {
+	if err := eval.CheckArgs("strings.Index", 2, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string)
+	arg1, ok1 := args[1].(string)
+	if !ok0 || !ok1 {
+		return nil, fmt.Errorf("cannot evaluate strings.Index(), invalid args %v", args)
+	}
+	return int64(strings.Index(arg0, arg1)), nil
+}
+
+// Unlike Go strings.Split, returns n-th (zero-based) element, or empty string if there are not enough elements
+func callStringsSplit(args []any) (any, error) {
+	if err := eval.CheckArgs("strings.Split", 3, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string)
+	arg1, ok1 := args[1].(string) // Separator
+	arg2, ok2 := argToInt(args[2])
+	if !ok0 || !ok1 || !ok2 {
+		return nil, fmt.Errorf("cannot evaluate strings.Split(), invalid args %v", args)
+	}
+	if arg2 < 0 {
+		return nil, fmt.Errorf("cannot evaluate strings.Split(), negative element index %d", arg2)
+	}
+	parts := strings.SplitN(arg0, arg1, arg2+2)
+	if arg2 >= len(parts) {
+		return "", nil
+	}
+	return parts[arg2], nil
+}
+
+func callStringsRepeat(args []any) (any, error) {
+	if err := eval.CheckArgs("strings.Repeat", 2, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string)
+	arg1, ok1 := argToInt(args[1])
+	if !ok0 || !ok1 {
+		return nil, fmt.Errorf("cannot evaluate strings.Repeat(), invalid args %v", args)
+	}
+	if arg1 < 0 {
+		return nil, fmt.Errorf("cannot evaluate strings.Repeat(), negative count %d", arg1)
+	}
+	return strings.Repeat(arg0, arg1), nil
+}
+
+// substr(s, start, length): start and length are in characters, not bytes.
+// Out-of-range start and length are clipped to the string, so there is no need to check len() first.
+func callSubstr(args []any) (any, error) {
+	if err := eval.CheckArgs("substr", 3, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(string)
+	arg1, ok1 := argToInt(args[1])
+	arg2, ok2 := argToInt(args[2])
+	if !ok0 || !ok1 || !ok2 {
+		return nil, fmt.Errorf("cannot evaluate substr(), invalid args %v", args)
+	}
+	if arg1 < 0 || arg2 < 0 {
+		return nil, fmt.Errorf("cannot evaluate substr(), negative start %d or length %d", arg1, arg2)
+	}
+
+	// Find byte offsets without converting the whole string to runes, start beyond the end gives empty string
+	startByte := len(arg0)
+	endByte := len(arg0)
+	runeIdx := 0
+	for byteIdx := range arg0 {
+		if runeIdx == arg1 {
+			startByte = byteIdx
+		}
+		if runeIdx == arg1+arg2 {
+			endByte = byteIdx
+			break
+		}
+		runeIdx++
+	}
+	return arg0[startByte:endByte], nil
+}
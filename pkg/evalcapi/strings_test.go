@@ -11,4 +11,37 @@ func TestStringsFunctions(t *testing.T) {
 	assertEqual(t, `strings.ReplaceAll("abc","a","b")`, "bbc", varValuesMap)
 	assertEvalError(t, `strings.ReplaceAll("a","b")`, "cannot evaluate strings.ReplaceAll(), requires 3 args, 2 supplied", varValuesMap)
 	assertEvalError(t, `strings.ReplaceAll("a","b",1)`, "cannot convert strings.ReplaceAll() args a,b,1 to string", varValuesMap)
+
+	varValuesMap = eval.VarValuesMap{"r": map[string]any{"name": "  Jean-luc o'neil_jr  ", "zip": "94105-1234", "n": int64(1)}}
+	assertEqual(t, `strings.ToUpper(strings.TrimSpace(r.name))`, "JEAN-LUC O'NEIL_JR", varValuesMap)
+	assertEqual(t, `strings.ToLower(r.zip)`, "94105-1234", varValuesMap)
+	assertEqual(t, `strings.Title(strings.TrimSpace(r.name))`, "Jean-Luc O'neil_jr", varValuesMap)
+	assertEqual(t, `strings.Trim(r.zip, "9-4")`, "105-123", varValuesMap)
+	assertEqual(t, `strings.HasPrefix(r.zip, "941")`, true, varValuesMap)
+	assertEqual(t, `strings.HasSuffix(r.zip, "941")`, false, varValuesMap)
+	assertEqual(t, `strings.Contains(r.name, "luc")`, true, varValuesMap)
+	assertEqual(t, `strings.Index(r.zip, "-")`, int64(5), varValuesMap)
+	assertEqual(t, `strings.Index(r.zip, "+")`, int64(-1), varValuesMap)
+	assertEqual(t, `strings.Repeat("ab", 3)`, "ababab", varValuesMap)
+	assertEvalError(t, `strings.Repeat("ab", -1)`, "cannot evaluate strings.Repeat(), negative count -1", varValuesMap)
+	assertEvalError(t, `strings.ToUpper(r.n)`, "cannot evaluate strings.ToUpper(), invalid args [1]", varValuesMap)
+	assertEvalError(t, `strings.Contains(r.zip)`, "cannot evaluate strings.Contains(), requires 2 args, 1 supplied", varValuesMap)
+
+	// n-th element
+	assertEqual(t, `strings.Split(r.zip, "-", 0)`, "94105", varValuesMap)
+	assertEqual(t, `strings.Split(r.zip, "-", r.n)`, "1234", varValuesMap)
+	assertEqual(t, `strings.Split("a,b,c", ",", 1)`, "b", varValuesMap)
+	assertEqual(t, `strings.Split(r.zip, "-", 2)`, "", varValuesMap)
+	assertEvalError(t, `strings.Split(r.zip, "-", -1)`, "cannot evaluate strings.Split(), negative element index -1", varValuesMap)
+	assertEvalError(t, `strings.Split(r.zip, "-", "1")`, "cannot evaluate strings.Split(), invalid args [94105-1234 - 1]", varValuesMap)
+
+	// Characters, not bytes, clipped to the string
+	assertEqual(t, `substr("Zürich", 1, 3)`, "üri", varValuesMap)
+	assertEqual(t, `substr("Zürich", 0, 0)`, "", varValuesMap)
+	assertEqual(t, `substr("Zürich", 4, 10)`, "ch", varValuesMap)
+	assertEqual(t, `substr("Zürich", 6, 1)`, "", varValuesMap)
+	assertEqual(t, `substr("Zürich", 10, 1)`, "", varValuesMap)
+	assertEqual(t, `substr(r.zip, len(r.zip) - 4, 4)`, "1234", varValuesMap)
+	assertEvalError(t, `substr(r.zip, -1, 4)`, "cannot evaluate substr(), negative start -1 or length 4", varValuesMap)
+	assertEvalError(t, `substr(r.zip, 1)`, "cannot evaluate substr(), requires 3 args, 2 supplied", varValuesMap)
 }
@@ -2,7 +2,9 @@ package evalcapi
 
 import (
 	"fmt"
+	"strings"
 	"time"
+	_ "time/tzdata" // time.In() should not depend on tzdata installed in the container
 
 	"github.com/capillariesio/capillaries/pkg/eval"
 )
@@ -130,3 +132,213 @@ func callTimeAfter(args []any) (any, error) {
 
 	return arg0.After(arg1), nil
 }
+
+func callTimeAddDate(args []any) (any, error) {
+	if err := eval.CheckArgs("time.AddDate", 4, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(time.Time)
+	arg1, ok1 := argToInt(args[1]) // Years
+	arg2, ok2 := argToInt(args[2]) // Months
+	arg3, ok3 := argToInt(args[3]) // Days
+	if !ok0 || !ok1 || !ok2 || !ok3 {
+		return nil, fmt.Errorf("cannot evaluate time.AddDate(), invalid args %v", args)
+	}
+	return arg0.AddDate(arg1, arg2, arg3), nil
+}
+
+// Duration as accepted by Go time.ParseDuration: "1h30m", "-15m", "500ms". There is no "d" unit, use time.AddDate() for days.
+func callTimeAdd(args []any) (any, error) {
+	if err := eval.CheckArgs("time.Add", 2, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(time.Time)
+	arg1, ok1 := args[1].(string)
+	if !ok0 || !ok1 {
+		return nil, fmt.Errorf("cannot evaluate time.Add(), invalid args %v", args)
+	}
+	d, err := time.ParseDuration(arg1)
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate time.Add(), invalid duration: %s", err.Error())
+	}
+	return arg0.Add(d), nil
+}
+
+// Unlike Go Time.Truncate, works with calendar units in the time's location: beginning of the year, quarter, month,
+// week (Monday), day, hour, minute or second
+func callTimeTruncate(args []any) (any, error) {
+	if err := eval.CheckArgs("time.Truncate", 2, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(time.Time)
+	arg1, ok1 := args[1].(string)
+	if !ok0 || !ok1 {
+		return nil, fmt.Errorf("cannot evaluate time.Truncate(), invalid args %v", args)
+	}
+	y, m, d := arg0.Date()
+	loc := arg0.Location()
+	switch arg1 {
+	case "year":
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, loc), nil
+	case "quarter":
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, loc), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc), nil
+	case "week":
+		daysSinceMonday := (int(arg0.Weekday()) + 6) % 7
+		return time.Date(y, m, d-daysSinceMonday, 0, 0, 0, 0, loc), nil
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), nil
+	case "hour":
+		return time.Date(y, m, d, arg0.Hour(), 0, 0, 0, loc), nil
+	case "minute":
+		return time.Date(y, m, d, arg0.Hour(), arg0.Minute(), 0, 0, loc), nil
+	case "second":
+		return time.Date(y, m, d, arg0.Hour(), arg0.Minute(), arg0.Second(), 0, loc), nil
+	default:
+		return nil, fmt.Errorf("cannot evaluate time.Truncate(), unknown unit %s, expected one of: year, quarter, month, week, day, hour, minute, second", arg1)
+	}
+}
+
+func callTimeIntPart(funcName string, f func(time.Time) int, args []any) (any, error) {
+	if err := eval.CheckArgs(funcName, 1, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(time.Time)
+	if !ok0 {
+		return nil, fmt.Errorf("cannot evaluate %s(), invalid args %v", funcName, args)
+	}
+	return int64(f(arg0)), nil
+}
+
+func callTimeYear(args []any) (any, error) {
+	return callTimeIntPart("time.Year", time.Time.Year, args)
+}
+
+// 1-12
+func callTimeMonth(args []any) (any, error) {
+	return callTimeIntPart("time.Month", func(t time.Time) int { return int(t.Month()) }, args)
+}
+
+func callTimeDay(args []any) (any, error) {
+	return callTimeIntPart("time.Day", time.Time.Day, args)
+}
+
+// 0 is Sunday, as in Go
+func callTimeWeekday(args []any) (any, error) {
+	return callTimeIntPart("time.Weekday", func(t time.Time) int { return int(t.Weekday()) }, args)
+}
+
+func callTimeYearDay(args []any) (any, error) {
+	return callTimeIntPart("time.YearDay", time.Time.YearDay, args)
+}
+
+// 1-4
+func callTimeQuarter(args []any) (any, error) {
+	return callTimeIntPart("time.Quarter", func(t time.Time) int { return (int(t.Month())-1)/3 + 1 }, args)
+}
+
+// Midnight of the last day of the month, in the time's location
+func callTimeEndOfMonth(args []any) (any, error) {
+	if err := eval.CheckArgs("time.EndOfMonth", 1, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(time.Time)
+	if !ok0 {
+		return nil, fmt.Errorf("cannot evaluate time.EndOfMonth(), invalid args %v", args)
+	}
+	// Day 0 of the next month is the last day of this month
+	return time.Date(arg0.Year(), arg0.Month()+1, 0, 0, 0, 0, 0, arg0.Location()), nil
+}
+
+var locationCache = newParsedArgCache[*time.Location]()
+
+// IANA zone name, like "America/New_York"
+func callTimeIn(args []any) (any, error) {
+	if err := eval.CheckArgs("time.In", 2, len(args)); err != nil {
+		return nil, err
+	}
+	arg0, ok0 := args[0].(time.Time)
+	arg1, ok1 := args[1].(string)
+	if !ok0 || !ok1 {
+		return nil, fmt.Errorf("cannot evaluate time.In(), invalid args %v", args)
+	}
+	loc, err := locationCache.get(arg1, time.LoadLocation)
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate time.In(): %s", err.Error())
+	}
+	return arg0.In(loc), nil
+}
+
+// Days since 1970-01-01 of the calendar date in the time's location
+func civilDayNumber(t time.Time) int64 {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+func isWeekendDayNumber(dayNum int64) bool {
+	// 1970-01-01 was Thursday
+	weekday := time.Weekday(((dayNum+4)%7 + 7) % 7)
+	return weekday == time.Saturday || weekday == time.Sunday
+}
+
+// Comma-separated 2006-01-02 dates, as passed in a {holidays|string} script param
+func parseHolidays(holidayList string) (map[int64]struct{}, error) {
+	holidays := map[int64]struct{}{}
+	for _, holidayStr := range strings.Split(holidayList, ",") {
+		holidayStr = strings.TrimSpace(holidayStr)
+		if holidayStr == "" {
+			continue
+		}
+		holiday, err := time.Parse("2006-01-02", holidayStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %s, expected 2006-01-02 format", holidayStr)
+		}
+		holidays[civilDayNumber(holiday)] = struct{}{}
+	}
+	return holidays, nil
+}
+
+var holidaysCache = newParsedArgCache[map[int64]struct{}]()
+
+// Mon-Fri days in [from, to), excluding holidays; negative if to is before from
+func businessDaysBetween(fromDayNum int64, toDayNum int64, holidays map[int64]struct{}) int64 {
+	if toDayNum < fromDayNum {
+		return -businessDaysBetween(toDayNum, fromDayNum, holidays)
+	}
+	totalDays := toDayNum - fromDayNum
+	count := totalDays / 7 * 5
+	for dayNum := fromDayNum + totalDays/7*7; dayNum < toDayNum; dayNum++ {
+		if !isWeekendDayNumber(dayNum) {
+			count++
+		}
+	}
+	for holidayDayNum := range holidays {
+		if holidayDayNum >= fromDayNum && holidayDayNum < toDayNum && !isWeekendDayNumber(holidayDayNum) {
+			count--
+		}
+	}
+	return count
+}
+
+// time.BusinessDaysBetween(from, to[, holidays]): Mon-Fri calendar days from (inclusive) to to (exclusive),
+// not counting holidays, a comma-separated list of 2006-01-02 dates. Time of day is ignored.
+func callTimeBusinessDaysBetween(args []any) (any, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("cannot evaluate time.BusinessDaysBetween(), requires 2 or 3 args, %d supplied", len(args))
+	}
+	arg0, ok0 := args[0].(time.Time)
+	arg1, ok1 := args[1].(time.Time)
+	arg2, ok2 := "", true
+	if len(args) == 3 {
+		arg2, ok2 = args[2].(string)
+	}
+	if !ok0 || !ok1 || !ok2 {
+		return nil, fmt.Errorf("cannot evaluate time.BusinessDaysBetween(), invalid args %v", args)
+	}
+	holidays, err := holidaysCache.get(arg2, parseHolidays)
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate time.BusinessDaysBetween(): %s", err.Error())
+	}
+	return businessDaysBetween(civilDayNumber(arg0), civilDayNumber(arg1), holidays), nil
+}
@@ -76,3 +76,58 @@ func TestNow(t *testing.T) {
 	assert.True(t, time.Since(resultTime).Milliseconds() < 500)
 	assertEvalError(t, `time.Now(1)`, "cannot evaluate time.Now(), requires 0 args, 1 supplied", eval.VarValuesMap{})
 }
+
+func TestCalendarTimeFunctions(t *testing.T) {
+	// Thursday
+	testTime := time.Date(2024, 2, 29, 13, 45, 30, 500000000, time.UTC)
+	varValuesMap := eval.VarValuesMap{"r": map[string]any{"d": testTime, "n": int64(1)}}
+
+	assertEqual(t, `time.AddDate(r.d, 0, 1, 0)`, time.Date(2024, 3, 29, 13, 45, 30, 500000000, time.UTC), varValuesMap)
+	assertEqual(t, `time.AddDate(r.d, r.n, 0, -1)`, time.Date(2025, 2, 28, 13, 45, 30, 500000000, time.UTC), varValuesMap)
+	assertEvalError(t, `time.AddDate(r.d, 1, 0)`, "cannot evaluate time.AddDate(), requires 4 args, 3 supplied", varValuesMap)
+	assertEvalError(t, `time.AddDate(r.d, 1, 0, "1")`, "cannot evaluate time.AddDate(), invalid args", varValuesMap)
+
+	assertEqual(t, `time.Add(r.d, "-1h45m30.5s")`, time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), varValuesMap)
+	assertEvalError(t, `time.Add(r.d, "1d")`, `cannot evaluate time.Add(), invalid duration: time: unknown unit "d" in duration "1d"`, varValuesMap)
+
+	assertEqual(t, `time.Truncate(r.d, "year")`, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.Truncate(r.d, "quarter")`, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.Truncate(time.Date(2024, time.December, 31, 1, 0, 0, 0, time.UTC), "quarter")`, time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.Truncate(r.d, "month")`, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.Truncate(r.d, "week")`, time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.Truncate(time.Date(2024, time.March, 3, 1, 0, 0, 0, time.UTC), "week")`, time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.Truncate(r.d, "day")`, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.Truncate(r.d, "hour")`, time.Date(2024, 2, 29, 13, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.Truncate(r.d, "minute")`, time.Date(2024, 2, 29, 13, 45, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.Truncate(r.d, "second")`, time.Date(2024, 2, 29, 13, 45, 30, 0, time.UTC), varValuesMap)
+	assertEvalError(t, `time.Truncate(r.d, "decade")`, "cannot evaluate time.Truncate(), unknown unit decade", varValuesMap)
+
+	assertEqual(t, `time.Year(r.d)`, int64(2024), varValuesMap)
+	assertEqual(t, `time.Month(r.d)`, int64(2), varValuesMap)
+	assertEqual(t, `time.Day(r.d)`, int64(29), varValuesMap)
+	assertEqual(t, `time.Weekday(r.d)`, int64(4), varValuesMap)
+	assertEqual(t, `time.YearDay(r.d)`, int64(60), varValuesMap)
+	assertEqual(t, `time.Quarter(r.d)`, int64(1), varValuesMap)
+	assertEqual(t, `time.Quarter(time.AddDate(r.d, 0, 10, 0))`, int64(4), varValuesMap)
+	assertEvalError(t, `time.Year("2024")`, "cannot evaluate time.Year(), invalid args [2024]", varValuesMap)
+
+	assertEqual(t, `time.EndOfMonth(r.d)`, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.EndOfMonth(time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC))`, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), varValuesMap)
+	assertEqual(t, `time.EndOfMonth(r.d) == time.Truncate(r.d, "day")`, true, varValuesMap)
+
+	newYork, _ := time.LoadLocation("America/New_York")
+	assertEqual(t, `time.In(r.d, "America/New_York")`, testTime.In(newYork), varValuesMap)
+	assertEqual(t, `time.Day(time.In(time.Date(2024, time.March, 1, 3, 0, 0, 0, time.UTC), "America/New_York"))`, int64(29), varValuesMap)
+	assertEvalError(t, `time.In(r.d, "Mars/Olympus_Mons")`, "cannot evaluate time.In(): unknown time zone Mars/Olympus_Mons", varValuesMap)
+
+	// Thu 2024-02-29 to Thu 2024-03-14: 10 weekdays, Fri 2024-03-01 is a holiday, Sat 2024-03-02 is not a business day anyway
+	assertEqual(t, `time.BusinessDaysBetween(r.d, time.Date(2024, time.March, 14, 0, 0, 0, 0, time.UTC))`, int64(10), varValuesMap)
+	assertEqual(t, `time.BusinessDaysBetween(r.d, time.Date(2024, time.March, 14, 0, 0, 0, 0, time.UTC), "2024-03-01, 2024-03-02,2024-12-25")`, int64(9), varValuesMap)
+	assertEqual(t, `time.BusinessDaysBetween(time.Date(2024, time.March, 14, 0, 0, 0, 0, time.UTC), r.d, "2024-03-01")`, int64(-9), varValuesMap)
+	assertEqual(t, `time.BusinessDaysBetween(r.d, r.d, "")`, int64(0), varValuesMap)
+	// Sat to Mon
+	assertEqual(t, `time.BusinessDaysBetween(time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC))`, int64(0), varValuesMap)
+	assertEqual(t, `time.BusinessDaysBetween(time.Date(1969, time.December, 26, 0, 0, 0, 0, time.UTC), time.Date(1970, time.January, 5, 0, 0, 0, 0, time.UTC))`, int64(6), varValuesMap)
+	assertEvalError(t, `time.BusinessDaysBetween(r.d)`, "cannot evaluate time.BusinessDaysBetween(), requires 2 or 3 args, 1 supplied", varValuesMap)
+	assertEvalError(t, `time.BusinessDaysBetween(r.d, r.d, "2024-13-01")`, "cannot evaluate time.BusinessDaysBetween(): invalid holiday 2024-13-01, expected 2006-01-02 format", varValuesMap)
+}
@@ -12,8 +12,10 @@ import (
 	"time"
 
 	"github.com/capillariesio/capillaries/pkg/api"
+	"github.com/capillariesio/capillaries/pkg/custom/pivot"
 	"github.com/capillariesio/capillaries/pkg/custom/pycalc"
 	"github.com/capillariesio/capillaries/pkg/custom/taganddenormalize"
+	"github.com/capillariesio/capillaries/pkg/custom/unpivot"
 	"github.com/capillariesio/capillaries/pkg/env"
 	"github.com/capillariesio/capillaries/pkg/l"
 	"github.com/capillariesio/capillaries/pkg/mq"
@@ -45,6 +47,10 @@ func (f *StandardDaemonProcessorDefFactory) Create(processorType string) (sc.Cus
 		return &pycalc.PyCalcProcessorDef{}, true
 	case taganddenormalize.ProcessorTagAndDenormalizeName:
 		return &taganddenormalize.TagAndDenormalizeProcessorDef{}, true
+	case pivot.ProcessorPivotName:
+		return &pivot.PivotProcessorDef{}, true
+	case unpivot.ProcessorUnpivotName:
+		return &unpivot.UnpivotProcessorDef{}, true
 	default:
 		return nil, false
 	}
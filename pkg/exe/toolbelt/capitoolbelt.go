@@ -14,8 +14,10 @@ import (
 	"time"
 
 	"github.com/capillariesio/capillaries/pkg/api"
+	"github.com/capillariesio/capillaries/pkg/custom/pivot"
 	"github.com/capillariesio/capillaries/pkg/custom/pycalc"
 	"github.com/capillariesio/capillaries/pkg/custom/taganddenormalize"
+	"github.com/capillariesio/capillaries/pkg/custom/unpivot"
 	"github.com/capillariesio/capillaries/pkg/db"
 	"github.com/capillariesio/capillaries/pkg/env"
 	"github.com/capillariesio/capillaries/pkg/evalcapi"
@@ -44,6 +46,10 @@ func (f *StandardToolbeltProcessorDefFactory) Create(processorType string) (sc.C
 		return &pycalc.PyCalcProcessorDef{}, true
 	case taganddenormalize.ProcessorTagAndDenormalizeName:
 		return &taganddenormalize.TagAndDenormalizeProcessorDef{}, true
+	case pivot.ProcessorPivotName:
+		return &pivot.PivotProcessorDef{}, true
+	case unpivot.ProcessorUnpivotName:
+		return &unpivot.UnpivotProcessorDef{}, true
 	default:
 		return nil, false
 	}
@@ -466,11 +472,12 @@ func execNode(envConfig *env.EnvConfig, logger *l.CapiLogger) int {
 func protoFileReaderCreator() int {
 	cmd := flag.NewFlagSet(CmdProtoFileReaderCreator, flag.ExitOnError)
 	filePath := cmd.String("file", "", "path to sample file")
-	fileType := cmd.String("file_type", "csv", "csv or parquet")
+	fileType := cmd.String("file_type", "csv", "csv, parquet or jsonl")
 	csvHeaderLine := cmd.Int("csv_hdr_line_idx", -1, "csv only: index of the header line")
 	csvFirstDataLine := cmd.Int("csv_first_line_idx", 0, "csv only: index of the first data line, must be greater than csv_hdr_line_idx")
-	csvSeparator := cmd.String("csv_separator", ",", "csv only: field separator")
-	if err := cmd.Parse(os.Args[2:]); err != nil || *filePath == "" || (*fileType != "csv" && *fileType != "parquet") || *csvHeaderLine >= *csvFirstDataLine || *csvSeparator == "" {
+	csvSeparator := cmd.String("csv_separator", "", "csv only: field separator, detected if not specified")
+	csvEncoding := cmd.String("csv_encoding", "", "csv only: file encoding like utf-8, utf-16 or iso-8859-1, detected if not specified")
+	if err := cmd.Parse(os.Args[2:]); err != nil || *filePath == "" || (*fileType != "csv" && *fileType != "parquet" && *fileType != "jsonl") || *csvHeaderLine >= *csvFirstDataLine {
 		usage(cmd)
 		return 0
 	}
@@ -501,19 +508,23 @@ func protoFileReaderCreator() int {
 	var errGuess error
 	var guessedFields []*storage.GuessedField
 	var fieldSettingsRemover *regexp.Regexp
-	if *fileType == "csv" {
-		guessedFields, errGuess = storage.CsvGuessFields(*filePath, *csvHeaderLine, *csvFirstDataLine, *csvSeparator)
-		if errGuess != nil && len(guessedFields) == 0 {
-			fmt.Fprintln(os.Stderr, errGuess.Error())
-			return 1
-		}
+	switch *fileType {
+	case "csv":
 		fileReaderDef.ReaderFileType = sc.ReaderFileTypeCsv
 		fileReaderDef.Csv = sc.CsvReaderSettings{
 			SrcFileHdrLineIdx:       *csvHeaderLine,
 			SrcFileFirstDataLineIdx: *csvFirstDataLine,
 			Separator:               *csvSeparator,
+			Encoding:                *csvEncoding,
 			ColumnIndexingMode:      sc.FileColumnIndexingUnknown}
 
+		// Detects separator and encoding if not specified
+		guessedFields, errGuess = storage.CsvGuessFields(*filePath, &fileReaderDef.Csv)
+		if errGuess != nil && len(guessedFields) == 0 {
+			fmt.Fprintln(os.Stderr, errGuess.Error())
+			return 1
+		}
+
 		for colIdx, gf := range guessedFields {
 			colIdxToUse := colIdx
 			if len(gf.OriginalHeader) > 0 {
@@ -527,7 +538,7 @@ func protoFileReaderCreator() int {
 					SrcColHeader: gf.OriginalHeader,
 					SrcColFormat: gf.Format}}
 		}
-		fileCreatorDef.Csv.Separator = *csvSeparator
+		fileCreatorDef.Csv.Separator = fileReaderDef.Csv.Separator
 		fileCreatorDef.Columns = make([]sc.WriteFileColumnDef, len(guessedFields))
 		for colIdx, gf := range guessedFields {
 			fileCreatorDef.Columns[colIdx] = sc.WriteFileColumnDef{
@@ -538,8 +549,8 @@ func protoFileReaderCreator() int {
 					Format: fileWriterFormatMap[gf.Type],
 					Header: gf.OriginalHeader}}
 		}
-		fieldSettingsRemover = regexp.MustCompile(`,*[ \t\n]*"parquet":[ \t\n]*{[^}]*}`)
-	} else {
+		fieldSettingsRemover = regexp.MustCompile(`,[ \t\n]*"(parquet|jsonl|fixed_width)":[ \t\n]*{[^}]*}`)
+	case "parquet":
 		guessedFields, errGuess = storage.ParquetGuessFields(*filePath)
 		if errGuess != nil && len(guessedFields) == 0 {
 			fmt.Fprintln(os.Stderr, errGuess.Error())
@@ -551,6 +562,9 @@ func protoFileReaderCreator() int {
 				Type: gf.Type,
 				Parquet: sc.ParquetReaderColumnSettings{
 					SrcColName: gf.OriginalHeader}}
+			if gf.IsRepeated {
+				fileReaderDef.Columns[gf.CapiName].Parquet.Repeated = sc.ParquetRepeatedJoin
+			}
 		}
 		fileCreatorDef.Parquet.Codec = sc.ParquetCodecGzip
 		fileCreatorDef.Columns = make([]sc.WriteFileColumnDef, len(guessedFields))
@@ -560,9 +574,35 @@ func protoFileReaderCreator() int {
 				RawExpression: "r." + strings.ReplaceAll(gf.CapiName, "col_", ""),
 				Type:          gf.Type,
 				Parquet: sc.WriteParquetColumnSettings{
-					ColumnName: gf.OriginalHeader}}
+					ColumnName: strings.ReplaceAll(gf.OriginalHeader, ".", "_")}} // Nested fields are written flat
+		}
+		fieldSettingsRemover = regexp.MustCompile(`,[ \t\n]*"(csv|jsonl|fixed_width)":[ \t\n]*{[^}]*}`)
+	case "jsonl":
+		guessedFields, errGuess = storage.JsonlGuessFields(*filePath)
+		if errGuess != nil && len(guessedFields) == 0 {
+			fmt.Fprintln(os.Stderr, errGuess.Error())
+			return 1
 		}
-		fieldSettingsRemover = regexp.MustCompile(`[ \t\n]*"csv":[ \t\n]*{[^}]*},*`)
+		fileReaderDef.ReaderFileType = sc.ReaderFileTypeJsonl
+		for _, gf := range guessedFields {
+			fileReaderDef.Columns[gf.CapiName] = &sc.FileReaderColumnDef{
+				Type: gf.Type,
+				Jsonl: sc.JsonlReaderColumnSettings{
+					SrcPath:      gf.OriginalHeader,
+					SrcColFormat: gf.Format}}
+		}
+		fileCreatorDef.Columns = make([]sc.WriteFileColumnDef, len(guessedFields))
+		for colIdx, gf := range guessedFields {
+			// Nested fields are written flat, with dotted keys
+			fileCreatorDef.Columns[colIdx] = sc.WriteFileColumnDef{
+				Name:          gf.CapiName,
+				RawExpression: "r." + strings.ReplaceAll(gf.CapiName, "col_", ""),
+				Type:          gf.Type,
+				Jsonl: sc.WriteJsonlColumnSettings{
+					Key:    gf.OriginalHeader,
+					Format: gf.Format}}
+		}
+		fieldSettingsRemover = regexp.MustCompile(`,[ \t\n]*"(csv|parquet|fixed_width)":[ \t\n]*{[^}]*}`)
 	}
 
 	reNonAlphanum := regexp.MustCompile("[^a-zA-Z0-9_]")
@@ -680,14 +720,19 @@ func runNode(envConfig *env.EnvConfig, logger *l.CapiLogger, nodeName string, ru
 		return 0, fmt.Errorf("cannot find node to start with: %s in the script %s", nodeName, scriptFilePath)
 	}
 
-	intervals, err := node.GetTokenIntervalsByNumberOfBatches()
+	affectedNodes := script.GetAffectedNodes([]string{nodeName})
+	resolvedFileUrls, err := api.ResolveFileReaderUrls(logger, envConfig, cqlSession, keyspace, script, affectedNodes)
+	if err != nil {
+		return 0, err
+	}
+
+	intervals, err := api.GetNodeBatchIntervals(logger, envConfig, node, resolvedFileUrls)
 	if err != nil {
 		return 0, err
 	}
 
 	// Write affected nodes
-	affectedNodes := script.GetAffectedNodes([]string{nodeName})
-	if err := wfdb.WriteRunProperties(cqlSession, keyspace, runId, []string{nodeName}, affectedNodes, scriptFilePath, paramsFilePath, "started by Toolbelt direct RunNode"); err != nil {
+	if err := wfdb.WriteRunProperties(cqlSession, keyspace, runId, []string{nodeName}, affectedNodes, scriptFilePath, paramsFilePath, "started by Toolbelt direct RunNode", resolvedFileUrls); err != nil {
 		return 0, err
 	}
 
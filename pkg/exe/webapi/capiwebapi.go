@@ -17,8 +17,10 @@ import (
 
 	"github.com/capillariesio/capillaries/pkg/api"
 	"github.com/capillariesio/capillaries/pkg/cql"
+	"github.com/capillariesio/capillaries/pkg/custom/pivot"
 	"github.com/capillariesio/capillaries/pkg/custom/pycalc"
 	"github.com/capillariesio/capillaries/pkg/custom/taganddenormalize"
+	"github.com/capillariesio/capillaries/pkg/custom/unpivot"
 	"github.com/capillariesio/capillaries/pkg/db"
 	"github.com/capillariesio/capillaries/pkg/env"
 	"github.com/capillariesio/capillaries/pkg/gocqlshims"
@@ -44,6 +46,10 @@ func (f *StandardWebapiProcessorDefFactory) Create(processorType string) (sc.Cus
 		return &pycalc.PyCalcProcessorDef{}, true
 	case taganddenormalize.ProcessorTagAndDenormalizeName:
 		return &taganddenormalize.TagAndDenormalizeProcessorDef{}, true
+	case pivot.ProcessorPivotName:
+		return &pivot.PivotProcessorDef{}, true
+	case unpivot.ProcessorUnpivotName:
+		return &unpivot.UnpivotProcessorDef{}, true
 	default:
 		return nil, false
 	}
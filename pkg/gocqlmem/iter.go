@@ -119,7 +119,9 @@ func (iter *gocqlmemIter) Scan(dest ...any) bool {
 	for i := range len(iter.retrievedColumnInfos) {
 		if dest[i] != nil {
 			if iter.retrievedValues[iter.pos][i] == nil {
-				dest[i] = nil
+				if !nullToProvidedPtrPtr(dest[i]) {
+					dest[i] = nil
+				}
 			} else {
 				if err := clientTypedValueToProvidedPtr(iter.retrievedValues[iter.pos][i], dest[i]); err != nil {
 					iter.SetErr(fmt.Errorf("cannot scan column %d: %s", i, err.Error()))
@@ -117,3 +117,31 @@ func TestScanner(t *testing.T) {
 		assert.Equal(t, *float64ToDecNoCheck(float64(2.2)), resultDec)
 	}
 }
+
+func TestIterScanNullable(t *testing.T) {
+	s := NewGocqlmemSession()
+	assert.Nil(t, s.Query("CREATE KEYSPACE ks1").Exec())
+	assert.Nil(t, s.Query("CREATE TABLE ks1.t1 (f_int int, f_bigint bigint, f_text text, f_dec decimal, primary key (f_int))").Exec())
+	assert.Nil(t, s.Query("INSERT INTO ks1.t1 (f_int, f_bigint, f_text, f_dec) VALUES (1, 2, '1', 2.2)").Exec())
+	assert.Nil(t, s.Query("INSERT INTO ks1.t1 (f_int) VALUES (2)").Exec())
+
+	iter := s.Query(`SELECT f_int, f_bigint, f_text, f_dec FROM ks1.t1 WHERE f_int = 1`).Iter()
+	resultInt := int32(0)
+	resultBigint := new(*int64)
+	resultText := new(*string)
+	resultDec := new(*inf.Dec)
+	assert.True(t, iter.Scan(&resultInt, resultBigint, resultText, resultDec))
+	assert.Nil(t, iter.Err())
+	assert.Equal(t, int64(2), **resultBigint)
+	assert.Equal(t, "1", **resultText)
+	assert.Equal(t, *float64ToDecNoCheck(float64(2.2)), **resultDec)
+
+	// Same pointers, values become nil
+	iter = s.Query(`SELECT f_int, f_bigint, f_text, f_dec FROM ks1.t1 WHERE f_int = 2`).Iter()
+	assert.True(t, iter.Scan(&resultInt, resultBigint, resultText, resultDec))
+	assert.Nil(t, iter.Err())
+	assert.Equal(t, int32(2), resultInt)
+	assert.Nil(t, *resultBigint)
+	assert.Nil(t, *resultText)
+	assert.Nil(t, *resultDec)
+}
@@ -180,6 +180,42 @@ func TestPageSize(t *testing.T) {
 	assert.Equal(t, int64(4), resultB)
 }
 
+func TestClusteringRangeOrderByLimit(t *testing.T) {
+	s := NewGocqlmemSession()
+	assert.Nil(t, s.Query("CREATE KEYSPACE ks1").Exec())
+	// No WITH CLUSTERING ORDER BY: clustering columns are ASC
+	assert.Nil(t, s.Query("CREATE TABLE ks1.t1 (a text, b text, c bigint, primary key ((a), b, c))").Exec())
+	for _, q := range []string{
+		"INSERT INTO ks1.t1 (a,b,c) VALUES ('x','20',4)",
+		"INSERT INTO ks1.t1 (a,b,c) VALUES ('x','05',1)",
+		"INSERT INTO ks1.t1 (a,b,c) VALUES ('y','07',5)",
+		"INSERT INTO ks1.t1 (a,b,c) VALUES ('x','10',2)",
+	} {
+		assert.Nil(t, s.Query(q).Exec())
+	}
+
+	rows, err := s.Query("SELECT b,c FROM ks1.t1 WHERE a = ? AND b <= ? ORDER BY b DESC LIMIT 1", "x", "15").Iter().SliceMap()
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"b": "10", "c": int64(2)}}, rows)
+
+	rows, err = s.Query("SELECT b,c FROM ks1.t1 WHERE a = ? AND b >= ? ORDER BY b ASC LIMIT 1", "x", "06").Iter().SliceMap()
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"b": "10", "c": int64(2)}}, rows)
+
+	rows, err = s.Query("SELECT b,c FROM ks1.t1 WHERE a = ? AND b <= ? ORDER BY b DESC LIMIT 1", "x", "04").Iter().SliceMap()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(rows))
+
+	// LIMIT wins over page size, and there is no next page
+	iter := s.Query("SELECT b,c FROM ks1.t1 WHERE a = ? ORDER BY b ASC LIMIT 2", "x").PageSize(10).Iter()
+	rows, err = iter.SliceMap()
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"b": "05", "c": int64(1)}, {"b": "10", "c": int64(2)}}, rows)
+	rows, err = s.Query("SELECT b,c FROM ks1.t1 WHERE a = ? ORDER BY b ASC LIMIT 2", "x").PageSize(10).PageState(iter.PageState()).Iter().SliceMap()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(rows))
+}
+
 func TestUuid(t *testing.T) {
 	s := NewGocqlmemSession()
 	assert.Nil(t, s.Query("CREATE KEYSPACE ks1").Exec())
@@ -28,7 +28,9 @@ func (is *iterScanner) Scan(dest ...any) error {
 
 	for i := range len(is.Cols) {
 		if is.Cols[i] == nil {
-			dest[i] = nil
+			if !nullToProvidedPtrPtr(dest[i]) {
+				dest[i] = nil
+			}
 		} else {
 			if err := clientTypedValueToProvidedPtr(is.Cols[i], dest[i]); err != nil {
 				return fmt.Errorf("cannot scan column %d: %s", i, err.Error())
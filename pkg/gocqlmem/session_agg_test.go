@@ -366,3 +366,22 @@ func TestSumPrecision(t *testing.T) {
 	}
 	assertIterScan(t, "[[15.299999999999999,15.299999999999999,15.3]]", s, "SELECT sum(v1), sum(v2), sum(v3) from ks1.t1")
 }
+
+func TestStatisticalAggregates(t *testing.T) {
+	s := NewGocqlmemSession()
+	assert.Nil(t, s.Query("CREATE KEYSPACE ks1").Exec())
+	assert.Nil(t, s.Query("CREATE TABLE ks1.t1 (a int, b int, c double, d text, e boolean, primary key (a, b))").Exec())
+
+	assertIterScan(t, "[[0,0,<nil>,<nil>,<nil>]]", s, "SELECT count_distinct(d), stddev(c), median(c), first(d, b), bool_and(e) FROM ks1.t1")
+
+	existingRowMap := map[string]any{}
+
+	assertUpserMapScanCas(t, true, s, "INSERT INTO ks1.t1 (a, b, c, d, e) VALUES (1, 1, 2.0, 'x', true)", existingRowMap)
+	assertUpserMapScanCas(t, true, s, "INSERT INTO ks1.t1 (a, b, c, d, e) VALUES (1, 2, 4.0, 'y', true)", existingRowMap)
+	assertUpserMapScanCas(t, true, s, "INSERT INTO ks1.t1 (a, b, c, d, e) VALUES (1, 3, 9.0, 'x', false)", existingRowMap)
+
+	assertIterScan(t, "[[2,13,4,x,x,false,true]]", s,
+		"SELECT count_distinct(d), variance(c), median(c), first(d, b), last(d, c), bool_and(e), bool_or(e) FROM ks1.t1")
+	assertIterScan(t, "[[2,4]]", s, "SELECT percentile(b, 0.5), percentile(c, 0.5, 'tdigest') FROM ks1.t1 WHERE a = 1")
+	assertIterSliceMap(t, "[map[p90:8]]", "", s, "SELECT percentile(c, 0.9) AS p90 FROM ks1.t1")
+}
@@ -10,6 +10,7 @@ import (
 	"go/token"
 	"math"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -55,6 +56,10 @@ func createColDef(name string, mapColType map[string]gocql.Type, primaryKeyType
 	clusteringOrder, ok := mapColClusteringOrder[name]
 	if !ok {
 		clusteringOrder = ClusteringOrderNone
+		// Cassandra sorts clustering columns ASC unless WITH CLUSTERING ORDER BY says otherwise
+		if primaryKeyType == PrimaryKeyClustering {
+			clusteringOrder = ClusteringOrderAsc
+		}
 	}
 	// For partition keys force ASC, we need it for our internal purposes when we walk through values
 	if primaryKeyType == PrimaryKeyPartition {
@@ -1071,6 +1076,18 @@ func (t *tableStore) execSelect(cmd *CommandSelect, lastSelectedRowIdx int, maxR
 		maxRows = -1
 	}
 
+	// LIMIT does not affect aggregates, and it's applied only to the first page
+	limitRows := -1
+	if cmd.Limit != nil && !isAgg && lastSelectedRowIdx == -1 {
+		limitRows, err = strconv.Atoi(cmd.Limit.V)
+		if err != nil {
+			return nil, nil, nil, -1, fmt.Errorf("cannot use limit %s: %s", cmd.Limit.V, err.Error())
+		}
+		if maxRows <= 0 || limitRows < maxRows {
+			maxRows = limitRows
+		}
+	}
+
 	resultRows := [][]any{}
 	valMap := eval.VarValuesMap{}
 	valMap[""] = map[string]any{}
@@ -1148,6 +1165,11 @@ func (t *tableStore) execSelect(cmd *CommandSelect, lastSelectedRowIdx int, maxR
 			}
 
 			selectedRowCount++
+			if selectedRowCount == limitRows {
+				// Nothing left for the next page
+				newLastSelectedRowIdx = selectSeq[len(selectSeq)-1]
+				break
+			}
 			if selectedRowCount == maxRows {
 				break
 			}
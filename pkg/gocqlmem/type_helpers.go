@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -271,7 +272,26 @@ func compareInternalKnownType(left any, right any, cqlType gocql.Type) (int, err
 }
 
 // gocql type matching on data return
+// Like gocql: pointer to pointer, used for nullable values, gets nil. Returns false for other dest types.
+func nullToProvidedPtrPtr(destPtr any) bool {
+	v := reflect.ValueOf(destPtr)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Pointer {
+		return false
+	}
+	v.Elem().SetZero()
+	return true
+}
+
 func clientTypedValueToProvidedPtr(src any, destPtr any) error {
+	// Pointer to pointer, like **int64: allocate the value
+	if v := reflect.ValueOf(destPtr); v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Pointer {
+		newPtr := reflect.New(v.Elem().Type().Elem())
+		if err := clientTypedValueToProvidedPtr(src, newPtr.Interface()); err != nil {
+			return err
+		}
+		v.Elem().Set(newPtr)
+		return nil
+	}
 	switch typedSrc := src.(type) {
 	case int64:
 		switch typedDestPtr := destPtr.(type) {
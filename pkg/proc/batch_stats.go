@@ -2,6 +2,7 @@ package proc
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -23,6 +24,11 @@ type BatchStats struct {
 	IdxElapsedMax    int64
 	IdxElapsedTotal  int64
 	IdxElapsedAvg    float64
+	RuleViolations   map[string]int64 // Data quality nodes only
+	BadRows          int64            // File readers only, rows skipped because of max_bad_rows
+	Files            []string         // Partitioned file creators only, files produced by the batch
+	RowGroupsTotal   int              // Parquet readers only
+	RowGroupsSkipped int              // Parquet readers only, row groups skipped by reader filter statistics check
 }
 
 func (bs *BatchStats) UpdateElapsedStats(dur time.Duration, instr *TableInserter) {
@@ -42,6 +48,27 @@ func (bs *BatchStats) ToString() string {
 	if bs.RowsRead > 0 {
 		fmt.Fprintf(&sb, "row_writes: %d, %.1f w/s; ", bs.RowsWritten, float64(bs.RowsWritten)/s)
 	}
+	if bs.BadRows > 0 {
+		fmt.Fprintf(&sb, "bad_rows: %d; ", bs.BadRows)
+	}
+	if bs.RowGroupsTotal > 0 {
+		fmt.Fprintf(&sb, "row_groups_skipped: %d/%d; ", bs.RowGroupsSkipped, bs.RowGroupsTotal)
+	}
+	if len(bs.Files) > 0 {
+		fmt.Fprintf(&sb, "files: %d, %s; ", len(bs.Files), strings.Join(bs.Files, ", "))
+	}
+	if len(bs.RuleViolations) > 0 {
+		ruleNames := make([]string, 0, len(bs.RuleViolations))
+		for ruleName := range bs.RuleViolations {
+			ruleNames = append(ruleNames, ruleName)
+		}
+		sort.Strings(ruleNames)
+		ruleViolations := make([]string, len(ruleNames))
+		for i, ruleName := range ruleNames {
+			ruleViolations[i] = fmt.Sprintf("%s %d", ruleName, bs.RuleViolations[ruleName])
+		}
+		fmt.Fprintf(&sb, "rule_violations: %s; ", strings.Join(ruleViolations, ", "))
+	}
 	if bs.DataCount > 0 {
 		fmt.Fprintf(&sb, "data_inserts: %d, min/avg/max %.4f / %.4f / %.4f s, total %.4f s; ", bs.DataCount, float64(bs.DataElapsedMin)/1000000000.0, bs.DataElapsedAvg, float64(bs.DataElapsedMax)/1000000000.0, float64(bs.DataElapsedTotal)/1000000000.0)
 	}
@@ -34,7 +34,11 @@ func CallAppropriateProcessorForBatch(envConfig *env.EnvConfig, logger *l.CapiLo
 
 	switch pCtx.CurrentScriptNode.Type {
 	case sc.NodeTypeFileTable:
-		if pCtx.Msg.FirstToken != pCtx.Msg.LastToken || pCtx.Msg.FirstToken < 0 || pCtx.Msg.FirstToken >= int64(len(pCtx.CurrentScriptNode.FileReader.SrcFileUrls)) {
+		if pCtx.CurrentScriptNode.FileReader.IsSplit() {
+			if err = pCtx.CurrentScriptNode.FileReader.CheckSplitTokens(pCtx.Msg.FirstToken, pCtx.Msg.LastToken); err == nil {
+				bs, err = runReadFileForBatch(envConfig, logger, pCtx, int(pCtx.Msg.FirstToken))
+			}
+		} else if pCtx.Msg.FirstToken != pCtx.Msg.LastToken || pCtx.Msg.FirstToken < 0 || pCtx.Msg.FirstToken >= int64(len(pCtx.CurrentScriptNode.FileReader.SrcFileUrls)) {
 			err = fmt.Errorf(
 				"startToken %d must equal endToken %d and must be smaller than the number of files specified by file reader %d",
 				pCtx.Msg.FirstToken,
@@ -47,12 +51,21 @@ func CallAppropriateProcessorForBatch(envConfig *env.EnvConfig, logger *l.CapiLo
 	case sc.NodeTypeTableTable:
 		bs, err = runCreateTableForBatch(envConfig, logger, pCtx, readerNodeRunId, pCtx.Msg.FirstToken, pCtx.Msg.LastToken)
 
+	case sc.NodeTypeTableUnionTable:
+		bs, err = runCreateTableForUnionBatch(envConfig, logger, pCtx, readerNodeRunId, pCtx.Msg.FirstToken, pCtx.Msg.LastToken)
+
 	case sc.NodeTypeDistinctTable:
 		bs, err = runCreateDistinctTableForBatch(envConfig, logger, pCtx, readerNodeRunId, pCtx.Msg.FirstToken, pCtx.Msg.LastToken)
 
 	case sc.NodeTypeTableLookupTable:
 		bs, err = runCreateTableRelForBatch(envConfig, logger, pCtx, readerNodeRunId, lookupNodeRunId, pCtx.Msg.FirstToken, pCtx.Msg.LastToken)
 
+	case sc.NodeTypeTableWindowTable:
+		bs, err = runCreateTableWindowForBatch(envConfig, logger, pCtx, readerNodeRunId, pCtx.Msg.FirstToken, pCtx.Msg.LastToken)
+
+	case sc.NodeTypeTableDqTable:
+		bs, err = runCreateTableDataQualityForBatch(envConfig, logger, pCtx, readerNodeRunId, pCtx.Msg.FirstToken, pCtx.Msg.LastToken)
+
 	case sc.NodeTypeTableFile:
 		bs, err = runCreateFile(envConfig, logger, pCtx, readerNodeRunId, pCtx.Msg.FirstToken, pCtx.Msg.LastToken)
 
@@ -0,0 +1,60 @@
+package proc
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Stream-decompresses source file data, the caller closes the returned reader
+func newDecompressingReader(r io.Reader, compression sc.CompressionType) (io.ReadCloser, error) {
+	switch compression {
+	case sc.CompressionNone:
+		return io.NopCloser(r), nil
+	case sc.CompressionGzip:
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open gzip stream: %s", err.Error())
+		}
+		return gzipReader, nil
+	case sc.CompressionZstd:
+		zstdDecoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open zstd stream: %s", err.Error())
+		}
+		return zstdDecoder.IOReadCloser(), nil
+	case sc.CompressionBzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %s", compression)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Stream-compresses output: the whole file is one gzip member or zstd frame.
+// The caller closes the returned writer before closing w
+func newCompressingWriter(w io.Writer, compression sc.CompressionType) (io.WriteCloser, error) {
+	switch compression {
+	case sc.CompressionNone:
+		return nopWriteCloser{w}, nil
+	case sc.CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case sc.CompressionZstd:
+		zstdEncoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create zstd writer: %s", err.Error())
+		}
+		return zstdEncoder, nil
+	default:
+		return nil, fmt.Errorf("unsupported output compression %s", compression)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/capillariesio/capillaries/pkg/db"
 	"github.com/capillariesio/capillaries/pkg/gocqlshims"
 	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
 )
 
 const MaxAmazonKeyspacesBatchLen int = 30
@@ -216,6 +217,92 @@ func selectBatchFromIdxTablePaged(logger *l.CapiLogger,
 	return nextPageState, nil
 }
 
+// Index keys are partition keys, so all rows with the same key have the same token and are returned by the same call
+func selectBatchFromIdxTableByTokenPaged(logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	rs *Rowset,
+	tableName string,
+	runId int16,
+	batchSize int,
+	pageState []byte,
+	startToken int64,
+	endToken int64) ([]byte, error) {
+
+	logger.PushF("proc.selectBatchFromIdxTableByTokenPaged")
+	defer logger.PopF()
+
+	if err := rs.InitRows(batchSize); err != nil {
+		return nil, err
+	}
+
+	qb := cql.QueryBuilder{}
+	q := qb.Keyspace(pCtx.Msg.DataKeyspace).
+		CondPrepared("token(key)", ">=").
+		CondPrepared("token(key)", "<=").
+		SelectRun(tableName, runId, *rs.GetFieldNames())
+
+	iter := pCtx.CqlSession.Query(q, startToken, endToken).PageSize(batchSize).PageState(pageState).Iter()
+	if iter.Err() != nil {
+		return nil, db.WrapDbErrorWithQuery("cannot create iterator", q, iter.Err())
+	}
+	nextPageState := iter.PageState()
+
+	dbWarnings := iter.Warnings()
+	if len(dbWarnings) > 0 {
+		logger.WarnCtx(pCtx, "%s", strings.Join(dbWarnings, ";"))
+	}
+
+	rs.RowCount = 0
+
+	scanner := iter.Scanner()
+	for scanner.Next() {
+		if rs.RowCount >= len(rs.Rows) {
+			return nil, fmt.Errorf("unexpected idx row retrieved, exceeding rowset size %d", len(rs.Rows))
+		}
+		if err := scanner.Scan(*rs.Rows[rs.RowCount]...); err != nil {
+			return nil, db.WrapDbErrorWithQuery("cannot scan idx row", q, err)
+		}
+		rs.RowCount++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, db.WrapDbErrorWithQuery("idx scanner error", q, err)
+	}
+
+	return nextPageState, nil
+}
+
+// As_of idx rows with the same key are sorted by as_of_key, so the nearest row is the first one in the requested range
+func selectNearestRowidFromAsOfIdxTable(pCtx *ctx.MessageProcessingContext,
+	idxName string,
+	lookupNodeRunId int16,
+	key string,
+	asOfKey string,
+	direction sc.AsOfDirection) (int64, bool, error) {
+
+	asOfKeyOp := "<="
+	asOfKeyOrder := "as_of_key DESC"
+	if direction == sc.AsOfForward {
+		asOfKeyOp = ">="
+		asOfKeyOrder = "as_of_key ASC"
+	}
+
+	qb := cql.QueryBuilder{}
+	q := qb.Keyspace(pCtx.Msg.DataKeyspace).
+		CondPrepared("key", "=").
+		CondPrepared("as_of_key", asOfKeyOp).
+		OrderBy(asOfKeyOrder).
+		Limit(1).
+		SelectRun(idxName, lookupNodeRunId, []string{"rowid"})
+
+	iter := pCtx.CqlSession.Query(q, key, asOfKey).Iter()
+	var rowid int64
+	isFound := iter.Scan(&rowid)
+	if err := iter.Close(); err != nil {
+		return 0, false, db.WrapDbErrorWithQuery("cannot select nearest idx row", q, err)
+	}
+	return rowid, isFound, nil
+}
+
 func selectBatchFromTableByToken(logger *l.CapiLogger,
 	pCtx *ctx.MessageProcessingContext,
 	rs *Rowset,
@@ -362,3 +449,20 @@ func deleteIdxRecordByKey(pCtx *ctx.MessageProcessingContext, idxName string, ke
 	}
 	return nil
 }
+
+// As_of idx rows are deleted one by one: key alone would delete rows of other batches
+func deleteAsOfIdxRecords(pCtx *ctx.MessageProcessingContext, idxName string, fullKeys []string, rowids []int64) error {
+	for i, fullKey := range fullKeys {
+		key, asOfKey := sc.SplitAsOfIdxKey(fullKey)
+		q := (&cql.QueryBuilder{}).
+			Keyspace(pCtx.Msg.DataKeyspace).
+			Cond("key", "=", key).
+			Cond("as_of_key", "=", asOfKey).
+			Cond("rowid", "=", rowids[i]).
+			DeleteRun(idxName, pCtx.Msg.RunId)
+		if err := pCtx.CqlSession.Query(q).Exec(); err != nil {
+			return db.WrapDbErrorWithQuery("cannot delete from as_of idx table", q, err)
+		}
+	}
+	return nil
+}
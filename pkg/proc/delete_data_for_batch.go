@@ -17,9 +17,9 @@ const HarvestForDeleteRowsetSize = 1000 // Do not let users tweak it, maybe too
 
 func initRowidsAndKeysToDelete(rowCount int, indexesMap sc.IdxDefMap) ([]int64, map[string][]string) {
 	rowIdsToDelete := make([]int64, rowCount)
-	uniqueKeysToDeleteMap := map[string][]string{} // unique_idx_name -> list_of_keys_to_delete
+	uniqueKeysToDeleteMap := map[string][]string{} // unique_or_as_of_idx_name -> list_of_keys_to_delete
 	for idxName, idxDef := range indexesMap {
-		if idxDef.Uniqueness == sc.IdxUnique {
+		if idxDef.Uniqueness == sc.IdxUnique || idxDef.Uniqueness == sc.IdxAsOf {
 			uniqueKeysToDeleteMap[idxName] = make([]string, rowCount)
 		}
 	}
@@ -88,9 +88,9 @@ func DeleteDataAndUniqueIndexesByBatchIdx(logger *l.CapiLogger, pCtx *ctx.Messag
 	deleteStartTime := time.Now()
 
 	// Retrieve ALL records from data table (we cannot filter by batch_idx, this is Cassandra),
-	// retrieve all fields that are involved in building unique indexes.
+	// retrieve all fields that are involved in building unique and as_of indexes.
 	// It may take a while, but there is no other way.
-	uniqueIdxFieldRefs := pCtx.CurrentScriptNode.GetUniqueIndexesFieldRefs()
+	uniqueIdxFieldRefs := pCtx.CurrentScriptNode.GetUniqueAndAsOfIndexesFieldRefs()
 	rs := NewRowsetFromFieldRefs(
 		sc.FieldRefs{sc.RowidFieldRef(pCtx.CurrentScriptNode.TableCreator.Name)},
 		*uniqueIdxFieldRefs,
@@ -163,7 +163,12 @@ func DeleteDataAndUniqueIndexesByBatchIdx(logger *l.CapiLogger, pCtx *ctx.Messag
 				// Trim unused empty key slots
 				trimmedIdxKeysToDelete := idxKeysToDelete[:rowIdsToDeleteCount]
 				logger.DebugCtx(pCtx, "deleting %d idx %s records from %d/%s idx %s for batch_idx %d: '%s'", len(rowIdsToDelete), idxName, pCtx.Msg.RunId, pCtx.Msg.TargetNodeName, idxName, pCtx.Msg.BatchIdx, strings.Join(trimmedIdxKeysToDelete, `','`))
-				if err := deleteIdxRecordByKey(pCtx, idxName, trimmedIdxKeysToDelete); err != nil {
+				if pCtx.CurrentScriptNode.TableCreator.Indexes[idxName].Uniqueness == sc.IdxAsOf {
+					// Many rowids share an as_of idx key, delete only idx records pointing to deleted rowids
+					if err := deleteAsOfIdxRecords(pCtx, idxName, trimmedIdxKeysToDelete, rowIdsToDelete); err != nil {
+						return err
+					}
+				} else if err := deleteIdxRecordByKey(pCtx, idxName, trimmedIdxKeysToDelete); err != nil {
 					return err
 				}
 			}
@@ -0,0 +1,568 @@
+package proc
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/capillariesio/capillaries/pkg/storage"
+	"github.com/capillariesio/capillaries/pkg/xfer"
+	gp "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/parquet"
+	"github.com/shopspring/decimal"
+)
+
+// Batch file downloaded to a local temp file, unless it's local already
+type finalizePart struct {
+	Url       string
+	LocalPath string
+	IsTemp    bool
+	f         *os.File
+}
+
+func openFinalizePart(envConfig *env.EnvConfig, partUrl string) (*finalizePart, error) {
+	u, err := url.Parse(partUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse batch file url %s: %s", partUrl, err.Error())
+	}
+
+	part := finalizePart{Url: partUrl}
+	switch u.Scheme {
+	case "":
+		part.LocalPath = partUrl
+	case xfer.UrlSchemeFile:
+		part.LocalPath = u.Path
+	case xfer.UrlSchemeS3, xfer.UrlSchemeSftp:
+		dstFile, err := os.CreateTemp("", "capi")
+		if err != nil {
+			return nil, fmt.Errorf("cannot create temp file for %s: %s", partUrl, err.Error())
+		}
+		part.LocalPath = dstFile.Name()
+		part.IsTemp = true
+		if u.Scheme == xfer.UrlSchemeS3 {
+			var readCloser io.ReadCloser
+			readCloser, err = xfer.GetS3ReadCloser(partUrl)
+			if err == nil {
+				_, err = io.Copy(dstFile, readCloser)
+				readCloser.Close()
+			}
+		} else {
+			err = xfer.DownloadSftpFile(partUrl, envConfig.PrivateKeys, dstFile)
+		}
+		dstFile.Close()
+		if err != nil {
+			os.Remove(part.LocalPath)
+			return nil, fmt.Errorf("cannot download batch file %s: %s", partUrl, err.Error())
+		}
+	default:
+		return nil, fmt.Errorf("cannot read batch file %s: url scheme %s not supported", partUrl, u.Scheme)
+	}
+
+	part.f, err = os.Open(part.LocalPath)
+	if err != nil {
+		part.close()
+		return nil, fmt.Errorf("cannot open batch file %s: %s", partUrl, err.Error())
+	}
+	return &part, nil
+}
+
+func (part *finalizePart) close() {
+	if part.f != nil {
+		part.f.Close()
+		part.f = nil
+	}
+	if part.IsTemp {
+		os.Remove(part.LocalPath)
+	}
+}
+
+// Called once per node, when all batches have succeeded: merges batch files into finalize.url
+func FinalizeFileCreator(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext) error {
+	logger.PushF("proc.FinalizeFileCreator")
+	defer logger.PopF()
+
+	fileCreator := &pCtx.CurrentScriptNode.FileCreator
+	if !fileCreator.HasFinalize() {
+		return nil
+	}
+
+	startTime := time.Now()
+
+	partUrls := make([]string, pCtx.Msg.BatchesTotal)
+	for batchIdx := range partUrls {
+		partUrls[batchIdx] = fileCreator.GetPartUrl(pCtx.Msg.RunId, int16(batchIdx))
+	}
+	finalUrl := fileCreator.GetFinalizeUrl(pCtx.Msg.RunId)
+
+	var err error
+	if fileCreator.HasTop() {
+		err = mergeTopParts(envConfig, logger, pCtx, partUrls, finalUrl)
+	} else if fileCreator.CreatorFileType == sc.CreatorFileTypeParquet {
+		err = concatParquetParts(envConfig, pCtx, partUrls, finalUrl)
+	} else {
+		err = concatTextParts(envConfig, pCtx, partUrls, finalUrl)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot merge %d batch files into %s: %s", len(partUrls), finalUrl, err.Error())
+	}
+	logger.InfoCtx(pCtx, "merged %d batch files into %s in %.3fs", len(partUrls), finalUrl, time.Since(startTime).Seconds())
+
+	if fileCreator.Finalize.DeleteParts {
+		for _, partUrl := range partUrls {
+			// The final file is complete, leftovers are not a reason to fail the node
+			if err := xfer.DeleteFile(partUrl, envConfig.PrivateKeys); err != nil {
+				logger.WarnCtx(pCtx, "cannot delete batch file after merging: %s", err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// CSV and JSON Lines: stream batch files one after another, CSV header is taken from the first file only
+func concatTextParts(envConfig *env.EnvConfig, pCtx *ctx.MessageProcessingContext, partUrls []string, finalUrl string) error {
+	fileCreator := &pCtx.CurrentScriptNode.FileCreator
+
+	uw, err := xfer.NewStreamUploadWriter(finalUrl, envConfig.PrivateKeys)
+	if err != nil {
+		return err
+	}
+	cw, err := newCompressingWriter(uw, fileCreator.GetCompression())
+	if err != nil {
+		uw.Abort(err)
+		return err
+	}
+
+	for partIdx, partUrl := range partUrls {
+		skipHeader := partIdx > 0 && fileCreator.CreatorFileType == sc.CreatorFileTypeCsv
+		if err := copyTextPart(envConfig, fileCreator, partUrl, skipHeader, cw); err != nil {
+			cw.Close()
+			uw.Abort(err)
+			return err
+		}
+		pCtx.SendHeartbeat()
+	}
+
+	if err := cw.Close(); err != nil {
+		uw.Abort(err)
+		return fmt.Errorf("cannot complete compressed data: %s", err.Error())
+	}
+	return uw.Close()
+}
+
+func copyTextPart(envConfig *env.EnvConfig, fileCreator *sc.FileCreatorDef, partUrl string, skipHeader bool, w io.Writer) error {
+	part, err := openFinalizePart(envConfig, partUrl)
+	if err != nil {
+		return err
+	}
+	defer part.close()
+
+	decompressingReader, err := newDecompressingReader(bufio.NewReader(part.f), fileCreator.GetCompression())
+	if err != nil {
+		return fmt.Errorf("cannot read compressed batch file %s: %s", partUrl, err.Error())
+	}
+	defer decompressingReader.Close()
+
+	r := bufio.NewReader(decompressingReader)
+	if skipHeader {
+		if _, err := r.ReadString('\n'); err != nil && err != io.EOF {
+			return fmt.Errorf("cannot read header of batch file %s: %s", partUrl, err.Error())
+		}
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("cannot copy batch file %s: %s", partUrl, err.Error())
+	}
+	return nil
+}
+
+// Parquet: copy rows without converting them, each batch file row group becomes a row group in the final file
+func concatParquetParts(envConfig *env.EnvConfig, pCtx *ctx.MessageProcessingContext, partUrls []string, finalUrl string) error {
+	fileCreator := &pCtx.CurrentScriptNode.FileCreator
+
+	uw, err := xfer.NewStreamUploadWriter(finalUrl, envConfig.PrivateKeys)
+	if err != nil {
+		return err
+	}
+	w, err := storage.NewParquetWriter(uw, fileCreator.Parquet)
+	if err != nil {
+		uw.Abort(err)
+		return err
+	}
+	for i := 0; i < len(fileCreator.Columns); i++ {
+		if err := w.AddColumn(fileCreator.Columns[i].Parquet.ColumnName, fileCreator.Columns[i].Type, &fileCreator.Columns[i].Parquet); err != nil {
+			uw.Abort(err)
+			return err
+		}
+	}
+
+	for _, partUrl := range partUrls {
+		if err := copyParquetPart(envConfig, partUrl, w); err != nil {
+			uw.Abort(err)
+			return err
+		}
+		pCtx.SendHeartbeat()
+	}
+
+	if err := w.Close(); err != nil {
+		uw.Abort(err)
+		return err
+	}
+	return uw.Close()
+}
+
+func copyParquetPart(envConfig *env.EnvConfig, partUrl string, w *storage.ParquetWriter) error {
+	part, err := openFinalizePart(envConfig, partUrl)
+	if err != nil {
+		return err
+	}
+	defer part.close()
+
+	reader, err := gp.NewFileReader(part.f)
+	if err != nil {
+		return fmt.Errorf("cannot read parquet batch file %s: %s", partUrl, err.Error())
+	}
+	for rowGroupIdx := 0; rowGroupIdx < reader.RowGroupCount(); rowGroupIdx++ {
+		rowCount, err := reader.RowGroupNumRows()
+		if err != nil {
+			return fmt.Errorf("cannot read row group %d of parquet batch file %s: %s", rowGroupIdx, partUrl, err.Error())
+		}
+		for rowIdx := int64(0); rowIdx < rowCount; rowIdx++ {
+			d, err := reader.NextRow()
+			if err != nil {
+				return fmt.Errorf("cannot read row %d of row group %d of parquet batch file %s: %s", rowIdx, rowGroupIdx, partUrl, err.Error())
+			}
+			if err := w.AddData(d); err != nil {
+				return fmt.Errorf("cannot copy row %d of row group %d of parquet batch file %s: %s", rowIdx, rowGroupIdx, partUrl, err.Error())
+			}
+		}
+		if err := w.FlushRowGroup(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads typed file records back from a batch file, returns io.EOF when there are no more records
+type finalizePartReader interface {
+	next() ([]any, error)
+}
+
+type csvFinalizePartReader struct {
+	fileCreator *sc.FileCreatorDef
+	partUrl     string
+	r           storage.CsvRecordReader
+}
+
+func newCsvFinalizePartReader(fileCreator *sc.FileCreatorDef, partUrl string, fileReader io.Reader) (*csvFinalizePartReader, error) {
+	r, err := storage.NewCsvRecordReader(fileReader, &sc.CsvReaderSettings{Separator: fileCreator.Csv.Separator})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Read(); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("cannot read header of batch file %s: %s", partUrl, err.Error())
+	}
+	return &csvFinalizePartReader{fileCreator: fileCreator, partUrl: partUrl, r: r}, nil
+}
+
+func parseCsvFinalizeValue(colDef *sc.WriteFileColumnDef, s string) (any, error) {
+	switch evalcapi.FieldTypeKind(colDef.Type) {
+	case evalcapi.FieldTypeString:
+		return s, nil
+	case evalcapi.FieldTypeInt:
+		return strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	case evalcapi.FieldTypeFloat:
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	case evalcapi.FieldTypeDecimal2:
+		return decimal.NewFromString(strings.TrimSpace(s))
+	case evalcapi.FieldTypeBool:
+		return strconv.ParseBool(strings.TrimSpace(s))
+	case evalcapi.FieldTypeDateTime:
+		return time.Parse(colDef.Csv.Format, s)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", colDef.Type)
+	}
+}
+
+func (pr *csvFinalizePartReader) next() ([]any, error) {
+	line, err := pr.r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot read batch file %s: %s", pr.partUrl, err.Error())
+	}
+	if len(line) != len(pr.fileCreator.Columns) {
+		return nil, fmt.Errorf("cannot read batch file %s: expected %d columns, got %d", pr.partUrl, len(pr.fileCreator.Columns), len(line))
+	}
+	fileRecord := make([]any, len(line))
+	for i := range line {
+		colDef := &pr.fileCreator.Columns[i]
+		if fileRecord[i], err = parseCsvFinalizeValue(colDef, line[i]); err != nil {
+			return nil, fmt.Errorf("cannot parse column %s value [%s] in batch file %s: %s", colDef.Name, line[i], pr.partUrl, err.Error())
+		}
+	}
+	return fileRecord, nil
+}
+
+type jsonlFinalizePartReader struct {
+	fileCreator *sc.FileCreatorDef
+	partUrl     string
+	dec         *json.Decoder
+}
+
+func newJsonlFinalizePartReader(fileCreator *sc.FileCreatorDef, partUrl string, fileReader io.Reader) *jsonlFinalizePartReader {
+	dec := json.NewDecoder(fileReader)
+	dec.UseNumber()
+	return &jsonlFinalizePartReader{fileCreator: fileCreator, partUrl: partUrl, dec: dec}
+}
+
+func parseJsonlFinalizeValue(colDef *sc.WriteFileColumnDef, val any) (any, error) {
+	switch typedVal := val.(type) {
+	case string:
+		switch evalcapi.FieldTypeKind(colDef.Type) {
+		case evalcapi.FieldTypeString:
+			return typedVal, nil
+		case evalcapi.FieldTypeDateTime:
+			return time.Parse(colDef.Jsonl.Format, typedVal)
+		}
+	case json.Number:
+		switch evalcapi.FieldTypeKind(colDef.Type) {
+		case evalcapi.FieldTypeInt:
+			return typedVal.Int64()
+		case evalcapi.FieldTypeFloat:
+			return typedVal.Float64()
+		case evalcapi.FieldTypeDecimal2:
+			return decimal.NewFromString(typedVal.String())
+		}
+	case bool:
+		if colDef.Type == evalcapi.FieldTypeBool {
+			return typedVal, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %T for %s", val, colDef.Type)
+}
+
+func (pr *jsonlFinalizePartReader) next() ([]any, error) {
+	var doc map[string]any
+	if err := pr.dec.Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot read batch file %s: %s", pr.partUrl, err.Error())
+	}
+	fileRecord := make([]any, len(pr.fileCreator.Columns))
+	for i := range pr.fileCreator.Columns {
+		colDef := &pr.fileCreator.Columns[i]
+		var err error
+		if fileRecord[i], err = parseJsonlFinalizeValue(colDef, doc[colDef.Jsonl.Key]); err != nil {
+			return nil, fmt.Errorf("cannot parse column %s value [%v] in batch file %s: %s", colDef.Name, doc[colDef.Jsonl.Key], pr.partUrl, err.Error())
+		}
+	}
+	return fileRecord, nil
+}
+
+type parquetFinalizePartReader struct {
+	fileCreator      *sc.FileCreatorDef
+	partUrl          string
+	reader           *gp.FileReader
+	schemaElementMap map[string]*parquet.SchemaElement
+}
+
+func newParquetFinalizePartReader(fileCreator *sc.FileCreatorDef, partUrl string, fileReadSeeker io.ReadSeeker) (*parquetFinalizePartReader, error) {
+	reader, err := gp.NewFileReader(fileReadSeeker)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read parquet batch file %s: %s", partUrl, err.Error())
+	}
+	schemaElementMap := map[string]*parquet.SchemaElement{}
+	for _, column := range reader.GetSchemaDefinition().RootColumn.Children {
+		schemaElementMap[column.SchemaElement.Name] = column.SchemaElement
+	}
+	for i := range fileCreator.Columns {
+		if _, ok := schemaElementMap[fileCreator.Columns[i].Parquet.ColumnName]; !ok {
+			return nil, fmt.Errorf("cannot find column %s in parquet batch file %s", fileCreator.Columns[i].Parquet.ColumnName, partUrl)
+		}
+	}
+	return &parquetFinalizePartReader{fileCreator: fileCreator, partUrl: partUrl, reader: reader, schemaElementMap: schemaElementMap}, nil
+}
+
+func (pr *parquetFinalizePartReader) next() ([]any, error) {
+	d, err := pr.reader.NextRow()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot read parquet batch file %s: %s", pr.partUrl, err.Error())
+	}
+	fileRecord := make([]any, len(pr.fileCreator.Columns))
+	for i := range pr.fileCreator.Columns {
+		colDef := &pr.fileCreator.Columns[i]
+		val := d[colDef.Parquet.ColumnName]
+		se := pr.schemaElementMap[colDef.Parquet.ColumnName]
+		switch evalcapi.FieldTypeKind(colDef.Type) {
+		case evalcapi.FieldTypeString:
+			fileRecord[i], err = storage.ParquetReadString(val, se)
+		case evalcapi.FieldTypeInt:
+			fileRecord[i], err = storage.ParquetReadInt(val, se)
+		case evalcapi.FieldTypeFloat:
+			fileRecord[i], err = storage.ParquetReadFloat(val, se)
+		case evalcapi.FieldTypeBool:
+			fileRecord[i], err = storage.ParquetReadBool(val, se)
+		case evalcapi.FieldTypeDateTime:
+			fileRecord[i], err = storage.ParquetReadDateTime(val, se)
+		case evalcapi.FieldTypeDecimal2:
+			fileRecord[i], err = storage.ParquetReadDecimal2(val, se)
+		default:
+			err = fmt.Errorf("unsupported type %s", colDef.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read column %s value [%v] in parquet batch file %s: %s", colDef.Name, val, pr.partUrl, err.Error())
+		}
+	}
+	return fileRecord, nil
+}
+
+type finalizeHeapItem struct {
+	FileRecord []any
+	Key        string
+	PartIdx    int
+}
+
+// Min-heap: batch files are already ordered, so the smallest key among their current records goes next
+type finalizeHeap []*finalizeHeapItem
+
+func (h finalizeHeap) Len() int           { return len(h) }
+func (h finalizeHeap) Less(i, j int) bool { return h[i].Key < h[j].Key }
+func (h finalizeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *finalizeHeap) Push(x any) {
+	item, ok := x.(*finalizeHeapItem)
+	if ok {
+		*h = append(*h, item)
+	}
+}
+func (h *finalizeHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[0 : n-1]
+	return item
+}
+
+func pushNextFinalizeRecord(fileCreator *sc.FileCreatorDef, h *finalizeHeap, partReaders []finalizePartReader, partIdx int) error {
+	fileRecord, err := partReaders[partIdx].next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	keyVars := map[string]any{}
+	for i := 0; i < len(fileCreator.Columns); i++ {
+		keyVars[fileCreator.Columns[i].Name] = fileRecord[i]
+	}
+	key, err := sc.BuildKey(keyVars, &fileCreator.Top.OrderIdxDef)
+	if err != nil {
+		return fmt.Errorf("cannot build top key for [%v]: [%s]", fileRecord, err.Error())
+	}
+	heap.Push(h, &finalizeHeapItem{FileRecord: fileRecord, Key: key, PartIdx: partIdx})
+	return nil
+}
+
+// Each batch wrote its own top, ordered: merge them and take top.limit records overall
+func mergeTopParts(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, partUrls []string, finalUrl string) error {
+	fileCreator := &pCtx.CurrentScriptNode.FileCreator
+
+	partReaders := make([]finalizePartReader, len(partUrls))
+	for partIdx, partUrl := range partUrls {
+		part, err := openFinalizePart(envConfig, partUrl)
+		if err != nil {
+			return err
+		}
+		defer part.close()
+
+		switch fileCreator.CreatorFileType {
+		case sc.CreatorFileTypeCsv, sc.CreatorFileTypeJsonl:
+			decompressingReader, err := newDecompressingReader(bufio.NewReader(part.f), fileCreator.GetCompression())
+			if err != nil {
+				return fmt.Errorf("cannot read compressed batch file %s: %s", partUrl, err.Error())
+			}
+			defer decompressingReader.Close()
+			if fileCreator.CreatorFileType == sc.CreatorFileTypeCsv {
+				if partReaders[partIdx], err = newCsvFinalizePartReader(fileCreator, partUrl, decompressingReader); err != nil {
+					return err
+				}
+			} else {
+				partReaders[partIdx] = newJsonlFinalizePartReader(fileCreator, partUrl, decompressingReader)
+			}
+		case sc.CreatorFileTypeParquet:
+			if partReaders[partIdx], err = newParquetFinalizePartReader(fileCreator, partUrl, part.f); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown file creator type: %d", fileCreator.CreatorFileType)
+		}
+	}
+
+	h := finalizeHeap{}
+	heap.Init(&h)
+	for partIdx := range partReaders {
+		if err := pushNextFinalizeRecord(fileCreator, &h, partReaders, partIdx); err != nil {
+			return err
+		}
+	}
+
+	instr, err := newPartitionedFileInserter(logger, pCtx, fileCreator, finalUrl, envConfig.PrivateKeys)
+	if err != nil {
+		return err
+	}
+
+	recordCount := 0
+	for h.Len() > 0 && recordCount < fileCreator.Top.Limit {
+		item, ok := heap.Pop(&h).(*finalizeHeapItem)
+		if !ok {
+			err = errors.New("cannot pop from the heap, expected *finalizeHeapItem")
+			break
+		}
+		if err = instr.add(logger, item.FileRecord); err != nil {
+			break
+		}
+		recordCount++
+		if err = pushNextFinalizeRecord(fileCreator, &h, partReaders, item.PartIdx); err != nil {
+			break
+		}
+		if recordCount%DefaultFileInserterBatchCapacity == 0 {
+			if err = instr.checkWorkerOutputForErrors(); err != nil {
+				break
+			}
+			pCtx.SendHeartbeat()
+		}
+	}
+
+	if err != nil {
+		if closeErr := instr.waitForWorkersAndCloseErrorsOut(logger, pCtx); closeErr != nil {
+			logger.ErrorCtx(pCtx, "unexpected error while calling waitForWorkersAndCloseErrorsOut: %s", closeErr.Error())
+		}
+		instr.abort(err)
+		return err
+	}
+
+	if err := instr.waitForWorkersAndCloseErrorsOut(logger, pCtx); err != nil {
+		instr.abort(err)
+		return err
+	}
+
+	return instr.close()
+}
@@ -2,11 +2,13 @@ package proc
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/capillariesio/capillaries/pkg/ctx"
 	"github.com/capillariesio/capillaries/pkg/l"
 	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/capillariesio/capillaries/pkg/xfer"
 )
 
 type FileInserter struct {
@@ -18,7 +20,9 @@ type FileInserter struct {
 	RecordWrittenStatuses chan error
 	BatchesSent           int
 	FinalFileUrl          string
-	TempFilePath          string
+	Writer                xfer.StreamUploadWriter
+	CompressingWriter     io.WriteCloser // csv and jsonl only, wraps Writer
+	WorkerDone            chan error
 }
 
 const DefaultFileInserterBatchCapacity int = 1000
@@ -35,7 +39,7 @@ func newWriteFileBatch(batchCapacity int) *WriteFileBatch {
 	}
 }
 
-func newFileInserter(pCtx *ctx.MessageProcessingContext, fileCreator *sc.FileCreatorDef, runId int16, batchIdx int16) *FileInserter {
+func newFileInserter(pCtx *ctx.MessageProcessingContext, fileCreator *sc.FileCreatorDef, finalFileUrl string) *FileInserter {
 	instr := FileInserter{
 		PCtx:                  pCtx,
 		FileCreator:           fileCreator,
@@ -43,12 +47,41 @@ func newFileInserter(pCtx *ctx.MessageProcessingContext, fileCreator *sc.FileCre
 		BatchesIn:             make(chan *WriteFileBatch, sc.MaxFileCreatorTopLimit/DefaultFileInserterBatchCapacity),
 		RecordWrittenStatuses: make(chan error, 1),
 		BatchesSent:           0,
-		FinalFileUrl:          strings.ReplaceAll(strings.ReplaceAll(fileCreator.UrlTemplate, sc.ReservedParamRunId, fmt.Sprintf("%05d", runId)), sc.ReservedParamBatchIdx, fmt.Sprintf("%05d", batchIdx)),
+		WorkerDone:            make(chan error, 1),
+		FinalFileUrl:          finalFileUrl,
 	}
 
 	return &instr
 }
 
+// Workers write straight to the destination: local file, S3 multipart upload or sftp .part file
+func (instr *FileInserter) openWriter(privateKeys map[string]string) error {
+	w, err := xfer.NewStreamUploadWriter(instr.FinalFileUrl, privateKeys)
+	if err != nil {
+		return fmt.Errorf("cannot open %s for writing: %s", instr.FinalFileUrl, err.Error())
+	}
+	instr.Writer = w
+	return nil
+}
+
+// Text formats are compressed as a stream, the worker closes CompressingWriter after the last batch
+func (instr *FileInserter) openCompressingWriter() error {
+	w, err := newCompressingWriter(instr.Writer, instr.FileCreator.GetCompression())
+	if err != nil {
+		return fmt.Errorf("cannot open %s for compression: %s", instr.FinalFileUrl, err.Error())
+	}
+	instr.CompressingWriter = w
+	return nil
+}
+
+// Flushes the compressed stream tail, Writer stays open
+func (instr *FileInserter) closeCompressingWriter() error {
+	if err := instr.CompressingWriter.Close(); err != nil {
+		return fmt.Errorf("cannot complete compressed data for %s: [%s]", instr.FinalFileUrl, err.Error())
+	}
+	return nil
+}
+
 func (instr *FileInserter) checkWorkerOutputForErrors() error {
 	foundErrors := make([]string, 0)
 	for {
@@ -116,7 +149,13 @@ func (instr *FileInserter) waitForWorkerAndCloseErrorsOut(logger *l.CapiLogger,
 	logger.DebugCtx(pCtx, "closing RecordWrittenStatuses")
 	close(instr.RecordWrittenStatuses)
 	logger.DebugCtx(pCtx, "closed RecordWrittenStatuses")
-	return err
+
+	// Worker is done with BatchesIn, but it may still be writing the file tail (parquet footer)
+	workerErr := <-instr.WorkerDone
+	if err != nil {
+		return err
+	}
+	return workerErr
 }
 
 func (instr *FileInserter) add(row []any) {
@@ -2,33 +2,25 @@ package proc
 
 import (
 	"fmt"
-	"net/url"
-	"os"
+	"io"
 	"strings"
 	"time"
 
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
 	"github.com/capillariesio/capillaries/pkg/l"
-	"github.com/capillariesio/capillaries/pkg/xfer"
 	"github.com/shopspring/decimal"
 )
 
-func (instr *FileInserter) createCsvFileAndStartWorker(logger *l.CapiLogger, u *url.URL) error {
+func (instr *FileInserter) createCsvFileAndStartWorker(logger *l.CapiLogger, privateKeys map[string]string) error {
 	logger.PushF("proc.createCsvFileAndStartWorker")
 	defer logger.PopF()
 
-	var err error
-	var f *os.File
-	if u.Scheme == xfer.UrlSchemeSftp || u.Scheme == xfer.UrlSchemeS3 {
-		f, err = os.CreateTemp("", "capi")
-		if err != nil {
-			return fmt.Errorf("cannot create temp file for %s: %s", instr.FinalFileUrl, err.Error())
-		}
-		instr.TempFilePath = f.Name()
-	} else {
-		f, err = os.Create(instr.FinalFileUrl)
-		if err != nil {
-			return err
-		}
+	if err := instr.openWriter(privateKeys); err != nil {
+		return err
+	}
+	if err := instr.openCompressingWriter(); err != nil {
+		instr.Writer.Abort(err)
+		return err
 	}
 
 	// Header
@@ -47,15 +39,14 @@ func (instr *FileInserter) createCsvFileAndStartWorker(logger *l.CapiLogger, u *
 			b.WriteString(instr.FileCreator.Csv.Separator)
 		}
 	}
-	if _, err := f.WriteString(b.String()); err != nil {
-		f.Close()
+	if _, err := io.WriteString(instr.CompressingWriter, b.String()); err != nil {
+		instr.Writer.Abort(err)
 		return fmt.Errorf("cannot write file [%s] header line: [%s]", instr.FinalFileUrl, err.Error())
 	}
 
-	f.Close()
-
 	newLogger, err := l.NewLoggerFromLogger(logger)
 	if err != nil {
+		instr.Writer.Abort(err)
 		return err
 	}
 	go instr.csvFileInserterWorker(newLogger)
@@ -67,39 +58,19 @@ func (instr *FileInserter) csvFileInserterWorker(logger *l.CapiLogger) {
 	logger.PushF("proc.csvFileInserterWorker")
 	defer logger.Close()
 
-	var localFilePath string
-	if instr.TempFilePath != "" {
-		localFilePath = instr.TempFilePath
-	} else {
-		localFilePath = instr.FinalFileUrl
-	}
-
-	var errOpen error
-	f, err := os.OpenFile(localFilePath, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		errOpen = fmt.Errorf("cannot open csv %s(temp %s) for appending: [%s]", instr.FinalFileUrl, instr.TempFilePath, err.Error())
-	}
-	if f == nil {
-		errOpen = fmt.Errorf("cannot open csv %s(temp %s) for appending: unknown error", instr.FinalFileUrl, instr.TempFilePath)
-	} else {
-		defer f.Close()
-	}
-
 	for batch := range instr.BatchesIn {
-		if errOpen != nil {
-			instr.RecordWrittenStatuses <- errOpen
-			continue
-		}
 		batchStartTime := time.Now()
 		b := strings.Builder{}
 		for rowIdx := 0; rowIdx < batch.RowCount; rowIdx++ {
 			for i := 0; i < len(instr.FileCreator.Columns); i++ {
 				var stringVal string
 				switch assertedVal := batch.Rows[rowIdx][i].(type) {
+				case nil:
+					// Null is an empty value
 				case time.Time:
 					stringVal = assertedVal.Format(instr.FileCreator.Columns[i].Csv.Format)
 				case decimal.Decimal:
-					stringVal = fmt.Sprintf(instr.FileCreator.Columns[i].Csv.Format, assertedVal.StringFixed(2))
+					stringVal = fmt.Sprintf(instr.FileCreator.Columns[i].Csv.Format, assertedVal.StringFixed(evalcapi.DecimalFieldTypeScale(instr.FileCreator.Columns[i].Type)))
 				default:
 					stringVal = fmt.Sprintf(instr.FileCreator.Columns[i].Csv.Format, batch.Rows[rowIdx][i])
 				}
@@ -120,17 +91,15 @@ func (instr *FileInserter) csvFileInserterWorker(logger *l.CapiLogger) {
 			}
 		}
 
-		if err = f.Sync(); err == nil {
-			if _, err = f.WriteString(b.String()); err != nil {
-				instr.RecordWrittenStatuses <- fmt.Errorf("cannot write string to %s(temp %s): [%s]", instr.FinalFileUrl, instr.TempFilePath, err.Error())
-			} else {
-				dur := time.Since(batchStartTime)
-				logger.InfoCtx(instr.PCtx, "%d items in %.3fs (%.0f items/s)", batch.RowCount, dur.Seconds(), float64(batch.RowCount)/dur.Seconds())
-				instr.RecordWrittenStatuses <- nil
-			}
+		if _, err := io.WriteString(instr.CompressingWriter, b.String()); err != nil {
+			instr.RecordWrittenStatuses <- fmt.Errorf("cannot write string to %s: [%s]", instr.FinalFileUrl, err.Error())
 		} else {
-			instr.RecordWrittenStatuses <- fmt.Errorf("cannot sync file %s(temp %s): [%s]", instr.FinalFileUrl, instr.TempFilePath, err.Error())
+			dur := time.Since(batchStartTime)
+			logger.InfoCtx(instr.PCtx, "%d items in %.3fs (%.0f items/s)", batch.RowCount, dur.Seconds(), float64(batch.RowCount)/dur.Seconds())
+			instr.RecordWrittenStatuses <- nil
 		}
 		instr.PCtx.SendHeartbeat()
 	} // next batch
+
+	instr.WorkerDone <- instr.closeCompressingWriter()
 }
@@ -0,0 +1,100 @@
+package proc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/shopspring/decimal"
+)
+
+func (instr *FileInserter) createJsonlFileAndStartWorker(logger *l.CapiLogger, privateKeys map[string]string) error {
+	logger.PushF("proc.createJsonlFileAndStartWorker")
+	defer logger.PopF()
+
+	// No header, the worker writes everything
+	if err := instr.openWriter(privateKeys); err != nil {
+		return err
+	}
+	if err := instr.openCompressingWriter(); err != nil {
+		instr.Writer.Abort(err)
+		return err
+	}
+
+	newLogger, err := l.NewLoggerFromLogger(logger)
+	if err != nil {
+		instr.Writer.Abort(err)
+		return err
+	}
+	go instr.jsonlFileInserterWorker(newLogger)
+
+	return nil
+}
+
+// Keys are written in column order, so we do not marshal a map
+func (instr *FileInserter) writeJsonlRow(b *strings.Builder, row []any) error {
+	b.WriteString("{")
+	for i := 0; i < len(instr.FileCreator.Columns); i++ {
+		colDef := &instr.FileCreator.Columns[i]
+		if i > 0 {
+			b.WriteString(",")
+		}
+		keyBytes, err := json.Marshal(colDef.Jsonl.Key)
+		if err != nil {
+			return err
+		}
+		b.Write(keyBytes)
+		b.WriteString(":")
+
+		val := row[i]
+		switch assertedVal := val.(type) {
+		case time.Time:
+			val = assertedVal.Format(colDef.Jsonl.Format)
+		case decimal.Decimal:
+			// Number, not string
+			b.WriteString(assertedVal.StringFixed(evalcapi.DecimalFieldTypeScale(colDef.Type)))
+			continue
+		}
+		valBytes, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Errorf("cannot write column %s value %v: %s", colDef.Name, val, err.Error())
+		}
+		b.Write(valBytes)
+	}
+	b.WriteString("}\n")
+	return nil
+}
+
+func (instr *FileInserter) jsonlFileInserterWorker(logger *l.CapiLogger) {
+	logger.PushF("proc.jsonlFileInserterWorker")
+	defer logger.Close()
+
+	for batch := range instr.BatchesIn {
+		batchStartTime := time.Now()
+		b := strings.Builder{}
+		var errRow error
+		for rowIdx := 0; rowIdx < batch.RowCount && errRow == nil; rowIdx++ {
+			errRow = instr.writeJsonlRow(&b, batch.Rows[rowIdx])
+		}
+		if errRow != nil {
+			instr.RecordWrittenStatuses <- fmt.Errorf("cannot serialize row for %s: [%s]", instr.FinalFileUrl, errRow.Error())
+			instr.PCtx.SendHeartbeat()
+			continue
+		}
+
+		if _, err := io.WriteString(instr.CompressingWriter, b.String()); err != nil {
+			instr.RecordWrittenStatuses <- fmt.Errorf("cannot write string to %s: [%s]", instr.FinalFileUrl, err.Error())
+		} else {
+			dur := time.Since(batchStartTime)
+			logger.InfoCtx(instr.PCtx, "%d items in %.3fs (%.0f items/s)", batch.RowCount, dur.Seconds(), float64(batch.RowCount)/dur.Seconds())
+			instr.RecordWrittenStatuses <- nil
+		}
+		instr.PCtx.SendHeartbeat()
+	} // next batch
+
+	instr.WorkerDone <- instr.closeCompressingWriter()
+}
@@ -2,45 +2,28 @@ package proc
 
 import (
 	"fmt"
-	"net/url"
-	"os"
 	"time"
 
 	"github.com/capillariesio/capillaries/pkg/evalcapi"
 	"github.com/capillariesio/capillaries/pkg/l"
-	"github.com/capillariesio/capillaries/pkg/sc"
 	"github.com/capillariesio/capillaries/pkg/storage"
-	"github.com/capillariesio/capillaries/pkg/xfer"
 	"github.com/shopspring/decimal"
 )
 
-func (instr *FileInserter) createParquetFileAndStartWorker(logger *l.CapiLogger, codec sc.ParquetCodecType, u *url.URL) error {
+func (instr *FileInserter) createParquetFileAndStartWorker(logger *l.CapiLogger, privateKeys map[string]string) error {
 	logger.PushF("proc.createParquetFileAndStartWorker")
 	defer logger.PopF()
 
-	var err error
-
-	var f *os.File
-	if u.Scheme == xfer.UrlSchemeSftp || u.Scheme == xfer.UrlSchemeS3 {
-		f, err = os.CreateTemp("", "capi")
-		if err != nil {
-			return fmt.Errorf("cannot create temp file for %s: %s", instr.FinalFileUrl, err.Error())
-		}
-		instr.TempFilePath = f.Name()
-	} else {
-		f, err = os.Create(instr.FinalFileUrl)
-		if err != nil {
-			return err
-		}
+	if err := instr.openWriter(privateKeys); err != nil {
+		return err
 	}
 
-	f.Close()
-
 	newLogger, err := l.NewLoggerFromLogger(logger)
 	if err != nil {
+		instr.Writer.Abort(err)
 		return err
 	}
-	go instr.parquetFileInserterWorker(newLogger, codec)
+	go instr.parquetFileInserterWorker(newLogger)
 
 	return nil
 }
@@ -48,7 +31,11 @@ func (instr *FileInserter) createParquetFileAndStartWorker(logger *l.CapiLogger,
 func (instr *FileInserter) generateMapToAdd(batch *WriteFileBatch, rowIdx int) (map[string]any, error) {
 	d := map[string]any{}
 	for i := 0; i < len(instr.FileCreator.Columns); i++ {
-		switch instr.FileCreator.Columns[i].Type {
+		if batch.Rows[rowIdx][i] == nil {
+			// All columns are optional, missing key is written as null with definition level 0
+			continue
+		}
+		switch evalcapi.FieldTypeKind(instr.FileCreator.Columns[i].Type) {
 		case evalcapi.FieldTypeString:
 			typedValue, ok := batch.Rows[rowIdx][i].(string)
 			if !ok {
@@ -78,7 +65,11 @@ func (instr *FileInserter) generateMapToAdd(batch *WriteFileBatch, rowIdx int) (
 			if !ok {
 				return nil, fmt.Errorf("cannot convert column %s value [%v] to Parquet decimal", instr.FileCreator.Columns[i].Parquet.ColumnName, batch.Rows[rowIdx][i])
 			}
-			d[instr.FileCreator.Columns[i].Parquet.ColumnName] = storage.ParquetWriterDecimal2(typedValue)
+			parquetValue, err := storage.ParquetWriterDecimal(typedValue, instr.FileCreator.Columns[i].Type)
+			if err != nil {
+				return nil, err
+			}
+			d[instr.FileCreator.Columns[i].Parquet.ColumnName] = parquetValue
 		case evalcapi.FieldTypeDateTime:
 			typedValue, ok := batch.Rows[rowIdx][i].(time.Time)
 			if !ok {
@@ -93,38 +84,19 @@ func (instr *FileInserter) generateMapToAdd(batch *WriteFileBatch, rowIdx int) (
 	return d, nil
 }
 
-func (instr *FileInserter) parquetFileInserterWorker(logger *l.CapiLogger, codec sc.ParquetCodecType) {
+func (instr *FileInserter) parquetFileInserterWorker(logger *l.CapiLogger) {
 	logger.PushF("proc.parquetFileInserterWorker")
 	defer logger.Close()
 
-	var localFilePath string
-	if instr.TempFilePath != "" {
-		localFilePath = instr.TempFilePath
-	} else {
-		localFilePath = instr.FinalFileUrl
-	}
-
 	var errOpen error
-	var w *storage.ParquetWriter
-	f, err := os.OpenFile(localFilePath, os.O_APPEND|os.O_WRONLY, 0644)
+	w, err := storage.NewParquetWriter(instr.Writer, instr.FileCreator.Parquet)
 	if err != nil {
-		errOpen = fmt.Errorf("cannot open parquet %s(temp %s) for appending: [%s]", instr.FinalFileUrl, instr.TempFilePath, err.Error())
+		errOpen = err
 	} else {
-		if f == nil {
-			errOpen = fmt.Errorf("cannot open parquet %s(temp %s) for appending: unknown error", instr.FinalFileUrl, instr.TempFilePath)
-		} else {
-			defer f.Close()
-		}
-
-		w, err = storage.NewParquetWriter(f, codec)
-		if err != nil {
-			errOpen = err
-		} else {
-			for i := 0; i < len(instr.FileCreator.Columns); i++ {
-				if err := w.AddColumn(instr.FileCreator.Columns[i].Parquet.ColumnName, instr.FileCreator.Columns[i].Type); err != nil {
-					errOpen = err
-					break
-				}
+		for i := 0; i < len(instr.FileCreator.Columns); i++ {
+			if err := w.AddColumn(instr.FileCreator.Columns[i].Parquet.ColumnName, instr.FileCreator.Columns[i].Type, &instr.FileCreator.Columns[i].Parquet); err != nil {
+				errOpen = err
+				break
 			}
 		}
 	}
@@ -142,7 +114,7 @@ func (instr *FileInserter) parquetFileInserterWorker(logger *l.CapiLogger, codec
 			if errAddData != nil {
 				break
 			}
-			if err := w.FileWriter.AddData(d); err != nil {
+			if err := w.AddData(d); err != nil {
 				errAddData = err
 				break
 			}
@@ -158,9 +130,12 @@ func (instr *FileInserter) parquetFileInserterWorker(logger *l.CapiLogger, codec
 		instr.PCtx.SendHeartbeat()
 	} // next batch
 
-	if w != nil {
+	// Flush the last row group and write the footer
+	var errClose error
+	if errOpen == nil {
 		if err := w.Close(); err != nil {
-			logger.ErrorCtx(instr.PCtx, "cannot close parquet writer %s(temp %s): [%s]", instr.FinalFileUrl, instr.TempFilePath, err.Error())
+			errClose = fmt.Errorf("cannot close parquet writer %s: [%s]", instr.FinalFileUrl, err.Error())
 		}
 	}
+	instr.WorkerDone <- errClose
 }
@@ -0,0 +1,152 @@
+package proc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/capillariesio/capillaries/pkg/xfer"
+)
+
+// One FileInserter (writer and worker) per distinct combination of partition values referenced in url_template.
+// Non-partitioned file creator has exactly one inserter, created upfront, so the file is produced even if there are no rows.
+type PartitionedFileInserter struct {
+	PCtx        *ctx.MessageProcessingContext
+	FileCreator *sc.FileCreatorDef
+	FileUrl     string // run_id and batch_idx resolved, partition references are not
+	PrivateKeys map[string]string
+	Inserters   map[string]*FileInserter
+	FileUrls    []string // In creation order, to be saved in batch history
+}
+
+func newPartitionedFileInserter(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, fileCreator *sc.FileCreatorDef, fileUrl string, privateKeys map[string]string) (*PartitionedFileInserter, error) {
+	pInstr := PartitionedFileInserter{
+		PCtx:        pCtx,
+		FileCreator: fileCreator,
+		FileUrl:     fileUrl,
+		PrivateKeys: privateKeys,
+		Inserters:   map[string]*FileInserter{},
+		FileUrls:    make([]string, 0),
+	}
+
+	if !fileCreator.HasPartitions() {
+		if _, err := pInstr.startInserter(logger, pInstr.FileUrl); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pInstr, nil
+}
+
+func (pInstr *PartitionedFileInserter) startInserter(logger *l.CapiLogger, fileUrl string) (*FileInserter, error) {
+	instr := newFileInserter(pInstr.PCtx, pInstr.FileCreator, fileUrl)
+
+	switch pInstr.FileCreator.CreatorFileType {
+	case sc.CreatorFileTypeCsv:
+		if err := instr.createCsvFileAndStartWorker(logger, pInstr.PrivateKeys); err != nil {
+			return nil, fmt.Errorf("cannot start csv inserter worker: %s", err.Error())
+		}
+	case sc.CreatorFileTypeJsonl:
+		if err := instr.createJsonlFileAndStartWorker(logger, pInstr.PrivateKeys); err != nil {
+			return nil, fmt.Errorf("cannot start jsonl inserter worker: %s", err.Error())
+		}
+	case sc.CreatorFileTypeParquet:
+		if err := instr.createParquetFileAndStartWorker(logger, pInstr.PrivateKeys); err != nil {
+			return nil, fmt.Errorf("cannot start parquet inserter worker: %s", err.Error())
+		}
+	default:
+		return nil, fmt.Errorf("unknown inserter file type: %d", pInstr.FileCreator.CreatorFileType)
+	}
+
+	pInstr.Inserters[fileUrl] = instr
+	pInstr.FileUrls = append(pInstr.FileUrls, fileUrl)
+	return instr, nil
+}
+
+func (pInstr *PartitionedFileInserter) add(logger *l.CapiLogger, row []any) error {
+	fileUrl := pInstr.FileUrl
+	if pInstr.FileCreator.HasPartitions() {
+		var err error
+		fileUrl, err = pInstr.FileCreator.ResolvePartitionUrl(pInstr.FileUrl, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	instr, ok := pInstr.Inserters[fileUrl]
+	if !ok {
+		// Writers stay open till the end of the batch, S3 and sftp files cannot be re-opened for appending
+		if len(pInstr.Inserters) >= pInstr.FileCreator.GetMaxOpenPartitions() {
+			return fmt.Errorf("cannot create partition file %s, this batch already has %d open partition files, see max_open_partitions", fileUrl, len(pInstr.Inserters))
+		}
+		var err error
+		instr, err = pInstr.startInserter(logger, fileUrl)
+		if err != nil {
+			return err
+		}
+	}
+
+	instr.add(row)
+	return nil
+}
+
+func (pInstr *PartitionedFileInserter) checkWorkerOutputForErrors() error {
+	foundErrors := make([]string, 0)
+	for _, fileUrl := range pInstr.FileUrls {
+		if err := pInstr.Inserters[fileUrl].checkWorkerOutputForErrors(); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("%s: %s", fileUrl, err.Error()))
+		}
+	}
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
+
+func (pInstr *PartitionedFileInserter) waitForWorkersAndCloseErrorsOut(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext) error {
+	foundErrors := make([]string, 0)
+	for _, fileUrl := range pInstr.FileUrls {
+		if err := pInstr.Inserters[fileUrl].waitForWorkerAndCloseErrorsOut(logger, pCtx); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("%s: %s", fileUrl, err.Error()))
+		}
+	}
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
+
+// Do not leave partial files at the destination
+func (pInstr *PartitionedFileInserter) abort(err error) {
+	for _, fileUrl := range pInstr.FileUrls {
+		pInstr.Inserters[fileUrl].Writer.Abort(err)
+	}
+}
+
+// Complete S3 multipart uploads, rename sftp .part files. On the first failure, the rest of the files are aborted
+// and the files completed so far are deleted, so a failed batch leaves no files. Deletion is best effort:
+// files that cannot be deleted are listed in the returned error.
+func (pInstr *PartitionedFileInserter) close() error {
+	for i, fileUrl := range pInstr.FileUrls {
+		if err := pInstr.Inserters[fileUrl].Writer.Close(); err != nil {
+			// S3 and sftp writers clean up after a failed Close themselves, a local file is removed here
+			pInstr.Inserters[fileUrl].Writer.Abort(err)
+			for _, restFileUrl := range pInstr.FileUrls[i+1:] {
+				pInstr.Inserters[restFileUrl].Writer.Abort(err)
+			}
+			deleteErrors := make([]string, 0)
+			for _, completedFileUrl := range pInstr.FileUrls[:i] {
+				if deleteErr := xfer.DeleteFile(completedFileUrl, pInstr.PrivateKeys); deleteErr != nil {
+					deleteErrors = append(deleteErrors, deleteErr.Error())
+				}
+			}
+			if len(deleteErrors) > 0 {
+				return fmt.Errorf("cannot complete file %s: [%s]; cannot delete files completed before it: [%s]", fileUrl, err.Error(), strings.Join(deleteErrors, "; "))
+			}
+			return fmt.Errorf("cannot complete file %s: [%s]", fileUrl, err.Error())
+		}
+	}
+	return nil
+}
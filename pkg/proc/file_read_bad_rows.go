@@ -0,0 +1,99 @@
+package proc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/xfer"
+)
+
+// Collects rows that file readers failed to parse, see max_bad_rows and bad_rows_url
+type BadRowsWriter struct {
+	MaxBadRows   int64
+	BadRows      int64
+	FinalFileUrl string
+	uw           xfer.StreamUploadWriter
+	w            *csv.Writer
+}
+
+func newBadRowsWriter(envConfig *env.EnvConfig, pCtx *ctx.MessageProcessingContext) (*BadRowsWriter, error) {
+	frDef := &pCtx.CurrentScriptNode.FileReader
+	bw := BadRowsWriter{MaxBadRows: frDef.MaxBadRows}
+	if frDef.BadRowsUrl == "" {
+		return &bw, nil
+	}
+
+	// Always create the file, even if there are no bad rows: this batch may be a re-run
+	bw.FinalFileUrl = frDef.GetBadRowsUrl(pCtx.Msg.RunId, pCtx.Msg.BatchIdx)
+	var err error
+	bw.uw, err = xfer.NewStreamUploadWriter(bw.FinalFileUrl, envConfig.PrivateKeys)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create bad rows file %s: %s", bw.FinalFileUrl, err.Error())
+	}
+
+	bw.w = csv.NewWriter(bw.uw)
+	if err := bw.w.Write([]string{"line_idx", "error", "row"}); err != nil {
+		bw.close()
+		return nil, fmt.Errorf("cannot write bad rows file %s header: %s", bw.FinalFileUrl, err.Error())
+	}
+	return &bw, nil
+}
+
+// Returns rowErr as is if bad rows are not tolerated, or a wrapped error when there are too many of them
+func (bw *BadRowsWriter) add(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, lineIdx int64, rawRow string, rowErr error) error {
+	if bw.MaxBadRows == 0 {
+		return rowErr
+	}
+	bw.BadRows++
+	if bw.BadRows > bw.MaxBadRows {
+		return fmt.Errorf("more than %d bad rows, giving up at line %d: %s", bw.MaxBadRows, lineIdx, rowErr.Error())
+	}
+
+	logger.WarnCtx(pCtx, "skipping bad row %d: %s", lineIdx, rowErr.Error())
+	if bw.w == nil {
+		return nil
+	}
+	if err := bw.w.Write([]string{fmt.Sprintf("%d", lineIdx), rowErr.Error(), rawRow}); err != nil {
+		return fmt.Errorf("cannot write bad row %d to %s: %s", lineIdx, bw.FinalFileUrl, err.Error())
+	}
+	return nil
+}
+
+// Aborts the upload unless finish() completed it
+func (bw *BadRowsWriter) close() {
+	if bw.uw == nil {
+		return
+	}
+	bw.uw.Abort(nil)
+	bw.uw = nil
+}
+
+// Called on batch success only, a failed batch does not produce the bad rows file at the final destination
+func (bw *BadRowsWriter) finish(bs *BatchStats) error {
+	bs.BadRows = bw.BadRows
+	if bw.w == nil {
+		return nil
+	}
+	defer bw.close()
+
+	bw.w.Flush()
+	if err := bw.w.Error(); err != nil {
+		return fmt.Errorf("cannot flush bad rows file %s: %s", bw.FinalFileUrl, err.Error())
+	}
+
+	uw := bw.uw
+	bw.uw = nil
+	if err := uw.Close(); err != nil {
+		return fmt.Errorf("cannot complete bad rows file %s: %s", bw.FinalFileUrl, err.Error())
+	}
+	return nil
+}
+
+// Best effort: for the reject file only, quoting is lost
+func csvRawRow(line []string, separator string) string {
+	return strings.Join(line, separator)
+}
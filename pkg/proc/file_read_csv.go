@@ -2,6 +2,7 @@ package proc
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -11,20 +12,27 @@ import (
 	"github.com/capillariesio/capillaries/pkg/eval"
 	"github.com/capillariesio/capillaries/pkg/l"
 	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/capillariesio/capillaries/pkg/storage"
 )
 
-func readCsv(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, totalStartTime time.Time, filePath string, fileReader io.Reader) (BatchStats, error) {
+// With dataOnly, fileReader starts at data lines: header lines were handled by the caller, see readCsvSplit
+func readCsv(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, totalStartTime time.Time, filePath string, fileReader io.Reader, dataOnly bool) (BatchStats, error) {
 	node := pCtx.CurrentScriptNode
 	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: filePath, Dst: node.TableCreator.Name}
 
-	r := csv.NewReader(fileReader)
-	r.Comma = rune(node.FileReader.Csv.Separator[0])
-
-	// To avoid bare \" error: https://stackoverflow.com/questions/31326659/golang-csv-error-bare-in-non-quoted-field
-	r.LazyQuotes = true
+	r, err := storage.NewCsvRecordReader(fileReader, &node.FileReader.Csv)
+	if err != nil {
+		return bs, fmt.Errorf("cannot read csv file [%s]: [%s]", filePath, err.Error())
+	}
 
 	var lineIdx int64 // CSV file line idx, includes headers
 
+	badRows, err := newBadRowsWriter(envConfig, pCtx)
+	if err != nil {
+		return bs, err
+	}
+	defer badRows.close()
+
 	instr, err := createInserterAndStartWorkers(logger, envConfig, pCtx, &node.TableCreator, DataIdxSeqModeDataFirst, logger.ZapMachine.String)
 	if err != nil {
 		return bs, err
@@ -43,47 +51,59 @@ func readCsv(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessagePr
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
+		var parseErr *csv.ParseError
+		isHdrLine := !dataOnly && node.FileReader.Csv.ColumnIndexingMode == sc.FileColumnIndexingName && int64(node.FileReader.Csv.SrcFileHdrLineIdx) == lineIdx
+		isDataLine := dataOnly || lineIdx >= int64(node.FileReader.Csv.SrcFileFirstDataLineIdx)
+		if err != nil && (!errors.As(err, &parseErr) || isHdrLine || !isDataLine) {
 			instr.cancelDrainer(fmt.Errorf("cannot read csv file [%s]: [%s]", filePath, err.Error()))
 			return bs, instr.waitForDrainer()
 		}
-		if node.FileReader.Csv.ColumnIndexingMode == sc.FileColumnIndexingName && int64(node.FileReader.Csv.SrcFileHdrLineIdx) == lineIdx {
+		if err != nil {
+			// Malformed line, like wrong number of fields: the reader can move on to the next one
+			if err := badRows.add(logger, pCtx, lineIdx, csvRawRow(line, node.FileReader.Csv.Separator), fmt.Errorf("cannot read csv file [%s]: [%s]", filePath, err.Error())); err != nil {
+				instr.cancelDrainer(err)
+				return bs, instr.waitForDrainer()
+			}
+			bs.RowsRead++
+		} else if isHdrLine {
 			if err := node.FileReader.ResolveCsvColumnIndexesFromNames(line); err != nil {
 				instr.cancelDrainer(fmt.Errorf("cannot parse column headers of csv file [%s]: [%s]", filePath, err.Error()))
 				return bs, instr.waitForDrainer()
 			}
-		} else if lineIdx >= int64(node.FileReader.Csv.SrcFileFirstDataLineIdx) {
+		} else if isDataLine {
 
 			// FileReader: read columns
 			clear(colVars)
 			if err := node.FileReader.ReadCsvLineToValuesMap(&line, colVars); err != nil {
-				instr.cancelDrainer(fmt.Errorf("cannot read values from csv file [%s], line %d: [%s]", filePath, lineIdx, err.Error()))
-				return bs, instr.waitForDrainer()
-			}
-
-			// TableCreator: evaluate table column expressions
-			tableRecord, err = node.TableCreator.CalculateTableRecordFromSrcVars(colVars)
-			if err != nil {
-				instr.cancelDrainer(fmt.Errorf("cannot populate table record from csv file [%s], line %d: [%s]", filePath, lineIdx, err.Error()))
-				return bs, instr.waitForDrainer()
-			}
-
-			// Check table creator having
-			inResult, err = node.TableCreator.CheckTableRecordHavingCondition(tableRecord)
-			if err != nil {
-				instr.cancelDrainer(fmt.Errorf("cannot check having condition [%s], csv file [%s] line %d, table record [%v]: [%s]", node.TableCreator.RawHaving, filePath, lineIdx, tableRecord, err.Error()))
-				return bs, instr.waitForDrainer()
-			}
+				if err := badRows.add(logger, pCtx, lineIdx, csvRawRow(line, node.FileReader.Csv.Separator), fmt.Errorf("cannot read values from csv file [%s], line %d: [%s]", filePath, lineIdx, err.Error())); err != nil {
+					instr.cancelDrainer(err)
+					return bs, instr.waitForDrainer()
+				}
+			} else {
+				// TableCreator: evaluate table column expressions
+				tableRecord, err = node.TableCreator.CalculateTableRecordFromSrcVars(colVars)
+				if err != nil {
+					instr.cancelDrainer(fmt.Errorf("cannot populate table record from csv file [%s], line %d: [%s]", filePath, lineIdx, err.Error()))
+					return bs, instr.waitForDrainer()
+				}
 
-			if inResult {
-				err = instr.buildIndexKeys(tableRecord, indexKeyMap)
+				// Check table creator having
+				inResult, err = node.TableCreator.CheckTableRecordHavingCondition(tableRecord)
 				if err != nil {
-					instr.cancelDrainer(fmt.Errorf("cannot build index keys for table %s, csv file [%s] line %d: [%s]", node.TableCreator.Name, filePath, lineIdx, err.Error()))
+					instr.cancelDrainer(fmt.Errorf("cannot check having condition [%s], csv file [%s] line %d, table record [%v]: [%s]", node.TableCreator.RawHaving, filePath, lineIdx, tableRecord, err.Error()))
 					return bs, instr.waitForDrainer()
 				}
 
-				instr.add(tableRecord, indexKeyMap)
-				bs.RowsWritten++
+				if inResult {
+					err = instr.buildIndexKeys(tableRecord, indexKeyMap)
+					if err != nil {
+						instr.cancelDrainer(fmt.Errorf("cannot build index keys for table %s, csv file [%s] line %d: [%s]", node.TableCreator.Name, filePath, lineIdx, err.Error()))
+						return bs, instr.waitForDrainer()
+					}
+
+					instr.add(tableRecord, indexKeyMap)
+					bs.RowsWritten++
+				}
 			}
 			bs.RowsRead++
 		}
@@ -98,6 +118,10 @@ func readCsv(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessagePr
 		return bs, err
 	}
 
+	if err := badRows.finish(&bs); err != nil {
+		return bs, err
+	}
+
 	bs.UpdateElapsedStats(time.Since(totalStartTime), instr)
 	reportWriteTableComplete(logger, pCtx, bs.RowsRead, bs.RowsWritten, bs.Elapsed, len(node.TableCreator.Indexes), instr.NumWorkers)
 
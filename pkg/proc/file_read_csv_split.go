@@ -0,0 +1,160 @@
+package proc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/capillariesio/capillaries/pkg/storage"
+	"github.com/capillariesio/capillaries/pkg/xfer"
+)
+
+func openFileAtOffset(envConfig *env.EnvConfig, u *url.URL, filePath string, offset int64) (io.ReadCloser, error) {
+	switch u.Scheme {
+	case xfer.UrlSchemeFile, "":
+		f, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("cannot seek to %d in %s: %s", offset, filePath, err.Error())
+		}
+		return f, nil
+	case xfer.UrlSchemeHttp, xfer.UrlSchemeHttps:
+		return xfer.GetHttpRangeReadCloser(filePath, u.Scheme, envConfig.CaPath, offset)
+	case xfer.UrlSchemeS3:
+		return xfer.GetS3RangeReadCloser(filePath, offset)
+	default:
+		return nil, fmt.Errorf("cannot read %s from offset %d: url scheme %s not supported", filePath, offset, u.Scheme)
+	}
+}
+
+// Every split reads header lines from the beginning of the file: column indexes may have to be resolved from names.
+// Returns the offset of the first data line.
+func readCsvSplitHeader(envConfig *env.EnvConfig, u *url.URL, filePath string, frDef *sc.FileReaderDef) (int64, error) {
+	if frDef.Csv.SrcFileFirstDataLineIdx == 0 {
+		return 0, nil
+	}
+
+	rc, err := openFileAtOffset(envConfig, u, filePath, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	r, err := storage.NewCsvRecordReader(rc, &frDef.Csv)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read csv file [%s] header: [%s]", filePath, err.Error())
+	}
+
+	for lineIdx := 0; lineIdx < frDef.Csv.SrcFileFirstDataLineIdx; lineIdx++ {
+		line, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("cannot read csv file [%s] header: [%s]", filePath, err.Error())
+		}
+		if frDef.Csv.ColumnIndexingMode == sc.FileColumnIndexingName && frDef.Csv.SrcFileHdrLineIdx == lineIdx {
+			if err := frDef.ResolveCsvColumnIndexesFromNames(line); err != nil {
+				return 0, fmt.Errorf("cannot parse column headers of csv file [%s]: [%s]", filePath, err.Error())
+			}
+		}
+	}
+	return r.InputOffset(), nil
+}
+
+// Delivers bytes before the last byte of the split, and then the rest of the line that contains that last byte.
+// The next split skips everything up to and including the first newline at or after its offset-1,
+// so every line is read by exactly one split: the one its first byte belongs to.
+type csvSplitReader struct {
+	r          *bufio.Reader
+	remaining  int64
+	tail       []byte
+	tailLoaded bool
+}
+
+func (sr *csvSplitReader) Read(p []byte) (int, error) {
+	if sr.remaining > 0 {
+		if int64(len(p)) > sr.remaining {
+			p = p[:sr.remaining]
+		}
+		n, err := sr.r.Read(p)
+		sr.remaining -= int64(n)
+		return n, err
+	}
+	if !sr.tailLoaded {
+		tail, err := sr.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		sr.tail = tail
+		sr.tailLoaded = true
+	}
+	if len(sr.tail) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, sr.tail)
+	sr.tail = sr.tail[n:]
+	return n, nil
+}
+
+// Reads lines that start in [splitStart, splitStart+split_size_bytes). Quoted fields with line breaks are not supported:
+// a split cannot tell a line break inside quotes from the one that ends a record.
+func readCsvSplit(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, totalStartTime time.Time, filePath string, u *url.URL, splitStart int64) (BatchStats, error) {
+	frDef := &pCtx.CurrentScriptNode.FileReader
+	splitEnd := splitStart + frDef.Csv.SplitSizeBytes
+
+	if compression := frDef.GetCompression(filePath); compression != sc.CompressionNone {
+		return BatchStats{RowsRead: 0, RowsWritten: 0}, fmt.Errorf("cannot split %s compressed file %s", compression, filePath)
+	}
+
+	dataStart, err := readCsvSplitHeader(envConfig, u, filePath, frDef)
+	if err != nil {
+		return BatchStats{RowsRead: 0, RowsWritten: 0}, err
+	}
+
+	// Find the first line that starts in this split
+	var rc io.ReadCloser
+	var lineStart int64
+	if splitStart <= dataStart {
+		lineStart = dataStart
+		if lineStart < splitEnd {
+			if rc, err = openFileAtOffset(envConfig, u, filePath, lineStart); err != nil {
+				return BatchStats{RowsRead: 0, RowsWritten: 0}, err
+			}
+		}
+	} else {
+		if rc, err = openFileAtOffset(envConfig, u, filePath, splitStart-1); err != nil {
+			return BatchStats{RowsRead: 0, RowsWritten: 0}, err
+		}
+	}
+
+	var fileReader io.Reader = strings.NewReader("") // No lines start in this split
+	if rc != nil {
+		defer rc.Close()
+		br := bufio.NewReader(rc)
+		if splitStart > dataStart {
+			partialLine, err := br.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return BatchStats{RowsRead: 0, RowsWritten: 0}, fmt.Errorf("cannot read csv file [%s] at offset %d: [%s]", filePath, splitStart-1, err.Error())
+			}
+			lineStart = splitStart - 1 + int64(len(partialLine))
+		}
+		if lineStart < splitEnd {
+			fileReader = &csvSplitReader{r: br, remaining: splitEnd - 1 - lineStart}
+		}
+	}
+
+	logger.InfoCtx(pCtx, "reading csv file %s split [%d,%d), first line at %d", filePath, splitStart, splitEnd, lineStart)
+	return readCsv(envConfig, logger, pCtx, totalStartTime, filePath, fileReader, true)
+}
@@ -0,0 +1,111 @@
+package proc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/l"
+)
+
+func readJsonl(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, totalStartTime time.Time, filePath string, fileReader io.Reader) (BatchStats, error) {
+	node := pCtx.CurrentScriptNode
+	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: filePath, Dst: node.TableCreator.Name}
+
+	// No line length limit, unlike bufio.Scanner
+	r := bufio.NewReader(fileReader)
+
+	var lineIdx int64
+
+	badRows, err := newBadRowsWriter(envConfig, pCtx)
+	if err != nil {
+		return bs, err
+	}
+	defer badRows.close()
+
+	instr, err := createInserterAndStartWorkers(logger, envConfig, pCtx, &node.TableCreator, DataIdxSeqModeDataFirst, logger.ZapMachine.String)
+	if err != nil {
+		return bs, err
+	}
+	instr.startDrainer()
+	defer instr.closeInserter(logger, pCtx)
+
+	// Minimize allocations to help GC in this high-traffic loop
+	var tableRecord map[string]any
+	indexKeyMap := map[string]string{}
+	colVars := eval.VarValuesMap{}
+	var line []byte
+	var inResult bool
+	for {
+		line, err = r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			instr.cancelDrainer(fmt.Errorf("cannot read jsonl file [%s]: [%s]", filePath, err.Error()))
+			return bs, instr.waitForDrainer()
+		}
+		isEOF := err == io.EOF
+
+		// Blank lines are allowed, the last line may not have \n
+		if len(bytes.TrimSpace(line)) > 0 {
+			// FileReader: read columns
+			clear(colVars)
+			if err := node.FileReader.ReadJsonlLineToValuesMap(line, colVars); err != nil {
+				if err := badRows.add(logger, pCtx, lineIdx, string(bytes.TrimRight(line, "\r\n")), fmt.Errorf("cannot read values from jsonl file [%s], line %d: [%s]", filePath, lineIdx, err.Error())); err != nil {
+					instr.cancelDrainer(err)
+					return bs, instr.waitForDrainer()
+				}
+			} else {
+				// TableCreator: evaluate table column expressions
+				tableRecord, err = node.TableCreator.CalculateTableRecordFromSrcVars(colVars)
+				if err != nil {
+					instr.cancelDrainer(fmt.Errorf("cannot populate table record from jsonl file [%s], line %d: [%s]", filePath, lineIdx, err.Error()))
+					return bs, instr.waitForDrainer()
+				}
+
+				// Check table creator having
+				inResult, err = node.TableCreator.CheckTableRecordHavingCondition(tableRecord)
+				if err != nil {
+					instr.cancelDrainer(fmt.Errorf("cannot check having condition [%s], jsonl file [%s] line %d, table record [%v]: [%s]", node.TableCreator.RawHaving, filePath, lineIdx, tableRecord, err.Error()))
+					return bs, instr.waitForDrainer()
+				}
+
+				if inResult {
+					err = instr.buildIndexKeys(tableRecord, indexKeyMap)
+					if err != nil {
+						instr.cancelDrainer(fmt.Errorf("cannot build index keys for table %s, jsonl file [%s] line %d: [%s]", node.TableCreator.Name, filePath, lineIdx, err.Error()))
+						return bs, instr.waitForDrainer()
+					}
+
+					instr.add(tableRecord, indexKeyMap)
+					bs.RowsWritten++
+				}
+			}
+			bs.RowsRead++
+			if bs.RowsRead%100 == 0 {
+				instr.PCtx.SendHeartbeat()
+			}
+		}
+		lineIdx++
+		if isEOF {
+			break
+		}
+	}
+
+	instr.doneSending()
+	if err := instr.waitForDrainer(); err != nil {
+		return bs, err
+	}
+
+	if err := badRows.finish(&bs); err != nil {
+		return bs, err
+	}
+
+	bs.UpdateElapsedStats(time.Since(totalStartTime), instr)
+	reportWriteTableComplete(logger, pCtx, bs.RowsRead, bs.RowsWritten, bs.Elapsed, len(node.TableCreator.Indexes), instr.NumWorkers)
+
+	return bs, nil
+}
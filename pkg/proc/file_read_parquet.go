@@ -3,6 +3,8 @@ package proc
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/capillariesio/capillaries/pkg/ctx"
@@ -14,70 +16,228 @@ import (
 	"github.com/capillariesio/capillaries/pkg/storage"
 	gp "github.com/fraugster/parquet-go"
 	"github.com/fraugster/parquet-go/parquet"
+	"github.com/fraugster/parquet-go/parquetschema"
+	"github.com/shopspring/decimal"
 )
 
-func readParquetRowToValuesMap(d map[string]any,
+// Source column resolved against the file schema
+type parquetReaderColumn struct {
+	CapiFieldName string
+	ColDef        *sc.FileReaderColumnDef
+	Resolved      *storage.ParquetResolvedColumn
+	ParquetType   evalcapi.TableFieldType // Guessed from the schema, may differ from col_type
+	Values        []any                   // Re-used for every row
+}
+
+func readParquetValue(volatile any, capiType evalcapi.TableFieldType, se *parquet.SchemaElement) (any, error) {
+	if volatile == nil {
+		return sc.GetDefaultFieldTypeValue(capiType), nil
+	}
+	switch evalcapi.FieldTypeKind(capiType) {
+	case evalcapi.FieldTypeString:
+		return storage.ParquetReadString(volatile, se)
+	case evalcapi.FieldTypeInt:
+		return storage.ParquetReadInt(volatile, se)
+	case evalcapi.FieldTypeFloat:
+		return storage.ParquetReadFloat(volatile, se)
+	case evalcapi.FieldTypeBool:
+		return storage.ParquetReadBool(volatile, se)
+	case evalcapi.FieldTypeDateTime:
+		return storage.ParquetReadDateTime(volatile, se)
+	case evalcapi.FieldTypeDecimal2:
+		return storage.ParquetReadDecimal2(volatile, se)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", capiType)
+	}
+}
+
+func parquetValueToJoinString(val any) string {
+	switch typedVal := val.(type) {
+	case string:
+		return typedVal
+	case int64:
+		return strconv.FormatInt(typedVal, 10)
+	case float64:
+		return strconv.FormatFloat(typedVal, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typedVal)
+	case decimal.Decimal:
+		return typedVal.String()
+	case time.Time:
+		return typedVal.Format(sc.CassandraDatetimeFormat)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+func readParquetColumnValue(col *parquetReaderColumn, volatile any, rowIdx int) (any, error) {
+	if volatile == nil && col.ColDef.Nullable {
+		return nil, nil
+	}
+	val, err := readParquetValue(volatile, col.ParquetType, col.Resolved.SchemaElement)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s row %d, column %s: %s", col.ParquetType, rowIdx, col.ColDef.Parquet.SrcColName, err.Error())
+	}
+	// File scale may differ from col_type scale
+	return sc.RoundDecimalValue(val, col.ColDef.Type), nil
+}
+
+// Fills colVars for each row produced by source row d: one row, or one row per element of exploded columns.
+// Returns the number of rows; colVarsList grows as needed and is re-used for the next source row.
+func readParquetRowToValuesMaps(d map[string]any,
 	rowIdx int,
-	requestedParquetColumnNames []string,
-	parquetToCapiFieldNameMap map[string]string,
-	parquetToCapiTypeMap map[string]evalcapi.TableFieldType,
-	schemaElementMap map[string]*parquet.SchemaElement,
-	colVars eval.VarValuesMap) error {
-	colVars[sc.ReaderAlias] = map[string]any{}
-	for _, parquetColName := range requestedParquetColumnNames {
-		capiFieldName, ok := parquetToCapiFieldNameMap[parquetColName]
-		if !ok {
-			return fmt.Errorf("dev error, parquet column %s does not map to a Capillaries field", parquetColName)
-		}
+	columns []*parquetReaderColumn,
+	colVarsList []eval.VarValuesMap) ([]eval.VarValuesMap, int, error) {
 
-		capiType, ok := parquetToCapiTypeMap[parquetColName]
-		if !ok {
-			return fmt.Errorf("dev error, parquet column %s does not map to a Capillaries type", parquetColName)
+	explodedCount := -1
+	var explodedCol *parquetReaderColumn
+	var err error
+	for _, col := range columns {
+		col.Values, err = col.Resolved.Values(d, col.Values[:0])
+		if err != nil {
+			return colVarsList, 0, err
 		}
-
-		se, ok := schemaElementMap[parquetColName]
-		if !ok {
-			return fmt.Errorf("dev error, no schema element found for parquet column %s", parquetColName)
+		if col.ColDef.Parquet.IsExploded() {
+			if explodedCount >= 0 && len(col.Values) != explodedCount {
+				return colVarsList, 0, fmt.Errorf("exploded parquet columns %s and %s have different number of values in row %d: %d and %d", explodedCol.ColDef.Parquet.SrcColName, col.ColDef.Parquet.SrcColName, rowIdx, explodedCount, len(col.Values))
+			}
+			explodedCount = len(col.Values)
+			explodedCol = col
 		}
+	}
 
-		volatile, present := d[parquetColName]
-		if !present || volatile == nil {
-			colVars[sc.ReaderAlias][capiFieldName] = sc.GetDefaultFieldTypeValue(capiType)
-			continue
+	rowCount := 1
+	if explodedCol != nil {
+		rowCount = explodedCount
+		if rowCount == 0 && explodedCol.ColDef.Parquet.Repeated == sc.ParquetRepeatedExplodeOuter {
+			rowCount = 1
 		}
+	}
+	for len(colVarsList) < rowCount {
+		colVarsList = append(colVarsList, eval.VarValuesMap{})
+	}
 
-		var err error
-		switch capiType {
-		case evalcapi.FieldTypeString:
-			if colVars[sc.ReaderAlias][capiFieldName], err = storage.ParquetReadString(volatile, se); err != nil {
-				return fmt.Errorf("cannot read string row %d, column %s: %s", rowIdx, parquetColName, err.Error())
-			}
-		case evalcapi.FieldTypeInt:
-			if colVars[sc.ReaderAlias][capiFieldName], err = storage.ParquetReadInt(volatile, se); err != nil {
-				return fmt.Errorf("cannot read int row %d, column %s: %s", rowIdx, parquetColName, err.Error())
+	for i := 0; i < rowCount; i++ {
+		clear(colVarsList[i])
+		colVarsList[i][sc.ReaderAlias] = map[string]any{}
+	}
+
+	for _, col := range columns {
+		var val any
+		switch {
+		case col.ColDef.Parquet.IsExploded():
+			for i := 0; i < rowCount; i++ {
+				var volatile any
+				if i < len(col.Values) {
+					volatile = col.Values[i]
+				}
+				if colVarsList[i][sc.ReaderAlias][col.CapiFieldName], err = readParquetColumnValue(col, volatile, rowIdx); err != nil {
+					return colVarsList, 0, err
+				}
 			}
-		case evalcapi.FieldTypeFloat:
-			if colVars[sc.ReaderAlias][capiFieldName], err = storage.ParquetReadFloat(volatile, se); err != nil {
-				return fmt.Errorf("cannot read float row %d, column %s: %s", rowIdx, parquetColName, err.Error())
+			continue
+		case col.ColDef.Parquet.Repeated == sc.ParquetRepeatedJoin:
+			strValues := make([]string, 0, len(col.Values))
+			for _, volatile := range col.Values {
+				if volatile == nil {
+					continue
+				}
+				typedVal, err := readParquetColumnValue(col, volatile, rowIdx)
+				if err != nil {
+					return colVarsList, 0, err
+				}
+				strValues = append(strValues, parquetValueToJoinString(typedVal))
 			}
-		case evalcapi.FieldTypeBool:
-			if colVars[sc.ReaderAlias][capiFieldName], err = storage.ParquetReadBool(volatile, se); err != nil {
-				return fmt.Errorf("cannot read bool row %d, column %s: %s", rowIdx, parquetColName, err.Error())
+			val = strings.Join(strValues, col.ColDef.Parquet.GetJoinSeparator())
+		default:
+			if val, err = readParquetColumnValue(col, col.Values[0], rowIdx); err != nil {
+				return colVarsList, 0, err
 			}
-		case evalcapi.FieldTypeDateTime:
-			if colVars[sc.ReaderAlias][capiFieldName], err = storage.ParquetReadDateTime(volatile, se); err != nil {
-				return fmt.Errorf("cannot read DateTime row %d, column %s: %s", rowIdx, parquetColName, err.Error())
+		}
+		for i := 0; i < rowCount; i++ {
+			colVarsList[i][sc.ReaderAlias][col.CapiFieldName] = val
+		}
+	}
+
+	return colVarsList, rowCount, nil
+}
+
+// Resolves configured column names against the file schema, checks repeated settings
+func resolveParquetReaderColumns(node *sc.ScriptNodeDef, schemaDef *parquetschema.SchemaDefinition) ([]*parquetReaderColumn, error) {
+	columns := make([]*parquetReaderColumn, 0, len(node.FileReader.Columns))
+	var explodedCol *parquetReaderColumn
+	for capiFieldName, colDef := range node.FileReader.Columns {
+		resolved, err := storage.ParquetResolveColumn(schemaDef.RootColumn, colDef.Parquet.ParsedPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find requested parquet column in the file: %s: %s", colDef.Parquet.SrcColName, err.Error())
+		}
+		t, err := storage.ParquetGuessCapiType(resolved.SchemaElement)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read parquet column %s: %s", colDef.Parquet.SrcColName, err.Error())
+		}
+		col := parquetReaderColumn{CapiFieldName: capiFieldName, ColDef: colDef, Resolved: resolved, ParquetType: t, Values: make([]any, 0, 1)}
+
+		if resolved.IsRepeated() && colDef.Parquet.Repeated == sc.ParquetRepeatedNone {
+			return nil, fmt.Errorf("parquet column %s is a list, map or repeated field, specify parquet.repeated: %s, %s or %s", colDef.Parquet.SrcColName, sc.ParquetRepeatedExplode, sc.ParquetRepeatedExplodeOuter, sc.ParquetRepeatedJoin)
+		}
+		if !resolved.IsRepeated() && colDef.Parquet.Repeated != sc.ParquetRepeatedNone {
+			return nil, fmt.Errorf("parquet column %s is not a list, map or repeated field, remove parquet.repeated", colDef.Parquet.SrcColName)
+		}
+		if colDef.Parquet.IsExploded() {
+			// Exploded values are zipped: items.sku and items.qty work together, tags and items.sku don't
+			if explodedCol != nil && explodedCol.Resolved.RepeatedPrefix() != resolved.RepeatedPrefix() {
+				return nil, fmt.Errorf("exploded parquet columns %s and %s belong to different repeated fields %s and %s, only one repeated field can be exploded", explodedCol.ColDef.Parquet.SrcColName, colDef.Parquet.SrcColName, explodedCol.Resolved.RepeatedPrefix(), resolved.RepeatedPrefix())
 			}
-		case evalcapi.FieldTypeDecimal2:
-			if colVars[sc.ReaderAlias][capiFieldName], err = storage.ParquetReadDecimal2(volatile, se); err != nil {
-				return fmt.Errorf("cannot read decimal2 row %d, column %s: %s", rowIdx, parquetColName, err.Error())
+			explodedCol = &col
+		}
+		columns = append(columns, &col)
+	}
+	return columns, nil
+}
+
+// Reader filter columns that have row group statistics: no joined values, they are strings made of many values
+func parquetFilterColumns(node *sc.ScriptNodeDef, columns []*parquetReaderColumn) []*parquetReaderColumn {
+	filterColumns := make([]*parquetReaderColumn, 0)
+	if !node.FileReader.UsesFilter() {
+		return filterColumns
+	}
+	for _, col := range columns {
+		if col.ColDef.Parquet.Repeated == sc.ParquetRepeatedJoin {
+			continue
+		}
+		for _, fieldRef := range node.FileReader.UsedInFilterFields {
+			if fieldRef.FieldName == col.CapiFieldName {
+				filterColumns = append(filterColumns, col)
+				break
 			}
-		default:
-			return fmt.Errorf("cannot read unsupported type %s row %d, column %s", capiType, rowIdx, parquetColName)
 		}
 	}
+	return filterColumns
+}
 
-	return nil
+// Filter column values in this row group, as readParquetValue returns them; columns without usable statistics are omitted
+func parquetRowGroupFilterStats(rowGroup *parquet.RowGroup, filterColumns []*parquetReaderColumn) map[string]*sc.ReaderColumnStats {
+	stats := make(map[string]*sc.ReaderColumnStats, len(filterColumns))
+	for _, col := range filterColumns {
+		chunkStats := storage.ParquetColumnChunkStats(rowGroup, col.Resolved)
+		if chunkStats == nil {
+			continue
+		}
+		minVal, err := readParquetValue(chunkStats.Min, col.ParquetType, col.Resolved.SchemaElement)
+		if err != nil {
+			continue
+		}
+		maxVal, err := readParquetValue(chunkStats.Max, col.ParquetType, col.Resolved.SchemaElement)
+		if err != nil {
+			continue
+		}
+		stats[col.CapiFieldName] = &sc.ReaderColumnStats{
+			Min: minVal,
+			Max: maxVal,
+			// explode_outer adds a default value row for each empty list
+			MayHaveNulls: chunkStats.NullCount == nil || *chunkStats.NullCount > 0 || col.ColDef.Parquet.Repeated == sc.ParquetRepeatedExplodeOuter}
+	}
+	return stats
 }
 
 func readParquet(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, totalStartTime time.Time, filePath string, fileReadSeeker io.ReadSeeker) (BatchStats, error) {
@@ -88,40 +248,33 @@ func readParquet(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.Messa
 		return bs, fmt.Errorf("cannot read parquet file without io.ReadSeeker: %s", filePath)
 	}
 
-	// Digest source column config from the script
-	requestedParquetColumnNames := make([]string, len(node.FileReader.Columns))
-	parquetToCapiFieldNameMap := map[string]string{}
-	colIdx := 0
-	for capiFieldName, colDef := range node.FileReader.Columns {
-		requestedParquetColumnNames[colIdx] = colDef.Parquet.SrcColName
-		parquetToCapiFieldNameMap[colDef.Parquet.SrcColName] = capiFieldName
-		colIdx++
+	// Footer only: header magic check would cost an extra ranged read for S3 files
+	meta, err := gp.ReadFileMetaData(fileReadSeeker, false)
+	if err != nil {
+		return bs, fmt.Errorf("cannot read parquet [%s] metadata: %s", filePath, err.Error())
 	}
-
-	reader, err := gp.NewFileReader(fileReadSeeker, requestedParquetColumnNames...)
+	reader, err := gp.NewFileReaderWithMetaData(fileReadSeeker, meta)
 	if err != nil {
 		return bs, err
 	}
 
-	// Digest schema
-	schemaElementMap := map[string]*parquet.SchemaElement{}
-	parquetToCapiTypeMap := map[string]evalcapi.TableFieldType{}
-	schemaDef := reader.GetSchemaDefinition()
-	for _, column := range schemaDef.RootColumn.Children {
-		t, err := storage.ParquetGuessCapiType(column.SchemaElement)
-		if err != nil {
-			return bs, fmt.Errorf("cannot read parquet column %s: %s", column.SchemaElement.Name, err.Error())
-		}
-		parquetToCapiTypeMap[column.SchemaElement.Name] = t
-		schemaElementMap[column.SchemaElement.Name] = column.SchemaElement
+	// Digest schema: nested columns are selected by their physical paths, nothing is read yet
+	columns, err := resolveParquetReaderColumns(node, reader.GetSchemaDefinition())
+	if err != nil {
+		return bs, err
 	}
+	selectedPaths := make([]gp.ColumnPath, len(columns))
+	for i, col := range columns {
+		selectedPaths[i] = col.Resolved.PhysicalPath
+	}
+	reader.SetSelectedColumnsByPath(selectedPaths...)
+	filterColumns := parquetFilterColumns(node, columns)
 
-	// Check that for each Parquet field we have a corresponding mapping
-	for _, requestedColumnName := range requestedParquetColumnNames {
-		if _, ok := schemaElementMap[requestedColumnName]; !ok {
-			return bs, fmt.Errorf("cannot find requested parquet column in the file: %s", requestedColumnName)
-		}
+	badRows, err := newBadRowsWriter(envConfig, pCtx)
+	if err != nil {
+		return bs, err
 	}
+	defer badRows.close()
 
 	// Prepare inserter
 	instr, err := createInserterAndStartWorkers(logger, envConfig, pCtx, &node.TableCreator, DataIdxSeqModeDataFirst, logger.ZapMachine.String)
@@ -134,61 +287,96 @@ func readParquet(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *ctx.Messa
 	// Minimize allocations to help GC in this high-traffic loop
 	var tableRecord map[string]any
 	indexKeyMap := map[string]string{}
-	colVars := eval.VarValuesMap{}
+	colVarsList := []eval.VarValuesMap{}
+	var rowCount int
 	var d map[string]any
+	var inFilter bool
 	var inResult bool
-	for {
-		d, err = reader.NextRow()
-
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			instr.cancelDrainer(fmt.Errorf("cannot get parquet [%s] row %d: %s", filePath, bs.RowsRead, err.Error()))
-			return bs, instr.waitForDrainer()
-		}
-
-		clear(colVars)
-		if err := readParquetRowToValuesMap(d, bs.RowsRead, requestedParquetColumnNames, parquetToCapiFieldNameMap, parquetToCapiTypeMap, schemaElementMap, colVars); err != nil {
-			instr.cancelDrainer(fmt.Errorf("cannot read values from parquet [%s] row %d: %s", filePath, bs.RowsRead, err.Error()))
-			return bs, instr.waitForDrainer()
-		}
-
-		// TableCreator: evaluate table column expressions
-		tableRecord, err = node.TableCreator.CalculateTableRecordFromSrcVars(colVars)
-		if err != nil {
-			instr.cancelDrainer(fmt.Errorf("cannot populate table record from parquet [%s] row %d: [%s]", filePath, bs.RowsRead, err.Error()))
-			return bs, instr.waitForDrainer()
+	bs.RowGroupsTotal = len(meta.RowGroups)
+	for rowGroupIdx, rowGroup := range meta.RowGroups {
+		if len(filterColumns) > 0 && !node.FileReader.FilterMayMatch(parquetRowGroupFilterStats(rowGroup, filterColumns)) {
+			bs.RowGroupsSkipped++
+			continue
 		}
 
-		// Check table creator having
-		inResult, err = node.TableCreator.CheckTableRecordHavingCondition(tableRecord)
-		if err != nil {
-			instr.cancelDrainer(fmt.Errorf("cannot check having condition [%s] from parquet [%s] row %d, table record [%v]: [%s]", node.TableCreator.RawHaving, filePath, bs.RowsRead, tableRecord, err.Error()))
+		// Row group positions are 1-based
+		if err := reader.SeekToRowGroup(rowGroupIdx + 1); err != nil {
+			instr.cancelDrainer(fmt.Errorf("cannot read parquet [%s] row group %d: %s", filePath, rowGroupIdx, err.Error()))
 			return bs, instr.waitForDrainer()
 		}
 
-		if inResult {
-			err = instr.buildIndexKeys(tableRecord, indexKeyMap)
+		for rowGroupRowIdx := int64(0); rowGroupRowIdx < rowGroup.NumRows; rowGroupRowIdx++ {
+			d, err = reader.NextRow()
 			if err != nil {
-				instr.cancelDrainer(fmt.Errorf("cannot build index keys for table %s from parquet [%s] row %d: [%s]", node.TableCreator.Name, filePath, bs.RowsRead, err.Error()))
+				instr.cancelDrainer(fmt.Errorf("cannot get parquet [%s] row %d: %s", filePath, bs.RowsRead, err.Error()))
 				return bs, instr.waitForDrainer()
 			}
 
-			instr.add(tableRecord, indexKeyMap)
-			bs.RowsWritten++
-		}
-		bs.RowsRead++
-		if bs.RowsRead%100 == 0 {
-			instr.PCtx.SendHeartbeat()
+			colVarsList, rowCount, err = readParquetRowToValuesMaps(d, bs.RowsRead, columns, colVarsList)
+			if err != nil {
+				if err := badRows.add(logger, pCtx, int64(bs.RowsRead), fmt.Sprintf("%v", d), fmt.Errorf("cannot read values from parquet [%s] row %d: %s", filePath, bs.RowsRead, err.Error())); err != nil {
+					instr.cancelDrainer(err)
+					return bs, instr.waitForDrainer()
+				}
+			} else {
+				// Exploded columns: one table record per element
+				for _, colVars := range colVarsList[:rowCount] {
+					// Row group statistics only tell which groups have no matching rows, check each row
+					inFilter, err = node.FileReader.CheckFilterCondition(colVars)
+					if err != nil {
+						instr.cancelDrainer(fmt.Errorf("cannot check reader filter condition [%s] from parquet [%s] row %d: [%s]", node.FileReader.RawFilter, filePath, bs.RowsRead, err.Error()))
+						return bs, instr.waitForDrainer()
+					}
+					if !inFilter {
+						continue
+					}
+
+					// TableCreator: evaluate table column expressions
+					tableRecord, err = node.TableCreator.CalculateTableRecordFromSrcVars(colVars)
+					if err != nil {
+						instr.cancelDrainer(fmt.Errorf("cannot populate table record from parquet [%s] row %d: [%s]", filePath, bs.RowsRead, err.Error()))
+						return bs, instr.waitForDrainer()
+					}
+
+					// Check table creator having
+					inResult, err = node.TableCreator.CheckTableRecordHavingCondition(tableRecord)
+					if err != nil {
+						instr.cancelDrainer(fmt.Errorf("cannot check having condition [%s] from parquet [%s] row %d, table record [%v]: [%s]", node.TableCreator.RawHaving, filePath, bs.RowsRead, tableRecord, err.Error()))
+						return bs, instr.waitForDrainer()
+					}
+
+					if inResult {
+						err = instr.buildIndexKeys(tableRecord, indexKeyMap)
+						if err != nil {
+							instr.cancelDrainer(fmt.Errorf("cannot build index keys for table %s from parquet [%s] row %d: [%s]", node.TableCreator.Name, filePath, bs.RowsRead, err.Error()))
+							return bs, instr.waitForDrainer()
+						}
+
+						instr.add(tableRecord, indexKeyMap)
+						bs.RowsWritten++
+					}
+				}
+			}
+			bs.RowsRead++
+			if bs.RowsRead%100 == 0 {
+				instr.PCtx.SendHeartbeat()
+			}
 		}
 	}
 
+	if bs.RowGroupsSkipped > 0 {
+		logger.InfoCtx(pCtx, "skipped %d of %d row groups of parquet [%s] by reader filter statistics", bs.RowGroupsSkipped, bs.RowGroupsTotal, filePath)
+	}
+
 	instr.doneSending()
 	if err := instr.waitForDrainer(); err != nil {
 		return bs, err
 	}
 
+	if err := badRows.finish(&bs); err != nil {
+		return bs, err
+	}
+
 	bs.UpdateElapsedStats(time.Since(totalStartTime), instr)
 	reportWriteTableComplete(logger, pCtx, bs.RowsRead, bs.RowsWritten, bs.Elapsed, len(node.TableCreator.Indexes), instr.NumWorkers)
 
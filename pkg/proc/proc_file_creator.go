@@ -4,9 +4,6 @@ import (
 	"container/heap"
 	"errors"
 	"fmt"
-	"io/fs"
-	"net/url"
-	"os"
 	"time"
 
 	"github.com/capillariesio/capillaries/pkg/cql"
@@ -15,7 +12,6 @@ import (
 	"github.com/capillariesio/capillaries/pkg/eval"
 	"github.com/capillariesio/capillaries/pkg/l"
 	"github.com/capillariesio/capillaries/pkg/sc"
-	"github.com/capillariesio/capillaries/pkg/xfer"
 )
 
 type FileRecordHeapItem struct {
@@ -44,9 +40,9 @@ func (h *FileRecordHeap) Pop() any {
 	return item
 }
 
-func readAndInsert(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, tableName string, rs *Rowset, instr *FileInserter, readerNodeRunId int16, startToken int64, endToken int64, srcBatchSize int) (BatchStats, error) {
+func readAndInsert(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, tableName string, rs *Rowset, instr *PartitionedFileInserter, readerNodeRunId int16, startToken int64, endToken int64, srcBatchSize int) (BatchStats, error) {
 
-	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: tableName + cql.RunIdSuffix(readerNodeRunId), Dst: instr.FinalFileUrl}
+	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: tableName + cql.RunIdSuffix(readerNodeRunId), Dst: instr.FileUrl}
 
 	var topHeap FileRecordHeap
 	if instr.FileCreator.HasTop() {
@@ -115,7 +111,9 @@ func readAndInsert(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, tab
 					heap.Pop(&topHeap)
 				}
 			} else {
-				instr.add(fileRecord)
+				if err := instr.add(logger, fileRecord); err != nil {
+					return bs, err
+				}
 				bs.RowsWritten++
 			}
 		}
@@ -126,7 +124,7 @@ func readAndInsert(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, tab
 		// because of the rowid overlapping/epilogue logic, selectBatchFromTableByToken returns less rows than rs capacity
 
 		if err := instr.checkWorkerOutputForErrors(); err != nil {
-			return bs, fmt.Errorf("cannot save record batch from %s to %s(temp %s): [%s]", tableName, instr.FinalFileUrl, instr.TempFilePath, err.Error())
+			return bs, fmt.Errorf("cannot save record batch from %s to %s: [%s]", tableName, instr.FileUrl, err.Error())
 		}
 
 	} // for each source table batch
@@ -141,7 +139,9 @@ func readAndInsert(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, tab
 			}
 		}
 		for i := 0; i < len(properlyOrderedTopList); i++ {
-			instr.add(*properlyOrderedTopList[i].FileRecord)
+			if err := instr.add(logger, *properlyOrderedTopList[i].FileRecord); err != nil {
+				return bs, err
+			}
 			bs.RowsWritten++
 		}
 	}
@@ -182,75 +182,36 @@ func runCreateFile(envConfig *env.EnvConfig,
 		sc.FieldRefs{sc.RowidTokenFieldRef()},
 		srcFieldRefs)
 
-	instr := newFileInserter(pCtx, &node.FileCreator, pCtx.Msg.RunId, pCtx.Msg.BatchIdx)
-
-	u, err := url.Parse(instr.FinalFileUrl)
+	instr, err := newPartitionedFileInserter(logger, pCtx, &node.FileCreator, node.FileCreator.GetPartUrl(pCtx.Msg.RunId, pCtx.Msg.BatchIdx), envConfig.PrivateKeys)
 	if err != nil {
-		return BatchStats{RowsRead: 0, RowsWritten: 0}, fmt.Errorf("cannot parse file url %s: %s", instr.FinalFileUrl, err.Error())
-	}
-
-	switch node.FileCreator.CreatorFileType {
-
-	case sc.CreatorFileTypeCsv:
-		if err := instr.createCsvFileAndStartWorker(logger, u); err != nil {
-			return BatchStats{RowsRead: 0, RowsWritten: 0}, fmt.Errorf("cannot start csv inserter worker: %s", err.Error())
-		}
-	case sc.CreatorFileTypeParquet:
-		if err := instr.createParquetFileAndStartWorker(logger, node.FileCreator.Parquet.Codec, u); err != nil {
-			return BatchStats{RowsRead: 0, RowsWritten: 0}, fmt.Errorf("cannot start parquet inserter worker: %s", err.Error())
-		}
-	default:
-		return BatchStats{RowsRead: 0, RowsWritten: 0}, fmt.Errorf("unknown inserter file type: %d", node.FileCreator.CreatorFileType)
+		return BatchStats{RowsRead: 0, RowsWritten: 0}, err
 	}
 
 	bs, err := readAndInsert(logger, pCtx, node.TableReader.TableName, rs, instr, readerNodeRunId, startToken, endToken, node.TableReader.RowsetSize)
 	if err != nil {
-		if closeErr := instr.waitForWorkerAndCloseErrorsOut(logger, pCtx); err != nil {
-			logger.ErrorCtx(pCtx, "unexpected error while calling waitForWorkerAndCloseErrorsOut: %s", closeErr.Error())
+		if closeErr := instr.waitForWorkersAndCloseErrorsOut(logger, pCtx); closeErr != nil {
+			logger.ErrorCtx(pCtx, "unexpected error while calling waitForWorkersAndCloseErrorsOut: %s", closeErr.Error())
 		}
+		instr.abort(err)
 		return bs, err
 	}
 
 	// Successful so far, write leftovers
-	if err := instr.waitForWorkerAndCloseErrorsOut(logger, pCtx); err != nil {
-		return bs, fmt.Errorf("cannot save record batch from %s to %s(temp %s): [%s]", node.TableReader.TableName, instr.FinalFileUrl, instr.TempFilePath, err.Error())
+	if err := instr.waitForWorkersAndCloseErrorsOut(logger, pCtx); err != nil {
+		instr.abort(err)
+		return bs, fmt.Errorf("cannot save record batch from %s to %s: [%s]", node.TableReader.TableName, instr.FileUrl, err.Error())
 	}
 
-	bs.UpdateElapsedStats(time.Since(totalStartTime), nil)
-	logger.InfoCtx(pCtx, "WriteFileComplete: read %d, wrote %d items in %.3fs (%.0f items/s)", bs.RowsRead, bs.RowsWritten, bs.Elapsed.Seconds(), float64(bs.RowsWritten)/bs.Elapsed.Seconds())
-
-	if instr.TempFilePath == "" {
-		// Nothing to do, the file is already at its destination
-		return bs, nil
-	}
-	defer os.Remove(instr.TempFilePath)
-
-	// TODO: make it prettier
-	// Wait till inserter calls w.Close() to flush the file
-	var st fs.FileInfo
-	for i := 0; i < 30; i++ {
-		st, err = os.Stat(instr.TempFilePath)
-		if err != nil {
-			return bs, fmt.Errorf("cannot get size of result file %s: %s", instr.TempFilePath, err.Error())
-		}
-		if st.Size() > 0 {
-			break
-		}
-		time.Sleep(1 * time.Second)
+	if err := instr.close(); err != nil {
+		return bs, err
 	}
 
-	if st.Size() == 0 {
-		return bs, fmt.Errorf("cannot obtain non-empty result file %s", instr.TempFilePath)
+	if node.FileCreator.HasPartitions() {
+		bs.Files = instr.FileUrls
 	}
 
-	logger.InfoCtx(pCtx, "uploading %s of size %d to %s...", instr.TempFilePath, st.Size(), instr.FinalFileUrl)
+	bs.UpdateElapsedStats(time.Since(totalStartTime), nil)
+	logger.InfoCtx(pCtx, "WriteFileComplete: read %d, wrote %d items to %d file(s) in %.3fs (%.0f items/s)", bs.RowsRead, bs.RowsWritten, len(instr.FileUrls), bs.Elapsed.Seconds(), float64(bs.RowsWritten)/bs.Elapsed.Seconds())
 
-	switch u.Scheme {
-	case xfer.UrlSchemeSftp:
-		return bs, xfer.UploadSftpFile(instr.TempFilePath, instr.FinalFileUrl, envConfig.PrivateKeys)
-	case xfer.UrlSchemeS3:
-		return bs, xfer.UploadS3File(instr.TempFilePath, u)
-	default:
-		return bs, fmt.Errorf("unexpected URL scheme %s in %s", u.Scheme, instr.FinalFileUrl)
-	}
+	return bs, nil
 }
@@ -18,6 +18,96 @@ type CustomProcessorRunner interface {
 	Run(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, rsIn *Rowset, flushVarsArrayCallback func(varsArray []eval.VarValuesMap, varsArrayCount int) error) error
 }
 
+// Reads source rows through the partition index, so all rows with the same index key come in one rowset.
+// A rowset contains whole partitions, roughly RowsetSize rows, see runCreateTableWindowForBatch
+func runCustomProcessorForPartitions(logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	readerNodeRunId int16,
+	idxName string,
+	srcFieldRefs sc.FieldRefs,
+	startToken int64,
+	endToken int64,
+	flushVarsArrayCallback func(varsArray []eval.VarValuesMap, varsArrayCount int) error) (int, error) {
+
+	node := pCtx.CurrentScriptNode
+
+	allPartitionRowids, err := collectPartitionRowids(logger, pCtx, idxName, readerNodeRunId, node.TableReader.RowsetSize, startToken, endToken)
+	if err != nil {
+		return 0, err
+	}
+
+	rs := NewRowsetFromFieldRefs(
+		sc.FieldRefs{sc.RowidFieldRef(node.TableReader.TableName)},
+		srcFieldRefs)
+
+	rowsRead := 0
+	chunkStartIdx := 0
+	chunkRowCount := 0
+	for partitionIdx := 0; partitionIdx < len(allPartitionRowids); partitionIdx++ {
+		chunkRowCount += len(allPartitionRowids[partitionIdx])
+		if chunkRowCount < node.TableReader.RowsetSize && partitionIdx < len(allPartitionRowids)-1 {
+			continue
+		}
+
+		rowidsToFind := map[int64]struct{}{}
+		for _, rowids := range allPartitionRowids[chunkStartIdx : partitionIdx+1] {
+			for _, rowid := range rowids {
+				rowidsToFind[rowid] = struct{}{}
+			}
+		}
+
+		// InitRows allocates new rows on each select, so rows can be moved to the chunk rowset
+		rsIn := NewRowsetFromFieldRefs(
+			sc.FieldRefs{sc.RowidFieldRef(node.TableReader.TableName)},
+			srcFieldRefs)
+		rsIn.Rows = make([]*[]any, 0, chunkRowCount)
+		for len(rowidsToFind) > 0 {
+			var pageState []byte
+			if _, err := selectBatchFromDataTablePaged(logger,
+				pCtx,
+				rs,
+				node.TableReader.TableName,
+				readerNodeRunId,
+				node.TableReader.RowsetSize,
+				pageState,
+				getFirstIntsFromSet(rowidsToFind, MaxAmazonKeyspacesInElements)); err != nil { // Amazon Keyspaces allows max 100 IN elements
+				return rowsRead, fmt.Errorf("cannot select batch from source table: %s", err.Error())
+			}
+
+			if rs.RowCount == 0 {
+				break
+			}
+
+			for rowIdx := 0; rowIdx < rs.RowCount; rowIdx++ {
+				delete(rowidsToFind, *((*rs.Rows[rowIdx])[rs.FieldsByFieldName["rowid"]].(*int64)))
+			}
+			rsIn.Rows = append(rsIn.Rows, rs.Rows[:rs.RowCount]...)
+			rsIn.RowCount += rs.RowCount
+		}
+
+		if len(rowidsToFind) > 0 {
+			logger.WarnCtx(pCtx, "%d source rows referenced by index %s were not found", len(rowidsToFind), idxName)
+		}
+
+		customProcBatchStartTime := time.Now()
+
+		if err := node.CustomProcessor.(CustomProcessorRunner).Run(logger, pCtx, rsIn, flushVarsArrayCallback); err != nil {
+			return rowsRead, err
+		}
+
+		custProcDur := time.Since(customProcBatchStartTime)
+		logger.InfoCtx(pCtx, "CustomProcessor: %d items in %d partitions in %v (%.0f items/s)", rsIn.RowCount, partitionIdx+1-chunkStartIdx, custProcDur, float64(rsIn.RowCount)/custProcDur.Seconds())
+
+		pCtx.SendHeartbeat()
+
+		rowsRead += rsIn.RowCount
+		chunkStartIdx = partitionIdx + 1
+		chunkRowCount = 0
+	}
+
+	return rowsRead, nil
+}
+
 func runCreateTableForCustomProcessorForBatch(envConfig *env.EnvConfig,
 	logger *l.CapiLogger,
 	pCtx *ctx.MessageProcessingContext,
@@ -104,6 +194,16 @@ func runCreateTableForCustomProcessorForBatch(envConfig *env.EnvConfig,
 		return instr.waitForDrainer()
 	}
 
+	if partitionedProcDef, ok := node.CustomProcessor.(sc.PartitionedCustomProcessorDef); ok {
+		bs.RowsRead, err = runCustomProcessorForPartitions(logger, pCtx, readerNodeRunId, partitionedProcDef.GetPartitionIndexName(), srcLeftFieldRefs, startLeftToken, endLeftToken, flushVarsArrayCallback)
+		if err != nil {
+			return bs, fmt.Errorf("cannot run custom processor for partitions, node %s: %s", node.Name, err.Error())
+		}
+		bs.UpdateElapsedStats(time.Since(totalStartTime), instr)
+		reportWriteTableComplete(logger, pCtx, bs.RowsRead, bs.RowsWritten, bs.Elapsed, len(node.TableCreator.Indexes), instr.NumWorkers)
+		return bs, nil
+	}
+
 	var curStartLeftTokenRowIds []int64
 	for {
 		lastRetrievedLeftToken, endTokenRowIds, err := selectBatchFromTableByToken(logger,
@@ -0,0 +1,262 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/cql"
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+)
+
+// Returns indexes of rows that share their uniqueness index key with other rows of the source table.
+// The whole index table is checked, so duplicates from other batches are caught too.
+func findDuplicateKeyRows(logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	rowVars []eval.VarValuesMap,
+	ruleDef *sc.DataQualityRuleDef,
+	readerNodeRunId int16,
+	idxReadBatchSize int) (map[int]struct{}, error) {
+
+	keyToRowIdxMap := map[string][]int{}
+	for rowIdx, vars := range rowVars {
+		key, err := sc.BuildKey(vars[sc.ReaderAlias], ruleDef.UniqueIdxDef)
+		if err != nil {
+			return nil, err
+		}
+		keyToRowIdxMap[key] = append(keyToRowIdxMap[key], rowIdx)
+	}
+
+	allKeysToFind := make([]string, 0, len(keyToRowIdxMap))
+	for key := range keyToRowIdxMap {
+		allKeysToFind = append(allKeysToFind, key)
+	}
+
+	rsIdx := NewRowsetFromFieldRefs(
+		sc.FieldRefs{sc.RowidFieldRef(ruleDef.UniqueIdxName)},
+		sc.FieldRefs{sc.KeyTokenFieldRef()},
+		sc.FieldRefs{sc.IdxKeyFieldRef()})
+
+	keyRowidCount := map[string]int{}
+	for _, keysToFind := range splitKeysIntoChunks(allKeysToFind, MaxAmazonKeyspacesBatchLen) {
+		var idxPageState []byte
+		var err error
+		for {
+			idxPageState, err = selectBatchFromIdxTablePaged(logger,
+				pCtx,
+				rsIdx,
+				ruleDef.UniqueIdxName,
+				readerNodeRunId,
+				idxReadBatchSize,
+				idxPageState,
+				&keysToFind)
+			if err != nil {
+				return nil, err
+			}
+			for rowIdx := 0; rowIdx < rsIdx.RowCount; rowIdx++ {
+				keyRowidCount[*((*rsIdx.Rows[rowIdx])[rsIdx.FieldsByFieldName["key"]].(*string))]++
+			}
+			if rsIdx.RowCount == 0 || len(idxPageState) == 0 {
+				break
+			}
+		}
+	}
+
+	duplicateRows := map[int]struct{}{}
+	for key, rowIdxs := range keyToRowIdxMap {
+		if keyRowidCount[key] > 1 {
+			for _, rowIdx := range rowIdxs {
+				duplicateRows[rowIdx] = struct{}{}
+			}
+		}
+	}
+	return duplicateRows, nil
+}
+
+// Checks all rules against a rowset of source rows, writes violating rows to the quarantine table
+func writeDataQualityViolations(logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	rsIn *Rowset,
+	readerNodeRunId int16,
+	instr *TableInserter,
+	bs *BatchStats) error {
+
+	node := pCtx.CurrentScriptNode
+
+	rowVars := make([]eval.VarValuesMap, rsIn.RowCount)
+	for rowIdx := 0; rowIdx < rsIn.RowCount; rowIdx++ {
+		rowVars[rowIdx] = eval.VarValuesMap{}
+		if err := rsIn.ExportToVars(rowIdx, rowVars[rowIdx]); err != nil {
+			return err
+		}
+	}
+
+	// Help GC
+	var indexKeyMap = map[string]string{}
+
+	for _, ruleName := range node.DataQuality.RuleNames {
+		ruleDef := node.DataQuality.Rules[ruleName]
+
+		var duplicateRows map[int]struct{}
+		if ruleDef.IsUniqueness() {
+			var err error
+			if duplicateRows, err = findDuplicateKeyRows(logger, pCtx, rowVars, ruleDef, readerNodeRunId, node.TableReader.RowsetSize); err != nil {
+				return fmt.Errorf("cannot check data quality rule %s: %s", ruleName, err.Error())
+			}
+		}
+
+		for rowIdx, vars := range rowVars {
+			violated := false
+			if ruleDef.IsUniqueness() {
+				_, violated = duplicateRows[rowIdx]
+			} else {
+				isValid, err := ruleDef.CheckExpression(vars)
+				if err != nil {
+					return fmt.Errorf("cannot check data quality rule %s: %s", ruleName, err.Error())
+				}
+				violated = !isValid
+			}
+			if !violated {
+				continue
+			}
+
+			bs.RuleViolations[ruleName]++
+			vars[sc.DataQualityAlias] = map[string]any{sc.DataQualityRuleFieldName: ruleName}
+			tableRecord, err := node.TableCreator.CalculateTableRecordFromSrcVars(vars)
+			if err != nil {
+				return fmt.Errorf("cannot populate table record from [%v]: [%s]", vars, err.Error())
+			}
+			if err = checkHavingAddRecordAndSaveBatchIfNeeded(logger, node, tableRecord, indexKeyMap, instr); err != nil {
+				return err
+			}
+			bs.RowsWritten++
+		}
+	}
+
+	bs.RowsRead += rsIn.RowCount
+	return nil
+}
+
+// Returns an error listing rules that exceeded their thresholds, this fails the batch
+func checkDataQualityThresholds(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, bs *BatchStats) error {
+	node := pCtx.CurrentScriptNode
+	failedRules := make([]string, 0)
+	for _, ruleName := range node.DataQuality.RuleNames {
+		violations := bs.RuleViolations[ruleName]
+		if violations == 0 {
+			continue
+		}
+		maxViolations := node.DataQuality.Rules[ruleName].MaxViolations
+		logger.WarnCtx(pCtx, "data quality rule %s: %d violations, %d allowed", ruleName, violations, maxViolations)
+		if violations > maxViolations {
+			failedRules = append(failedRules, fmt.Sprintf("%s: %d violations, %d allowed", ruleName, violations, maxViolations))
+		}
+	}
+	if len(failedRules) > 0 {
+		return fmt.Errorf("data quality rules failed: [%s]", strings.Join(failedRules, "; "))
+	}
+	return nil
+}
+
+func runCreateTableDataQualityForBatch(envConfig *env.EnvConfig,
+	logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	readerNodeRunId int16,
+	startLeftToken int64,
+	endLeftToken int64) (BatchStats, error) {
+
+	logger.PushF("proc.runCreateTableDataQualityForBatch")
+	defer logger.PopF()
+
+	node := pCtx.CurrentScriptNode
+
+	totalStartTime := time.Now()
+
+	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: node.TableReader.TableName + cql.RunIdSuffix(readerNodeRunId), Dst: node.TableCreator.Name + cql.RunIdSuffix(readerNodeRunId), RuleViolations: map[string]int64{}}
+
+	if readerNodeRunId == 0 {
+		return bs, errors.New("this node has a dependency node to read data from that was never started in this keyspace (readerNodeRunId == 0)")
+	}
+
+	if !node.HasTableReader() {
+		return bs, errors.New("node does not have table reader")
+	}
+	if !node.HasTableCreator() {
+		return bs, errors.New("node does not have table creator")
+	}
+	if !node.HasDataQuality() {
+		return bs, errors.New("node does not have data quality rules")
+	}
+
+	for _, ruleName := range node.DataQuality.RuleNames {
+		bs.RuleViolations[ruleName] = 0
+	}
+
+	// Fields to read from source table
+	srcLeftFieldRefs := sc.FieldRefs{}
+	srcLeftFieldRefs.AppendWithFilter(node.TableCreator.UsedInTargetExpressionsFields, sc.ReaderAlias)
+	srcLeftFieldRefs.Append(node.DataQuality.UsedInRulesFields)
+
+	rsIn := NewRowsetFromFieldRefs(
+		sc.FieldRefs{sc.RowidFieldRef(node.TableReader.TableName)},
+		sc.FieldRefs{sc.RowidTokenFieldRef()},
+		srcLeftFieldRefs)
+
+	instr, err := createInserterAndStartWorkers(logger, envConfig, pCtx, &node.TableCreator, DataIdxSeqModeDataFirst, logger.ZapMachine.String)
+	if err != nil {
+		return bs, err
+	}
+	instr.startDrainer()
+	defer instr.closeInserter(logger, pCtx)
+
+	curStartLeftToken := startLeftToken
+	var curStartLeftTokenRowIds []int64
+	for {
+		lastRetrievedLeftToken, endTokenRowIds, err := selectBatchFromTableByToken(logger,
+			pCtx,
+			rsIn,
+			node.TableReader.TableName,
+			readerNodeRunId,
+			node.TableReader.RowsetSize,
+			curStartLeftToken,
+			endLeftToken,
+			curStartLeftTokenRowIds)
+		if err != nil {
+			instr.cancelDrainer(fmt.Errorf("cannot select batch from source table, node %s: %s", node.Name, err.Error()))
+			return bs, instr.waitForDrainer()
+		}
+
+		// See overlap/epilogue logic in selectBatchFromTableByToken
+		curStartLeftToken = lastRetrievedLeftToken
+		curStartLeftTokenRowIds = endTokenRowIds
+
+		if rsIn.RowCount == 0 {
+			break
+		}
+
+		if err := writeDataQualityViolations(logger, pCtx, rsIn, readerNodeRunId, instr, &bs); err != nil {
+			instr.cancelDrainer(fmt.Errorf("cannot write data quality violations, node %s: %s", node.Name, err.Error()))
+			return bs, instr.waitForDrainer()
+		}
+
+		// Do not break on rsIn.RowCount < RowsetSize, see overlap/epilogue logic in selectBatchFromTableByToken
+
+		instr.PCtx.SendHeartbeat()
+	}
+
+	instr.doneSending()
+	if err := instr.waitForDrainer(); err != nil {
+		return bs, err
+	}
+
+	bs.UpdateElapsedStats(time.Since(totalStartTime), instr)
+	reportWriteTableComplete(logger, pCtx, bs.RowsRead, bs.RowsWritten, bs.Elapsed, len(node.TableCreator.Indexes), instr.NumWorkers)
+
+	// Quarantine table is complete at this point, even if the batch fails
+	return bs, checkDataQualityThresholds(logger, pCtx, &bs)
+}
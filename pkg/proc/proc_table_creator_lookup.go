@@ -75,7 +75,7 @@ func setupEvalCtxForGroup(node *sc.ScriptNodeDef, rsLeft *Rowset) (map[int64]map
 					if newCtxErr != nil {
 						return nil, fmt.Errorf("cannot initialize ctx for group calc: %s", newCtxErr.Error())
 					}
-					newCtx.SetRoundDec(2) // decimal2
+					newCtx.SetRoundDec(evalcapi.DecimalFieldTypeScale(fieldDef.Type))
 				} else {
 					newCtx = eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, nil)
 				}
@@ -171,12 +171,17 @@ func produceGroupedTableRecord(node *sc.ScriptNodeDef, rsLeft *Rowset, leftRowId
 		// Grouped inner or left outer with present data on the right
 		leftRowid := *((*rsLeft.Rows[leftRowIdx])[rsLeft.FieldsByFieldName["rowid"]].(*int64))
 		for fieldName, fieldDef := range node.TableCreator.Fields {
-			// WARNING: this can be considered a Capillaries shortcoming:
-			// what if there are no rows to aggregate? SQL/CQL would return nil, but Capillaries cannot.
-			// So we have to use default value. Or should we make it configurable?
-			finalValue := eCtxMap[leftRowid][fieldName].GetSafeValue(sc.GetDefaultFieldTypeValue(fieldDef.Type))
+			// What if there are no rows to aggregate? SQL/CQL would return nil, so do nullable fields.
+			// Non-nullable fields have to use default value.
+			var finalValue any
+			if fieldDef.Nullable {
+				finalValue = eCtxMap[leftRowid][fieldName].GetValue()
+			} else {
+				finalValue = eCtxMap[leftRowid][fieldName].GetSafeValue(sc.GetDefaultFieldTypeValue(fieldDef.Type))
+			}
+			finalValue = sc.RoundDecimalValue(finalValue, fieldDef.Type)
 
-			if err := sc.CheckValueType(finalValue, fieldDef.Type); err != nil {
+			if err := sc.CheckNullableValueType(finalValue, fieldDef.Type, fieldDef.Nullable); err != nil {
 				return nil, fmt.Errorf("invalid field %s type: [%s]", fieldName, err.Error())
 			}
 			tableRecord[fieldName] = finalValue
@@ -315,6 +320,14 @@ func runCreateTableRelForBatch(envConfig *env.EnvConfig,
 		return bs, err
 	}
 
+	if node.IsUnmatchedRightBatch(int(pCtx.Msg.BatchIdx)) {
+		return runCreateTableRelUnmatchedRightForBatch(envConfig, logger, pCtx, readerNodeRunId, lookupNodeRunId, startLeftToken, endLeftToken)
+	}
+
+	if node.Lookup.IsAsOf() {
+		return runCreateTableRelAsOfForBatch(envConfig, logger, pCtx, readerNodeRunId, lookupNodeRunId, startLeftToken, endLeftToken)
+	}
+
 	// Fields to read from source table
 	srcLeftFieldRefs := sc.FieldRefs{}
 	srcLeftFieldRefs.AppendWithFilter(node.TableCreator.UsedInTargetExpressionsFields, sc.ReaderAlias)
@@ -544,8 +557,8 @@ func runCreateTableRelForBatch(envConfig *env.EnvConfig,
 		} // for each 100-key chunk
 
 		// For grouped - group
-		// For non-grouped left join - add empty left-side (those who have right counterpart were alredy hendled above)
-		// Non-grouped inner join - already handled above
+		// For non-grouped left/full join - add empty left-side (those who have right counterpart were alredy hendled above)
+		// Non-grouped inner/right join - already handled above (right rows without left counterparts are handled by a separate batch)
 		if node.Lookup.IsGroup {
 			// Help GC
 			var indexKeyMap = map[string]string{}
@@ -568,9 +581,9 @@ func runCreateTableRelForBatch(envConfig *env.EnvConfig,
 				}
 				bs.RowsWritten++
 			}
-		} else if node.Lookup.LookupJoin == sc.LookupJoinLeft {
+		} else if node.Lookup.IncludesUnmatchedLeft() {
 
-			// Non-grouped left outer join.
+			// Non-grouped left (or full) outer join.
 			// Handle those left rows that did not have right lookup counterpart
 			// (those who had - they have been written already)
 
@@ -0,0 +1,229 @@
+package proc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/cql"
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+)
+
+// For each left row, find the rowid of the nearest right row: one range read on the as_of() idx table per
+// distinct (key, as-of key) pair, the idx key order does the rest. Returns right rowid -> left row indexes.
+func findAsOfRightRowids(pCtx *ctx.MessageProcessingContext, rsLeft *Rowset, lookupNodeRunId int16) (map[int64][]int, int, error) {
+	node := pCtx.CurrentScriptNode
+
+	asOfKeysToLeftRowIdxs := map[[2]string][]int{}
+	for rowIdx := 0; rowIdx < rsLeft.RowCount; rowIdx++ {
+		vars := eval.VarValuesMap{}
+		if err := rsLeft.ExportToVars(rowIdx, vars); err != nil {
+			return nil, 0, err
+		}
+		if _, ok := vars[sc.ReaderAlias][node.Lookup.AsOf.LeftField.FieldName].(time.Time); !ok {
+			// Null left datetime never matches
+			continue
+		}
+		key, asOfKey, err := node.Lookup.BuildAsOfKeys(vars[sc.ReaderAlias])
+		if err != nil {
+			return nil, 0, err
+		}
+		asOfKeys := [2]string{key, asOfKey}
+		asOfKeysToLeftRowIdxs[asOfKeys] = append(asOfKeysToLeftRowIdxs[asOfKeys], rowIdx)
+	}
+
+	rightRowidToLeftRowIdxs := map[int64][]int{}
+	queriedCount := 0
+	for asOfKeys, leftRowIdxs := range asOfKeysToLeftRowIdxs {
+		rightRowid, isFound, err := selectNearestRowidFromAsOfIdxTable(pCtx, node.Lookup.IndexName, lookupNodeRunId, asOfKeys[0], asOfKeys[1], node.Lookup.AsOf.Direction)
+		if err != nil {
+			return nil, 0, err
+		}
+		if isFound {
+			rightRowidToLeftRowIdxs[rightRowid] = append(rightRowidToLeftRowIdxs[rightRowid], leftRowIdxs...)
+		}
+		queriedCount++
+		if queriedCount%1000 == 0 {
+			pCtx.SendHeartbeat()
+		}
+	}
+	return rightRowidToLeftRowIdxs, len(asOfKeysToLeftRowIdxs), nil
+}
+
+// As-of lookup: each left row is joined with at most one right row, the one with the nearest datetime
+func runCreateTableRelAsOfForBatch(envConfig *env.EnvConfig,
+	logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	readerNodeRunId int16,
+	lookupNodeRunId int16,
+	startLeftToken int64,
+	endLeftToken int64) (BatchStats, error) {
+
+	logger.PushF("proc.runCreateTableRelAsOfForBatch")
+	defer logger.PopF()
+
+	node := pCtx.CurrentScriptNode
+
+	totalStartTime := time.Now()
+
+	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: node.TableReader.TableName + cql.RunIdSuffix(readerNodeRunId), Dst: node.TableCreator.Name + cql.RunIdSuffix(readerNodeRunId)}
+
+	if err := checkRunCreateTableRelForBatchSanity(node, readerNodeRunId, lookupNodeRunId); err != nil {
+		return bs, err
+	}
+
+	// Fields to read from source table
+	srcLeftFieldRefs := sc.FieldRefs{}
+	srcLeftFieldRefs.AppendWithFilter(node.TableCreator.UsedInTargetExpressionsFields, sc.ReaderAlias)
+	srcLeftFieldRefs.Append(node.Lookup.LeftTableFields)
+	srcLeftFieldRefs.Append(sc.FieldRefs{node.Lookup.AsOf.LeftField})
+
+	srcRightFieldRefs := sc.FieldRefs{}
+	srcRightFieldRefs.AppendWithFilter(node.TableCreator.UsedInTargetExpressionsFields, sc.LookupAlias)
+
+	rsLeft := NewRowsetFromFieldRefs(
+		sc.FieldRefs{sc.RowidFieldRef(node.TableReader.TableName)},
+		sc.FieldRefs{sc.RowidTokenFieldRef()},
+		srcLeftFieldRefs)
+
+	instr, err := createInserterAndStartWorkers(logger, envConfig, pCtx, &node.TableCreator, DataIdxSeqModeDataFirst, logger.ZapMachine.String)
+	if err != nil {
+		return bs, err
+	}
+	instr.startDrainer()
+	defer instr.closeInserter(logger, pCtx)
+
+	// Help GC
+	var indexKeyMap = map[string]string{}
+	var tableRecord map[string]any
+
+	curStartLeftToken := startLeftToken
+	leftPageIdx := 0
+	var curStartLeftTokenRowIds []int64
+	for {
+		lastRetrievedLeftToken, endTokenRowIds, err := selectBatchFromTableByToken(logger,
+			pCtx,
+			rsLeft,
+			node.TableReader.TableName,
+			readerNodeRunId,
+			node.TableReader.RowsetSize,
+			curStartLeftToken,
+			endLeftToken,
+			curStartLeftTokenRowIds)
+		if err != nil {
+			instr.cancelDrainer(fmt.Errorf("cannot select batch from source table, node %s: %s", node.Name, err.Error()))
+			return bs, instr.waitForDrainer()
+		}
+
+		// See overlap/epilogue logic in selectBatchFromTableByToken
+		curStartLeftToken = lastRetrievedLeftToken
+		curStartLeftTokenRowIds = endTokenRowIds
+
+		if rsLeft.RowCount == 0 {
+			break
+		}
+
+		findRightStartTime := time.Now()
+		rightRowidToLeftRowIdxs, asOfKeyCount, err := findAsOfRightRowids(pCtx, rsLeft, lookupNodeRunId)
+		if err != nil {
+			instr.cancelDrainer(fmt.Errorf("cannot find as-of right rowids for the left-side rowset, node %s: %s", node.Name, err.Error()))
+			return bs, instr.waitForDrainer()
+		}
+
+		logger.DebugCtx(pCtx, "findAsOfRightRowids: leftPageIdx %d, queried %d as-of keys in %.3fs, found %d right rowids", leftPageIdx, asOfKeyCount, time.Since(findRightStartTime).Seconds(), len(rightRowidToLeftRowIdxs))
+
+		leftRowFoundRightLookup := make([]bool, rsLeft.RowCount)
+
+		rightRowidsToFind := make(map[int64]struct{}, len(rightRowidToLeftRowIdxs))
+		for rightRowid := range rightRowidToLeftRowIdxs {
+			rightRowidsToFind[rightRowid] = struct{}{}
+		}
+
+		rsRight := NewRowsetFromFieldRefs(
+			sc.FieldRefs{sc.RowidFieldRef(node.Lookup.TableCreator.Name)},
+			sc.FieldRefs{sc.RowidTokenFieldRef()},
+			srcRightFieldRefs)
+
+		for len(rightRowidsToFind) > 0 {
+			var rightPageState []byte
+			selectBatchStartTime := time.Now()
+			_, err = selectBatchFromDataTablePaged(logger,
+				pCtx,
+				rsRight,
+				node.Lookup.TableCreator.Name,
+				lookupNodeRunId,
+				node.Lookup.RightLookupReadBatchSize,
+				rightPageState,
+				getFirstIntsFromSet(rightRowidsToFind, MaxAmazonKeyspacesInElements)) // Amazon Keyspaces allows max 100 IN elements
+			if err != nil {
+				instr.cancelDrainer(fmt.Errorf("cannot select batch from right-side table, node %s: %s", node.Name, err.Error()))
+				return bs, instr.waitForDrainer()
+			}
+
+			logger.DebugCtx(pCtx, "selectBatchFromDataTablePaged: leftPageIdx %d, queried %d rowids in %.3fs, retrieved %d rowids", leftPageIdx, len(rightRowidsToFind), time.Since(selectBatchStartTime).Seconds(), rsRight.RowCount)
+
+			if rsRight.RowCount == 0 {
+				break
+			}
+
+			for rightRowIdx := 0; rightRowIdx < rsRight.RowCount; rightRowIdx++ {
+				rightRowId := *((*rsRight.Rows[rightRowIdx])[rsRight.FieldsByFieldName["rowid"]].(*int64))
+				delete(rightRowidsToFind, rightRowId)
+
+				for _, leftRowIdx := range rightRowidToLeftRowIdxs[rightRowId] {
+					leftRowFoundRightLookup[leftRowIdx] = true
+
+					tableRecord, err = produceNonGroupedTableRecordForLeftWithChildren(node, rsLeft, leftRowIdx, rsRight, rightRowIdx)
+					if err != nil {
+						instr.cancelDrainer(fmt.Errorf("cannot produceNonGroupedTableRecordForLeftWithChildren, node %s: %s", node.Name, err.Error()))
+						return bs, instr.waitForDrainer()
+					}
+
+					if err = checkHavingAddRecordAndSaveBatchIfNeeded(logger, node, tableRecord, indexKeyMap, instr); err != nil {
+						instr.cancelDrainer(fmt.Errorf("cannot AsOf checkHavingAddRecordAndSaveBatchIfNeeded, node %s: %s", node.Name, err.Error()))
+						return bs, instr.waitForDrainer()
+					}
+					bs.RowsWritten++
+				}
+			}
+		}
+
+		if node.Lookup.IncludesUnmatchedLeft() {
+			for leftRowIdx := 0; leftRowIdx < rsLeft.RowCount; leftRowIdx++ {
+				if leftRowFoundRightLookup[leftRowIdx] {
+					continue
+				}
+				tableRecord, err = produceNonGroupedTableRecordForCheldlessLeft(node, rsLeft, leftRowIdx)
+				if err != nil {
+					instr.cancelDrainer(fmt.Errorf("cannot produceNonGroupedTableRecordForCheldlessLeft, node %s: %s", node.Name, err.Error()))
+					return bs, instr.waitForDrainer()
+				}
+
+				if err = checkHavingAddRecordAndSaveBatchIfNeeded(logger, node, tableRecord, indexKeyMap, instr); err != nil {
+					instr.cancelDrainer(fmt.Errorf("cannot AsOf LookupJoinLeft checkHavingAddRecordAndSaveBatchIfNeeded, node %s: %s", node.Name, err.Error()))
+					return bs, instr.waitForDrainer()
+				}
+				bs.RowsWritten++
+			}
+		}
+
+		bs.RowsRead += rsLeft.RowCount
+		instr.PCtx.SendHeartbeat()
+
+		// Do not break on rsLeft.RowCount < RowsetSize, see overlap/epilogue logic in selectBatchFromTableByToken
+		leftPageIdx++
+	}
+
+	instr.doneSending()
+	if err := instr.waitForDrainer(); err != nil {
+		return bs, err
+	}
+
+	bs.UpdateElapsedStats(time.Since(totalStartTime), instr)
+	reportWriteTableComplete(logger, pCtx, bs.RowsRead, bs.RowsWritten, bs.Elapsed, len(node.TableCreator.Indexes), instr.NumWorkers)
+
+	return bs, nil
+}
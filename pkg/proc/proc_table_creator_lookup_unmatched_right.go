@@ -0,0 +1,242 @@
+package proc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/cql"
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+)
+
+func produceNonGroupedTableRecordForParentlessRight(node *sc.ScriptNodeDef, rsRight *Rowset, rightRowIdx int) (map[string]any, error) {
+	tableRecord := map[string]any{}
+
+	rightVars := eval.VarValuesMap{}
+	if err := rsRight.ExportToVarsWithAlias(rightRowIdx, rightVars, sc.LookupAlias); err != nil {
+		return nil, err
+	}
+
+	var err error
+	for fieldName, fieldDef := range node.TableCreator.Fields {
+		if fieldDef.UsedFields.HasFieldsWithTableAlias(sc.ReaderAlias) {
+			// This field expression uses fields from the left table - produce default value
+			tableRecord[fieldName], err = node.TableCreator.GetFieldDefaultReadyForDb(fieldName)
+			if err != nil {
+				return nil, fmt.Errorf("cannot initialize non-grouped default field %s: [%s]", fieldName, err.Error())
+			}
+		} else {
+			// This field expression does not use fields from the left table - assume the expression contains only lookup fields
+			tableRecord[fieldName], err = sc.CalculateFieldValue(fieldName, fieldDef, rightVars)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tableRecord, nil
+}
+
+// Collects keys for all left rows in the token range (normally, the whole left table).
+// Memory is O(distinct left keys), see Join operations in glossary.md
+func collectAllLeftKeys(logger *l.CapiLogger, pCtx *ctx.MessageProcessingContext, readerNodeRunId int16, startLeftToken int64, endLeftToken int64) (map[string]struct{}, int, error) {
+	node := pCtx.CurrentScriptNode
+
+	rsLeft := NewRowsetFromFieldRefs(
+		sc.FieldRefs{sc.RowidFieldRef(node.TableReader.TableName)},
+		sc.FieldRefs{sc.RowidTokenFieldRef()},
+		node.Lookup.LeftTableFields)
+
+	leftKeys := map[string]struct{}{}
+	rowsRead := 0
+	curStartLeftToken := startLeftToken
+	var curStartLeftTokenRowIds []int64
+	for {
+		lastRetrievedLeftToken, endTokenRowIds, err := selectBatchFromTableByToken(logger,
+			pCtx,
+			rsLeft,
+			node.TableReader.TableName,
+			readerNodeRunId,
+			node.TableReader.RowsetSize,
+			curStartLeftToken,
+			endLeftToken,
+			curStartLeftTokenRowIds)
+		if err != nil {
+			return nil, rowsRead, fmt.Errorf("cannot select batch from source table: %s", err.Error())
+		}
+
+		// See overlap/epilogue logic in selectBatchFromTableByToken
+		curStartLeftToken = lastRetrievedLeftToken
+		curStartLeftTokenRowIds = endTokenRowIds
+
+		if rsLeft.RowCount == 0 {
+			break
+		}
+
+		keys, _, err := buildKeysToFindInTheLookupIndex(rsLeft, node.Lookup)
+		if err != nil {
+			return nil, rowsRead, fmt.Errorf("cannot build keys for the left-side rowset: %s", err.Error())
+		}
+		for _, key := range keys {
+			leftKeys[key] = struct{}{}
+		}
+		rowsRead += rsLeft.RowCount
+	}
+
+	return leftKeys, rowsRead, nil
+}
+
+// Right/full outer join: write right rows that do not have left counterparts.
+// This batch does not depend on other batches of this node: it reads all left keys itself
+// and walks through the whole lookup index looking for keys that are not on the left.
+func runCreateTableRelUnmatchedRightForBatch(envConfig *env.EnvConfig,
+	logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	readerNodeRunId int16,
+	lookupNodeRunId int16,
+	startLeftToken int64,
+	endLeftToken int64) (BatchStats, error) {
+
+	logger.PushF("proc.runCreateTableRelUnmatchedRightForBatch")
+	defer logger.PopF()
+
+	node := pCtx.CurrentScriptNode
+
+	totalStartTime := time.Now()
+
+	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: node.Lookup.TableCreator.Name + cql.RunIdSuffix(lookupNodeRunId), Dst: node.TableCreator.Name + cql.RunIdSuffix(readerNodeRunId)}
+
+	if err := checkRunCreateTableRelForBatchSanity(node, readerNodeRunId, lookupNodeRunId); err != nil {
+		return bs, err
+	}
+
+	leftKeys, leftRowsRead, err := collectAllLeftKeys(logger, pCtx, readerNodeRunId, startLeftToken, endLeftToken)
+	if err != nil {
+		return bs, fmt.Errorf("cannot collect left keys, node %s: %s", node.Name, err.Error())
+	}
+
+	logger.InfoCtx(pCtx, "collected %d left keys from %d left rows", len(leftKeys), leftRowsRead)
+
+	srcRightFieldRefs := sc.FieldRefs{}
+	srcRightFieldRefs.AppendWithFilter(node.TableCreator.UsedInTargetExpressionsFields, sc.LookupAlias)
+	if node.Lookup.UsesFilter() {
+		srcRightFieldRefs.AppendWithFilter(node.Lookup.UsedInFilterFields, sc.LookupAlias)
+	}
+
+	instr, err := createInserterAndStartWorkers(logger, envConfig, pCtx, &node.TableCreator, DataIdxSeqModeDataFirst, logger.ZapMachine.String)
+	if err != nil {
+		return bs, err
+	}
+	instr.startDrainer()
+	defer instr.closeInserter(logger, pCtx)
+
+	rsIdx := NewRowsetFromFieldRefs(
+		sc.FieldRefs{sc.RowidFieldRef(node.Lookup.IndexName)},
+		sc.FieldRefs{sc.IdxKeyFieldRef()})
+
+	rsRight := NewRowsetFromFieldRefs(
+		sc.FieldRefs{sc.RowidFieldRef(node.Lookup.TableCreator.Name)},
+		sc.FieldRefs{sc.RowidTokenFieldRef()},
+		srcRightFieldRefs)
+
+	// Help GC
+	var indexKeyMap = map[string]string{}
+	var tableRecord map[string]any
+
+	var idxPageState []byte
+	rightIdxPageIdx := 0
+	for {
+		idxPageState, err = selectBatchPagedAllRowids(logger,
+			pCtx,
+			rsIdx,
+			node.Lookup.IndexName,
+			lookupNodeRunId,
+			node.Lookup.IdxReadBatchSize,
+			idxPageState)
+		if err != nil {
+			instr.cancelDrainer(fmt.Errorf("cannot select batch from idx table, node %s: %s", node.Name, err.Error()))
+			return bs, instr.waitForDrainer()
+		}
+
+		// Right rowids with keys that never showed up on the left
+		rightRowidsToFind := map[int64]struct{}{}
+		for rowIdx := 0; rowIdx < rsIdx.RowCount; rowIdx++ {
+			key := *((*rsIdx.Rows[rowIdx])[rsIdx.FieldsByFieldName["key"]].(*string))
+			if _, ok := leftKeys[key]; !ok {
+				rightRowid := *((*rsIdx.Rows[rowIdx])[rsIdx.FieldsByFieldName["rowid"]].(*int64))
+				rightRowidsToFind[rightRowid] = struct{}{}
+			}
+		}
+
+		logger.DebugCtx(pCtx, "selectBatchPagedAllRowids: rightIdxPageIdx %d, retrieved %d idx rows, %d right rowids without left counterparts", rightIdxPageIdx, rsIdx.RowCount, len(rightRowidsToFind))
+
+		for len(rightRowidsToFind) > 0 {
+			var rightPageState []byte
+			_, err = selectBatchFromDataTablePaged(logger,
+				pCtx,
+				rsRight,
+				node.Lookup.TableCreator.Name,
+				lookupNodeRunId,
+				node.Lookup.RightLookupReadBatchSize,
+				rightPageState,
+				getFirstIntsFromSet(rightRowidsToFind, MaxAmazonKeyspacesInElements)) // Amazon Keyspaces allows max 100 IN elements
+			if err != nil {
+				instr.cancelDrainer(fmt.Errorf("cannot select batch from right-side table, node %s: %s", node.Name, err.Error()))
+				return bs, instr.waitForDrainer()
+			}
+
+			if rsRight.RowCount == 0 {
+				break
+			}
+
+			for rightRowIdx := 0; rightRowIdx < rsRight.RowCount; rightRowIdx++ {
+				rightRowId := *((*rsRight.Rows[rightRowIdx])[rsRight.FieldsByFieldName["rowid"]].(*int64))
+				delete(rightRowidsToFind, rightRowId)
+
+				bs.RowsRead++
+
+				lookupFilterOk, err := checkLookupFilter(&node.Lookup, rsRight, rightRowIdx)
+				if err != nil {
+					instr.cancelDrainer(fmt.Errorf("cannot check lookup filter, node %s: %s", node.Name, err.Error()))
+					return bs, instr.waitForDrainer()
+				}
+
+				if !lookupFilterOk {
+					// Skip this right row
+					continue
+				}
+
+				tableRecord, err = produceNonGroupedTableRecordForParentlessRight(node, rsRight, rightRowIdx)
+				if err != nil {
+					instr.cancelDrainer(fmt.Errorf("cannot produceNonGroupedTableRecordForParentlessRight, node %s: %s", node.Name, err.Error()))
+					return bs, instr.waitForDrainer()
+				}
+
+				if err = checkHavingAddRecordAndSaveBatchIfNeeded(logger, node, tableRecord, indexKeyMap, instr); err != nil {
+					instr.cancelDrainer(fmt.Errorf("cannot JoinRight checkHavingAddRecordAndSaveBatchIfNeeded, node %s: %s", node.Name, err.Error()))
+					return bs, instr.waitForDrainer()
+				}
+				bs.RowsWritten++
+			}
+
+			instr.PCtx.SendHeartbeat()
+		}
+
+		if len(idxPageState) == 0 {
+			break
+		}
+		rightIdxPageIdx++
+	}
+
+	instr.doneSending()
+	if err := instr.waitForDrainer(); err != nil {
+		return bs, err
+	}
+
+	bs.UpdateElapsedStats(time.Since(totalStartTime), instr)
+	reportWriteTableComplete(logger, pCtx, bs.RowsRead, bs.RowsWritten, bs.Elapsed, len(node.TableCreator.Indexes), instr.NumWorkers)
+
+	return bs, nil
+}
@@ -54,6 +54,10 @@ func runReadFileForBatch(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *c
 	bs.Src = filePath
 	bs.Dst = node.TableCreator.Name + cql.RunIdSuffix(pCtx.Msg.RunId)
 
+	if node.FileReader.IsSplit() {
+		return readCsvSplit(envConfig, logger, pCtx, totalStartTime, filePath, u, pCtx.Msg.LastToken)
+	}
+
 	var localSrcFile *os.File
 	var fileReader io.Reader
 	var fileReadSeeker io.ReadSeeker
@@ -65,6 +69,16 @@ func runReadFileForBatch(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *c
 		defer localSrcFile.Close()
 		fileReader = bufio.NewReader(localSrcFile)
 		fileReadSeeker = localSrcFile
+	} else if u.Scheme == xfer.UrlSchemeS3 && node.FileReader.ReaderFileType == sc.ReaderFileTypeParquet {
+		// Ranged reads: only the footer and the selected column chunks of non-skipped row groups are downloaded
+		s3ReadSeeker, err := xfer.NewS3ReadSeeker(filePath)
+		if err != nil {
+			return bs, fmt.Errorf("cannot open s3 file %s: %s", filePath, err.Error())
+		}
+		defer func() {
+			logger.Info("downloaded %d of %d bytes of s3 file %s", s3ReadSeeker.BytesDownloaded, s3ReadSeeker.Size(), filePath)
+		}()
+		fileReadSeeker = s3ReadSeeker
 	} else if u.Scheme == xfer.UrlSchemeHttp || u.Scheme == xfer.UrlSchemeHttps || u.Scheme == xfer.UrlSchemeS3 {
 		var readCloser io.ReadCloser
 		switch u.Scheme {
@@ -140,7 +154,26 @@ func runReadFileForBatch(envConfig *env.EnvConfig, logger *l.CapiLogger, pCtx *c
 
 	switch node.FileReader.ReaderFileType {
 	case sc.ReaderFileTypeCsv:
-		return readCsv(envConfig, logger, pCtx, totalStartTime, filePath, fileReader)
+		decompressingReader, err := newDecompressingReader(fileReader, node.FileReader.GetCompression(filePath))
+		if err != nil {
+			return bs, fmt.Errorf("cannot read compressed file %s: %s", filePath, err.Error())
+		}
+		defer decompressingReader.Close()
+		return readCsv(envConfig, logger, pCtx, totalStartTime, filePath, decompressingReader, false)
+	case sc.ReaderFileTypeJsonl:
+		decompressingReader, err := newDecompressingReader(fileReader, node.FileReader.GetCompression(filePath))
+		if err != nil {
+			return bs, fmt.Errorf("cannot read compressed file %s: %s", filePath, err.Error())
+		}
+		defer decompressingReader.Close()
+		return readJsonl(envConfig, logger, pCtx, totalStartTime, filePath, decompressingReader)
+	case sc.ReaderFileTypeFixedWidth:
+		decompressingReader, err := newDecompressingReader(fileReader, node.FileReader.GetCompression(filePath))
+		if err != nil {
+			return bs, fmt.Errorf("cannot read compressed file %s: %s", filePath, err.Error())
+		}
+		defer decompressingReader.Close()
+		return readFixedWidth(envConfig, logger, pCtx, totalStartTime, filePath, decompressingReader)
 	case sc.ReaderFileTypeParquet:
 		return readParquet(envConfig, logger, pCtx, totalStartTime, filePath, fileReadSeeker)
 	default:
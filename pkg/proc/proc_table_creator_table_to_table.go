@@ -25,17 +25,56 @@ func runCreateTableForBatch(envConfig *env.EnvConfig,
 
 	node := pCtx.CurrentScriptNode
 
+	if !node.HasTableReader() {
+		return BatchStats{RowsRead: 0, RowsWritten: 0}, errors.New("node does not have table reader")
+	}
+
+	return createTableFromTableReader(envConfig, logger, pCtx, &node.TableReader, readerNodeRunId, startLeftToken, endLeftToken)
+}
+
+func runCreateTableForUnionBatch(envConfig *env.EnvConfig,
+	logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	readerNodeRunId int16,
+	startLeftToken int64,
+	endLeftToken int64) (BatchStats, error) {
+
+	logger.PushF("proc.runCreateTableForUnionBatch")
+	defer logger.PopF()
+
+	node := pCtx.CurrentScriptNode
+
+	if !node.HasUnionTableReader() {
+		return BatchStats{RowsRead: 0, RowsWritten: 0}, errors.New("node does not have union table reader")
+	}
+
+	// Each batch reads from one source table only
+	tableReaderIdx, err := node.UnionReader.GetTableReaderIdxByBatchIdx(int(pCtx.Msg.BatchIdx))
+	if err != nil {
+		return BatchStats{RowsRead: 0, RowsWritten: 0}, err
+	}
+
+	return createTableFromTableReader(envConfig, logger, pCtx, &node.UnionReader.Tables[tableReaderIdx], readerNodeRunId, startLeftToken, endLeftToken)
+}
+
+func createTableFromTableReader(envConfig *env.EnvConfig,
+	logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	tableReader *sc.TableReaderDef,
+	readerNodeRunId int16,
+	startLeftToken int64,
+	endLeftToken int64) (BatchStats, error) {
+
+	node := pCtx.CurrentScriptNode
+
 	// batchStartTime := time.Now()
 	totalStartTime := time.Now()
-	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: node.TableReader.TableName + cql.RunIdSuffix(readerNodeRunId), Dst: node.TableCreator.Name + cql.RunIdSuffix(readerNodeRunId)}
+	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: tableReader.TableName + cql.RunIdSuffix(readerNodeRunId), Dst: node.TableCreator.Name + cql.RunIdSuffix(readerNodeRunId)}
 
 	if readerNodeRunId == 0 {
 		return bs, errors.New("this node has a dependency node to read data from that was never started in this keyspace (readerNodeRunId == 0)")
 	}
 
-	if !node.HasTableReader() {
-		return bs, errors.New("node does not have table reader")
-	}
 	if !node.HasTableCreator() {
 		return bs, errors.New("node does not have table creator")
 	}
@@ -44,12 +83,12 @@ func runCreateTableForBatch(envConfig *env.EnvConfig,
 	srcLeftFieldRefs := sc.FieldRefs{}
 	srcLeftFieldRefs.AppendWithFilter(node.TableCreator.UsedInTargetExpressionsFields, sc.ReaderAlias)
 
-	leftBatchSize := node.TableReader.RowsetSize
+	leftBatchSize := tableReader.RowsetSize
 	// tableRecordBatchCount := 0
 	curStartLeftToken := startLeftToken
 
 	rsIn := NewRowsetFromFieldRefs(
-		sc.FieldRefs{sc.RowidFieldRef(node.TableReader.TableName)},
+		sc.FieldRefs{sc.RowidFieldRef(tableReader.TableName)},
 		sc.FieldRefs{sc.RowidTokenFieldRef()},
 		srcLeftFieldRefs)
 
@@ -65,7 +104,7 @@ func runCreateTableForBatch(envConfig *env.EnvConfig,
 		lastRetrievedLeftToken, endTokenRowIds, err := selectBatchFromTableByToken(logger,
 			pCtx,
 			rsIn,
-			node.TableReader.TableName,
+			tableReader.TableName,
 			readerNodeRunId,
 			leftBatchSize,
 			curStartLeftToken,
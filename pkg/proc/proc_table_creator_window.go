@@ -0,0 +1,256 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/cql"
+	"github.com/capillariesio/capillaries/pkg/ctx"
+	"github.com/capillariesio/capillaries/pkg/env"
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/l"
+	"github.com/capillariesio/capillaries/pkg/sc"
+)
+
+type windowPartitionRow struct {
+	rowid    int64
+	orderKey string
+	vars     eval.VarValuesMap
+}
+
+// Collects all partitions (index keys) with token(key) in the token range, keeps rowids of each partition together
+func collectPartitionRowids(logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	idxName string,
+	readerNodeRunId int16,
+	idxReadBatchSize int,
+	startToken int64,
+	endToken int64) ([][]int64, error) {
+
+	rsIdx := NewRowsetFromFieldRefs(
+		sc.FieldRefs{sc.RowidFieldRef(idxName)},
+		sc.FieldRefs{sc.IdxKeyFieldRef()})
+
+	keyToPartitionIdx := map[string]int{}
+	allPartitionRowids := make([][]int64, 0)
+	var idxPageState []byte
+	var err error
+	for {
+		idxPageState, err = selectBatchFromIdxTableByTokenPaged(logger,
+			pCtx,
+			rsIdx,
+			idxName,
+			readerNodeRunId,
+			idxReadBatchSize,
+			idxPageState,
+			startToken,
+			endToken)
+		if err != nil {
+			return nil, fmt.Errorf("cannot select batch from idx table: %s", err.Error())
+		}
+
+		for rowIdx := 0; rowIdx < rsIdx.RowCount; rowIdx++ {
+			key := *((*rsIdx.Rows[rowIdx])[rsIdx.FieldsByFieldName["key"]].(*string))
+			rowid := *((*rsIdx.Rows[rowIdx])[rsIdx.FieldsByFieldName["rowid"]].(*int64))
+			partitionIdx, ok := keyToPartitionIdx[key]
+			if !ok {
+				partitionIdx = len(allPartitionRowids)
+				keyToPartitionIdx[key] = partitionIdx
+				allPartitionRowids = append(allPartitionRowids, []int64{})
+			}
+			allPartitionRowids[partitionIdx] = append(allPartitionRowids[partitionIdx], rowid)
+		}
+
+		if len(idxPageState) == 0 {
+			break
+		}
+	}
+
+	logger.DebugCtx(pCtx, "selectBatchFromIdxTableByTokenPaged: queried tokens from %d to %d, retrieved %d partitions", startToken, endToken, len(allPartitionRowids))
+
+	return allPartitionRowids, nil
+}
+
+// Reads source rows for a set of partitions, sorts each partition by order key and writes one target row per source row
+func writeWindowPartitions(logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	readerNodeRunId int16,
+	partitionRowids [][]int64,
+	srcFieldRefs sc.FieldRefs,
+	instr *TableInserter,
+	bs *BatchStats) error {
+
+	node := pCtx.CurrentScriptNode
+
+	rowidToPartitionIdx := map[int64]int{}
+	rowidsToFind := map[int64]struct{}{}
+	for partitionIdx, rowids := range partitionRowids {
+		for _, rowid := range rowids {
+			rowidToPartitionIdx[rowid] = partitionIdx
+			rowidsToFind[rowid] = struct{}{}
+		}
+	}
+
+	rs := NewRowsetFromFieldRefs(
+		sc.FieldRefs{sc.RowidFieldRef(node.TableReader.TableName)},
+		sc.FieldRefs{sc.RowidTokenFieldRef()},
+		srcFieldRefs)
+
+	partitions := make([][]windowPartitionRow, len(partitionRowids))
+	for len(rowidsToFind) > 0 {
+		var pageState []byte
+		if _, err := selectBatchFromDataTablePaged(logger,
+			pCtx,
+			rs,
+			node.TableReader.TableName,
+			readerNodeRunId,
+			node.TableReader.RowsetSize,
+			pageState,
+			getFirstIntsFromSet(rowidsToFind, MaxAmazonKeyspacesInElements)); err != nil { // Amazon Keyspaces allows max 100 IN elements
+			return fmt.Errorf("cannot select batch from source table: %s", err.Error())
+		}
+
+		if rs.RowCount == 0 {
+			break
+		}
+
+		for rowIdx := 0; rowIdx < rs.RowCount; rowIdx++ {
+			rowid := *((*rs.Rows[rowIdx])[rs.FieldsByFieldName["rowid"]].(*int64))
+			delete(rowidsToFind, rowid)
+
+			vars := eval.VarValuesMap{}
+			if err := rs.ExportToVars(rowIdx, vars); err != nil {
+				return err
+			}
+			orderKey, err := node.Window.BuildOrderKey(vars[sc.ReaderAlias])
+			if err != nil {
+				return fmt.Errorf("cannot build order key: %s", err.Error())
+			}
+			partitionIdx := rowidToPartitionIdx[rowid]
+			partitions[partitionIdx] = append(partitions[partitionIdx], windowPartitionRow{rowid: rowid, orderKey: orderKey, vars: vars})
+		}
+	}
+
+	if len(rowidsToFind) > 0 {
+		logger.WarnCtx(pCtx, "%d source rows referenced by index %s were not found", len(rowidsToFind), node.Window.IndexName)
+	}
+
+	// Help GC
+	var indexKeyMap = map[string]string{}
+
+	for _, partition := range partitions {
+		// Rowid makes the order deterministic for rows with the same order key
+		sort.Slice(partition, func(i, j int) bool {
+			if partition[i].orderKey == partition[j].orderKey {
+				return partition[i].rowid < partition[j].rowid
+			}
+			return partition[i].orderKey < partition[j].orderKey
+		})
+
+		partitionVars := make([]eval.VarValuesMap, len(partition))
+		orderKeys := make([]string, len(partition))
+		for i := range partition {
+			partitionVars[i] = partition[i].vars
+			orderKeys[i] = partition[i].orderKey
+		}
+
+		winValues, err := node.Window.CalculateWindowValues(partitionVars, orderKeys)
+		if err != nil {
+			return err
+		}
+
+		for i := range partition {
+			partitionVars[i][sc.WindowAlias] = winValues[i]
+			tableRecord, err := node.TableCreator.CalculateTableRecordFromSrcVars(partitionVars[i])
+			if err != nil {
+				return fmt.Errorf("cannot populate table record from [%v]: [%s]", partitionVars[i], err.Error())
+			}
+			if err = checkHavingAddRecordAndSaveBatchIfNeeded(logger, node, tableRecord, indexKeyMap, instr); err != nil {
+				return err
+			}
+			bs.RowsWritten++
+		}
+		bs.RowsRead += len(partition)
+	}
+
+	return nil
+}
+
+// Window batch handles all partitions (index keys) with token(key) in the batch token range
+func runCreateTableWindowForBatch(envConfig *env.EnvConfig,
+	logger *l.CapiLogger,
+	pCtx *ctx.MessageProcessingContext,
+	readerNodeRunId int16,
+	startToken int64,
+	endToken int64) (BatchStats, error) {
+
+	logger.PushF("proc.runCreateTableWindowForBatch")
+	defer logger.PopF()
+
+	node := pCtx.CurrentScriptNode
+
+	totalStartTime := time.Now()
+
+	bs := BatchStats{RowsRead: 0, RowsWritten: 0, Src: node.TableReader.TableName + cql.RunIdSuffix(readerNodeRunId), Dst: node.TableCreator.Name + cql.RunIdSuffix(readerNodeRunId)}
+
+	if readerNodeRunId == 0 {
+		return bs, errors.New("this node has a dependency node to read data from that was never started in this keyspace (readerNodeRunId == 0)")
+	}
+
+	if !node.HasTableReader() {
+		return bs, errors.New("node does not have table reader")
+	}
+	if !node.HasTableCreator() {
+		return bs, errors.New("node does not have table creator")
+	}
+	if !node.HasWindow() {
+		return bs, errors.New("node does not have window")
+	}
+
+	// Fields to read from source table
+	srcFieldRefs := sc.FieldRefs{}
+	srcFieldRefs.AppendWithFilter(node.TableCreator.UsedInTargetExpressionsFields, sc.ReaderAlias)
+	srcFieldRefs.Append(node.Window.UsedInFunctionsFields)
+	srcFieldRefs.Append(node.Window.GetOrderFieldRefs())
+
+	allPartitionRowids, err := collectPartitionRowids(logger, pCtx, node.Window.IndexName, readerNodeRunId, node.TableReader.RowsetSize, startToken, endToken)
+	if err != nil {
+		return bs, fmt.Errorf("cannot collect partitions, node %s: %s", node.Name, err.Error())
+	}
+
+	instr, err := createInserterAndStartWorkers(logger, envConfig, pCtx, &node.TableCreator, DataIdxSeqModeDataFirst, logger.ZapMachine.String)
+	if err != nil {
+		return bs, err
+	}
+	instr.startDrainer()
+	defer instr.closeInserter(logger, pCtx)
+
+	// Process partitions in chunks of roughly RowsetSize rows, a partition is never split between chunks
+	chunkStartIdx := 0
+	chunkRowCount := 0
+	for partitionIdx := 0; partitionIdx < len(allPartitionRowids); partitionIdx++ {
+		chunkRowCount += len(allPartitionRowids[partitionIdx])
+		if chunkRowCount < node.TableReader.RowsetSize && partitionIdx < len(allPartitionRowids)-1 {
+			continue
+		}
+		if err := writeWindowPartitions(logger, pCtx, readerNodeRunId, allPartitionRowids[chunkStartIdx:partitionIdx+1], srcFieldRefs, instr, &bs); err != nil {
+			instr.cancelDrainer(fmt.Errorf("cannot write window partitions, node %s: %s", node.Name, err.Error()))
+			return bs, instr.waitForDrainer()
+		}
+		chunkStartIdx = partitionIdx + 1
+		chunkRowCount = 0
+		instr.PCtx.SendHeartbeat()
+	}
+
+	instr.doneSending()
+	if err := instr.waitForDrainer(); err != nil {
+		return bs, err
+	}
+
+	bs.UpdateElapsedStats(time.Since(totalStartTime), instr)
+	reportWriteTableComplete(logger, pCtx, bs.RowsRead, bs.RowsWritten, bs.Elapsed, len(node.TableCreator.Indexes), instr.NumWorkers)
+
+	return bs, nil
+}
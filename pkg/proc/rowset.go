@@ -38,7 +38,9 @@ func (rs *Rowset) ToString() string {
 	for rowIdx := 0; rowIdx < rs.RowCount; rowIdx++ {
 		vals := rs.Rows[rowIdx]
 		for _, val := range *vals {
-			switch typedVal := val.(type) {
+			switch typedVal := derefNullableValuePtr(val).(type) {
+			case nil:
+				fmt.Fprintf(&b, "%30s", "null")
 			case *int64:
 				fmt.Fprintf(&b, "%30d", *typedVal)
 			case *float64:
@@ -133,6 +135,59 @@ func (rs *Rowset) AppendFieldRefsWithFilter(fieldRefs *sc.FieldRefs, tableFilter
 	}
 }
 
+func newNullableValuePtr(fieldType evalcapi.TableFieldType) (any, error) {
+	switch evalcapi.FieldTypeKind(fieldType) {
+	case evalcapi.FieldTypeInt:
+		return new(*int64), nil
+	case evalcapi.FieldTypeFloat:
+		return new(*float64), nil
+	case evalcapi.FieldTypeString:
+		return new(*string), nil
+	case evalcapi.FieldTypeDecimal2:
+		return new(*inf.Dec), nil
+	case evalcapi.FieldTypeBool:
+		return new(*bool), nil
+	case evalcapi.FieldTypeDateTime:
+		return new(*time.Time), nil
+	default:
+		return nil, fmt.Errorf("unsupported nullable field type %s", fieldType)
+	}
+}
+
+// Returns the inner pointer of a nullable value, nil if the value is null.
+// Non-nullable value pointers are returned as is.
+func derefNullableValuePtr(valuePtr any) any {
+	switch typedPtr := valuePtr.(type) {
+	case **int64:
+		if *typedPtr != nil {
+			return *typedPtr
+		}
+	case **float64:
+		if *typedPtr != nil {
+			return *typedPtr
+		}
+	case **string:
+		if *typedPtr != nil {
+			return *typedPtr
+		}
+	case **inf.Dec:
+		if *typedPtr != nil {
+			return *typedPtr
+		}
+	case **bool:
+		if *typedPtr != nil {
+			return *typedPtr
+		}
+	case **time.Time:
+		if *typedPtr != nil {
+			return *typedPtr
+		}
+	default:
+		return valuePtr
+	}
+	return nil
+}
+
 func (rs *Rowset) InitRows(capacity int) error {
 	if rs.Rows == nil || len(rs.Rows) != capacity {
 		rs.Rows = make([](*[]any), capacity)
@@ -141,7 +196,16 @@ func (rs *Rowset) InitRows(capacity int) error {
 		newRow := make([]any, len(rs.Fields))
 		rs.Rows[rowIdx] = &newRow
 		for colIdx := 0; colIdx < len(rs.Fields); colIdx++ {
-			switch rs.Fields[colIdx].FieldType {
+			if rs.Fields[colIdx].Nullable {
+				// gocql sets the inner pointer to nil when the value is null
+				valuePtr, err := newNullableValuePtr(rs.Fields[colIdx].FieldType)
+				if err != nil {
+					return fmt.Errorf("InitRows %s, field %s.%s", err.Error(), rs.Fields[colIdx].TableName, rs.Fields[colIdx].FieldName)
+				}
+				(*rs.Rows[rowIdx])[colIdx] = valuePtr
+				continue
+			}
+			switch evalcapi.FieldTypeKind(rs.Fields[colIdx].FieldType) {
 			case evalcapi.FieldTypeInt:
 				v := int64(0)
 				(*rs.Rows[rowIdx])[colIdx] = &v
@@ -175,8 +239,10 @@ func (rs *Rowset) GetTableRecord(rowIdx int) (map[string]any, error) {
 	tableRecord := map[string]any{}
 	for colIdx := 0; colIdx < len(rs.Fields); colIdx++ {
 		fName := rs.Fields[colIdx].FieldName
-		valuePtr := (*rs.Rows[rowIdx])[rs.FieldsByFieldName[fName]]
+		valuePtr := derefNullableValuePtr((*rs.Rows[rowIdx])[rs.FieldsByFieldName[fName]])
 		switch assertedValuePtr := valuePtr.(type) {
+		case nil:
+			tableRecord[fName] = nil
 		case *int64:
 			tableRecord[fName] = *assertedValuePtr
 		case *string:
@@ -214,8 +280,10 @@ func (rs *Rowset) ExportToVarsWithAlias(rowIdx int, vars eval.VarValuesMap, useT
 		if !ok {
 			vars[*tName] = map[string]any{}
 		}
-		valuePtr := (*rs.Rows[rowIdx])[colIdx]
+		valuePtr := derefNullableValuePtr((*rs.Rows[rowIdx])[colIdx])
 		switch assertedValuePtr := valuePtr.(type) {
+		case nil:
+			vars[*tName][*fName] = nil
 		case *int64:
 			vars[*tName][*fName] = *assertedValuePtr
 		case *string:
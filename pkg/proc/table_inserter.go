@@ -17,6 +17,7 @@ import (
 	"github.com/capillariesio/capillaries/pkg/evalcapi"
 	"github.com/capillariesio/capillaries/pkg/l"
 	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/shopspring/decimal"
 
 	_ "unsafe" // nanotime()
 )
@@ -95,6 +96,16 @@ type TableRecordItem struct {
 	Value     any
 }
 
+// Query builder rounds decimal values to decimal2, decimal(p,s) fields need their own scale
+func (instr *TableInserter) fieldValueToCqlParam(fieldName string, value any) any {
+	if decValue, ok := value.(decimal.Decimal); ok {
+		if fieldDef, ok := instr.TableCreator.Fields[fieldName]; ok {
+			return cql.DecimalToCqlParam(decValue, evalcapi.DecimalFieldTypeScale(fieldDef.Type))
+		}
+	}
+	return value
+}
+
 func buildTableRecordItems(tr TableRecord) []TableRecordItem {
 	result := make([]TableRecordItem, len(tr))
 	i := 0
@@ -211,6 +222,11 @@ func CreateIdxTableCql(keyspace string, runId int16, idxName string, idxDef *sc.
 	if idxDef.Uniqueness == sc.IdxUnique {
 		// Key must be unique, let Cassandra enforce it for us: PRIMARY KEY (key)
 		qb.PartitionKey("key")
+	} else if idxDef.Uniqueness == sc.IdxAsOf {
+		// Rows with the same key are sorted by the datetime component, so as-of lookups can read the nearest one: PRIMARY KEY (key, as_of_key, rowid)
+		qb.ColumnDef("as_of_key", evalcapi.FieldTypeString)
+		qb.PartitionKey("key")
+		qb.ClusteringKey("as_of_key", "rowid")
 	} else {
 		// There can be multiple rowids with the same key:  PRIMARY KEY (key, rowid)
 		qb.PartitionKey("key")
@@ -351,11 +367,16 @@ func newDataQueryBuilder(keyspace string, tableRecordItems []TableRecordItem, ba
 	return dataQb, nil
 }
 
-func newIdxQueryBuilder(keyspace string) (*cql.QueryBuilder, error) {
+func newIdxQueryBuilder(keyspace string, idxUniqueness sc.IdxUniqueness) (*cql.QueryBuilder, error) {
 	idxQb := cql.NewQB().Keyspace(keyspace)
 	if err := idxQb.WritePreparedColumn("key"); err != nil {
 		return nil, err
 	}
+	if idxUniqueness == sc.IdxAsOf {
+		if err := idxQb.WritePreparedColumn("as_of_key"); err != nil {
+			return nil, err
+		}
+	}
 	if err := idxQb.WritePreparedColumn("rowid"); err != nil {
 		return nil, err
 	}
@@ -417,7 +438,7 @@ func (instr *TableInserter) insertDataRecordWithRowid(logger *l.CapiLogger, tabl
 
 	// field1=123, field2=456
 	for _, tri := range tableRecordItems {
-		if err := pq.Qb.WritePreparedValue(tri.FieldName, tri.Value); err != nil {
+		if err := pq.Qb.WritePreparedValue(tri.FieldName, instr.fieldValueToCqlParam(tri.FieldName, tri.Value)); err != nil {
 			return fmt.Errorf("cannot write prepared value for %s: %s", tri.FieldName, err.Error())
 		}
 	}
@@ -570,10 +591,10 @@ func (instr *TableInserter) insertIdxRecordWithRowid(logger *l.CapiLogger, idxNa
 		ifNotExistsFlag = cql.IfNotExistsLwt
 	}
 
-	// Prepare generic idx insert query, do this once for all indexes and rows
+	// Prepare generic idx insert query, do this once for all indexes and rows (as_of indexes have their own pq)
 	if pq.Qb == nil {
-		// key=?, rowid=?
-		pq.Qb, err = newIdxQueryBuilder(instr.PCtx.Msg.DataKeyspace)
+		// key=?, rowid=? or key=?, as_of_key=?, rowid=?
+		pq.Qb, err = newIdxQueryBuilder(instr.PCtx.Msg.DataKeyspace, idxUniqueness)
 		if err != nil {
 			return fmt.Errorf("cannot prepare idx builder: %s", err.Error())
 		}
@@ -587,6 +608,13 @@ func (instr *TableInserter) insertIdxRecordWithRowid(logger *l.CapiLogger, idxNa
 	}
 
 	// Provide parameters
+	if idxUniqueness == sc.IdxAsOf {
+		var asOfKey string
+		idxKey, asOfKey = sc.SplitAsOfIdxKey(idxKey)
+		if err := pq.Qb.WritePreparedValue("as_of_key", asOfKey); err != nil {
+			return err
+		}
+	}
 	if err := pq.Qb.WritePreparedValue("key", idxKey); err != nil {
 		return err
 	}
@@ -762,7 +790,7 @@ func (instr *TableInserter) insertDistinctIdxAndDataRecords(logger *l.CapiLogger
 	return curRowid, errorToReport
 }
 
-func (instr *TableInserter) insertIdxRecordsForIndexes(logger *l.CapiLogger, writeItem *WriteChannelItem, idxNameToSkip string, newRowid int64, piq *PreparedQuery) error {
+func (instr *TableInserter) insertIdxRecordsForIndexes(logger *l.CapiLogger, writeItem *WriteChannelItem, idxNameToSkip string, newRowid int64, piq *PreparedQuery, paiq *PreparedQuery) error {
 	for _, ikmi := range writeItem.IndexKeyItems {
 		if idxNameToSkip != "" && ikmi.IdxName == idxNameToSkip {
 			continue
@@ -771,7 +799,11 @@ func (instr *TableInserter) insertIdxRecordsForIndexes(logger *l.CapiLogger, wri
 		if !ok {
 			return fmt.Errorf("dev error, inserter cannot find index %s in %v", ikmi.IdxName, instr.TableCreator.Indexes)
 		}
-		if err := instr.insertIdxRecordWithRowid(logger, ikmi.IdxName, idxDef.Uniqueness, ikmi.KeyValue, newRowid, piq); err != nil {
+		pq := piq
+		if idxDef.Uniqueness == sc.IdxAsOf {
+			pq = paiq
+		}
+		if err := instr.insertIdxRecordWithRowid(logger, ikmi.IdxName, idxDef.Uniqueness, ikmi.KeyValue, newRowid, pq); err != nil {
 			return fmt.Errorf("cannot insert idx record to %s: %s", ikmi.IdxName, err.Error())
 		}
 	}
@@ -789,6 +821,7 @@ func (instr *TableInserter) tableInserterWorker(logger *l.CapiLogger, pCtx *ctx.
 
 	pdq := PreparedQuery{}
 	piq := PreparedQuery{}
+	paiq := PreparedQuery{}
 
 	logger.DebugCtx(pCtx, "started reading from RecordsIn")
 
@@ -804,7 +837,7 @@ func (instr *TableInserter) tableInserterWorker(logger *l.CapiLogger, pCtx *ctx.
 			newRowid, errorToReport = instr.insertDataRecord(logger, writeItem.TableRecordItems, &pdq, rowidRand)
 			if errorToReport == nil {
 				// Index tables
-				err := instr.insertIdxRecordsForIndexes(logger, &writeItem, "", newRowid, &piq)
+				err := instr.insertIdxRecordsForIndexes(logger, &writeItem, "", newRowid, &piq, &paiq)
 				if err != nil {
 					errorToReport = fmt.Errorf("cannot insert index records for DataFirst: %s", err.Error())
 				}
@@ -823,7 +856,7 @@ func (instr *TableInserter) tableInserterWorker(logger *l.CapiLogger, pCtx *ctx.
 					newRowid, errorToReport = instr.insertDistinctIdxAndDataRecords(logger, pCtx, writeItem.TableRecordItems, distinctIdxName, distinctIdxKeyVal, &pdq, &piq, rowidRand)
 					if errorToReport == nil {
 						// Create records for other indexes if any (they all must be non-unique)
-						err := instr.insertIdxRecordsForIndexes(logger, &writeItem, distinctIdxName, newRowid, &piq)
+						err := instr.insertIdxRecordsForIndexes(logger, &writeItem, distinctIdxName, newRowid, &piq, &paiq)
 						if err != nil {
 							errorToReport = fmt.Errorf("cannot insert index records for IdxFirst: %s", err.Error())
 						}
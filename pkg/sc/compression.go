@@ -0,0 +1,53 @@
+package sc
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type CompressionType string
+
+const (
+	CompressionAuto  CompressionType = ""     // Detect by file extension
+	CompressionNone  CompressionType = "none" // Explicitly uncompressed, whatever the extension
+	CompressionGzip  CompressionType = "gzip"
+	CompressionZstd  CompressionType = "zstd"
+	CompressionBzip2 CompressionType = "bz2"
+)
+
+func checkCompression(compression CompressionType) error {
+	switch compression {
+	case CompressionAuto, CompressionNone, CompressionGzip, CompressionZstd, CompressionBzip2:
+		return nil
+	default:
+		return fmt.Errorf("invalid compression %s, expected one of: %s, %s, %s, %s", compression, CompressionNone, CompressionGzip, CompressionZstd, CompressionBzip2)
+	}
+}
+
+// Ignores http query, so https://host/orders.csv.gz?v=1 is still gzip
+func compressionFromUrl(fileUrl string) CompressionType {
+	filePath := fileUrl
+	if u, err := url.Parse(fileUrl); err == nil && len(u.Scheme) > 0 {
+		filePath = u.Path
+	}
+	filePath = strings.ToLower(filePath)
+	switch {
+	case strings.HasSuffix(filePath, ".gz") || strings.HasSuffix(filePath, ".gzip"):
+		return CompressionGzip
+	case strings.HasSuffix(filePath, ".zst") || strings.HasSuffix(filePath, ".zstd"):
+		return CompressionZstd
+	case strings.HasSuffix(filePath, ".bz2"):
+		return CompressionBzip2
+	default:
+		return CompressionNone
+	}
+}
+
+// Explicit setting wins, otherwise detect by file extension
+func resolveCompression(compression CompressionType, fileUrl string) CompressionType {
+	if compression != CompressionAuto {
+		return compression
+	}
+	return compressionFromUrl(fileUrl)
+}
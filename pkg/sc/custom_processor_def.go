@@ -13,3 +13,10 @@ type CustomProcessorDef interface {
 	GetFieldRefs() *FieldRefs
 	GetUsedInTargetExpressionsFields() *FieldRefs
 }
+
+// Custom processors that need all source rows with the same key in one rowset (like window node partitions)
+// implement this: rows are read through the returned non-unique index of the source table
+type PartitionedCustomProcessorDef interface {
+	GetPartitionIndexName() string
+	CheckPartitionIndex(idxDef *IdxDef) error
+}
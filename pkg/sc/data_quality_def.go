@@ -0,0 +1,149 @@
+package sc
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strings"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+)
+
+const DataQualityAlias string = "dq"
+const DataQualityRuleFieldName string = "rule"
+
+// Data quality rule: either a boolean expression that must be true for a valid row,
+// or a non-unique index of the source table whose keys must not be shared by multiple rows
+type DataQualityRuleDef struct {
+	RawExpression    string    `json:"expression,omitempty" yaml:"expression,omitempty"`
+	UniqueIdxName    string    `json:"unique_idx,omitempty" yaml:"unique_idx,omitempty"`
+	MaxViolations    int64     `json:"max_violations" yaml:"max_violations"` // Per batch, exceeding it fails the batch
+	ParsedExpression ast.Expr  `json:"-"`
+	UsedFields       FieldRefs `json:"-"`
+	UniqueIdxDef     *IdxDef   `json:"-"`
+}
+
+func (ruleDef *DataQualityRuleDef) IsUniqueness() bool {
+	return len(ruleDef.UniqueIdxName) > 0
+}
+
+// Data quality node: evaluates named rules against each source row,
+// violating rows are written to the quarantine table, one row per violated rule
+type DataQualityDef struct {
+	Rules             map[string]*DataQualityRuleDef `json:"rules" yaml:"rules"`
+	RuleNames         []string                       `json:"-"` // Sorted, so violations are always checked and reported in the same order
+	UsedInRulesFields FieldRefs                      `json:"-"`
+}
+
+func (dqDef *DataQualityDef) parseRules() error {
+	if len(dqDef.Rules) == 0 {
+		return errors.New("data quality node must have at least one rule")
+	}
+
+	foundErrors := make([]string, 0)
+	dqDef.RuleNames = make([]string, 0, len(dqDef.Rules))
+	for ruleName, ruleDef := range dqDef.Rules {
+		dqDef.RuleNames = append(dqDef.RuleNames, ruleName)
+		if ruleDef.MaxViolations < 0 {
+			foundErrors = append(foundErrors, fmt.Sprintf("data quality rule %s max_violations cannot be negative, %d specified", ruleName, ruleDef.MaxViolations))
+		}
+		hasExpression := len(strings.TrimSpace(ruleDef.RawExpression)) > 0
+		if hasExpression == ruleDef.IsUniqueness() {
+			foundErrors = append(foundErrors, fmt.Sprintf("data quality rule %s must have either expression or unique_idx", ruleName))
+			continue
+		}
+		if !hasExpression {
+			continue
+		}
+		var err error
+		ruleDef.UsedFields = FieldRefs{}
+		if ruleDef.ParsedExpression, err = ParseRawGolangExpressionStringAndHarvestFieldRefs(ruleDef.RawExpression, &ruleDef.UsedFields); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot parse data quality rule %s expression [%s]: [%s]", ruleName, ruleDef.RawExpression, err.Error()))
+			continue
+		}
+		v := AggFinderVisitor{}
+		ast.Walk(&v, ruleDef.ParsedExpression)
+		if v.Error != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot use agg functions in data quality rule %s expression [%s]: [%s]", ruleName, ruleDef.RawExpression, v.Error.Error()))
+		}
+	}
+	sort.Strings(dqDef.RuleNames)
+
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
+
+// Called when source table fields and uniqueness indexes are known
+func (dqDef *DataQualityDef) resolve(srcFieldRefs *FieldRefs, idxDefMap IdxDefMap) error {
+	foundErrors := make([]string, 0)
+	dqDef.UsedInRulesFields = FieldRefs{}
+	for _, ruleName := range dqDef.RuleNames {
+		ruleDef := dqDef.Rules[ruleName]
+		if ruleDef.IsUniqueness() {
+			idxDef, ok := idxDefMap[ruleDef.UniqueIdxName]
+			if !ok {
+				foundErrors = append(foundErrors, fmt.Sprintf("data quality rule %s index [%s] is not an index of the source table", ruleName, ruleDef.UniqueIdxName))
+				continue
+			}
+			if idxDef.Uniqueness != IdxNonUnique {
+				foundErrors = append(foundErrors, fmt.Sprintf("data quality rule %s index [%s] must be non-unique, a unique index does not allow duplicates in the first place", ruleName, ruleDef.UniqueIdxName))
+				continue
+			}
+			ruleDef.UniqueIdxDef = idxDef
+			dqDef.UsedInRulesFields.Append(idxDef.getComponentFieldRefs(ReaderAlias))
+			continue
+		}
+
+		// Only reader fields are allowed in rule expressions
+		if err := checkAllowed(&ruleDef.UsedFields, nil, srcFieldRefs); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("invalid field(s) in data quality rule %s expression [%s]: [%s]", ruleName, ruleDef.RawExpression, err.Error()))
+			continue
+		}
+		// Field types are known now
+		dqDef.UsedInRulesFields.Append(ruleDef.UsedFields)
+		if err := evalExpressionWithFieldRefsAndCheckType(ruleDef.ParsedExpression, ruleDef.UsedFields, evalcapi.FieldTypeBool); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot evaluate data quality rule %s expression [%s]: [%s]", ruleName, ruleDef.RawExpression, err.Error()))
+		}
+	}
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
+
+// Quarantine table fields can use dq.rule, the name of the violated rule
+func (dqDef *DataQualityDef) GetFieldRefs() *FieldRefs {
+	return &FieldRefs{{TableName: DataQualityAlias, FieldName: DataQualityRuleFieldName, FieldType: evalcapi.FieldTypeString}}
+}
+
+func (dqDef *DataQualityDef) UsesIdx(idxName string) bool {
+	for _, ruleDef := range dqDef.Rules {
+		if ruleDef.UniqueIdxName == idxName {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the row satisfies the expression rule
+func (ruleDef *DataQualityRuleDef) CheckExpression(vars eval.VarValuesMap) (bool, error) {
+	eCtx := eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, vars)
+	eCtx.SetRoundDec(ruleDef.UsedFields.MaxDecimalScale())
+	valVolatile, err := eCtx.Eval(ruleDef.ParsedExpression)
+	if err != nil {
+		return false, fmt.Errorf("cannot evaluate data quality rule expression [%s]: [%s]", ruleDef.RawExpression, err.Error())
+	}
+	if valVolatile == nil {
+		// Null result means the rule cannot be checked, report the row
+		return false, nil
+	}
+	valBool, ok := valVolatile.(bool)
+	if !ok {
+		return false, fmt.Errorf("cannot get bool when evaluating data quality rule expression [%s], got %v(%T) instead", ruleDef.RawExpression, valVolatile, valVolatile)
+	}
+	return valBool, nil
+}
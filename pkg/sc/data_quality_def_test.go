@@ -0,0 +1,182 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const dataQualityScriptJson string = `
+{
+	"nodes": {
+		"read_orders": {
+			"type": "file_table",
+			"r": {
+				"urls": ["orders.csv"],
+				"csv":{
+					"first_data_line_idx": 0
+				},
+				"columns": {
+					"col_order_id": {
+						"csv":{
+							"col_idx": 0
+						},
+						"col_type": "string"
+					},
+					"col_email": {
+						"csv":{
+							"col_idx": 1
+						},
+						"col_type": "string"
+					},
+					"col_amount": {
+						"csv":{
+							"col_idx": 2,
+							"col_format": "%f"
+						},
+						"col_type": "decimal2"
+					}
+				}
+			},
+			"w": {
+				"name": "orders",
+				"fields": {
+					"order_id": {
+						"expression": "r.col_order_id",
+						"type": "string"
+					},
+					"email": {
+						"expression": "r.col_email",
+						"type": "string"
+					},
+					"amount": {
+						"expression": "r.col_amount",
+						"type": "decimal2"
+					}
+				},
+				"indexes": {
+					"idx_orders_order_id": "non_unique(order_id)"
+				}
+			}
+		},
+		"check_orders": {
+			"type": "table_dq_table",
+			"r": {
+				"table": "orders"
+			},
+			"dq": {
+				"rules": {
+					"order_id_not_empty": {"expression": "r.order_id != \"\""},
+					"amount_in_range": {"expression": "r.amount > 0 && r.amount < 1000", "max_violations": 10},
+					"email_format": {"expression": "re.MatchString(\"^[^@]+@[^@]+$\", r.email)", "max_violations": 5},
+					"order_id_unique": {"unique_idx": "idx_orders_order_id"}
+				}
+			},
+			"w": {
+				"name": "orders_quarantine",
+				"fields": {
+					"order_id": {
+						"expression": "r.order_id",
+						"type": "string"
+					},
+					"rule": {
+						"expression": "dq.rule",
+						"type": "string"
+					}
+				}
+			}
+		}
+	},
+	"dependency_policies": {
+		"current_active_first_stopped_nogo":` + DefaultPolicyCheckerConfJson +
+	`
+	}
+}`
+
+func TestDataQuality(t *testing.T) {
+	scriptDef := &ScriptDef{}
+	assert.Nil(t, scriptDef.Deserialize([]byte(dataQualityScriptJson), ScriptJson, nil, nil, "", nil))
+
+	node := scriptDef.ScriptNodes["check_orders"]
+	assert.True(t, node.HasDataQuality())
+	assert.True(t, node.HasTableReader())
+	assert.True(t, node.HasTableCreator())
+	assert.Equal(t, []string{"amount_in_range", "email_format", "order_id_not_empty", "order_id_unique"}, node.DataQuality.RuleNames)
+	assert.True(t, node.DataQuality.Rules["order_id_unique"].IsUniqueness())
+	assert.Equal(t, "order_id", node.DataQuality.Rules["order_id_unique"].UniqueIdxDef.Components[0].FieldName)
+	assert.Equal(t, int64(10), node.DataQuality.Rules["amount_in_range"].MaxViolations)
+	assert.Equal(t, int64(0), node.DataQuality.Rules["order_id_not_empty"].MaxViolations)
+	assert.Equal(t, 3, len(node.DataQuality.UsedInRulesFields))
+
+	vars := eval.VarValuesMap{ReaderAlias: {"order_id": "o1", "email": "a@b.com", "amount": decimal.RequireFromString("12.5")}}
+	for _, ruleName := range []string{"amount_in_range", "email_format", "order_id_not_empty"} {
+		isValid, err := node.DataQuality.Rules[ruleName].CheckExpression(vars)
+		assert.Nil(t, err)
+		assert.True(t, isValid, ruleName)
+	}
+
+	vars = eval.VarValuesMap{ReaderAlias: {"order_id": "", "email": "a.b.com", "amount": decimal.RequireFromString("-1")}}
+	for _, ruleName := range []string{"amount_in_range", "email_format", "order_id_not_empty"} {
+		isValid, err := node.DataQuality.Rules[ruleName].CheckExpression(vars)
+		assert.Nil(t, err)
+		assert.False(t, isValid, ruleName)
+	}
+}
+
+func TestDataQualityBadDefs(t *testing.T) {
+	scriptDef := &ScriptDef{}
+
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(dataQualityScriptJson, `{"unique_idx": "idx_orders_order_id"}`, `{"unique_idx": "idx_orders_order_id", "expression": "true"}`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"data quality rule order_id_unique must have either expression or unique_idx")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(dataQualityScriptJson, `"max_violations": 10`, `"max_violations": -1`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"data quality rule amount_in_range max_violations cannot be negative, -1 specified")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(dataQualityScriptJson, `"r.order_id != \"\""`, `"count() > 0"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"cannot use agg functions in data quality rule order_id_not_empty expression [count() > 0]: [found aggregate function count()]")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(dataQualityScriptJson, `"r.order_id != \"\""`, `"r.amount < percentile(r.amount, 0.99)"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"[found aggregate function percentile()]")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(dataQualityScriptJson, `"r.order_id != \"\""`, `"r.order_id"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"cannot evaluate data quality rule order_id_not_empty expression [r.order_id]")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(dataQualityScriptJson, `"r.order_id != \"\""`, `"r.bad_field != \"\""`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"invalid field(s) in data quality rule order_id_not_empty expression [r.bad_field != \"\"]: [unknown field r.bad_field]")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(dataQualityScriptJson, `"non_unique(order_id)"`, `"unique(order_id)"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"data quality rule order_id_unique index [idx_orders_order_id] must be non-unique, a unique index does not allow duplicates in the first place")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(dataQualityScriptJson, `{"unique_idx": "idx_orders_order_id"}`, `{"unique_idx": "idx_bad"}`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"data quality rule order_id_unique index [idx_bad] is not an index of the source table")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(dataQualityScriptJson, `"expression": "dq.rule"`, `"expression": "dq.bad_field"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"unknown field dq.bad_field")
+}
+
+func TestDataQualityDecimalScale(t *testing.T) {
+	script := strings.ReplaceAll(dataQualityScriptJson, `"decimal2"`, `"decimal(18,6)"`)
+	script = strings.Replace(script, `"r.amount > 0 && r.amount < 1000"`, `"r.amount * r.amount > 0.0005"`, 1)
+	scriptDef := &ScriptDef{}
+	assert.Nil(t, scriptDef.Deserialize([]byte(script), ScriptJson, nil, nil, "", nil))
+
+	ruleDef := scriptDef.ScriptNodes["check_orders"].DataQuality.Rules["amount_in_range"]
+	assert.Equal(t, int32(6), ruleDef.UsedFields.MaxDecimalScale())
+
+	// 0.03*0.03=0.0009 would be 0.00 if rounded to decimal2 scale
+	vars := eval.VarValuesMap{ReaderAlias: {"order_id": "o1", "email": "a@b.com", "amount": decimal.RequireFromString("0.03")}}
+	isValid, err := ruleDef.CheckExpression(vars)
+	assert.Nil(t, err)
+	assert.True(t, isValid)
+
+	vars = eval.VarValuesMap{ReaderAlias: {"order_id": "o1", "email": "a@b.com", "amount": decimal.RequireFromString("0.02")}}
+	isValid, err = ruleDef.CheckExpression(vars)
+	assert.Nil(t, err)
+	assert.False(t, isValid)
+
+	assert.Equal(t, int32(2), scriptDef.ScriptNodes["check_orders"].DataQuality.Rules["order_id_not_empty"].UsedFields.MaxDecimalScale())
+}
@@ -17,6 +17,7 @@ type FieldRef struct {
 	TableName string
 	FieldName string
 	FieldType evalcapi.TableFieldType
+	Nullable  bool // Rowset keeps nullable values as pointers to pointers, see Rowset.InitRows()
 }
 
 func (fr *FieldRef) GetAliasHash() string {
@@ -143,6 +144,10 @@ func evalExpressionWithFieldRefsAndCheckType(exp ast.Expr, fieldRefs FieldRefs,
 		return nil
 	}
 
+	if err := checkFunctionSignatures(exp, fieldRefs); err != nil {
+		return err
+	}
+
 	deltaInt := int64(0)
 	deltaFloat := float64(0)
 	deltaDecimal := decimal.NewFromFloat(0)
@@ -156,7 +161,7 @@ func evalExpressionWithFieldRefsAndCheckType(exp ast.Expr, fieldRefs FieldRefs,
 			if _, ok := varValuesMap[tName]; !ok {
 				varValuesMap[tName] = map[string]any{}
 			}
-			switch fType {
+			switch evalcapi.FieldTypeKind(fType) {
 			case evalcapi.FieldTypeInt:
 				varValuesMap[tName][fName] = int64(0) + deltaInt
 			case evalcapi.FieldTypeFloat:
@@ -183,7 +188,7 @@ func evalExpressionWithFieldRefsAndCheckType(exp ast.Expr, fieldRefs FieldRefs,
 			if err != nil {
 				return err
 			}
-			eCtx.SetRoundDec(2) // decimal2
+			eCtx.SetRoundDec(evalcapi.DecimalFieldTypeScale(expectedType))
 		} else {
 			eCtx = eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, varValuesMap)
 		}
@@ -216,6 +221,23 @@ func (fieldRefs *FieldRefs) FindByFieldName(fieldName string) (*FieldRef, bool)
 	return nil, false
 }
 
+// Largest scale among decimal2 and decimal(p,s) fields, for expressions that do not produce a field value
+// of their own (boolean rules, keys). Returns 2 (decimal2) when no decimal fields are used.
+func (fieldRefs *FieldRefs) MaxDecimalScale() int32 {
+	maxScale := int32(-1)
+	for i := 0; i < len(*fieldRefs); i++ {
+		if evalcapi.IsDecimalFieldType((*fieldRefs)[i].FieldType) {
+			if scale := evalcapi.DecimalFieldTypeScale((*fieldRefs)[i].FieldType); scale > maxScale {
+				maxScale = scale
+			}
+		}
+	}
+	if maxScale < 0 {
+		return 2
+	}
+	return maxScale
+}
+
 func checkAllowed(fieldRefsToCheck *FieldRefs, prohibitedFieldRefs *FieldRefs, allowedFieldRefs *FieldRefs) error {
 	if fieldRefsToCheck == nil {
 		return nil
@@ -258,6 +280,7 @@ func checkAllowed(fieldRefsToCheck *FieldRefs, prohibitedFieldRefs *FieldRefs, a
 		} else {
 			// Update check field type, we will use it later for test eval
 			(*fieldRefsToCheck)[i].FieldType = allowedHashes[hash].FieldType
+			(*fieldRefsToCheck)[i].Nullable = allowedHashes[hash].Nullable
 		}
 	}
 
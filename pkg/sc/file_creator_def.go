@@ -15,6 +15,7 @@ const (
 	CreatorFileTypeUnknown int = 0
 	CreatorFileTypeCsv     int = 1
 	CreatorFileTypeParquet int = 2
+	CreatorFileTypeJsonl   int = 3
 )
 
 type ParquetCodecType string
@@ -22,6 +23,7 @@ type ParquetCodecType string
 const (
 	ParquetCodecGzip         ParquetCodecType = "gzip"
 	ParquetCodecSnappy       ParquetCodecType = "snappy"
+	ParquetCodecZstd         ParquetCodecType = "zstd"
 	ParquetCodecUncompressed ParquetCodecType = "uncompressed"
 )
 
@@ -32,14 +34,25 @@ type WriteCsvColumnSettings struct {
 
 type WriteParquetColumnSettings struct {
 	ColumnName string `json:"column_name" yaml:"column_name"`
+	Dictionary *bool  `json:"dictionary,omitempty" yaml:"dictionary,omitempty"` // Overrides creator parquet.dictionary
+	Statistics *bool  `json:"statistics,omitempty" yaml:"statistics,omitempty"` // Overrides creator parquet.statistics
 }
 
+type WriteJsonlColumnSettings struct {
+	Key    string `json:"key" yaml:"key"`
+	Format string `json:"format,omitempty" yaml:"format,omitempty"` // Datetime only, RFC3339 with milliseconds if empty
+}
+
+const DefaultJsonlDateTimeFormat string = "2006-01-02T15:04:05.000Z07:00"
+
 type WriteFileColumnDef struct {
 	RawExpression    string                     `json:"expression" yaml:"expression"`
-	Name             string                     `json:"name"` // To be used in Having
-	Type             evalcapi.TableFieldType    `json:"type"` // To be checked when checking expressions and to be used in Having
+	Name             string                     `json:"name"`               // To be used in Having
+	Type             evalcapi.TableFieldType    `json:"type"`               // To be checked when checking expressions and to be used in Having
+	Nullable         bool                       `json:"nullable,omitempty"` // Null is written as empty CSV value, JSON null or Parquet null
 	Csv              WriteCsvColumnSettings     `json:"csv,omitempty"`
 	Parquet          WriteParquetColumnSettings `json:"parquet,omitempty"`
+	Jsonl            WriteJsonlColumnSettings   `json:"jsonl,omitempty"`
 	ParsedExpression ast.Expr                   `json:"-"`
 	UsedFields       FieldRefs                  `json:"-"`
 }
@@ -51,11 +64,17 @@ type TopDef struct {
 }
 
 type CsvCreatorSettings struct {
-	Separator string `json:"separator" yaml:"separator"`
+	Separator   string          `json:"separator" yaml:"separator"`
+	Compression CompressionType `json:"compression,omitempty" yaml:"compression,omitempty"` // Detected by url_template extension if empty, applies to jsonl too
 }
 
+// See file_creator_parquet.go for defaults
 type ParquetCreatorSettings struct {
-	Codec ParquetCodecType `json:"codec" yaml:"codec"`
+	Codec             ParquetCodecType `json:"codec" yaml:"codec"`
+	RowGroupSizeBytes int64            `json:"row_group_size_bytes,omitempty" yaml:"row_group_size_bytes,omitempty"` // Uncompressed size at which a row group is flushed
+	PageSizeBytes     int64            `json:"page_size_bytes,omitempty" yaml:"page_size_bytes,omitempty"`
+	Dictionary        *bool            `json:"dictionary,omitempty" yaml:"dictionary,omitempty"` // Dictionary encoding for all non-bool columns, true if not specified
+	Statistics        *bool            `json:"statistics,omitempty" yaml:"statistics,omitempty"` // Column chunk min/max and null count, true if not specified
 }
 
 type FileCreatorDef struct {
@@ -65,10 +84,14 @@ type FileCreatorDef struct {
 	Csv                           CsvCreatorSettings     `json:"csv,omitempty"`
 	Parquet                       ParquetCreatorSettings `json:"parquet,omitempty"`
 	Columns                       []WriteFileColumnDef   `json:"columns" yaml:"columns"`
+	MaxOpenPartitions             int                    `json:"max_open_partitions,omitempty" yaml:"max_open_partitions,omitempty"`
+	Finalize                      FinalizeDef            `json:"finalize,omitempty" yaml:"finalize,omitempty"`
 	Having                        ast.Expr               `json:"-"`
 	UsedInHavingFields            FieldRefs              `json:"-"`
 	UsedInTargetExpressionsFields FieldRefs              `json:"-"`
 	CreatorFileType               int                    `json:"-"`
+	PartitionColumnIdxs           []int                  `json:"-"` // Columns referenced in url_template as {w.column_name}
+	partitionRefs                 []string
 }
 
 // 500k is conservative
@@ -80,7 +103,8 @@ func (creatorDef *FileCreatorDef) getFieldRefs() *FieldRefs {
 		fieldRefs[i] = FieldRef{
 			TableName: CreatorAlias,
 			FieldName: creatorDef.Columns[i].Name,
-			FieldType: creatorDef.Columns[i].Type}
+			FieldType: creatorDef.Columns[i].Type,
+			Nullable:  creatorDef.Columns[i].Nullable}
 	}
 	return &fieldRefs
 }
@@ -108,6 +132,10 @@ func (creatorDef *FileCreatorDef) GetFieldRefsUsedInAllTargetFileExpressions() F
 	return fieldRefs
 }
 
+func (creatorDef *FileCreatorDef) GetCompression() CompressionType {
+	return resolveCompression(creatorDef.Csv.Compression, creatorDef.UrlTemplate)
+}
+
 func (creatorDef *FileCreatorDef) HasTop() bool {
 	return len(strings.TrimSpace(creatorDef.Top.RawOrder)) > 0
 }
@@ -122,13 +150,43 @@ func (creatorDef *FileCreatorDef) Deserialize(rawWriter json.RawMessage) error {
 		if creatorDef.Parquet.Codec == "" {
 			creatorDef.Parquet.Codec = ParquetCodecGzip
 		}
+	} else if len(creatorDef.Columns) > 0 && creatorDef.Columns[0].Jsonl.Key != "" {
+		creatorDef.CreatorFileType = CreatorFileTypeJsonl
+		usedKeys := map[string]struct{}{}
+		for i := range creatorDef.Columns {
+			colDef := &creatorDef.Columns[i]
+			if colDef.Jsonl.Key == "" {
+				return fmt.Errorf("jsonl column %s has no key", colDef.Name)
+			}
+			if _, ok := usedKeys[colDef.Jsonl.Key]; ok {
+				return fmt.Errorf("jsonl key %s is used by more than one column", colDef.Jsonl.Key)
+			}
+			usedKeys[colDef.Jsonl.Key] = struct{}{}
+			if colDef.Type == evalcapi.FieldTypeDateTime && colDef.Jsonl.Format == "" {
+				colDef.Jsonl.Format = DefaultJsonlDateTimeFormat
+			}
+		}
 	} else if len(creatorDef.Columns) > 0 && creatorDef.Columns[0].Csv.Header != "" {
 		creatorDef.CreatorFileType = CreatorFileTypeCsv
 		if len(creatorDef.Csv.Separator) == 0 {
 			creatorDef.Csv.Separator = ","
 		}
 	} else {
-		return errors.New("cannot cannot detect file creator type: parquet should have column_name, csv should have header etc")
+		return errors.New("cannot cannot detect file creator type: parquet should have column_name, csv should have header, jsonl should have key etc")
+	}
+
+	if creatorDef.CreatorFileType == CreatorFileTypeCsv || creatorDef.CreatorFileType == CreatorFileTypeJsonl {
+		if err := checkCompression(creatorDef.Csv.Compression); err != nil {
+			return err
+		}
+		// Go has bzip2 decompressor only
+		if creatorDef.GetCompression() == CompressionBzip2 {
+			return fmt.Errorf("file creator does not support %s compression, use %s or %s", CompressionBzip2, CompressionGzip, CompressionZstd)
+		}
+	}
+
+	if err := creatorDef.checkParquetSettings(); err != nil {
+		return err
 	}
 
 	// Having
@@ -149,6 +207,14 @@ func (creatorDef *FileCreatorDef) Deserialize(rawWriter json.RawMessage) error {
 		}
 	}
 
+	if err := creatorDef.parsePartitions(); err != nil {
+		return err
+	}
+
+	if err := creatorDef.checkFinalize(); err != nil {
+		return err
+	}
+
 	// Top
 	if creatorDef.HasTop() {
 		if creatorDef.Top.Limit <= 0 {
@@ -175,12 +241,13 @@ func (creatorDef *FileCreatorDef) CalculateFileRecordFromSrcVars(srcVars eval.Va
 
 	for colIdx := 0; colIdx < len(creatorDef.Columns); colIdx++ {
 		eCtx := eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, srcVars)
-		eCtx.SetRoundDec(2) // decimal2
+		eCtx.SetRoundDec(evalcapi.DecimalFieldTypeScale(creatorDef.Columns[colIdx].Type))
 		valVolatile, err := eCtx.Eval(creatorDef.Columns[colIdx].ParsedExpression)
 		if err != nil {
 			foundErrors = append(foundErrors, fmt.Sprintf("cannot evaluate expression for column %s: [%s]", creatorDef.Columns[colIdx].Name, err.Error()))
 		}
-		if err := CheckValueType(valVolatile, creatorDef.Columns[colIdx].Type); err != nil {
+		valVolatile = RoundDecimalValue(valVolatile, creatorDef.Columns[colIdx].Type)
+		if err := CheckNullableValueType(valVolatile, creatorDef.Columns[colIdx].Type, creatorDef.Columns[colIdx].Nullable); err != nil {
 			foundErrors = append(foundErrors, fmt.Sprintf("invalid field %s type: [%s]", creatorDef.Columns[colIdx].Name, err.Error()))
 		}
 		fileRecord[colIdx] = valVolatile
@@ -212,6 +279,9 @@ func (creatorDef *FileCreatorDef) CheckFileRecordHavingCondition(fileRecord []an
 	if err != nil {
 		return false, fmt.Errorf("cannot evaluate 'having' expression: [%s]", err.Error())
 	}
+	if valVolatile == nil {
+		return false, nil
+	}
 	valBool, ok := valVolatile.(bool)
 	if !ok {
 		return false, fmt.Errorf("cannot get bool when evaluating having expression, got %v(%T) instead", valVolatile, valVolatile)
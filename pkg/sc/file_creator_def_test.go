@@ -2,6 +2,7 @@ package sc
 
 import (
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -100,3 +101,64 @@ func TestCheckFileRecordHavingCondition(t *testing.T) {
 	_, err = c.CheckFileRecordHavingCondition([]any{})
 	assert.Contains(t, err.Error(), "file record length 0 does not match file creator column list length 1")
 }
+
+func TestFileCreatorCompression(t *testing.T) {
+	c := FileCreatorDef{}
+
+	assert.Nil(t, c.Deserialize([]byte(nodeCfgCsvJson)))
+	assert.Equal(t, CompressionNone, c.GetCompression())
+
+	assert.Nil(t, c.Deserialize([]byte(strings.Replace(nodeCfgCsvJson, `"taxed_table1.csv"`, `"taxed_table1.csv.gz"`, 1))))
+	assert.Equal(t, CompressionGzip, c.GetCompression())
+
+	c = FileCreatorDef{}
+	assert.Nil(t, c.Deserialize([]byte(strings.Replace(nodeCfgCsvJson, `"top":`, `"csv": {"compression": "zstd"}, "top":`, 1))))
+	assert.Equal(t, CompressionZstd, c.GetCompression())
+
+	c = FileCreatorDef{}
+	assert.Contains(t, c.Deserialize([]byte(strings.Replace(nodeCfgCsvJson, `"taxed_table1.csv"`, `"taxed_table1.csv.bz2"`, 1))).Error(), "file creator does not support bz2 compression, use gzip or zstd")
+
+	c = FileCreatorDef{}
+	assert.Contains(t, c.Deserialize([]byte(strings.Replace(nodeCfgCsvJson, `"top":`, `"csv": {"compression": "lz4"}, "top":`, 1))).Error(), "invalid compression lz4, expected one of: none, gzip, zstd, bz2")
+}
+
+const nodeCfgJsonlJson string = `
+{
+	"url_template": "taxed_table1.jsonl.gz",
+	"columns": [
+		{
+			"jsonl":{
+				"key": "field_string1"
+			},
+			"name": "field_string1",
+			"expression": "r.field_string1",
+			"type": "string"
+		},
+		{
+			"jsonl":{
+				"key": "field_dt1"
+			},
+			"name": "field_dt1",
+			"expression": "r.field_dt1",
+			"type": "datetime"
+		}
+	]
+}
+`
+
+func TestFileCreatorJsonl(t *testing.T) {
+	c := FileCreatorDef{}
+	assert.Nil(t, c.Deserialize([]byte(nodeCfgJsonlJson)))
+	assert.Equal(t, CreatorFileTypeJsonl, c.CreatorFileType)
+	assert.Equal(t, DefaultJsonlDateTimeFormat, c.Columns[1].Jsonl.Format)
+	assert.Equal(t, CompressionGzip, c.GetCompression())
+
+	c = FileCreatorDef{}
+	assert.Contains(t, c.Deserialize([]byte(strings.Replace(nodeCfgJsonlJson, `"key": "field_dt1"`, `"key": ""`, 1))).Error(), "jsonl column field_dt1 has no key")
+
+	c = FileCreatorDef{}
+	assert.Contains(t, c.Deserialize([]byte(strings.Replace(nodeCfgJsonlJson, `"key": "field_dt1"`, `"key": "field_string1"`, 1))).Error(), "jsonl key field_string1 is used by more than one column")
+
+	c = FileCreatorDef{}
+	assert.Contains(t, c.Deserialize([]byte(strings.Replace(nodeCfgJsonlJson, `"taxed_table1.jsonl.gz"`, `"taxed_table1.jsonl.bz2"`, 1))).Error(), "file creator does not support bz2 compression, use gzip or zstd")
+}
@@ -0,0 +1,49 @@
+package sc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Merges per-batch files produced by url_template into a single file once all node batches succeed
+type FinalizeDef struct {
+	Url         string `json:"url" yaml:"url"`
+	DeleteParts bool   `json:"delete_parts,omitempty" yaml:"delete_parts,omitempty"`
+}
+
+func (creatorDef *FileCreatorDef) HasFinalize() bool {
+	return creatorDef.Finalize.Url != ""
+}
+
+func (creatorDef *FileCreatorDef) checkFinalize() error {
+	if !creatorDef.HasFinalize() {
+		if creatorDef.Finalize.DeleteParts {
+			return fmt.Errorf("finalize.delete_parts requires finalize.url to be specified")
+		}
+		return nil
+	}
+	if !strings.Contains(creatorDef.UrlTemplate, ReservedParamBatchIdx) {
+		return fmt.Errorf("finalize requires url_template %s to contain %s", creatorDef.UrlTemplate, ReservedParamBatchIdx)
+	}
+	if strings.Contains(creatorDef.Finalize.Url, ReservedParamBatchIdx) {
+		return fmt.Errorf("finalize.url %s cannot contain %s", creatorDef.Finalize.Url, ReservedParamBatchIdx)
+	}
+	if creatorDef.HasPartitions() {
+		return fmt.Errorf("finalize cannot be used with partitioned url_template %s", creatorDef.UrlTemplate)
+	}
+	if creatorDef.CreatorFileType == CreatorFileTypeCsv || creatorDef.CreatorFileType == CreatorFileTypeJsonl {
+		finalCompression := resolveCompression(creatorDef.Csv.Compression, creatorDef.Finalize.Url)
+		if finalCompression != creatorDef.GetCompression() {
+			return fmt.Errorf("finalize.url %s compression %s does not match url_template %s compression %s", creatorDef.Finalize.Url, finalCompression, creatorDef.UrlTemplate, creatorDef.GetCompression())
+		}
+	}
+	return nil
+}
+
+func (creatorDef *FileCreatorDef) GetPartUrl(runId int16, batchIdx int16) string {
+	return strings.ReplaceAll(strings.ReplaceAll(creatorDef.UrlTemplate, ReservedParamRunId, fmt.Sprintf("%05d", runId)), ReservedParamBatchIdx, fmt.Sprintf("%05d", batchIdx))
+}
+
+func (creatorDef *FileCreatorDef) GetFinalizeUrl(runId int16) string {
+	return strings.ReplaceAll(creatorDef.Finalize.Url, ReservedParamRunId, fmt.Sprintf("%05d", runId))
+}
@@ -0,0 +1,63 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nodeCfgFinalizeCsvJson string = `
+{
+	"url_template": "s3://bucket/out/{run_id|string}/part_{batch_idx|string}.csv.gz",
+	"finalize": {
+		"url": "s3://bucket/out/{run_id|string}/all.csv.gz",
+		"delete_parts": true
+	},
+	"columns": [
+		{
+			"csv":{"header": "a", "format": "%s"},
+			"name": "a",
+			"expression": "r.a",
+			"type": "string"
+		}
+	]
+}
+`
+
+func TestFileCreatorFinalize(t *testing.T) {
+	c := FileCreatorDef{}
+	assert.Nil(t, c.Deserialize([]byte(nodeCfgFinalizeCsvJson)))
+	assert.True(t, c.HasFinalize())
+	assert.True(t, c.Finalize.DeleteParts)
+	assert.Equal(t, "s3://bucket/out/00002/part_00005.csv.gz", c.GetPartUrl(2, 5))
+	assert.Equal(t, "s3://bucket/out/00002/all.csv.gz", c.GetFinalizeUrl(2))
+
+	// No finalize
+	c = FileCreatorDef{}
+	assert.Nil(t, c.Deserialize([]byte(strings.Replace(nodeCfgFinalizeCsvJson, `"url": "s3://bucket/out/{run_id|string}/all.csv.gz",
+		"delete_parts": true`, "", 1))))
+	assert.False(t, c.HasFinalize())
+}
+
+func TestFileCreatorFinalizeFailures(t *testing.T) {
+	c := FileCreatorDef{}
+	err := c.Deserialize([]byte(strings.Replace(nodeCfgFinalizeCsvJson, `"url": "s3://bucket/out/{run_id|string}/all.csv.gz",`, "", 1)))
+	assert.Contains(t, err.Error(), "finalize.delete_parts requires finalize.url to be specified")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgFinalizeCsvJson, `part_{batch_idx|string}.csv.gz`, `part.csv.gz`, 1)))
+	assert.Contains(t, err.Error(), "finalize requires url_template s3://bucket/out/{run_id|string}/part.csv.gz to contain {batch_idx|string}")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgFinalizeCsvJson, `all.csv.gz`, `all_{batch_idx|string}.csv.gz`, 1)))
+	assert.Contains(t, err.Error(), "finalize.url s3://bucket/out/{run_id|string}/all_{batch_idx|string}.csv.gz cannot contain {batch_idx|string}")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgFinalizeCsvJson, `all.csv.gz`, `all.csv`, 1)))
+	assert.Contains(t, err.Error(), "finalize.url s3://bucket/out/{run_id|string}/all.csv compression none does not match url_template s3://bucket/out/{run_id|string}/part_{batch_idx|string}.csv.gz compression gzip")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgFinalizeCsvJson, `{run_id|string}/part_`, `{run_id|string}/a={w.a}/part_`, 1)))
+	assert.Contains(t, err.Error(), "finalize cannot be used with partitioned url_template s3://bucket/out/{run_id|string}/a={w.a}/part_{batch_idx|string}.csv.gz")
+}
@@ -0,0 +1,85 @@
+package sc
+
+import (
+	"fmt"
+
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+)
+
+const (
+	DefaultParquetRowGroupSizeBytes int64 = 64 * 1024 * 1024 // Uncompressed
+	DefaultParquetPageSizeBytes     int64 = 1024 * 1024
+	MinParquetPageSizeBytes         int64 = 1024
+)
+
+func (s *ParquetCreatorSettings) GetRowGroupSizeBytes() int64 {
+	if s.RowGroupSizeBytes == 0 {
+		return DefaultParquetRowGroupSizeBytes
+	}
+	return s.RowGroupSizeBytes
+}
+
+func (s *ParquetCreatorSettings) GetPageSizeBytes() int64 {
+	if s.PageSizeBytes == 0 {
+		return DefaultParquetPageSizeBytes
+	}
+	return s.PageSizeBytes
+}
+
+// Column setting wins, then creator setting, then true
+func resolveParquetFlag(colFlag *bool, creatorFlag *bool) bool {
+	if colFlag != nil {
+		return *colFlag
+	}
+	if creatorFlag != nil {
+		return *creatorFlag
+	}
+	return true
+}
+
+// Nil colSettings: creator-level setting
+func (s *ParquetCreatorSettings) UsesDictionary(colSettings *WriteParquetColumnSettings) bool {
+	if colSettings == nil {
+		return resolveParquetFlag(nil, s.Dictionary)
+	}
+	return resolveParquetFlag(colSettings.Dictionary, s.Dictionary)
+}
+
+// Nil colSettings: creator-level setting
+func (s *ParquetCreatorSettings) WritesStatistics(colSettings *WriteParquetColumnSettings) bool {
+	if colSettings == nil {
+		return resolveParquetFlag(nil, s.Statistics)
+	}
+	return resolveParquetFlag(colSettings.Statistics, s.Statistics)
+}
+
+func (creatorDef *FileCreatorDef) checkParquetSettings() error {
+	s := &creatorDef.Parquet
+	if creatorDef.CreatorFileType != CreatorFileTypeParquet {
+		if s.RowGroupSizeBytes != 0 || s.PageSizeBytes != 0 || s.Dictionary != nil || s.Statistics != nil {
+			return fmt.Errorf("parquet settings can be used with parquet file creator only")
+		}
+		return nil
+	}
+	switch s.Codec {
+	case ParquetCodecGzip, ParquetCodecSnappy, ParquetCodecZstd, ParquetCodecUncompressed:
+	default:
+		return fmt.Errorf("invalid parquet codec %s, expected one of: %s, %s, %s, %s", s.Codec, ParquetCodecGzip, ParquetCodecSnappy, ParquetCodecZstd, ParquetCodecUncompressed)
+	}
+	if s.RowGroupSizeBytes < 0 {
+		return fmt.Errorf("invalid parquet row_group_size_bytes %d, cannot be negative", s.RowGroupSizeBytes)
+	}
+	if s.PageSizeBytes != 0 && s.PageSizeBytes < MinParquetPageSizeBytes {
+		return fmt.Errorf("invalid parquet page_size_bytes %d, expected at least %d", s.PageSizeBytes, MinParquetPageSizeBytes)
+	}
+	if s.GetPageSizeBytes() > s.GetRowGroupSizeBytes() {
+		return fmt.Errorf("parquet page_size_bytes %d cannot exceed row_group_size_bytes %d", s.GetPageSizeBytes(), s.GetRowGroupSizeBytes())
+	}
+	for i := range creatorDef.Columns {
+		colDef := &creatorDef.Columns[i]
+		if colDef.Type == evalcapi.FieldTypeBool && colDef.Parquet.Dictionary != nil && *colDef.Parquet.Dictionary {
+			return fmt.Errorf("parquet column %s: bool columns cannot use dictionary encoding", colDef.Parquet.ColumnName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nodeCfgTunedParquetJson string = `
+{
+	"url_template": "out.parquet",
+	"parquet": {
+		"codec": "zstd",
+		"row_group_size_bytes": 268435456,
+		"page_size_bytes": 65536,
+		"dictionary": false
+	},
+	"columns": [
+		{
+			"parquet":{"column_name": "state", "dictionary": true},
+			"name": "state",
+			"expression": "r.state",
+			"type": "string"
+		},
+		{
+			"parquet":{"column_name": "comment", "statistics": false},
+			"name": "comment",
+			"expression": "r.comment",
+			"type": "string"
+		},
+		{
+			"parquet":{"column_name": "is_active"},
+			"name": "is_active",
+			"expression": "r.is_active",
+			"type": "bool"
+		}
+	]
+}
+`
+
+func TestFileCreatorParquetSettings(t *testing.T) {
+	c := FileCreatorDef{}
+	assert.Nil(t, c.Deserialize([]byte(nodeCfgTunedParquetJson)))
+	assert.Equal(t, ParquetCodecZstd, c.Parquet.Codec)
+	assert.Equal(t, int64(268435456), c.Parquet.GetRowGroupSizeBytes())
+	assert.Equal(t, int64(65536), c.Parquet.GetPageSizeBytes())
+
+	assert.True(t, c.Parquet.UsesDictionary(&c.Columns[0].Parquet))
+	assert.False(t, c.Parquet.UsesDictionary(&c.Columns[1].Parquet))
+	assert.False(t, c.Parquet.UsesDictionary(nil))
+	assert.True(t, c.Parquet.WritesStatistics(&c.Columns[0].Parquet))
+	assert.False(t, c.Parquet.WritesStatistics(&c.Columns[1].Parquet))
+	assert.True(t, c.Parquet.WritesStatistics(nil))
+
+	// Defaults
+	c = FileCreatorDef{}
+	assert.Nil(t, c.Deserialize([]byte(nodeCfgParquetJson)))
+	assert.Equal(t, ParquetCodecGzip, c.Parquet.Codec)
+	assert.Equal(t, DefaultParquetRowGroupSizeBytes, c.Parquet.GetRowGroupSizeBytes())
+	assert.Equal(t, DefaultParquetPageSizeBytes, c.Parquet.GetPageSizeBytes())
+	assert.True(t, c.Parquet.UsesDictionary(&c.Columns[0].Parquet))
+	assert.True(t, c.Parquet.WritesStatistics(&c.Columns[0].Parquet))
+}
+
+func TestFileCreatorParquetSettingsFailures(t *testing.T) {
+	c := FileCreatorDef{}
+	err := c.Deserialize([]byte(strings.Replace(nodeCfgTunedParquetJson, `"zstd"`, `"lz4"`, 1)))
+	assert.Contains(t, err.Error(), "invalid parquet codec lz4, expected one of: gzip, snappy, zstd, uncompressed")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgTunedParquetJson, `"row_group_size_bytes": 268435456`, `"row_group_size_bytes": -1`, 1)))
+	assert.Contains(t, err.Error(), "invalid parquet row_group_size_bytes -1, cannot be negative")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgTunedParquetJson, `"page_size_bytes": 65536`, `"page_size_bytes": 100`, 1)))
+	assert.Contains(t, err.Error(), "invalid parquet page_size_bytes 100, expected at least 1024")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgTunedParquetJson, `"row_group_size_bytes": 268435456`, `"row_group_size_bytes": 32768`, 1)))
+	assert.Contains(t, err.Error(), "parquet page_size_bytes 65536 cannot exceed row_group_size_bytes 32768")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgTunedParquetJson, `"column_name": "is_active"`, `"column_name": "is_active", "dictionary": true`, 1)))
+	assert.Contains(t, err.Error(), "parquet column is_active: bool columns cannot use dictionary encoding")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgCsvJson, `"url_template"`, `"parquet": {"dictionary": false}, "url_template"`, 1)))
+	assert.Contains(t, err.Error(), "parquet settings can be used with parquet file creator only")
+}
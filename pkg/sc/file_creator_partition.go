@@ -0,0 +1,123 @@
+package sc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/shopspring/decimal"
+)
+
+// Open partition writers per batch if max_open_partitions is not specified
+const DefaultMaxOpenPartitions int = 100
+
+// Datetime partition values are dates: date={w.d} -> date=2024-01-31
+const PartitionDateTimeFormat string = "2006-01-02"
+
+// Hive convention for empty partition values
+const HiveDefaultPartition string = "__HIVE_DEFAULT_PARTITION__"
+
+// {w.column_name} in url_template, script param loader leaves these alone because of the dot
+var partitionRefRegex = regexp.MustCompile(`{[ ]*` + CreatorAlias + `\.([a-zA-Z0-9_]+)[ ]*}`)
+
+func (creatorDef *FileCreatorDef) HasPartitions() bool {
+	return len(creatorDef.PartitionColumnIdxs) > 0
+}
+
+func (creatorDef *FileCreatorDef) GetMaxOpenPartitions() int {
+	if creatorDef.MaxOpenPartitions == 0 {
+		return DefaultMaxOpenPartitions
+	}
+	return creatorDef.MaxOpenPartitions
+}
+
+func (creatorDef *FileCreatorDef) parsePartitions() error {
+	creatorDef.PartitionColumnIdxs = make([]int, 0)
+	creatorDef.partitionRefs = make([]string, 0)
+	if creatorDef.MaxOpenPartitions < 0 {
+		return fmt.Errorf("max_open_partitions cannot be negative: %d", creatorDef.MaxOpenPartitions)
+	}
+
+	colIdxByName := map[string]int{}
+	for i := range creatorDef.Columns {
+		colIdxByName[creatorDef.Columns[i].Name] = i
+	}
+
+	usedRefs := map[string]struct{}{}
+	for _, match := range partitionRefRegex.FindAllStringSubmatch(creatorDef.UrlTemplate, -1) {
+		if _, ok := usedRefs[match[0]]; ok {
+			continue
+		}
+		usedRefs[match[0]] = struct{}{}
+		colIdx, ok := colIdxByName[match[1]]
+		if !ok {
+			return fmt.Errorf("url_template %s references unknown column %s", creatorDef.UrlTemplate, match[1])
+		}
+		switch evalcapi.FieldTypeKind(creatorDef.Columns[colIdx].Type) {
+		case evalcapi.FieldTypeString, evalcapi.FieldTypeInt, evalcapi.FieldTypeBool, evalcapi.FieldTypeDecimal2, evalcapi.FieldTypeDateTime:
+		default:
+			return fmt.Errorf("cannot partition by column %s of type %s, expected string, int, bool, decimal2, decimal(p,s) or datetime", match[1], creatorDef.Columns[colIdx].Type)
+		}
+		if creatorDef.Columns[colIdx].Nullable {
+			return fmt.Errorf("cannot partition by nullable column %s", match[1])
+		}
+		creatorDef.PartitionColumnIdxs = append(creatorDef.PartitionColumnIdxs, colIdx)
+		creatorDef.partitionRefs = append(creatorDef.partitionRefs, match[0])
+	}
+
+	if creatorDef.MaxOpenPartitions > 0 && !creatorDef.HasPartitions() {
+		return fmt.Errorf("max_open_partitions requires url_template %s to reference at least one column like {%s.column_name}", creatorDef.UrlTemplate, CreatorAlias)
+	}
+	return nil
+}
+
+// Same as Hive: path separators, '=' and other characters that may confuse readers are %-escaped
+func escapePartitionValue(s string) string {
+	if s == "" {
+		return HiveDefaultPartition
+	}
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == 0x7F || strings.IndexByte("\"#%'*/:=?\\{}[]^", c) >= 0 {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func partitionValueToString(val any) (string, error) {
+	switch typedVal := val.(type) {
+	case string:
+		return typedVal, nil
+	case int64:
+		return fmt.Sprintf("%d", typedVal), nil
+	case bool:
+		return fmt.Sprintf("%t", typedVal), nil
+	case decimal.Decimal:
+		return typedVal.String(), nil
+	case time.Time:
+		return typedVal.Format(PartitionDateTimeFormat), nil
+	default:
+		return "", fmt.Errorf("unsupported partition value %v(%T)", val, val)
+	}
+}
+
+// Replaces {w.column_name} references in fileUrl (run_id and batch_idx already resolved) with file record values
+func (creatorDef *FileCreatorDef) ResolvePartitionUrl(fileUrl string, fileRecord []any) (string, error) {
+	if len(fileRecord) != len(creatorDef.Columns) {
+		return "", fmt.Errorf("file record length %d does not match file creator column list length %d", len(fileRecord), len(creatorDef.Columns))
+	}
+	for i, colIdx := range creatorDef.PartitionColumnIdxs {
+		s, err := partitionValueToString(fileRecord[colIdx])
+		if err != nil {
+			return "", fmt.Errorf("cannot get partition value for column %s: %s", creatorDef.Columns[colIdx].Name, err.Error())
+		}
+		fileUrl = strings.ReplaceAll(fileUrl, creatorDef.partitionRefs[i], escapePartitionValue(s))
+	}
+	return fileUrl, nil
+}
@@ -0,0 +1,96 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const nodeCfgPartitionedParquetJson string = `
+{
+	"url_template": "s3://bucket/out/region={w.region}/date={ w.d }/part-{batch_idx|string}.parquet",
+	"max_open_partitions": 10,
+	"columns": [
+		{
+			"parquet":{"column_name": "region"},
+			"name": "region",
+			"expression": "r.region",
+			"type": "string"
+		},
+		{
+			"parquet":{"column_name": "d"},
+			"name": "d",
+			"expression": "r.d",
+			"type": "datetime"
+		},
+		{
+			"parquet":{"column_name": "amount"},
+			"name": "amount",
+			"expression": "r.amount",
+			"type": "float"
+		}
+	]
+}
+`
+
+func TestFileCreatorPartitions(t *testing.T) {
+	c := FileCreatorDef{}
+	assert.Nil(t, c.Deserialize([]byte(nodeCfgPartitionedParquetJson)))
+	assert.True(t, c.HasPartitions())
+	assert.Equal(t, []int{0, 1}, c.PartitionColumnIdxs)
+	assert.Equal(t, 10, c.GetMaxOpenPartitions())
+
+	d := time.Date(2024, 1, 31, 10, 20, 30, 0, time.UTC)
+	fileUrl, err := c.ResolvePartitionUrl("s3://bucket/out/region={w.region}/date={ w.d }/part-00005.parquet", []any{"us/east=1", d, 1.5})
+	assert.Nil(t, err)
+	assert.Equal(t, "s3://bucket/out/region=us%2Feast%3D1/date=2024-01-31/part-00005.parquet", fileUrl)
+
+	fileUrl, err = c.ResolvePartitionUrl("region={w.region}/date={ w.d }", []any{"", d, 1.5})
+	assert.Nil(t, err)
+	assert.Equal(t, "region=__HIVE_DEFAULT_PARTITION__/date=2024-01-31", fileUrl)
+
+	_, err = c.ResolvePartitionUrl("region={w.region}", []any{"a", d})
+	assert.Contains(t, err.Error(), "file record length 2 does not match file creator column list length 3")
+
+	_, err = c.ResolvePartitionUrl("region={w.region}", []any{1.5, d, 1.5})
+	assert.Contains(t, err.Error(), "cannot get partition value for column region: unsupported partition value 1.5(float64)")
+
+	// Decimal and default limit
+	c = FileCreatorDef{}
+	assert.Nil(t, c.Deserialize([]byte(strings.Replace(strings.Replace(strings.Replace(nodeCfgPartitionedParquetJson, `"max_open_partitions": 10,`, "", 1), `"type": "float"`, `"type": "decimal2"`, 1), `{w.region}`, `{w.amount}`, 1))))
+	assert.Equal(t, DefaultMaxOpenPartitions, c.GetMaxOpenPartitions())
+	assert.Equal(t, []int{2, 1}, c.PartitionColumnIdxs)
+	fileUrl, err = c.ResolvePartitionUrl("amount={w.amount}/date={ w.d }", []any{"a", d, decimal.NewFromFloat(1.5)})
+	assert.Nil(t, err)
+	assert.Equal(t, "amount=1.5/date=2024-01-31", fileUrl)
+
+	// Not partitioned
+	c = FileCreatorDef{}
+	assert.Nil(t, c.Deserialize([]byte(strings.Replace(strings.Replace(nodeCfgPartitionedParquetJson, `"max_open_partitions": 10,`, "", 1), `region={w.region}/date={ w.d }/`, "", 1))))
+	assert.False(t, c.HasPartitions())
+}
+
+func TestFileCreatorPartitionsFailures(t *testing.T) {
+	c := FileCreatorDef{}
+	err := c.Deserialize([]byte(strings.Replace(nodeCfgPartitionedParquetJson, `{w.region}`, `{w.country}`, 1)))
+	assert.Contains(t, err.Error(), "url_template s3://bucket/out/region={w.country}/date={ w.d }/part-{batch_idx|string}.parquet references unknown column country")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgPartitionedParquetJson, `{w.region}`, `{w.amount}`, 1)))
+	assert.Contains(t, err.Error(), "cannot partition by column amount of type float, expected string, int, bool, decimal2, decimal(p,s) or datetime")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgPartitionedParquetJson, `"expression": "r.region",`, `"expression": "r.region", "nullable": true,`, 1)))
+	assert.Contains(t, err.Error(), "cannot partition by nullable column region")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgPartitionedParquetJson, `"max_open_partitions": 10`, `"max_open_partitions": -1`, 1)))
+	assert.Contains(t, err.Error(), "max_open_partitions cannot be negative: -1")
+
+	c = FileCreatorDef{}
+	err = c.Deserialize([]byte(strings.Replace(nodeCfgPartitionedParquetJson, `region={w.region}/date={ w.d }/`, "", 1)))
+	assert.Contains(t, err.Error(), "max_open_partitions requires url_template s3://bucket/out/part-{batch_idx|string}.parquet to reference at least one column like {w.column_name}")
+}
@@ -0,0 +1,43 @@
+package sc
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/capillariesio/capillaries/pkg/xfer"
+)
+
+// Rows that cannot be parsed are skipped (and written to bad_rows_url, if specified) instead of failing the batch,
+// as long as there are no more than max_bad_rows of them in a batch
+func (frDef *FileReaderDef) HasBadRowsQuarantine() bool {
+	return frDef.MaxBadRows > 0
+}
+
+func (frDef *FileReaderDef) checkBadRows() []string {
+	foundErrors := make([]string, 0)
+	if frDef.MaxBadRows < 0 {
+		foundErrors = append(foundErrors, fmt.Sprintf("max_bad_rows cannot be negative: %d", frDef.MaxBadRows))
+	}
+	if frDef.BadRowsUrl == "" {
+		return foundErrors
+	}
+	if frDef.MaxBadRows == 0 {
+		foundErrors = append(foundErrors, "bad_rows_url requires max_bad_rows to be specified")
+	}
+	// Batches of the same node run in parallel, each of them needs its own reject file
+	if !strings.Contains(frDef.BadRowsUrl, ReservedParamBatchIdx) {
+		foundErrors = append(foundErrors, fmt.Sprintf("bad_rows_url %s must contain %s", frDef.BadRowsUrl, ReservedParamBatchIdx))
+	}
+	u, err := url.Parse(frDef.BadRowsUrl)
+	if err != nil {
+		foundErrors = append(foundErrors, fmt.Sprintf("cannot parse bad_rows_url %s: %s", frDef.BadRowsUrl, err.Error()))
+	} else if u.Scheme != xfer.UrlSchemeFile && u.Scheme != "" && u.Scheme != xfer.UrlSchemeSftp && u.Scheme != xfer.UrlSchemeS3 {
+		foundErrors = append(foundErrors, fmt.Sprintf("unsupported bad_rows_url scheme %s, expected file, sftp or s3", u.Scheme))
+	}
+	return foundErrors
+}
+
+func (frDef *FileReaderDef) GetBadRowsUrl(runId int16, batchIdx int16) string {
+	return strings.ReplaceAll(strings.ReplaceAll(frDef.BadRowsUrl, ReservedParamRunId, fmt.Sprintf("%05d", runId)), ReservedParamBatchIdx, fmt.Sprintf("%05d", batchIdx))
+}
@@ -0,0 +1,63 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const badRowsReaderConf string = `
+{
+	"urls": ["a.csv"],
+	"max_bad_rows": 10,
+	"bad_rows_url": "sftp://user@host/rejects/a_{batch_idx|string}.csv",
+	"csv": {
+		"hdr_line_idx": 0,
+		"first_data_line_idx": 1
+	},
+	"columns": {
+		"col_a": {
+			"csv": {"col_hdr": "a"},
+			"col_type": "string"
+		}
+	}
+}`
+
+func TestBadRows(t *testing.T) {
+	reader := FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(badRowsReaderConf)))
+	assert.True(t, reader.HasBadRowsQuarantine())
+	assert.Equal(t, int64(10), reader.MaxBadRows)
+	assert.Equal(t, "sftp://user@host/rejects/a_00005.csv", reader.GetBadRowsUrl(1, 5))
+
+	// No reject file, bad rows are only logged
+	reader = FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(strings.Replace(badRowsReaderConf, `"bad_rows_url": "sftp://user@host/rejects/a_{batch_idx|string}.csv",`, "", 1))))
+	assert.True(t, reader.HasBadRowsQuarantine())
+	assert.Equal(t, "", reader.BadRowsUrl)
+
+	// Default: any bad row fails the batch
+	reader = FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(strings.Replace(badRowsReaderConf, `"max_bad_rows": 10,
+	"bad_rows_url": "sftp://user@host/rejects/a_{batch_idx|string}.csv",`, "", 1))))
+	assert.False(t, reader.HasBadRowsQuarantine())
+}
+
+func TestBadRowsFailures(t *testing.T) {
+	reader := FileReaderDef{}
+	err := reader.Deserialize([]byte(strings.Replace(badRowsReaderConf, `"max_bad_rows": 10`, `"max_bad_rows": -1`, 1)))
+	assert.Contains(t, err.Error(), "max_bad_rows cannot be negative: -1")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(badRowsReaderConf, `"max_bad_rows": 10,`, "", 1)))
+	assert.Contains(t, err.Error(), "bad_rows_url requires max_bad_rows to be specified")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(badRowsReaderConf, `a_{batch_idx|string}.csv`, `a.csv`, 1)))
+	assert.Contains(t, err.Error(), "bad_rows_url sftp://user@host/rejects/a.csv must contain {batch_idx|string}")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(badRowsReaderConf, `sftp://`, `https://`, 1)))
+	assert.Contains(t, err.Error(), "unsupported bad_rows_url scheme https, expected file, sftp or s3")
+}
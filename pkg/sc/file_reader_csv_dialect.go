@@ -0,0 +1,70 @@
+package sc
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	CsvEncodingUtf8  string = "utf-8"
+	CsvEncodingUtf16 string = "utf-16"
+)
+
+const DefaultCsvQuoteChar string = `"`
+
+// Returns nil for UTF-8 (and for empty name): no decoding needed.
+// Plain utf-16 uses BOM to detect byte order and falls back to little endian, utf-16le and utf-16be ignore BOM.
+// Everything else is looked up by IANA name, like iso-8859-1, windows-1252, shift_jis.
+func GetTextEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", CsvEncodingUtf8, "utf8":
+		return nil, nil
+	case CsvEncodingUtf16:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), nil
+	}
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %s", name)
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("unsupported encoding %s", name)
+	}
+	return enc, nil
+}
+
+// Standard dialect can be handled by encoding/csv: single-character separator and comment prefix, double quotes
+func (s *CsvReaderSettings) IsStandardDialect() bool {
+	return utf8.RuneCountInString(s.Separator) == 1 &&
+		s.GetQuoteChar() == DefaultCsvQuoteChar &&
+		utf8.RuneCountInString(s.CommentPrefix) <= 1
+}
+
+func (s *CsvReaderSettings) GetQuoteChar() string {
+	if s.QuoteChar == "" {
+		return DefaultCsvQuoteChar
+	}
+	return s.QuoteChar
+}
+
+func (frDef *FileReaderDef) checkCsvDialect() []string {
+	foundErrors := make([]string, 0)
+	s := &frDef.Csv
+	if _, err := GetTextEncoding(s.Encoding); err != nil {
+		foundErrors = append(foundErrors, fmt.Sprintf("invalid csv encoding: %s", err.Error()))
+	}
+	if utf8.RuneCountInString(s.GetQuoteChar()) != 1 {
+		foundErrors = append(foundErrors, fmt.Sprintf("csv quote_char must be a single character, got [%s]", s.QuoteChar))
+	}
+	if strings.ContainsAny(s.Separator, "\r\n") || strings.Contains(s.Separator, s.GetQuoteChar()) {
+		foundErrors = append(foundErrors, fmt.Sprintf("csv separator [%s] cannot contain line breaks or quote_char", s.Separator))
+	}
+	if strings.ContainsAny(s.CommentPrefix, "\r\n") || (s.CommentPrefix != "" && strings.HasPrefix(s.Separator, s.CommentPrefix)) {
+		foundErrors = append(foundErrors, fmt.Sprintf("csv comment_prefix [%s] cannot contain line breaks or start the separator", s.CommentPrefix))
+	}
+	return foundErrors
+}
@@ -0,0 +1,77 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const dialectReaderConf string = `
+{
+	"urls": ["a.csv"],
+	"csv": {
+		"hdr_line_idx": 0,
+		"first_data_line_idx": 1,
+		"separator": "||",
+		"encoding": "ISO-8859-1",
+		"strip_bom": true,
+		"comment_prefix": "#",
+		"quote_char": "'",
+		"trim_spaces": true
+	},
+	"columns": {
+		"col_a": {
+			"csv": {"col_hdr": "a"},
+			"col_type": "string"
+		}
+	}
+}`
+
+func TestCsvDialect(t *testing.T) {
+	reader := FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(dialectReaderConf)))
+	assert.Equal(t, "||", reader.Csv.Separator)
+	assert.Equal(t, "'", reader.Csv.GetQuoteChar())
+	assert.True(t, reader.Csv.StripBom)
+	assert.True(t, reader.Csv.TrimSpaces)
+	assert.False(t, reader.Csv.IsStandardDialect())
+
+	reader = FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(strings.Replace(strings.Replace(dialectReaderConf, `"||"`, `";"`, 1), `"quote_char": "'",`, "", 1))))
+	assert.Equal(t, DefaultCsvQuoteChar, reader.Csv.GetQuoteChar())
+	assert.True(t, reader.Csv.IsStandardDialect())
+
+	for _, name := range []string{"", "utf-8", "UTF8"} {
+		enc, err := GetTextEncoding(name)
+		assert.Nil(t, err)
+		assert.Nil(t, enc)
+	}
+	for _, name := range []string{"utf-16", "utf-16le", "UTF-16BE", "iso-8859-1", "windows-1252", "latin1"} {
+		enc, err := GetTextEncoding(name)
+		assert.Nil(t, err)
+		assert.NotNil(t, enc)
+	}
+}
+
+func TestCsvDialectFailures(t *testing.T) {
+	reader := FileReaderDef{}
+	err := reader.Deserialize([]byte(strings.Replace(dialectReaderConf, `"ISO-8859-1"`, `"klingon"`, 1)))
+	assert.Contains(t, err.Error(), "invalid csv encoding: unknown encoding klingon")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(dialectReaderConf, `"quote_char": "'"`, `"quote_char": "''"`, 1)))
+	assert.Contains(t, err.Error(), "csv quote_char must be a single character, got ['']")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(dialectReaderConf, `"separator": "||"`, `"separator": "|'|"`, 1)))
+	assert.Contains(t, err.Error(), "csv separator [|'|] cannot contain line breaks or quote_char")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(dialectReaderConf, `"comment_prefix": "#"`, `"comment_prefix": "|"`, 1)))
+	assert.Contains(t, err.Error(), "csv comment_prefix [|] cannot contain line breaks or start the separator")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(dialectReaderConf, `"trim_spaces": true`, `"trim_spaces": true, "split_size_bytes": 1000`, 1)))
+	assert.Contains(t, err.Error(), "csv split_size_bytes cannot be used with ISO-8859-1 encoding")
+}
@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go/ast"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/capillariesio/capillaries/pkg/eval"
 	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/capillariesio/capillaries/pkg/xfer"
 	"github.com/shopspring/decimal"
 )
 
@@ -19,35 +21,81 @@ type CsvReaderColumnSettings struct {
 	SrcColFormat string `json:"col_format,omitempty"` // Optional for all except datetime
 }
 
+// Parquet column: dotted path for nested fields, like customer.address.zip, see file_reader_parquet.go
 type ParquetReaderColumnSettings struct {
-	SrcColName string `json:"col_name"`
+	SrcColName    string              `json:"col_name"`
+	Repeated      ParquetRepeatedMode `json:"repeated,omitempty"`       // Required for list, map and repeated columns
+	JoinSeparator string              `json:"join_separator,omitempty"` // Repeated join only, comma if empty
+	ParsedPath    []string            `json:"-"`
 }
 
 type FileReaderColumnDef struct {
-	DefaultValue string                      `json:"col_default_value,omitempty" yaml:"col_default_value,omitempty"` // Optional. If omitted, zero value is used
-	Type         evalcapi.TableFieldType     `json:"col_type" yaml:"col_type"`
-	Csv          CsvReaderColumnSettings     `json:"csv,omitempty" yaml:"csv,omitempty"`
-	Parquet      ParquetReaderColumnSettings `json:"parquet,omitempty" yaml:"parquet,omitempty"`
+	DefaultValue string                         `json:"col_default_value,omitempty" yaml:"col_default_value,omitempty"` // Optional. If omitted, zero value is used
+	Nullable     bool                           `json:"col_nullable,omitempty" yaml:"col_nullable,omitempty"`           // Empty and missing values are read as null, not as zero value
+	Type         evalcapi.TableFieldType        `json:"col_type" yaml:"col_type"`
+	Csv          CsvReaderColumnSettings        `json:"csv,omitempty" yaml:"csv,omitempty"`
+	Parquet      ParquetReaderColumnSettings    `json:"parquet,omitempty" yaml:"parquet,omitempty"`
+	Jsonl        JsonlReaderColumnSettings      `json:"jsonl,omitempty" yaml:"jsonl,omitempty"`
+	FixedWidth   FixedWidthReaderColumnSettings `json:"fixed_width,omitempty" yaml:"fixed_width,omitempty"`
 }
 
 type CsvReaderSettings struct {
 	SrcFileHdrLineIdx       int                    `json:"hdr_line_idx" yaml:"hdr_line_idx"`
 	SrcFileFirstDataLineIdx int                    `json:"first_data_line_idx,omitempty" yaml:"first_data_line_idx,omitempty"`
-	Separator               string                 `json:"separator,omitempty" yaml:"separator,omitempty"`
+	Separator               string                 `json:"separator,omitempty" yaml:"separator,omitempty"` // Can be longer than one character, like "||"
+	Encoding                string                 `json:"encoding,omitempty" yaml:"encoding,omitempty"`   // utf-8 if empty, see GetTextEncoding
+	StripBom                bool                   `json:"strip_bom,omitempty" yaml:"strip_bom,omitempty"`
+	CommentPrefix           string                 `json:"comment_prefix,omitempty" yaml:"comment_prefix,omitempty"`     // Lines starting with it are skipped and not counted
+	QuoteChar               string                 `json:"quote_char,omitempty" yaml:"quote_char,omitempty"`             // Double quote if empty
+	TrimSpaces              bool                   `json:"trim_spaces,omitempty" yaml:"trim_spaces,omitempty"`           // Trim leading and trailing whitespace of every field
+	Compression             CompressionType        `json:"compression,omitempty" yaml:"compression,omitempty"`           // Detected by file extension if empty, applies to jsonl too
+	SplitSizeBytes          int64                  `json:"split_size_bytes,omitempty" yaml:"split_size_bytes,omitempty"` // Read each file in several batches, one per byte range of this size
 	ColumnIndexingMode      FileColumnIndexingMode `json:"-"`
 }
 
 const (
-	ReaderFileTypeUnknown int = 0
-	ReaderFileTypeCsv     int = 1
-	ReaderFileTypeParquet int = 2
+	ReaderFileTypeUnknown    int = 0
+	ReaderFileTypeCsv        int = 1
+	ReaderFileTypeParquet    int = 2
+	ReaderFileTypeJsonl      int = 3
+	ReaderFileTypeFixedWidth int = 4
 )
 
 type FileReaderDef struct {
-	SrcFileUrls    []string                        `json:"urls" yaml:"urls"`
-	Csv            CsvReaderSettings               `json:"csv,omitempty" yaml:"csv,omitempty"`
-	Columns        map[string]*FileReaderColumnDef `json:"columns" yaml:"columns"` // Keys are names used in table writer
-	ReaderFileType int                             `json:"-"`
+	SrcFileUrls        []string                        `json:"urls" yaml:"urls"`
+	Csv                CsvReaderSettings               `json:"csv,omitempty" yaml:"csv,omitempty"`
+	FixedWidth         FixedWidthReaderSettings        `json:"fixed_width,omitempty" yaml:"fixed_width,omitempty"`
+	Columns            map[string]*FileReaderColumnDef `json:"columns" yaml:"columns"`                               // Keys are names used in table writer
+	Incremental        bool                            `json:"incremental,omitempty" yaml:"incremental,omitempty"`   // Skip files already ingested by this node in earlier runs, see wf_file_ledger
+	MaxBadRows         int64                           `json:"max_bad_rows,omitempty" yaml:"max_bad_rows,omitempty"` // Unparseable rows tolerated per batch, zero means any bad row fails the batch
+	BadRowsUrl         string                          `json:"bad_rows_url,omitempty" yaml:"bad_rows_url,omitempty"` // Optional reject file for bad rows, must contain {batch_idx|string}
+	RawFilter          string                          `json:"filter,omitempty" yaml:"filter,omitempty"`             // Parquet only, also skips row groups by min/max statistics, see file_reader_filter.go
+	Filter             ast.Expr                        `json:"-"`
+	UsedInFilterFields FieldRefs                       `json:"-"`
+	ReaderFileType     int                             `json:"-"`
+}
+
+// Urls with wildcards are expanded at run start, and the resolved list is persisted with run properties
+func (frDef *FileReaderDef) HasUrlPatterns() bool {
+	for _, fileUrl := range frDef.SrcFileUrls {
+		if xfer.IsUrlPattern(fileUrl) {
+			return true
+		}
+	}
+	return false
+}
+
+// CSV, JSONL and fixed-width only: files with different extensions may use different compression
+func (frDef *FileReaderDef) GetCompression(fileUrl string) CompressionType {
+	if frDef.ReaderFileType == ReaderFileTypeFixedWidth {
+		return resolveCompression(frDef.FixedWidth.Compression, fileUrl)
+	}
+	return resolveCompression(frDef.Csv.Compression, fileUrl)
+}
+
+// The list of files to read is decided at run start when urls are patterns, or when already ingested files are skipped
+func (frDef *FileReaderDef) IsResolvedAtRunStart() bool {
+	return frDef.Incremental || frDef.HasUrlPatterns()
 }
 
 func (frDef *FileReaderDef) getFieldRefs() *FieldRefs {
@@ -57,7 +105,8 @@ func (frDef *FileReaderDef) getFieldRefs() *FieldRefs {
 		fieldRefs[i] = FieldRef{
 			TableName: ReaderAlias,
 			FieldName: fieldName,
-			FieldType: colDef.Type}
+			FieldType: colDef.Type,
+			Nullable:  colDef.Nullable}
 		i++
 	}
 	return &fieldRefs
@@ -114,10 +163,30 @@ func (frDef *FileReaderDef) Deserialize(rawReader json.RawMessage) error {
 		foundErrors = append(foundErrors, "no source file urls specified, need at least one")
 	}
 
+	foundErrors = append(foundErrors, frDef.checkBadRows()...)
+
+	for colName, colDef := range frDef.Columns {
+		if colDef.Nullable && len(colDef.DefaultValue) > 0 {
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s cannot have both col_nullable and col_default_value", colName))
+		}
+	}
+
 	frDef.ReaderFileType = ReaderFileTypeUnknown
 	for _, colDef := range frDef.Columns {
 		if colDef.Parquet.SrcColName != "" {
 			frDef.ReaderFileType = ReaderFileTypeParquet
+			foundErrors = append(foundErrors, frDef.parseParquetColumns()...)
+			break
+		} else if colDef.Jsonl.SrcPath != "" {
+			frDef.ReaderFileType = ReaderFileTypeJsonl
+			foundErrors = append(foundErrors, frDef.parseJsonlColumns()...)
+			break
+		} else if colDef.FixedWidth.SrcColLength != 0 {
+			frDef.ReaderFileType = ReaderFileTypeFixedWidth
+			foundErrors = append(foundErrors, frDef.parseFixedWidthColumns()...)
+			if err := checkCompression(frDef.FixedWidth.Compression); err != nil {
+				foundErrors = append(foundErrors, err.Error())
+			}
 			break
 		} else if (colDef.Csv.SrcColHeader != "" || colDef.Csv.SrcColIdx > 0) ||
 			len(frDef.Columns) == 1 { // Special CSV case: no headers, only one column
@@ -134,12 +203,20 @@ func (frDef *FileReaderDef) Deserialize(rawReader json.RawMessage) error {
 			if len(frDef.Csv.Separator) == 0 {
 				frDef.Csv.Separator = ","
 			}
+
+			if err := checkCompression(frDef.Csv.Compression); err != nil {
+				foundErrors = append(foundErrors, err.Error())
+			}
+			foundErrors = append(foundErrors, frDef.checkCsvDialect()...)
+			foundErrors = append(foundErrors, frDef.checkCsvSplit()...)
 			break
 		}
 	}
 
+	foundErrors = append(foundErrors, frDef.parseFilter()...)
+
 	if frDef.ReaderFileType == ReaderFileTypeUnknown {
-		foundErrors = append(foundErrors, "cannot detect file reader type: parquet should have col_name, csv should have col_hdr or col_idx etc")
+		foundErrors = append(foundErrors, "cannot detect file reader type: parquet should have col_name, csv should have col_hdr or col_idx, jsonl should have path, fixed_width should have length etc")
 	}
 
 	if len(foundErrors) > 0 {
@@ -164,9 +241,9 @@ func (frDef *FileReaderDef) ResolveCsvColumnIndexesFromNames(srcHdrLine []string
 	return nil
 }
 
-func toString(colName string, colData string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
-	if len(colDef.Csv.SrcColFormat) > 0 {
-		return fmt.Errorf("cannot read string column %s, data '%s': format '%s' was specified, but string fields do not accept format specifier, remove this setting", colName, colData, colDef.Csv.SrcColFormat)
+func toString(colName string, colData string, colFormat string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
+	if len(colFormat) > 0 {
+		return fmt.Errorf("cannot read string column %s, data '%s': format '%s' was specified, but string fields do not accept format specifier, remove this setting", colName, colData, colFormat)
 	}
 	if len(colData) == 0 {
 		if len(colDef.DefaultValue) > 0 {
@@ -180,9 +257,9 @@ func toString(colName string, colData string, colDef *FileReaderColumnDef, colVa
 	return nil
 }
 
-func toBool(colName string, colData string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
-	if len(colDef.Csv.SrcColFormat) > 0 {
-		return fmt.Errorf("cannot read bool column %s, data '%s': format '%s' was specified, but bool fields do not accept format specifier, remove this setting", colName, colData, colDef.Csv.SrcColFormat)
+func toBool(colName string, colData string, colFormat string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
+	if len(colFormat) > 0 {
+		return fmt.Errorf("cannot read bool column %s, data '%s': format '%s' was specified, but bool fields do not accept format specifier, remove this setting", colName, colData, colFormat)
 	}
 
 	var err error
@@ -204,7 +281,7 @@ func toBool(colName string, colData string, colDef *FileReaderColumnDef, colVars
 	return nil
 }
 
-func toInt(colName string, colData string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
+func toInt(colName string, colData string, colFormat string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
 	if len(strings.TrimSpace(colData)) == 0 {
 		if len(strings.TrimSpace(colDef.DefaultValue)) > 0 {
 			valInt, err := strconv.ParseInt(colDef.DefaultValue, 10, 64)
@@ -216,11 +293,11 @@ func toInt(colName string, colData string, colDef *FileReaderColumnDef, colVars
 			colVars[ReaderAlias][colName] = GetDefaultFieldTypeValue(evalcapi.FieldTypeInt)
 		}
 	} else {
-		if len(colDef.Csv.SrcColFormat) > 0 {
+		if len(colFormat) > 0 {
 			var valInt int64
-			_, err := fmt.Sscanf(colData, colDef.Csv.SrcColFormat, &valInt)
+			_, err := fmt.Sscanf(colData, colFormat, &valInt)
 			if err != nil {
-				return fmt.Errorf("cannot read int64 column %s, data '%s', format '%s': %s", colName, colData, colDef.Csv.SrcColFormat, err.Error())
+				return fmt.Errorf("cannot read int64 column %s, data '%s', format '%s': %s", colName, colData, colFormat, err.Error())
 			}
 			colVars[ReaderAlias][colName] = valInt
 		} else {
@@ -234,10 +311,10 @@ func toInt(colName string, colData string, colDef *FileReaderColumnDef, colVars
 	return nil
 }
 
-func toDateTime(colName string, colData string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
+func toDateTime(colName string, colData string, colFormat string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
 	if len(strings.TrimSpace(colData)) == 0 {
 		if len(strings.TrimSpace(colDef.DefaultValue)) > 0 {
-			valTime, err := time.Parse(colDef.Csv.SrcColFormat, colDef.DefaultValue)
+			valTime, err := time.Parse(colFormat, colDef.DefaultValue)
 			if err != nil {
 				return fmt.Errorf("cannot read time column %s from default value string '%s': %s", colName, colDef.DefaultValue, err.Error())
 			}
@@ -246,20 +323,20 @@ func toDateTime(colName string, colData string, colDef *FileReaderColumnDef, col
 			colVars[ReaderAlias][colName] = GetDefaultFieldTypeValue(evalcapi.FieldTypeDateTime)
 		}
 	} else {
-		if len(colDef.Csv.SrcColFormat) == 0 {
+		if len(colFormat) == 0 {
 			return fmt.Errorf("cannot read datetime column %s, data '%s': column format is missing, consider specifying something like 2006-01-02T15:04:05.000-0700, see go datetime format documentation for details", colName, colData)
 		}
 
-		valTime, err := time.Parse(colDef.Csv.SrcColFormat, colData)
+		valTime, err := time.Parse(colFormat, colData)
 		if err != nil {
-			return fmt.Errorf("cannot read datetime column %s, data '%s', format '%s': %s", colName, colData, colDef.Csv.SrcColFormat, err.Error())
+			return fmt.Errorf("cannot read datetime column %s, data '%s', format '%s': %s", colName, colData, colFormat, err.Error())
 		}
 		colVars[ReaderAlias][colName] = valTime
 	}
 	return nil
 }
 
-func toFloat(colName string, colData string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
+func toFloat(colName string, colData string, colFormat string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
 	if len(strings.TrimSpace(colData)) == 0 {
 		if len(strings.TrimSpace(colDef.DefaultValue)) > 0 {
 			valFloat, err := strconv.ParseFloat(colDef.DefaultValue, 64)
@@ -271,11 +348,11 @@ func toFloat(colName string, colData string, colDef *FileReaderColumnDef, colVar
 			colVars[ReaderAlias][colName] = GetDefaultFieldTypeValue(evalcapi.FieldTypeFloat)
 		}
 	} else {
-		if len(colDef.Csv.SrcColFormat) > 0 {
+		if len(colFormat) > 0 {
 			var valFloat float64
-			_, err := fmt.Sscanf(colData, colDef.Csv.SrcColFormat, &valFloat)
+			_, err := fmt.Sscanf(colData, colFormat, &valFloat)
 			if err != nil {
-				return fmt.Errorf("cannot read float64 column %s, data '%s', format '%s': %s", colName, colData, colDef.Csv.SrcColFormat, err.Error())
+				return fmt.Errorf("cannot read float64 column %s, data '%s', format '%s': %s", colName, colData, colFormat, err.Error())
 			}
 			colVars[ReaderAlias][colName] = valFloat
 		} else {
@@ -289,69 +366,95 @@ func toFloat(colName string, colData string, colDef *FileReaderColumnDef, colVar
 	return nil
 }
 
-func toDecimal2(colName string, colData string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
-	// Round to 2 digits after decimal point right away
+// Handles decimal2 and decimal(p,s)
+func toDecimal(colName string, colData string, colFormat string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
+	// Round to 2 (or s) digits after decimal point right away
+	scale := evalcapi.DecimalFieldTypeScale(colDef.Type)
 	if len(strings.TrimSpace(colData)) == 0 {
 		if len(strings.TrimSpace(colDef.DefaultValue)) > 0 {
 			valDec, err := decimal.NewFromString(colDef.DefaultValue)
 			if err != nil {
-				return fmt.Errorf("cannot read decimal2 column %s from default value string '%s': %s", colName, colDef.DefaultValue, err.Error())
+				return fmt.Errorf("cannot read %s column %s from default value string '%s': %s", colDef.Type, colName, colDef.DefaultValue, err.Error())
 			}
-			colVars[ReaderAlias][colName] = valDec.Round(2)
+			colVars[ReaderAlias][colName] = valDec.Round(scale)
 		} else {
 			colVars[ReaderAlias][colName] = GetDefaultFieldTypeValue(evalcapi.FieldTypeDecimal2)
 		}
 	} else {
-		var valFloat float64
-		if len(colDef.Csv.SrcColFormat) > 0 {
-			// Decimal type does not support sscanf, so sscanf string first
-			_, err := fmt.Sscanf(colData, colDef.Csv.SrcColFormat, &valFloat)
+		if len(colFormat) > 0 {
+			// Decimal type does not support sscanf, so sscanf string first.
+			// %f goes through float64, use %s (or no format) to keep all digits of a decimal(p,s) value
+			var valDec decimal.Decimal
+			var err error
+			if strings.Contains(colFormat, "%s") {
+				var valString string
+				if _, err = fmt.Sscanf(colData, colFormat, &valString); err == nil {
+					valDec, err = decimal.NewFromString(valString)
+				}
+			} else {
+				var valFloat float64
+				if _, err = fmt.Sscanf(colData, colFormat, &valFloat); err == nil {
+					valDec = decimal.NewFromFloat(valFloat)
+				}
+			}
 			if err != nil {
-				return fmt.Errorf("cannot read decimal2 column %s, data '%s', format '%s': %s", colName, colData, colDef.Csv.SrcColFormat, err.Error())
+				return fmt.Errorf("cannot read %s column %s, data '%s', format '%s': %s", colDef.Type, colName, colData, colFormat, err.Error())
 			}
-			colVars[ReaderAlias][colName] = decimal.NewFromFloat(valFloat).Round(2)
+			colVars[ReaderAlias][colName] = valDec.Round(scale)
 		} else {
 			valDec, err := decimal.NewFromString(colData)
 			if err != nil {
-				return fmt.Errorf("cannot read decimal2 column %s, cannot parse data '%s': %s", colName, colData, err.Error())
+				return fmt.Errorf("cannot read %s column %s, cannot parse data '%s': %s", colDef.Type, colName, colData, err.Error())
 			}
-			colVars[ReaderAlias][colName] = valDec.Round(2)
+			colVars[ReaderAlias][colName] = valDec.Round(scale)
 		}
 	}
 	return nil
 }
 
+// Text-based readers (csv, fixed-width) share the same col_format parsing
+func readColumnValue(colName string, colData string, colFormat string, colDef *FileReaderColumnDef, colVars eval.VarValuesMap) error {
+	// Text formats cannot tell an empty value from a missing one. Whitespace is a valid string value though.
+	if colDef.Nullable && (len(colData) == 0 || colDef.Type != evalcapi.FieldTypeString && len(strings.TrimSpace(colData)) == 0) {
+		colVars[ReaderAlias][colName] = nil
+		return nil
+	}
+	switch evalcapi.FieldTypeKind(colDef.Type) {
+	case evalcapi.FieldTypeString:
+		if err := toString(colName, colData, colFormat, colDef, colVars); err != nil {
+			return err
+		}
+	case evalcapi.FieldTypeBool:
+		if err := toBool(colName, colData, colFormat, colDef, colVars); err != nil {
+			return err
+		}
+	case evalcapi.FieldTypeInt:
+		if err := toInt(colName, colData, colFormat, colDef, colVars); err != nil {
+			return err
+		}
+	case evalcapi.FieldTypeDateTime:
+		if err := toDateTime(colName, colData, colFormat, colDef, colVars); err != nil {
+			return err
+		}
+	case evalcapi.FieldTypeFloat:
+		if err := toFloat(colName, colData, colFormat, colDef, colVars); err != nil {
+			return err
+		}
+	case evalcapi.FieldTypeDecimal2:
+		if err := toDecimal(colName, colData, colFormat, colDef, colVars); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot read column %s, data '%s': unsupported column type '%s'", colName, colData, colDef.Type)
+	}
+	return nil
+}
+
 func (frDef *FileReaderDef) ReadCsvLineToValuesMap(line *[]string, colVars eval.VarValuesMap) error {
 	colVars[ReaderAlias] = map[string]any{}
 	for colName, colDef := range frDef.Columns {
-		colData := (*line)[colDef.Csv.SrcColIdx]
-		switch colDef.Type {
-		case evalcapi.FieldTypeString:
-			if err := toString(colName, colData, colDef, colVars); err != nil {
-				return err
-			}
-		case evalcapi.FieldTypeBool:
-			if err := toBool(colName, colData, colDef, colVars); err != nil {
-				return err
-			}
-		case evalcapi.FieldTypeInt:
-			if err := toInt(colName, colData, colDef, colVars); err != nil {
-				return err
-			}
-		case evalcapi.FieldTypeDateTime:
-			if err := toDateTime(colName, colData, colDef, colVars); err != nil {
-				return err
-			}
-		case evalcapi.FieldTypeFloat:
-			if err := toFloat(colName, colData, colDef, colVars); err != nil {
-				return err
-			}
-		case evalcapi.FieldTypeDecimal2:
-			if err := toDecimal2(colName, colData, colDef, colVars); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("cannot read column %s, data '%s': unsupported column type '%s'", colName, colData, colDef.Type)
+		if err := readColumnValue(colName, (*line)[colDef.Csv.SrcColIdx], colDef.Csv.SrcColFormat, colDef, colVars); err != nil {
+			return err
 		}
 	}
 	return nil
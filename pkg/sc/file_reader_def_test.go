@@ -327,6 +327,54 @@ func TestReadString(t *testing.T) {
 	assertErrorPrefix(t, "cannot read string column col_1, data 'data_str': format 'some_format' was specified, but string fields do not accept format specifier, remove this setting", err.Error())
 }
 
+func TestReadNullable(t *testing.T) {
+	confTemplate := `
+	{
+		"urls": [""],
+		"csv":{
+			"hdr_line_idx": 0,
+			"first_data_line_idx": 1
+		},
+		"columns":  {
+			"col_1": {
+				"csv":{
+					"col_idx": 1
+				},
+				%s
+				"col_nullable": true,
+				"col_type": "%s"
+			}
+		}
+	}`
+
+	srcLineEmpty := []string{"", "", ""}
+	srcLineSpace := []string{"", " ", ""}
+
+	colRecord, err := testReader(fmt.Sprintf(confTemplate, ``, "int"), srcLineEmpty)
+	assert.Nil(t, err)
+	assert.Nil(t, colRecord[ReaderAlias]["col_1"])
+
+	colRecord, err = testReader(fmt.Sprintf(confTemplate, ``, "int"), srcLineSpace)
+	assert.Nil(t, err)
+	assert.Nil(t, colRecord[ReaderAlias]["col_1"])
+
+	colRecord, err = testReader(fmt.Sprintf(confTemplate, ``, "int"), []string{"", "12", ""})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), colRecord[ReaderAlias]["col_1"])
+
+	colRecord, err = testReader(fmt.Sprintf(confTemplate, ``, "string"), srcLineEmpty)
+	assert.Nil(t, err)
+	assert.Nil(t, colRecord[ReaderAlias]["col_1"])
+
+	// Whitespace is a valid string
+	colRecord, err = testReader(fmt.Sprintf(confTemplate, ``, "string"), srcLineSpace)
+	assert.Nil(t, err)
+	assert.Equal(t, " ", colRecord[ReaderAlias]["col_1"])
+
+	_, err = testReader(fmt.Sprintf(confTemplate, `"col_default_value":"5",`, "int"), srcLineEmpty)
+	assert.Contains(t, err.Error(), "column col_1 cannot have both col_nullable and col_default_value")
+}
+
 func TestReadDatetime(t *testing.T) {
 	confTemplate := `
 	{
@@ -555,6 +603,47 @@ func TestReadDecimal(t *testing.T) {
 	_, err = testReader(confNoFormatBadDefault, srcLineComplexFormat)
 	assertErrorPrefix(t, `cannot read decimal2 column col_1, cannot parse data 'value(12.34)': can't convert value(12.34) to decimal: exponent is not numeric`, err.Error())
 }
+
+func TestReadDecimalPrecisionScale(t *testing.T) {
+	confTemplate := `
+	{
+		"urls": [""],
+		"csv":{
+			"hdr_line_idx": 0,
+			"first_data_line_idx": 1
+		},
+		"columns":  {
+			"col_1": {
+				"csv":{
+					%s
+					"col_idx": 1
+				},
+				"col_default_value":"1.23456789",
+				"col_type": "decimal(28,6)"
+			}
+		}
+	}`
+
+	// No float64 roundtrip: all 28 digits survive
+	colRecord, err := testReader(fmt.Sprintf(confTemplate, ``), []string{"", "1234567890123456789012.1234564", ""})
+	assert.Nil(t, err)
+	assert.Equal(t, "1234567890123456789012.123456", colRecord[ReaderAlias]["col_1"].(decimal.Decimal).StringFixed(6))
+
+	colRecord, err = testReader(fmt.Sprintf(confTemplate, `"col_format": "%s USD",`), []string{"", "1234567890123456789012.1234565 USD", ""})
+	assert.Nil(t, err)
+	assert.Equal(t, "1234567890123456789012.123457", colRecord[ReaderAlias]["col_1"].(decimal.Decimal).StringFixed(6))
+
+	colRecord, err = testReader(fmt.Sprintf(confTemplate, `"col_format": "%f",`), []string{"", "0.1234567", ""})
+	assert.Nil(t, err)
+	assert.Equal(t, decimal.RequireFromString("0.123457"), colRecord[ReaderAlias]["col_1"])
+
+	colRecord, err = testReader(fmt.Sprintf(confTemplate, ``), []string{"", "", ""})
+	assert.Nil(t, err)
+	assert.Equal(t, decimal.RequireFromString("1.234568"), colRecord[ReaderAlias]["col_1"])
+
+	_, err = testReader(fmt.Sprintf(confTemplate, `"col_format": "%s USD",`), []string{"", "abc USD", ""})
+	assertErrorPrefix(t, "cannot read decimal(28,6) column col_1, data 'abc USD', format '%s USD': can't convert abc to decimal", err.Error())
+}
 func TestReadBool(t *testing.T) {
 
 	confTemplate := `
@@ -628,3 +717,56 @@ func TestReadBool(t *testing.T) {
 	_, err = testReader(confWithFormat, srcLineTrue)
 	assertErrorPrefix(t, `cannot read bool column col_1, data 'True': format 'some_format' was specified, but bool fields do not accept format specifier, remove this setting`, err.Error())
 }
+
+func TestUrlPatterns(t *testing.T) {
+	reader := FileReaderDef{SrcFileUrls: []string{"/tmp/orders.csv", "https://example.com/orders.csv?v=1"}}
+	assert.False(t, reader.HasUrlPatterns())
+
+	reader.SrcFileUrls = []string{"/tmp/orders.csv", "s3://bucket/orders/*.csv"}
+	assert.True(t, reader.HasUrlPatterns())
+	assert.True(t, reader.IsResolvedAtRunStart())
+
+	incrementalReader := FileReaderDef{SrcFileUrls: []string{"/tmp/orders.csv"}, Incremental: true}
+	assert.False(t, incrementalReader.HasUrlPatterns())
+	assert.True(t, incrementalReader.IsResolvedAtRunStart())
+
+	node := &ScriptNodeDef{Name: "read_orders", FileReader: reader}
+	resolvedNode := node.WithResolvedFileUrls([]string{"/tmp/orders.csv", "s3://bucket/orders/1.csv"})
+	assert.Equal(t, []string{"/tmp/orders.csv", "s3://bucket/orders/1.csv"}, resolvedNode.FileReader.SrcFileUrls)
+	assert.False(t, resolvedNode.FileReader.HasUrlPatterns())
+	// Cached node is not touched
+	assert.Equal(t, []string{"/tmp/orders.csv", "s3://bucket/orders/*.csv"}, node.FileReader.SrcFileUrls)
+}
+
+func TestReaderCompression(t *testing.T) {
+	conf := `
+	{
+		"urls": ["orders.csv.gz"],
+		"csv":{
+			"hdr_line_idx": 0,
+			"first_data_line_idx": 1
+		},
+		"columns":  {
+			"col_order_id": {
+				"csv":{
+					"col_idx": 0
+				},
+				"col_type": "string"
+			}
+		}
+	}`
+	reader := FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(conf)))
+	assert.Equal(t, CompressionGzip, reader.GetCompression("orders.csv.gz"))
+	assert.Equal(t, CompressionGzip, reader.GetCompression("https://example.com/orders.csv.gz?v=1"))
+	assert.Equal(t, CompressionZstd, reader.GetCompression("s3://bucket/orders.csv.ZST"))
+	assert.Equal(t, CompressionBzip2, reader.GetCompression("sftp://user@host/orders.csv.bz2"))
+	assert.Equal(t, CompressionNone, reader.GetCompression("orders.csv"))
+
+	reader = FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(strings.Replace(conf, `"hdr_line_idx": 0,`, `"hdr_line_idx": 0, "compression": "none",`, 1))))
+	assert.Equal(t, CompressionNone, reader.GetCompression("orders.csv.gz"))
+
+	reader = FileReaderDef{}
+	assert.Contains(t, reader.Deserialize([]byte(strings.Replace(conf, `"hdr_line_idx": 0,`, `"hdr_line_idx": 0, "compression": "zip",`, 1))).Error(), "invalid compression zip")
+}
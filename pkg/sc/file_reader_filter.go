@@ -0,0 +1,346 @@
+package sc
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/shopspring/decimal"
+)
+
+// Reader filter is checked against each source row, and, before a row group is read, against row group min/max statistics.
+// Only Parquet files have statistics, so only Parquet reader supports it.
+
+// Row group statistics of a reader column, converted to the column type. Nil Min or Max: no statistics.
+type ReaderColumnStats struct {
+	Min          any
+	Max          any
+	MayHaveNulls bool // Nulls are read as default values, so the default value is in the range too
+}
+
+func (frDef *FileReaderDef) UsesFilter() bool {
+	return len(strings.TrimSpace(frDef.RawFilter)) > 0
+}
+
+func (frDef *FileReaderDef) parseFilter() []string {
+	if !frDef.UsesFilter() || frDef.ReaderFileType == ReaderFileTypeUnknown {
+		return []string{}
+	}
+	if frDef.ReaderFileType != ReaderFileTypeParquet {
+		return []string{"reader filter is supported by parquet reader only"}
+	}
+	var err error
+	frDef.Filter, err = ParseRawGolangExpressionStringAndHarvestFieldRefs(frDef.RawFilter, &frDef.UsedInFilterFields)
+	if err != nil {
+		return []string{fmt.Sprintf("cannot parse reader filter condition [%s]: %s", frDef.RawFilter, err.Error())}
+	}
+	if err := checkAllowed(&frDef.UsedInFilterFields, nil, frDef.getFieldRefs()); err != nil {
+		return []string{fmt.Sprintf("invalid field in reader filter condition [%s]: %s", frDef.RawFilter, err.Error())}
+	}
+	if err := evalExpressionWithFieldRefsAndCheckType(frDef.Filter, frDef.UsedInFilterFields, evalcapi.FieldTypeBool); err != nil {
+		return []string{fmt.Sprintf("cannot evaluate reader filter condition [%s]: %s", frDef.RawFilter, err.Error())}
+	}
+	return []string{}
+}
+
+func (frDef *FileReaderDef) CheckFilterCondition(colVars eval.VarValuesMap) (bool, error) {
+	if !frDef.UsesFilter() {
+		return true, nil
+	}
+	eCtx := eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, colVars)
+	valVolatile, err := eCtx.Eval(frDef.Filter)
+	if err != nil {
+		return false, fmt.Errorf("cannot evaluate expression: [%s]", err.Error())
+	}
+	if valVolatile == nil {
+		return false, nil
+	}
+	valBool, ok := valVolatile.(bool)
+	if !ok {
+		return false, fmt.Errorf("cannot evaluate reader filter condition expression, expected bool, got %v(%T) instead", valVolatile, valVolatile)
+	}
+	return valBool, nil
+}
+
+type filterTruth int
+
+const (
+	filterFalse filterTruth = iota
+	filterMaybe
+	filterTrue
+)
+
+// False only if no row described by stats (keys are reader column names) can satisfy the filter.
+// Comparisons of a column with a constant expression, &&, || and ! are checked, everything else may match.
+func (frDef *FileReaderDef) FilterMayMatch(stats map[string]*ReaderColumnStats) bool {
+	if !frDef.UsesFilter() {
+		return true
+	}
+	return frDef.evalFilterTruth(frDef.Filter, stats) != filterFalse
+}
+
+func (frDef *FileReaderDef) evalFilterTruth(exp ast.Expr, stats map[string]*ReaderColumnStats) filterTruth {
+	switch assertedExp := exp.(type) {
+	case *ast.ParenExpr:
+		return frDef.evalFilterTruth(assertedExp.X, stats)
+
+	case *ast.UnaryExpr:
+		if assertedExp.Op != token.NOT {
+			return filterMaybe
+		}
+		return filterTrue - frDef.evalFilterTruth(assertedExp.X, stats)
+
+	case *ast.SelectorExpr:
+		// Bool column
+		minVal, maxVal, ok := frDef.statsRange(assertedExp, stats)
+		if !ok {
+			return filterMaybe
+		}
+		minBool, minOk := minVal.(bool)
+		maxBool, maxOk := maxVal.(bool)
+		if !minOk || !maxOk {
+			return filterMaybe
+		}
+		if !maxBool {
+			return filterFalse
+		}
+		if minBool {
+			return filterTrue
+		}
+		return filterMaybe
+
+	case *ast.BinaryExpr:
+		switch assertedExp.Op {
+		case token.LAND:
+			return min(frDef.evalFilterTruth(assertedExp.X, stats), frDef.evalFilterTruth(assertedExp.Y, stats))
+		case token.LOR:
+			return max(frDef.evalFilterTruth(assertedExp.X, stats), frDef.evalFilterTruth(assertedExp.Y, stats))
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return frDef.evalCompareTruth(assertedExp, stats)
+		default:
+			return filterMaybe
+		}
+
+	default:
+		return filterMaybe
+	}
+}
+
+// Column range with nulls (read as defaults) accounted for
+func (frDef *FileReaderDef) statsRange(exp ast.Expr, stats map[string]*ReaderColumnStats) (any, any, bool) {
+	selExp, ok := exp.(*ast.SelectorExpr)
+	if !ok {
+		return nil, nil, false
+	}
+	identExp, ok := selExp.X.(*ast.Ident)
+	if !ok || identExp.Name != ReaderAlias {
+		return nil, nil, false
+	}
+	colStats, ok := stats[selExp.Sel.Name]
+	if !ok || colStats == nil || colStats.Min == nil || colStats.Max == nil {
+		return nil, nil, false
+	}
+	minVal, maxVal := colStats.Min, colStats.Max
+	if colStats.MayHaveNulls {
+		defaultVal := defaultValueOfSameType(minVal)
+		if defaultVal == nil {
+			return nil, nil, false
+		}
+		if cmpDefault, ok := compareFilterValues(defaultVal, minVal); !ok {
+			return nil, nil, false
+		} else if cmpDefault < 0 {
+			minVal = defaultVal
+		}
+		if cmpDefault, ok := compareFilterValues(defaultVal, maxVal); !ok {
+			return nil, nil, false
+		} else if cmpDefault > 0 {
+			maxVal = defaultVal
+		}
+	}
+	return minVal, maxVal, true
+}
+
+func (frDef *FileReaderDef) evalCompareTruth(exp *ast.BinaryExpr, stats map[string]*ReaderColumnStats) filterTruth {
+	op := exp.Op
+	minVal, maxVal, ok := frDef.statsRange(exp.X, stats)
+	constExp := exp.Y
+	if !ok {
+		// Constant on the left: 10 < r.col is r.col > 10
+		if minVal, maxVal, ok = frDef.statsRange(exp.Y, stats); !ok {
+			return filterMaybe
+		}
+		constExp = exp.X
+		switch op {
+		case token.LSS:
+			op = token.GTR
+		case token.LEQ:
+			op = token.GEQ
+		case token.GTR:
+			op = token.LSS
+		case token.GEQ:
+			op = token.LEQ
+		}
+	}
+
+	// The other side must not depend on row values
+	constFieldRefs := FieldRefs{}
+	if err := harvestFieldRefsFromParsedExpression(constExp, &constFieldRefs, FieldRefStrict); err != nil || len(constFieldRefs) > 0 {
+		return filterMaybe
+	}
+	constVal, err := eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, eval.VarValuesMap{}).Eval(constExp)
+	if err != nil {
+		return filterMaybe
+	}
+
+	cmpMin, okMin := compareFilterValues(minVal, constVal)
+	cmpMax, okMax := compareFilterValues(maxVal, constVal)
+	if !okMin || !okMax {
+		return filterMaybe
+	}
+
+	switch op {
+	case token.EQL:
+		if cmpMin > 0 || cmpMax < 0 {
+			return filterFalse
+		}
+		if cmpMin == 0 && cmpMax == 0 {
+			return filterTrue
+		}
+	case token.NEQ:
+		if cmpMin == 0 && cmpMax == 0 {
+			return filterFalse
+		}
+		if cmpMin > 0 || cmpMax < 0 {
+			return filterTrue
+		}
+	case token.LSS:
+		if cmpMin >= 0 {
+			return filterFalse
+		}
+		if cmpMax < 0 {
+			return filterTrue
+		}
+	case token.LEQ:
+		if cmpMin > 0 {
+			return filterFalse
+		}
+		if cmpMax <= 0 {
+			return filterTrue
+		}
+	case token.GTR:
+		if cmpMax <= 0 {
+			return filterFalse
+		}
+		if cmpMin > 0 {
+			return filterTrue
+		}
+	case token.GEQ:
+		if cmpMax < 0 {
+			return filterFalse
+		}
+		if cmpMin >= 0 {
+			return filterTrue
+		}
+	}
+	return filterMaybe
+}
+
+func defaultValueOfSameType(val any) any {
+	switch val.(type) {
+	case int64:
+		return GetDefaultFieldTypeValue(evalcapi.FieldTypeInt)
+	case float64:
+		return GetDefaultFieldTypeValue(evalcapi.FieldTypeFloat)
+	case string:
+		return GetDefaultFieldTypeValue(evalcapi.FieldTypeString)
+	case decimal.Decimal:
+		return GetDefaultFieldTypeValue(evalcapi.FieldTypeDecimal2)
+	case bool:
+		return GetDefaultFieldTypeValue(evalcapi.FieldTypeBool)
+	case time.Time:
+		return GetDefaultFieldTypeValue(evalcapi.FieldTypeDateTime)
+	default:
+		return nil
+	}
+}
+
+// Numbers of different types are compared the way eval does it: int < float < decimal. Not comparable: false.
+func compareFilterValues(left any, right any) (int, bool) {
+	switch typedLeft := left.(type) {
+	case string:
+		if typedRight, ok := right.(string); ok {
+			return strings.Compare(typedLeft, typedRight), true
+		}
+		return 0, false
+	case bool:
+		if typedRight, ok := right.(bool); ok {
+			if typedLeft == typedRight {
+				return 0, true
+			} else if typedRight {
+				return -1, true
+			}
+			return 1, true
+		}
+		return 0, false
+	case time.Time:
+		if typedRight, ok := right.(time.Time); ok {
+			return typedLeft.Compare(typedRight), true
+		}
+		return 0, false
+	}
+
+	switch typedLeft := left.(type) {
+	case int64:
+		switch typedRight := right.(type) {
+		case int64:
+			return cmp.Compare(typedLeft, typedRight), true
+		case float64:
+			return compareFloats(float64(typedLeft), typedRight)
+		case decimal.Decimal:
+			return decimal.NewFromInt(typedLeft).Cmp(typedRight), true
+		}
+	case float64:
+		switch typedRight := right.(type) {
+		case int64:
+			return compareFloats(typedLeft, float64(typedRight))
+		case float64:
+			return compareFloats(typedLeft, typedRight)
+		case decimal.Decimal:
+			if math.IsNaN(typedLeft) || math.IsInf(typedLeft, 0) {
+				return 0, false
+			}
+			return decimal.NewFromFloat(typedLeft).Cmp(typedRight), true
+		}
+	case decimal.Decimal:
+		switch typedRight := right.(type) {
+		case int64:
+			return typedLeft.Cmp(decimal.NewFromInt(typedRight)), true
+		case float64:
+			if math.IsNaN(typedRight) || math.IsInf(typedRight, 0) {
+				return 0, false
+			}
+			return typedLeft.Cmp(decimal.NewFromFloat(typedRight)), true
+		case decimal.Decimal:
+			return typedLeft.Cmp(typedRight), true
+		}
+	}
+	return 0, false
+}
+
+func compareFloats(left float64, right float64) (int, bool) {
+	if math.IsNaN(left) || math.IsNaN(right) {
+		return 0, false
+	}
+	if left < right {
+		return -1, true
+	} else if left > right {
+		return 1, true
+	}
+	return 0, true
+}
@@ -0,0 +1,133 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const parquetFilterReaderConf string = `
+{
+	"urls": ["loans.parquet"],
+	"filter": "{filter}",
+	"columns": {
+		"loan_amount": {
+			"parquet": {"col_name": "loan_amount"},
+			"col_type": "int"
+		},
+		"state": {
+			"parquet": {"col_name": "state"},
+			"col_type": "string"
+		},
+		"rate": {
+			"parquet": {"col_name": "rate"},
+			"col_type": "decimal2"
+		},
+		"origination_date": {
+			"parquet": {"col_name": "origination_date"},
+			"col_type": "datetime"
+		},
+		"is_first_time": {
+			"parquet": {"col_name": "is_first_time"},
+			"col_type": "bool"
+		}
+	}
+}`
+
+func newFilterReader(t *testing.T, filter string) *FileReaderDef {
+	reader := FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(strings.Replace(parquetFilterReaderConf, "{filter}", strings.ReplaceAll(filter, `"`, `\"`), 1))))
+	return &reader
+}
+
+func TestReaderFilter(t *testing.T) {
+	reader := newFilterReader(t, `r.loan_amount >= 100000 && r.state == "CA"`)
+	assert.True(t, reader.UsesFilter())
+	assert.Equal(t, 2, len(reader.UsedInFilterFields))
+
+	inFilter, err := reader.CheckFilterCondition(eval.VarValuesMap{"r": {"loan_amount": int64(150000), "state": "CA"}})
+	assert.Nil(t, err)
+	assert.True(t, inFilter)
+
+	inFilter, err = reader.CheckFilterCondition(eval.VarValuesMap{"r": {"loan_amount": int64(150000), "state": "NY"}})
+	assert.Nil(t, err)
+	assert.False(t, inFilter)
+
+	reader = newFilterReader(t, ``)
+	assert.False(t, reader.UsesFilter())
+	inFilter, err = reader.CheckFilterCondition(eval.VarValuesMap{})
+	assert.Nil(t, err)
+	assert.True(t, inFilter)
+}
+
+func TestReaderFilterFailures(t *testing.T) {
+	reader := FileReaderDef{}
+	err := reader.Deserialize([]byte(`{"urls": ["a.csv"], "filter": "r.col_a > 1", "columns": {"col_a": {"csv": {"col_idx": 0}, "col_type": "int"}}}`))
+	assert.Contains(t, err.Error(), "reader filter is supported by parquet reader only")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(parquetFilterReaderConf, "{filter}", "r.zip > 1", 1)))
+	assert.Contains(t, err.Error(), "invalid field in reader filter condition [r.zip > 1]")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(parquetFilterReaderConf, "{filter}", "r.loan_amount + 1", 1)))
+	assert.Contains(t, err.Error(), "cannot evaluate reader filter condition [r.loan_amount + 1]")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(parquetFilterReaderConf, "{filter}", "r.loan_amount >", 1)))
+	assert.Contains(t, err.Error(), "cannot parse reader filter condition [r.loan_amount >]")
+}
+
+func TestReaderFilterMayMatch(t *testing.T) {
+	loanStats := func(minAmount int64, maxAmount int64, mayHaveNulls bool) map[string]*ReaderColumnStats {
+		return map[string]*ReaderColumnStats{
+			"loan_amount": {Min: minAmount, Max: maxAmount, MayHaveNulls: mayHaveNulls},
+			"state":       {Min: "AK", Max: "CO"}}
+	}
+
+	reader := newFilterReader(t, `r.loan_amount >= 100000 && r.state == "CA"`)
+	assert.False(t, reader.FilterMayMatch(loanStats(1000, 99999, false)))
+	assert.True(t, reader.FilterMayMatch(loanStats(1000, 100000, false)))
+	assert.False(t, reader.FilterMayMatch(map[string]*ReaderColumnStats{
+		"loan_amount": {Min: int64(100000), Max: int64(200000)},
+		"state":       {Min: "NY", Max: "WA"}}))
+	// No statistics: may match
+	assert.True(t, reader.FilterMayMatch(map[string]*ReaderColumnStats{}))
+
+	// Nulls are read as zeroes
+	reader = newFilterReader(t, `r.loan_amount < 1000`)
+	assert.False(t, reader.FilterMayMatch(loanStats(1000, 2000, false)))
+	assert.True(t, reader.FilterMayMatch(loanStats(1000, 2000, true)))
+
+	// Constant on the left, constant expressions, negation
+	reader = newFilterReader(t, `100000 <= r.loan_amount || !(r.loan_amount != int(500 * 2))`)
+	assert.False(t, reader.FilterMayMatch(loanStats(1001, 99999, false)))
+	assert.True(t, reader.FilterMayMatch(loanStats(1000, 1000, false)))
+
+	// Int column compared with float, decimal column with decimal
+	reader = newFilterReader(t, `r.loan_amount > 999.5 && r.rate < decimal2(3.5)`)
+	assert.False(t, reader.FilterMayMatch(map[string]*ReaderColumnStats{
+		"loan_amount": {Min: int64(1000), Max: int64(2000)},
+		"rate":        {Min: decimal.NewFromFloat(3.5), Max: decimal.NewFromFloat(7.25)}}))
+	assert.True(t, reader.FilterMayMatch(map[string]*ReaderColumnStats{
+		"loan_amount": {Min: int64(1000), Max: int64(2000)},
+		"rate":        {Min: decimal.NewFromFloat(3.25), Max: decimal.NewFromFloat(7.25)}}))
+
+	// Datetime and bool columns
+	reader = newFilterReader(t, `r.origination_date > time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC) && r.is_first_time`)
+	assert.False(t, reader.FilterMayMatch(map[string]*ReaderColumnStats{
+		"origination_date": {Min: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC), Max: time.Date(2019, time.December, 31, 0, 0, 0, 0, time.UTC)}}))
+	assert.False(t, reader.FilterMayMatch(map[string]*ReaderColumnStats{
+		"is_first_time": {Min: false, Max: false}}))
+	assert.True(t, reader.FilterMayMatch(map[string]*ReaderColumnStats{
+		"origination_date": {Min: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC), Max: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		"is_first_time":    {Min: false, Max: true}}))
+
+	// Not a comparison with a constant: may match
+	reader = newFilterReader(t, `len(r.state) > 2 || r.loan_amount > r.rate`)
+	assert.True(t, reader.FilterMayMatch(loanStats(1000, 2000, false)))
+}
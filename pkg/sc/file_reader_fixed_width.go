@@ -0,0 +1,89 @@
+package sc
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+)
+
+type FixedWidthTrimType string
+
+const (
+	FixedWidthTrimBoth  FixedWidthTrimType = "both"
+	FixedWidthTrimLeft  FixedWidthTrimType = "left"
+	FixedWidthTrimRight FixedWidthTrimType = "right"
+	FixedWidthTrimNone  FixedWidthTrimType = "none"
+)
+
+// Fixed-width column: start is zero-based character position in the line, length is the number of characters
+type FixedWidthReaderColumnSettings struct {
+	SrcColStart  int                `json:"start"`
+	SrcColLength int                `json:"length"`
+	SrcColFormat string             `json:"col_format,omitempty"` // Optional for all except datetime
+	Trim         FixedWidthTrimType `json:"trim,omitempty"`       // Default: both
+	PadChar      string             `json:"pad_char,omitempty"`   // Character trimmed from the field, default: space
+}
+
+type FixedWidthReaderSettings struct {
+	SrcFileFirstDataLineIdx int             `json:"first_data_line_idx,omitempty" yaml:"first_data_line_idx,omitempty"` // Lines before it (headers, banners) are skipped
+	Compression             CompressionType `json:"compression,omitempty" yaml:"compression,omitempty"`                 // Detected by file extension if empty
+}
+
+func (frDef *FileReaderDef) parseFixedWidthColumns() []string {
+	foundErrors := make([]string, 0)
+	for colName, colDef := range frDef.Columns {
+		settings := &colDef.FixedWidth
+		if settings.SrcColStart < 0 || settings.SrcColLength <= 0 {
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s: invalid fixed-width start %d and length %d, start cannot be negative, length must be positive", colName, settings.SrcColStart, settings.SrcColLength))
+		}
+		switch settings.Trim {
+		case "":
+			settings.Trim = FixedWidthTrimBoth
+		case FixedWidthTrimBoth, FixedWidthTrimLeft, FixedWidthTrimRight, FixedWidthTrimNone:
+		default:
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s: invalid fixed-width trim %s, expected one of: both, left, right, none", colName, settings.Trim))
+		}
+		if len(settings.PadChar) == 0 {
+			settings.PadChar = " "
+		} else if utf8.RuneCountInString(settings.PadChar) != 1 {
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s: fixed-width pad_char must be a single character, got '%s'", colName, settings.PadChar))
+		}
+		if colDef.Type == evalcapi.FieldTypeDateTime && len(settings.SrcColFormat) == 0 {
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s: datetime column format is missing, consider specifying something like 20060102150405, see go datetime format documentation for details", colName))
+		}
+	}
+	return foundErrors
+}
+
+// Characters beyond the end of a short line are treated as padding
+func fixedWidthField(line []rune, settings *FixedWidthReaderColumnSettings) string {
+	if settings.SrcColStart >= len(line) {
+		return ""
+	}
+	end := min(settings.SrcColStart+settings.SrcColLength, len(line))
+	field := string(line[settings.SrcColStart:end])
+	switch settings.Trim {
+	case FixedWidthTrimBoth:
+		return strings.Trim(field, settings.PadChar)
+	case FixedWidthTrimLeft:
+		return strings.TrimLeft(field, settings.PadChar)
+	case FixedWidthTrimRight:
+		return strings.TrimRight(field, settings.PadChar)
+	default:
+		return field
+	}
+}
+
+func (frDef *FileReaderDef) ReadFixedWidthLineToValuesMap(line string, colVars eval.VarValuesMap) error {
+	lineRunes := []rune(strings.TrimRight(line, "\r\n"))
+	colVars[ReaderAlias] = map[string]any{}
+	for colName, colDef := range frDef.Columns {
+		if err := readColumnValue(colName, fixedWidthField(lineRunes, &colDef.FixedWidth), colDef.FixedWidth.SrcColFormat, colDef, colVars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
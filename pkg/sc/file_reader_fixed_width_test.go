@@ -0,0 +1,91 @@
+package sc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const fixedWidthReaderConf string = `
+{
+	"urls": ["positions.txt"],
+	"fixed_width": {
+		"first_data_line_idx": 1
+	},
+	"columns": {
+		"col_account": {
+			"fixed_width": {"start": 0, "length": 8},
+			"col_type": "string"
+		},
+		"col_qty": {
+			"fixed_width": {"start": 8, "length": 6, "trim": "left", "pad_char": "0"},
+			"col_type": "int"
+		},
+		"col_price": {
+			"fixed_width": {"start": 14, "length": 8},
+			"col_type": "decimal2"
+		},
+		"col_trade_date": {
+			"fixed_width": {"start": 22, "length": 8, "col_format": "20060102"},
+			"col_type": "datetime"
+		},
+		"col_memo": {
+			"fixed_width": {"start": 30, "length": 6, "trim": "none"},
+			"col_type": "string",
+			"col_default_value": "n/a"
+		}
+	}
+}`
+
+func TestFixedWidthReader(t *testing.T) {
+	reader := FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(fixedWidthReaderConf)))
+	assert.Equal(t, ReaderFileTypeFixedWidth, reader.ReaderFileType)
+	assert.Equal(t, 1, reader.FixedWidth.SrcFileFirstDataLineIdx)
+	assert.Equal(t, FixedWidthTrimBoth, reader.Columns["col_account"].FixedWidth.Trim)
+	assert.Equal(t, " ", reader.Columns["col_account"].FixedWidth.PadChar)
+
+	vars := eval.VarValuesMap{}
+	assert.Nil(t, reader.ReadFixedWidthLineToValuesMap("ACC001  000120  12.345"+"20240131"+"ab    \r\n", vars))
+	assert.Equal(t, "ACC001", vars[ReaderAlias]["col_account"])
+	assert.Equal(t, int64(120), vars[ReaderAlias]["col_qty"])
+	assert.Equal(t, decimal.NewFromFloat(12.35), vars[ReaderAlias]["col_price"])
+	assert.Equal(t, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), vars[ReaderAlias]["col_trade_date"])
+	assert.Equal(t, "ab    ", vars[ReaderAlias]["col_memo"])
+
+	// Short line: missing characters are padding, empty fields get defaults
+	vars = eval.VarValuesMap{}
+	assert.Nil(t, reader.ReadFixedWidthLineToValuesMap("ACC002  000000", vars))
+	assert.Equal(t, "ACC002", vars[ReaderAlias]["col_account"])
+	assert.Equal(t, int64(0), vars[ReaderAlias]["col_qty"])
+	assert.Equal(t, decimal.NewFromInt(0), vars[ReaderAlias]["col_price"])
+	assert.Equal(t, "n/a", vars[ReaderAlias]["col_memo"])
+
+	vars = eval.VarValuesMap{}
+	assert.Contains(t, reader.ReadFixedWidthLineToValuesMap("ACC003  0001x0", vars).Error(), "cannot read int64 column col_qty, data '1x0', no format")
+}
+
+func TestFixedWidthReaderFailures(t *testing.T) {
+	reader := FileReaderDef{}
+	err := reader.Deserialize([]byte(`{"urls": [""], "columns": {"col_a": {"fixed_width": {"start": -1, "length": 2}, "col_type": "string"}}}`))
+	assert.Contains(t, err.Error(), "column col_a: invalid fixed-width start -1 and length 2")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(`{"urls": [""], "columns": {"col_a": {"fixed_width": {"start": 0, "length": 2, "trim": "middle"}, "col_type": "string"}}}`))
+	assert.Contains(t, err.Error(), "column col_a: invalid fixed-width trim middle, expected one of: both, left, right, none")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(`{"urls": [""], "columns": {"col_a": {"fixed_width": {"start": 0, "length": 2, "pad_char": "00"}, "col_type": "string"}}}`))
+	assert.Contains(t, err.Error(), "column col_a: fixed-width pad_char must be a single character, got '00'")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(`{"urls": [""], "columns": {"col_a": {"fixed_width": {"start": 0, "length": 8}, "col_type": "datetime"}}}`))
+	assert.Contains(t, err.Error(), "column col_a: datetime column format is missing")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(`{"urls": [""], "fixed_width": {"compression": "lz4"}, "columns": {"col_a": {"fixed_width": {"start": 0, "length": 8}, "col_type": "string"}}}`))
+	assert.Contains(t, err.Error(), "invalid compression lz4")
+}
@@ -0,0 +1,175 @@
+package sc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/shopspring/decimal"
+)
+
+// JSON Lines column: value is located by a dotted path, numeric path segments index arrays: customer.address.zip, items.0.sku
+type JsonlReaderColumnSettings struct {
+	SrcPath      string   `json:"path"`
+	SrcColFormat string   `json:"col_format,omitempty"` // Datetime only
+	ParsedPath   []string `json:"-"`
+}
+
+func parseJsonlPath(rawPath string) ([]string, error) {
+	path := strings.TrimPrefix(strings.TrimSpace(rawPath), "$.")
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty jsonl path [%s]", rawPath)
+	}
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if len(segment) == 0 {
+			return nil, fmt.Errorf("invalid jsonl path [%s]: empty segment", rawPath)
+		}
+	}
+	return segments, nil
+}
+
+func (frDef *FileReaderDef) parseJsonlColumns() []string {
+	foundErrors := make([]string, 0)
+	for colName, colDef := range frDef.Columns {
+		var err error
+		if colDef.Jsonl.ParsedPath, err = parseJsonlPath(colDef.Jsonl.SrcPath); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s: %s", colName, err.Error()))
+			continue
+		}
+		if colDef.Type == evalcapi.FieldTypeDateTime && len(colDef.Jsonl.SrcColFormat) == 0 {
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s: datetime column format is missing, consider specifying something like 2006-01-02T15:04:05.000-0700, see go datetime format documentation for details", colName))
+		}
+	}
+	return foundErrors
+}
+
+// Returns false if any of the path segments is missing
+func findJsonlValue(doc any, path []string) (any, bool) {
+	cur := doc
+	for _, segment := range path {
+		switch typedCur := cur.(type) {
+		case map[string]any:
+			next, ok := typedCur[segment]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []any:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(typedCur) {
+				return nil, false
+			}
+			cur = typedCur[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// Parses a string value (JSON string or col_default_value) into the column type
+func jsonlStringToFieldType(strVal string, colDef *FileReaderColumnDef) (any, error) {
+	switch evalcapi.FieldTypeKind(colDef.Type) {
+	case evalcapi.FieldTypeString:
+		return strVal, nil
+	case evalcapi.FieldTypeBool:
+		return strconv.ParseBool(strings.TrimSpace(strVal))
+	case evalcapi.FieldTypeInt:
+		return strconv.ParseInt(strings.TrimSpace(strVal), 10, 64)
+	case evalcapi.FieldTypeFloat:
+		return strconv.ParseFloat(strings.TrimSpace(strVal), 64)
+	case evalcapi.FieldTypeDecimal2:
+		valDec, err := decimal.NewFromString(strings.TrimSpace(strVal))
+		if err != nil {
+			return nil, err
+		}
+		return valDec.Round(evalcapi.DecimalFieldTypeScale(colDef.Type)), nil
+	case evalcapi.FieldTypeDateTime:
+		return time.Parse(colDef.Jsonl.SrcColFormat, strings.TrimSpace(strVal))
+	default:
+		return nil, fmt.Errorf("unsupported column type '%s'", colDef.Type)
+	}
+}
+
+// Coerces a decoded JSON value (decoded with UseNumber) into the column type
+func jsonlValueToFieldType(val any, colDef *FileReaderColumnDef) (any, error) {
+	switch typedVal := val.(type) {
+	case string:
+		return jsonlStringToFieldType(typedVal, colDef)
+	case json.Number:
+		switch evalcapi.FieldTypeKind(colDef.Type) {
+		case evalcapi.FieldTypeString:
+			return typedVal.String(), nil
+		case evalcapi.FieldTypeInt:
+			return typedVal.Int64()
+		case evalcapi.FieldTypeFloat:
+			return typedVal.Float64()
+		case evalcapi.FieldTypeDecimal2:
+			valDec, err := decimal.NewFromString(typedVal.String())
+			if err != nil {
+				return nil, err
+			}
+			return valDec.Round(evalcapi.DecimalFieldTypeScale(colDef.Type)), nil
+		}
+	case bool:
+		switch colDef.Type {
+		case evalcapi.FieldTypeBool:
+			return typedVal, nil
+		case evalcapi.FieldTypeString:
+			return strconv.FormatBool(typedVal), nil
+		}
+	case map[string]any, []any:
+		// Nested objects and arrays can be read as JSON text
+		if colDef.Type == evalcapi.FieldTypeString {
+			jsonBytes, err := json.Marshal(typedVal)
+			if err != nil {
+				return nil, err
+			}
+			return string(jsonBytes), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", val, colDef.Type)
+}
+
+func (frDef *FileReaderDef) ReadJsonlLineToValuesMap(line []byte, colVars eval.VarValuesMap) error {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("cannot parse json: %s", err.Error())
+	}
+
+	colVars[ReaderAlias] = map[string]any{}
+	for colName, colDef := range frDef.Columns {
+		val, ok := findJsonlValue(doc, colDef.Jsonl.ParsedPath)
+		if !ok || val == nil {
+			// Missing and null values get the default, or stay null
+			if colDef.Nullable {
+				colVars[ReaderAlias][colName] = nil
+				continue
+			}
+			if len(colDef.DefaultValue) == 0 {
+				colVars[ReaderAlias][colName] = GetDefaultFieldTypeValue(colDef.Type)
+				continue
+			}
+			valTyped, err := jsonlStringToFieldType(colDef.DefaultValue, colDef)
+			if err != nil {
+				return fmt.Errorf("cannot read %s column %s from default value string '%s': %s", colDef.Type, colName, colDef.DefaultValue, err.Error())
+			}
+			colVars[ReaderAlias][colName] = valTyped
+			continue
+		}
+		valTyped, err := jsonlValueToFieldType(val, colDef)
+		if err != nil {
+			return fmt.Errorf("cannot read %s column %s, path %s, data '%v': %s", colDef.Type, colName, colDef.Jsonl.SrcPath, val, err.Error())
+		}
+		colVars[ReaderAlias][colName] = valTyped
+	}
+	return nil
+}
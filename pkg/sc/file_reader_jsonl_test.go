@@ -0,0 +1,112 @@
+package sc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonlReaderConf string = `
+{
+	"urls": ["orders.jsonl"],
+	"columns": {
+		"col_order_id": {
+			"jsonl": {"path": "$.order_id"},
+			"col_type": "int"
+		},
+		"col_customer_name": {
+			"jsonl": {"path": "customer.name"},
+			"col_type": "string"
+		},
+		"col_first_sku": {
+			"jsonl": {"path": "items.0.sku"},
+			"col_type": "string"
+		},
+		"col_items": {
+			"jsonl": {"path": "items"},
+			"col_type": "string"
+		},
+		"col_total": {
+			"jsonl": {"path": "total"},
+			"col_type": "decimal2"
+		},
+		"col_weight": {
+			"jsonl": {"path": "weight"},
+			"col_type": "float",
+			"col_default_value": "1.5"
+		},
+		"col_is_paid": {
+			"jsonl": {"path": "is_paid"},
+			"col_type": "bool"
+		},
+		"col_ts": {
+			"jsonl": {"path": "ts", "col_format": "2006-01-02T15:04:05Z07:00"},
+			"col_type": "datetime"
+		}
+	}
+}`
+
+func TestJsonlReader(t *testing.T) {
+	reader := FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(jsonlReaderConf)))
+	assert.Equal(t, ReaderFileTypeJsonl, reader.ReaderFileType)
+	assert.Equal(t, []string{"order_id"}, reader.Columns["col_order_id"].Jsonl.ParsedPath)
+
+	vars := eval.VarValuesMap{}
+	assert.Nil(t, reader.ReadJsonlLineToValuesMap([]byte(`{"order_id":12,"customer":{"name":"John"},"items":[{"sku":"A1"}],"total":"10.456","is_paid":true,"ts":"2001-02-03T04:05:06Z"}`), vars))
+	assert.Equal(t, int64(12), vars[ReaderAlias]["col_order_id"])
+	assert.Equal(t, "John", vars[ReaderAlias]["col_customer_name"])
+	assert.Equal(t, "A1", vars[ReaderAlias]["col_first_sku"])
+	assert.Equal(t, `[{"sku":"A1"}]`, vars[ReaderAlias]["col_items"])
+	assert.Equal(t, decimal.NewFromFloat(10.46), vars[ReaderAlias]["col_total"])
+	assert.Equal(t, 1.5, vars[ReaderAlias]["col_weight"])
+	assert.Equal(t, true, vars[ReaderAlias]["col_is_paid"])
+	assert.Equal(t, time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC), vars[ReaderAlias]["col_ts"])
+
+	// Missing and null values
+	vars = eval.VarValuesMap{}
+	assert.Nil(t, reader.ReadJsonlLineToValuesMap([]byte(`{"order_id":13,"customer":null,"weight":2.25,"ts":"2001-02-03T04:05:06Z"}`), vars))
+	assert.Equal(t, "", vars[ReaderAlias]["col_customer_name"])
+	assert.Equal(t, "", vars[ReaderAlias]["col_first_sku"])
+	assert.Equal(t, 2.25, vars[ReaderAlias]["col_weight"])
+	assert.Equal(t, false, vars[ReaderAlias]["col_is_paid"])
+
+	vars = eval.VarValuesMap{}
+	assert.Contains(t, reader.ReadJsonlLineToValuesMap([]byte(`{"order_id":"abc"}`), vars).Error(), "cannot read int column col_order_id, path $.order_id, data 'abc'")
+
+	vars = eval.VarValuesMap{}
+	assert.Contains(t, reader.ReadJsonlLineToValuesMap([]byte(`{"order_id":true}`), vars).Error(), "cannot convert bool to int")
+
+	vars = eval.VarValuesMap{}
+	assert.Contains(t, reader.ReadJsonlLineToValuesMap([]byte(`{"order_id":`), vars).Error(), "cannot parse json")
+}
+
+func TestJsonlReaderNullable(t *testing.T) {
+	reader := FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(`{"urls": [""], "columns": {
+		"col_a": {"jsonl": {"path": "a"}, "col_type": "int", "col_nullable": true},
+		"col_b": {"jsonl": {"path": "b.c"}, "col_type": "string", "col_nullable": true}}}`)))
+
+	vars := eval.VarValuesMap{}
+	assert.Nil(t, reader.ReadJsonlLineToValuesMap([]byte(`{"a":null}`), vars))
+	assert.Nil(t, vars[ReaderAlias]["col_a"])
+	assert.Nil(t, vars[ReaderAlias]["col_b"])
+
+	vars = eval.VarValuesMap{}
+	assert.Nil(t, reader.ReadJsonlLineToValuesMap([]byte(`{"a":1,"b":{"c":""}}`), vars))
+	assert.Equal(t, int64(1), vars[ReaderAlias]["col_a"])
+	assert.Equal(t, "", vars[ReaderAlias]["col_b"])
+}
+
+func TestJsonlReaderFailures(t *testing.T) {
+	reader := FileReaderDef{}
+	err := reader.Deserialize([]byte(`{"urls": [""], "columns": {"col_a": {"jsonl": {"path": "a..b"}, "col_type": "string"}}}`))
+	assert.Contains(t, err.Error(), "column col_a: invalid jsonl path [a..b]: empty segment")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(`{"urls": [""], "columns": {"col_a": {"jsonl": {"path": "a"}, "col_type": "datetime"}}}`))
+	assert.Contains(t, err.Error(), "column col_a: datetime column format is missing")
+}
@@ -0,0 +1,88 @@
+package sc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+)
+
+// How a list, map or repeated Parquet column is read
+type ParquetRepeatedMode string
+
+const (
+	ParquetRepeatedNone         ParquetRepeatedMode = ""
+	ParquetRepeatedExplode      ParquetRepeatedMode = "explode"       // One row per element, rows with empty or null lists are skipped
+	ParquetRepeatedExplodeOuter ParquetRepeatedMode = "explode_outer" // One row per element, rows with empty or null lists produce one row with default values
+	ParquetRepeatedJoin         ParquetRepeatedMode = "join"          // Elements joined into one string, nulls are skipped
+)
+
+const DefaultParquetJoinSeparator string = ","
+
+func (s *ParquetReaderColumnSettings) IsExploded() bool {
+	return s.Repeated == ParquetRepeatedExplode || s.Repeated == ParquetRepeatedExplodeOuter
+}
+
+func (s *ParquetReaderColumnSettings) GetJoinSeparator() string {
+	if s.JoinSeparator == "" {
+		return DefaultParquetJoinSeparator
+	}
+	return s.JoinSeparator
+}
+
+// Struct fields, list elements and map keys/values are addressed the same way: tags, items.sku, attrs.key, attrs.value.
+// Physical paths (items.list.element.sku) are accepted too.
+func parseParquetPath(rawPath string) ([]string, error) {
+	path := strings.TrimSpace(rawPath)
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty parquet column name [%s]", rawPath)
+	}
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if len(segment) == 0 {
+			return nil, fmt.Errorf("invalid parquet column name [%s]: empty segment", rawPath)
+		}
+	}
+	return segments, nil
+}
+
+func (frDef *FileReaderDef) parseParquetColumns() []string {
+	foundErrors := make([]string, 0)
+
+	colNames := make([]string, 0, len(frDef.Columns))
+	for colName := range frDef.Columns {
+		colNames = append(colNames, colName)
+	}
+	sort.Strings(colNames)
+
+	explodedColName := ""
+	for _, colName := range colNames {
+		colDef := frDef.Columns[colName]
+		var err error
+		if colDef.Parquet.ParsedPath, err = parseParquetPath(colDef.Parquet.SrcColName); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s: %s", colName, err.Error()))
+			continue
+		}
+		switch colDef.Parquet.Repeated {
+		case ParquetRepeatedNone, ParquetRepeatedExplode, ParquetRepeatedExplodeOuter:
+		case ParquetRepeatedJoin:
+			if colDef.Type != evalcapi.FieldTypeString {
+				foundErrors = append(foundErrors, fmt.Sprintf("column %s: parquet repeated join produces strings, cannot read it as %s", colName, colDef.Type))
+			}
+		default:
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s: invalid parquet repeated mode %s, expected one of: %s, %s, %s", colName, colDef.Parquet.Repeated, ParquetRepeatedExplode, ParquetRepeatedExplodeOuter, ParquetRepeatedJoin))
+		}
+		if colDef.Parquet.JoinSeparator != "" && colDef.Parquet.Repeated != ParquetRepeatedJoin {
+			foundErrors = append(foundErrors, fmt.Sprintf("column %s: parquet join_separator requires repeated %s", colName, ParquetRepeatedJoin))
+		}
+		// Exploded columns are zipped, one source row cannot produce rows both with and without empty lists
+		if colDef.Parquet.IsExploded() {
+			if explodedColName != "" && frDef.Columns[explodedColName].Parquet.Repeated != colDef.Parquet.Repeated {
+				foundErrors = append(foundErrors, fmt.Sprintf("column %s: parquet repeated mode %s does not match column %s repeated mode %s, all exploded columns must use the same mode", colName, colDef.Parquet.Repeated, explodedColName, frDef.Columns[explodedColName].Parquet.Repeated))
+			}
+			explodedColName = colName
+		}
+	}
+	return foundErrors
+}
@@ -0,0 +1,70 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const parquetNestedReaderConf string = `
+{
+	"urls": ["events.parquet"],
+	"columns": {
+		"col_zip": {
+			"parquet": {"col_name": "customer.address.zip"},
+			"col_type": "string"
+		},
+		"col_tags": {
+			"parquet": {"col_name": "tags", "repeated": "join", "join_separator": "|"},
+			"col_type": "string"
+		},
+		"col_sku": {
+			"parquet": {"col_name": "items.sku", "repeated": "explode"},
+			"col_type": "string"
+		},
+		"col_qty": {
+			"parquet": {"col_name": "items.qty", "repeated": "explode"},
+			"col_type": "int"
+		}
+	}
+}`
+
+func TestParquetNestedColumns(t *testing.T) {
+	reader := FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(parquetNestedReaderConf)))
+	assert.Equal(t, ReaderFileTypeParquet, reader.ReaderFileType)
+	assert.Equal(t, []string{"customer", "address", "zip"}, reader.Columns["col_zip"].Parquet.ParsedPath)
+	assert.False(t, reader.Columns["col_zip"].Parquet.IsExploded())
+	assert.Equal(t, DefaultParquetJoinSeparator, reader.Columns["col_zip"].Parquet.GetJoinSeparator())
+	assert.Equal(t, "|", reader.Columns["col_tags"].Parquet.GetJoinSeparator())
+	assert.True(t, reader.Columns["col_sku"].Parquet.IsExploded())
+
+	reader = FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(strings.ReplaceAll(parquetNestedReaderConf, `"repeated": "explode"`, `"repeated": "explode_outer"`))))
+	assert.True(t, reader.Columns["col_qty"].Parquet.IsExploded())
+}
+
+func TestParquetNestedColumnsFailures(t *testing.T) {
+	reader := FileReaderDef{}
+	err := reader.Deserialize([]byte(strings.Replace(parquetNestedReaderConf, `"customer.address.zip"`, `"customer..zip"`, 1)))
+	assert.Contains(t, err.Error(), "column col_zip: invalid parquet column name [customer..zip]: empty segment")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(parquetNestedReaderConf, `"repeated": "join", "join_separator": "|"`, `"repeated": "concat"`, 1)))
+	assert.Contains(t, err.Error(), "column col_tags: invalid parquet repeated mode concat, expected one of: explode, explode_outer, join")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(parquetNestedReaderConf, `"repeated": "join", "join_separator": "|"`, `"repeated": "explode", "join_separator": "|"`, 1)))
+	assert.Contains(t, err.Error(), "column col_tags: parquet join_separator requires repeated join")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(parquetNestedReaderConf, `"items.qty", "repeated": "explode"},
+			"col_type": "int"`, `"items.qty", "repeated": "join"},
+			"col_type": "int"`, 1)))
+	assert.Contains(t, err.Error(), "column col_qty: parquet repeated join produces strings, cannot read it as int")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(parquetNestedReaderConf, `"items.sku", "repeated": "explode"`, `"items.sku", "repeated": "explode_outer"`, 1)))
+	assert.Contains(t, err.Error(), "column col_sku: parquet repeated mode explode_outer does not match column col_qty repeated mode explode, all exploded columns must use the same mode")
+}
@@ -0,0 +1,66 @@
+package sc
+
+import (
+	"fmt"
+	"math"
+)
+
+// Split CSV readers read each source file in several batches, one per split_size_bytes byte range.
+// A split batch carries the file index in its first token and the byte offset of the range in its last token.
+func (frDef *FileReaderDef) IsSplit() bool {
+	return frDef.ReaderFileType == ReaderFileTypeCsv && frDef.Csv.SplitSizeBytes > 0
+}
+
+func (frDef *FileReaderDef) checkCsvSplit() []string {
+	foundErrors := make([]string, 0)
+	if frDef.Csv.SplitSizeBytes < 0 {
+		foundErrors = append(foundErrors, fmt.Sprintf("csv split_size_bytes cannot be negative: %d", frDef.Csv.SplitSizeBytes))
+	}
+	if frDef.Csv.SplitSizeBytes > 0 {
+		if frDef.Csv.Compression != CompressionAuto && frDef.Csv.Compression != CompressionNone {
+			foundErrors = append(foundErrors, fmt.Sprintf("csv split_size_bytes cannot be used with %s compression, compressed files cannot be read from an offset", frDef.Csv.Compression))
+		}
+		// Byte offsets of a split are offsets in the source file, so lines must be readable without decoding
+		if enc, err := GetTextEncoding(frDef.Csv.Encoding); err == nil && enc != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("csv split_size_bytes cannot be used with %s encoding", frDef.Csv.Encoding))
+		}
+		// Ledger entry is per file, and a file would be marked as ingested by whichever of its batches succeeds first
+		if frDef.Incremental {
+			foundErrors = append(foundErrors, "csv split_size_bytes cannot be used with incremental reader")
+		}
+	}
+	return foundErrors
+}
+
+// One batch per split_size_bytes range of each file, empty files still get one batch
+func (frDef *FileReaderDef) GetSplitIntervals(fileSizes []int64) ([][]int64, error) {
+	if len(fileSizes) != len(frDef.SrcFileUrls) {
+		return nil, fmt.Errorf("cannot split files: got %d file sizes for %d files", len(fileSizes), len(frDef.SrcFileUrls))
+	}
+	intervals := make([][]int64, 0, len(fileSizes))
+	for fileIdx, fileSize := range fileSizes {
+		if fileSize < 0 {
+			return nil, fmt.Errorf("cannot split file %s: unknown size", frDef.SrcFileUrls[fileIdx])
+		}
+		splitCount := max(1, (fileSize+frDef.Csv.SplitSizeBytes-1)/frDef.Csv.SplitSizeBytes)
+		for splitIdx := int64(0); splitIdx < splitCount; splitIdx++ {
+			intervals = append(intervals, []int64{int64(fileIdx), splitIdx * frDef.Csv.SplitSizeBytes})
+		}
+		// Batch indexes are int16
+		if len(intervals) > math.MaxInt16 {
+			return nil, fmt.Errorf("cannot split files into more than %d batches, consider increasing split_size_bytes %d", math.MaxInt16, frDef.Csv.SplitSizeBytes)
+		}
+	}
+	return intervals, nil
+}
+
+// Validates split batch tokens, see IsSplit
+func (frDef *FileReaderDef) CheckSplitTokens(firstToken int64, lastToken int64) error {
+	if firstToken < 0 || firstToken >= int64(len(frDef.SrcFileUrls)) {
+		return fmt.Errorf("split batch file index %d must be smaller than the number of files specified by file reader %d", firstToken, len(frDef.SrcFileUrls))
+	}
+	if lastToken < 0 || lastToken%frDef.Csv.SplitSizeBytes != 0 {
+		return fmt.Errorf("split batch offset %d must be a non-negative multiple of split_size_bytes %d", lastToken, frDef.Csv.SplitSizeBytes)
+	}
+	return nil
+}
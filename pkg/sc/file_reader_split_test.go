@@ -0,0 +1,69 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const splitReaderConf string = `
+{
+	"urls": ["a.csv", "b.csv"],
+	"csv": {
+		"hdr_line_idx": 0,
+		"first_data_line_idx": 1,
+		"split_size_bytes": 100
+	},
+	"columns": {
+		"col_a": {
+			"csv": {"col_hdr": "a"},
+			"col_type": "string"
+		}
+	}
+}`
+
+func TestSplitIntervals(t *testing.T) {
+	reader := FileReaderDef{}
+	assert.Nil(t, reader.Deserialize([]byte(splitReaderConf)))
+	assert.True(t, reader.IsSplit())
+
+	intervals, err := reader.GetSplitIntervals([]int64{250, 0})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int64{{0, 0}, {0, 100}, {0, 200}, {1, 0}}, intervals)
+
+	intervals, err = reader.GetSplitIntervals([]int64{200, 100})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int64{{0, 0}, {0, 100}, {1, 0}}, intervals)
+
+	_, err = reader.GetSplitIntervals([]int64{200})
+	assert.Contains(t, err.Error(), "cannot split files: got 1 file sizes for 2 files")
+
+	_, err = reader.GetSplitIntervals([]int64{200, -1})
+	assert.Contains(t, err.Error(), "cannot split file b.csv: unknown size")
+
+	_, err = reader.GetSplitIntervals([]int64{100 * 40000, 0})
+	assert.Contains(t, err.Error(), "cannot split files into more than 32767 batches, consider increasing split_size_bytes 100")
+
+	assert.Nil(t, reader.CheckSplitTokens(1, 300))
+	assert.Contains(t, reader.CheckSplitTokens(2, 0).Error(), "split batch file index 2 must be smaller than the number of files specified by file reader 2")
+	assert.Contains(t, reader.CheckSplitTokens(0, 150).Error(), "split batch offset 150 must be a non-negative multiple of split_size_bytes 100")
+
+	node := ScriptNodeDef{Name: "read_file", Type: NodeTypeFileTable, FileReader: reader}
+	_, err = node.GetTokenIntervalsByNumberOfBatches()
+	assert.Contains(t, err.Error(), "cannot calculate intervals for node read_file without file sizes, it splits its files")
+}
+
+func TestSplitFailures(t *testing.T) {
+	reader := FileReaderDef{}
+	err := reader.Deserialize([]byte(strings.Replace(splitReaderConf, `"split_size_bytes": 100`, `"split_size_bytes": -1`, 1)))
+	assert.Contains(t, err.Error(), "csv split_size_bytes cannot be negative: -1")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(splitReaderConf, `"split_size_bytes": 100`, `"split_size_bytes": 100, "compression": "gzip"`, 1)))
+	assert.Contains(t, err.Error(), "csv split_size_bytes cannot be used with gzip compression, compressed files cannot be read from an offset")
+
+	reader = FileReaderDef{}
+	err = reader.Deserialize([]byte(strings.Replace(splitReaderConf, `"urls": ["a.csv", "b.csv"],`, `"urls": ["a.csv", "b.csv"], "incremental": true,`, 1)))
+	assert.Contains(t, err.Error(), "csv split_size_bytes cannot be used with incremental reader")
+}
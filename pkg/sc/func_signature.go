@@ -0,0 +1,94 @@
+package sc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+)
+
+func callExpFuncName(callExp *ast.CallExpr) string {
+	switch typedFun := callExp.Fun.(type) {
+	case *ast.Ident:
+		return typedFun.Name
+	case *ast.SelectorExpr:
+		if ident, ok := typedFun.X.(*ast.Ident); ok {
+			return fmt.Sprintf("%s.%s", ident.Name, typedFun.Sel.Name)
+		}
+	}
+	return ""
+}
+
+// Returns FieldTypeUnknown when the type cannot be told without evaluating the expression
+func inferExpressionType(exp ast.Expr, fieldRefs FieldRefs) evalcapi.TableFieldType {
+	switch typedExp := exp.(type) {
+	case *ast.ParenExpr:
+		return inferExpressionType(typedExp.X, fieldRefs)
+	case *ast.BasicLit:
+		switch typedExp.Kind {
+		case token.INT:
+			return evalcapi.FieldTypeInt
+		case token.FLOAT:
+			return evalcapi.FieldTypeFloat
+		case token.STRING:
+			return evalcapi.FieldTypeString
+		}
+	case *ast.Ident:
+		if typedExp.Name == "true" || typedExp.Name == "false" {
+			return evalcapi.FieldTypeBool
+		}
+	case *ast.SelectorExpr:
+		if ident, ok := typedExp.X.(*ast.Ident); ok {
+			for i := 0; i < len(fieldRefs); i++ {
+				if fieldRefs[i].TableName == ident.Name && fieldRefs[i].FieldName == typedExp.Sel.Name && evalcapi.IsValidFieldType(fieldRefs[i].FieldType) {
+					return fieldRefs[i].FieldType
+				}
+			}
+		}
+	case *ast.CallExpr:
+		if signature, ok := evalcapi.CapillariesEvalFunctionSignatures[callExpFuncName(typedExp)]; ok {
+			return signature.ReturnType
+		}
+	}
+	return evalcapi.FieldTypeUnknown
+}
+
+// Checks arg count and types of all function calls that have a signature. Evaluating the expression
+// with sample field values fails on most of these too, but does not say which arg is wrong.
+func checkFunctionSignatures(exp ast.Expr, fieldRefs FieldRefs) error {
+	foundErrors := make([]string, 0)
+	ast.Inspect(exp, func(node ast.Node) bool {
+		callExp, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		funcName := callExpFuncName(callExp)
+		signature, ok := evalcapi.CapillariesEvalFunctionSignatures[funcName]
+		if !ok {
+			return true
+		}
+		minArgCount := len(signature.ArgTypes) - signature.OptionalArgCount
+		if len(callExp.Args) < minArgCount || len(callExp.Args) > len(signature.ArgTypes) {
+			if signature.OptionalArgCount == 0 {
+				foundErrors = append(foundErrors, fmt.Sprintf("%s() requires %d args, %d supplied", funcName, len(signature.ArgTypes), len(callExp.Args)))
+			} else {
+				foundErrors = append(foundErrors, fmt.Sprintf("%s() requires %d to %d args, %d supplied", funcName, minArgCount, len(signature.ArgTypes), len(callExp.Args)))
+			}
+			return true
+		}
+		for i, argExp := range callExp.Args {
+			argType := inferExpressionType(argExp, fieldRefs)
+			// decimal(p,s) fields are accepted where decimal2 is expected
+			if argType != evalcapi.FieldTypeUnknown && signature.ArgTypes[i] != evalcapi.FieldTypeUnknown && evalcapi.FieldTypeKind(argType) != signature.ArgTypes[i] {
+				foundErrors = append(foundErrors, fmt.Sprintf("%s() arg %d must be %s, got %s", funcName, i+1, signature.ArgTypes[i], argType))
+			}
+		}
+		return true
+	})
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package sc
+
+import (
+	"go/parser"
+	"testing"
+
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFunctionSignatures(t *testing.T) {
+	fieldRefs := FieldRefs{
+		{FieldType: evalcapi.FieldTypeInt, TableName: "r", FieldName: "fieldInt"},
+		{FieldType: evalcapi.FieldTypeString, TableName: "r", FieldName: "fieldStr"},
+		{FieldType: evalcapi.FieldTypeDateTime, TableName: "r", FieldName: "fieldTime"}}
+
+	exp, err := parser.ParseExpr("strings.ToUpper(substr(strings.TrimSpace(r.fieldStr), r.fieldInt, 2)) + re.FindStringSubmatch(`(\\d+)`, r.fieldStr, 1)")
+	assert.Nil(t, err)
+	assert.Nil(t, evalExpressionWithFieldRefsAndCheckType(exp, fieldRefs, evalcapi.FieldTypeString))
+
+	exp, err = parser.ParseExpr(`strings.HasPrefix(r.fieldStr, "1") && strings.Index(r.fieldStr, "3") == (2)`)
+	assert.Nil(t, err)
+	assert.Nil(t, evalExpressionWithFieldRefsAndCheckType(exp, fieldRefs, evalcapi.FieldTypeBool))
+
+	exp, err = parser.ParseExpr(`substr(r.fieldInt, 0, 2)`)
+	assert.Nil(t, err)
+	assert.Equal(t, "substr() arg 1 must be string, got int", evalExpressionWithFieldRefsAndCheckType(exp, fieldRefs, evalcapi.FieldTypeString).Error())
+
+	exp, err = parser.ParseExpr(`strings.Split(r.fieldStr, ",", strings.Index(r.fieldStr, "a")) + strings.Repeat(strings.Contains(r.fieldStr, "a"), "2")`)
+	assert.Nil(t, err)
+	assert.Equal(t, "strings.Repeat() arg 1 must be string, got bool; strings.Repeat() arg 2 must be int, got string", evalExpressionWithFieldRefsAndCheckType(exp, fieldRefs, evalcapi.FieldTypeString).Error())
+
+	exp, err = parser.ParseExpr(`strings.Trim(r.fieldStr)`)
+	assert.Nil(t, err)
+	assert.Equal(t, "strings.Trim() requires 2 args, 1 supplied", evalExpressionWithFieldRefsAndCheckType(exp, fieldRefs, evalcapi.FieldTypeString).Error())
+
+	exp, err = parser.ParseExpr(`time.BusinessDaysBetween(time.Truncate(r.fieldTime, "month"), time.EndOfMonth(r.fieldTime)) + time.BusinessDaysBetween(r.fieldTime, r.fieldTime, "2024-12-25")`)
+	assert.Nil(t, err)
+	assert.Nil(t, evalExpressionWithFieldRefsAndCheckType(exp, fieldRefs, evalcapi.FieldTypeInt))
+
+	exp, err = parser.ParseExpr(`time.BusinessDaysBetween(r.fieldTime, r.fieldTime, "", "") + time.Year(r.fieldInt)`)
+	assert.Nil(t, err)
+	assert.Equal(t, "time.BusinessDaysBetween() requires 2 to 3 args, 4 supplied; time.Year() arg 1 must be datetime, got int", evalExpressionWithFieldRefsAndCheckType(exp, fieldRefs, evalcapi.FieldTypeInt).Error())
+
+	// Sample evaluation still catches what signatures cannot tell
+	exp, err = parser.ParseExpr("re.FindStringSubmatch(`(\\d+)`, r.fieldStr, 2)")
+	assert.Nil(t, err)
+	assert.Contains(t, evalExpressionWithFieldRefsAndCheckType(exp, fieldRefs, evalcapi.FieldTypeString).Error(), `pattern (\d+) has no group 2`)
+}
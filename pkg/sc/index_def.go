@@ -47,9 +47,32 @@ type IdxUniqueness string
 const (
 	IdxUnique            IdxUniqueness = "unique"
 	IdxNonUnique         IdxUniqueness = "non_unique"
+	IdxAsOf              IdxUniqueness = "as_of" // Non-unique, the last datetime component is stored separately and can be read in key order by as-of lookups
 	IdxUniquenessUnknown IdxUniqueness = "unknown"
 )
 
+// As-of idx key is the key of all components but the last one, as-of key is the last (datetime) component key,
+// which always has the same length, see BuildKey
+const asOfComponentKeyLen = 20
+
+func SplitAsOfIdxKey(fullKey string) (string, string) {
+	return fullKey[:len(fullKey)-asOfComponentKeyLen], fullKey[len(fullKey)-asOfComponentKeyLen:]
+}
+
+func (idxDef *IdxDef) checkAsOfComponent() error {
+	if len(idxDef.Components) < 2 {
+		return errors.New("as_of index needs at least one component before the datetime component")
+	}
+	asOfComp := idxDef.Components[len(idxDef.Components)-1]
+	if asOfComp.FieldType != evalcapi.FieldTypeDateTime {
+		return fmt.Errorf("as_of index last component %s has type %s, expected %s", asOfComp.FieldName, asOfComp.FieldType, evalcapi.FieldTypeDateTime)
+	}
+	if asOfComp.SortOrder == IdxSortDesc {
+		return fmt.Errorf("as_of index last component %s cannot use desc sort order", asOfComp.FieldName)
+	}
+	return nil
+}
+
 type IdxDef struct {
 	Uniqueness IdxUniqueness
 	Components []IdxComponentDef
@@ -163,6 +186,11 @@ func (idxDef *IdxDef) parseComponentExpr(fldExp *ast.Expr, fieldRefs *FieldRefs)
 			"invalid expression in index component definition, expected 'field([modifiers])' or 'field' where 'field' is one of the fields of the table created by this node")
 	}
 
+	// Keys are built from values, null has no key representation
+	if fieldRef, ok := fieldRefs.FindByFieldName(idxCompDef.FieldName); ok && fieldRef.Nullable {
+		return fmt.Errorf("cannot use nullable field %s in index component definition", idxCompDef.FieldName)
+	}
+
 	// Apply defaults if no modifiers supplied: string -> case sensitive, ordered idx -> sort asc
 	if idxCompDef.FieldType == evalcapi.FieldTypeString && idxCompDef.CaseSensitivity == IdxCaseSensitivityUnknown {
 		idxCompDef.CaseSensitivity = IdxCaseSensitive
@@ -202,17 +230,27 @@ func (idxDefMap *IdxDefMap) parseRawIndexDefMap(rawIdxDefMap map[string]string,
 				idxDef.Uniqueness = IdxUnique
 			case string(IdxNonUnique):
 				idxDef.Uniqueness = IdxNonUnique
+			case string(IdxAsOf):
+				idxDef.Uniqueness = IdxAsOf
 			default:
 				return fmt.Errorf(
-					"cannot parse index def [%s]: expected top level unique()) or non_unique() definition, found %s",
+					"cannot parse index def [%s]: expected top level unique(), non_unique() or as_of() definition, found %s",
 					rawIdxDef, identExp.Name)
 			}
 
 			// Walk through args - idx field components
+			componentsOk := true
 			for _, fldExp := range typedExp.Args {
 				err := idxDef.parseComponentExpr(&fldExp, fieldRefs)
 				if err != nil {
 					foundErrors = append(foundErrors, fmt.Sprintf("index %s: [%s]", rawIdxDef, err.Error()))
+					componentsOk = false
+				}
+			}
+
+			if idxDef.Uniqueness == IdxAsOf && componentsOk {
+				if err := idxDef.checkAsOfComponent(); err != nil {
+					foundErrors = append(foundErrors, fmt.Sprintf("index %s: [%s]", rawIdxDef, err.Error()))
 				}
 			}
 
@@ -221,7 +259,7 @@ func (idxDefMap *IdxDefMap) parseRawIndexDefMap(rawIdxDefMap map[string]string,
 
 		default:
 			return fmt.Errorf(
-				"cannot parse index def [%s]: expected top level unique()) or non_unique() definition, found unknown expression",
+				"cannot parse index def [%s]: expected top level unique(), non_unique() or as_of() definition, found unknown expression",
 				rawIdxDef)
 		}
 	}
@@ -2,6 +2,7 @@ package sc
 
 import (
 	"testing"
+	"time"
 
 	"github.com/capillariesio/capillaries/pkg/evalcapi"
 	"github.com/stretchr/testify/assert"
@@ -17,18 +18,19 @@ func assertIdxComp(t *testing.T, fName string, fType evalcapi.TableFieldType, ca
 
 func TestIndexDefParser(t *testing.T) {
 	fieldRefs := FieldRefs{
-		FieldRef{"t1", "f_int", evalcapi.FieldTypeInt},
-		FieldRef{"t1", "f_float", evalcapi.FieldTypeFloat},
-		FieldRef{"t1", "f_bool", evalcapi.FieldTypeBool},
-		FieldRef{"t1", "f_str", evalcapi.FieldTypeString},
-		FieldRef{"t1", "f_time", evalcapi.FieldTypeDateTime},
-		FieldRef{"t1", "f_dec", evalcapi.FieldTypeDecimal2},
+		FieldRef{TableName: "t1", FieldName: "f_int", FieldType: evalcapi.FieldTypeInt},
+		FieldRef{TableName: "t1", FieldName: "f_float", FieldType: evalcapi.FieldTypeFloat},
+		FieldRef{TableName: "t1", FieldName: "f_bool", FieldType: evalcapi.FieldTypeBool},
+		FieldRef{TableName: "t1", FieldName: "f_str", FieldType: evalcapi.FieldTypeString},
+		FieldRef{TableName: "t1", FieldName: "f_time", FieldType: evalcapi.FieldTypeDateTime},
+		FieldRef{TableName: "t1", FieldName: "f_dec", FieldType: evalcapi.FieldTypeDecimal2},
 	}
 	rawIdxDefMap := map[string]string{
 		"idx_all_default": "non_unique(f_int(),f_float(),f_bool(),f_str(),f_time(),f_dec())",
 		"idx_all_desc":    "unique(f_int(desc),f_float(desc),f_bool(desc),f_str(desc,ignore_case,128),f_time(desc),f_dec(desc))",
 		"idx_all_asc":     "unique(f_int(asc),f_float(asc),f_bool(asc),f_str(asc,case_sensitive,15),f_time(asc),f_dec(asc))",
 		"idx_no_mods":     "unique(f_int,f_float,f_bool,f_str,f_time,f_dec)",
+		"idx_as_of":       "as_of(f_str(16),f_int,f_time)",
 	}
 	idxDefMap := IdxDefMap{}
 	assert.Nil(t, idxDefMap.parseRawIndexDefMap(rawIdxDefMap, &fieldRefs))
@@ -74,21 +76,27 @@ func TestIndexDefParser(t *testing.T) {
 	assertIdxComp(t, "f_time", evalcapi.FieldTypeDateTime, IdxCaseSensitivityUnknown, IdxSortAsc, DefaultStringComponentLen, &idxDefMap["idx_no_mods"].Components[4])
 	assertIdxComp(t, "f_dec", evalcapi.FieldTypeDecimal2, IdxCaseSensitivityUnknown, IdxSortAsc, DefaultStringComponentLen, &idxDefMap["idx_no_mods"].Components[5])
 
+	assert.Equal(t, IdxAsOf, idxDefMap["idx_as_of"].Uniqueness)
+	fullKey, err := BuildKey(map[string]any{"f_str": "abc", "f_int": int64(-5), "f_time": time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)}, idxDefMap["idx_as_of"])
+	assert.Nil(t, err)
+	key, asOfKey := SplitAsOfIdxKey(fullKey)
+	assert.Equal(t, "abc             -99999999999999999994", key)
+	assert.Equal(t, "00063808214400000000", asOfKey)
 }
 
 func TestIndexDefParserBad(t *testing.T) {
 	fieldRefs := FieldRefs{
-		FieldRef{"t1", "f_int", evalcapi.FieldTypeInt},
-		FieldRef{"t1", "f_float", evalcapi.FieldTypeFloat},
-		FieldRef{"t1", "f_bool", evalcapi.FieldTypeBool},
-		FieldRef{"t1", "f_str", evalcapi.FieldTypeString},
-		FieldRef{"t1", "f_time", evalcapi.FieldTypeDateTime},
-		FieldRef{"t1", "f_dec", evalcapi.FieldTypeDecimal2},
+		FieldRef{TableName: "t1", FieldName: "f_int", FieldType: evalcapi.FieldTypeInt},
+		FieldRef{TableName: "t1", FieldName: "f_float", FieldType: evalcapi.FieldTypeFloat},
+		FieldRef{TableName: "t1", FieldName: "f_bool", FieldType: evalcapi.FieldTypeBool},
+		FieldRef{TableName: "t1", FieldName: "f_str", FieldType: evalcapi.FieldTypeString},
+		FieldRef{TableName: "t1", FieldName: "f_time", FieldType: evalcapi.FieldTypeDateTime},
+		FieldRef{TableName: "t1", FieldName: "f_dec", FieldType: evalcapi.FieldTypeDecimal2},
 	}
 	rawIdxDefMap := map[string]string{"idx_bad_unique": "somename(f_int,f_float,f_bool,f_str,f_time,f_dec)"}
 	idxDefMap := IdxDefMap{}
 	err := idxDefMap.parseRawIndexDefMap(rawIdxDefMap, &fieldRefs)
-	assert.Equal(t, "cannot parse index def [somename(f_int,f_float,f_bool,f_str,f_time,f_dec)]: expected top level unique()) or non_unique() definition, found somename", err.Error())
+	assert.Equal(t, "cannot parse index def [somename(f_int,f_float,f_bool,f_str,f_time,f_dec)]: expected top level unique(), non_unique() or as_of() definition, found somename", err.Error())
 
 	rawIdxDefMap = map[string]string{"idx_bad_field": "unique(somefield1,somefield2(),f_bool,f_str,f_time,f_dec)"}
 	idxDefMap = IdxDefMap{}
@@ -125,8 +133,23 @@ func TestIndexDefParserBad(t *testing.T) {
 	err = idxDefMap.parseRawIndexDefMap(rawIdxDefMap, &fieldRefs)
 	assert.Equal(t, "cannot parse order def 'unique(': 1:8: expected ')', found 'EOF'", err.Error())
 
+	rawIdxDefMap = map[string]string{"idx_bad_as_of_type": "as_of(f_str,f_int)"}
+	idxDefMap = IdxDefMap{}
+	err = idxDefMap.parseRawIndexDefMap(rawIdxDefMap, &fieldRefs)
+	assert.Equal(t, "cannot parse order definitions: [index as_of(f_str,f_int): [as_of index last component f_int has type int, expected datetime]]", err.Error())
+
+	rawIdxDefMap = map[string]string{"idx_bad_as_of_desc": "as_of(f_str,f_time(desc))"}
+	idxDefMap = IdxDefMap{}
+	err = idxDefMap.parseRawIndexDefMap(rawIdxDefMap, &fieldRefs)
+	assert.Equal(t, "cannot parse order definitions: [index as_of(f_str,f_time(desc)): [as_of index last component f_time cannot use desc sort order]]", err.Error())
+
+	rawIdxDefMap = map[string]string{"idx_bad_as_of_single": "as_of(f_time)"}
+	idxDefMap = IdxDefMap{}
+	err = idxDefMap.parseRawIndexDefMap(rawIdxDefMap, &fieldRefs)
+	assert.Equal(t, "cannot parse order definitions: [index as_of(f_time): [as_of index needs at least one component before the datetime component]]", err.Error())
+
 	rawIdxDefMap = map[string]string{"idx_bad_no_call": "unique"}
 	idxDefMap = IdxDefMap{}
 	err = idxDefMap.parseRawIndexDefMap(rawIdxDefMap, &fieldRefs)
-	assert.Equal(t, "cannot parse index def [unique]: expected top level unique()) or non_unique() definition, found unknown expression", err.Error())
+	assert.Equal(t, "cannot parse index def [unique]: expected top level unique(), non_unique() or as_of() definition, found unknown expression", err.Error())
 }
@@ -23,7 +23,7 @@ func getNumericValueSign(v any, expectedType evalcapi.TableFieldType) (string, a
 	var newVal any
 	var ok bool
 
-	switch expectedType {
+	switch evalcapi.FieldTypeKind(expectedType) {
 	case evalcapi.FieldTypeInt:
 		var n int64
 		if n, ok = v.(int64); !ok {
@@ -69,6 +69,23 @@ func getNumericValueSign(v any, expectedType evalcapi.TableFieldType) (string, a
 	return sign, newVal, nil
 }
 
+// sign+(p-s)digit+point+s, absVal is expected to be non-negative
+func decimalKeyString(sign string, absVal decimal.Decimal, precision int32, scale int32) (string, error) {
+	intPart, fracPart, _ := strings.Cut(absVal.StringFixed(scale), ".")
+	intWidth := int(precision - scale)
+	if intPart == "0" {
+		intPart = ""
+	}
+	if len(intPart) > intWidth {
+		return "", fmt.Errorf("cannot build key, value %s does not fit decimal(%d,%d)", absVal.String(), precision, scale)
+	}
+	stringValue := sign + strings.Repeat("0", intWidth-len(intPart)) + intPart
+	if scale > 0 {
+		stringValue += "." + fracPart
+	}
+	return stringValue, nil
+}
+
 func BuildKey(fieldMap map[string]any, idxDef *IdxDef) (string, error) {
 	var keyBuffer bytes.Buffer
 	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
@@ -81,7 +98,7 @@ func BuildKey(fieldMap map[string]any, idxDef *IdxDef) (string, error) {
 
 		var stringValue string
 
-		switch comp.FieldType {
+		switch evalcapi.FieldTypeKind(comp.FieldType) {
 		case evalcapi.FieldTypeInt:
 			sign, absVal, err := getNumericValueSign(fieldMap[comp.FieldName], evalcapi.FieldTypeInt)
 			if err != nil {
@@ -106,16 +123,23 @@ func BuildKey(fieldMap map[string]any, idxDef *IdxDef) (string, error) {
 			}
 
 		case evalcapi.FieldTypeDecimal2:
-			sign, absVal, err := getNumericValueSign(fieldMap[comp.FieldName], evalcapi.FieldTypeDecimal2)
+			sign, absVal, err := getNumericValueSign(fieldMap[comp.FieldName], comp.FieldType)
 			if err != nil {
 				return "", err
 			}
 			decVal, ok := absVal.(decimal.Decimal)
 			if !ok {
-				return "", fmt.Errorf("unexpectedly cannot convert value %v to type decimal2", fieldMap[comp.FieldName])
+				return "", fmt.Errorf("unexpectedly cannot convert value %v to type %s", fieldMap[comp.FieldName], comp.FieldType)
+			}
+			if precision, scale, ok := evalcapi.ParseDecimalFieldType(comp.FieldType); ok {
+				// Exact, unlike decimal2 that goes through float64
+				if stringValue, err = decimalKeyString(sign, decVal, precision, scale); err != nil {
+					return "", err
+				}
+			} else {
+				floatVal, _ := decVal.Float64()
+				stringValue = strings.ReplaceAll(fmt.Sprintf("%s%66s", sign, fmt.Sprintf("%.32f", floatVal)), " ", "0")
 			}
-			floatVal, _ := decVal.Float64()
-			stringValue = strings.ReplaceAll(fmt.Sprintf("%s%66s", sign, fmt.Sprintf("%.32f", floatVal)), " ", "0")
 			// If this is a negative value, flip every digit
 			if sign == "-" {
 				stringValue = flipReplacer.Replace(stringValue)
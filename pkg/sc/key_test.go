@@ -367,6 +367,51 @@ func TestDecimal(t *testing.T) {
 	assertKeyCompare(t, row1, ">", row2, idxDef)
 }
 
+func TestDecimalPrecisionScale(t *testing.T) {
+	idxDef := IdxDef{
+		Uniqueness: "UNIQUE",
+		Components: []IdxComponentDef{{FieldName: "fld", FieldType: evalcapi.DecimalFieldType(30, 4), SortOrder: IdxSortAsc}}}
+
+	key, err := BuildKey(map[string]any{"fld": decimal.RequireFromString("12.5")}, &idxDef)
+	assert.Nil(t, err)
+	assert.Equal(t, "000000000000000000000000012.5000", key)
+
+	key, err = BuildKey(map[string]any{"fld": decimal.RequireFromString("-12.5")}, &idxDef)
+	assert.Nil(t, err)
+	assert.Equal(t, "-99999999999999999999999987.4999", key)
+
+	// Beyond float64 precision
+	row1 := map[string]any{"fld": decimal.RequireFromString("12345678901234567890123456.0001")}
+	row2 := map[string]any{"fld": decimal.RequireFromString("12345678901234567890123456.0002")}
+	assertKeyCompare(t, row1, "<", row2, idxDef)
+
+	row1 = map[string]any{"fld": decimal.RequireFromString("-12345678901234567890123456.0001")}
+	row2 = map[string]any{"fld": decimal.RequireFromString("-12345678901234567890123456.0002")}
+	assertKeyCompare(t, row1, ">", row2, idxDef)
+
+	row1 = map[string]any{"fld": decimal.RequireFromString("-0.0001")}
+	row2 = map[string]any{"fld": decimal.RequireFromString("0")}
+	assertKeyCompare(t, row1, "<", row2, idxDef)
+
+	row1 = map[string]any{"fld": decimal.RequireFromString("-100")}
+	row2 = map[string]any{"fld": decimal.RequireFromString("-99.9999")}
+	assertKeyCompare(t, row1, "<", row2, idxDef)
+
+	idxDef.Components[0].SortOrder = IdxSortDesc
+	row1 = map[string]any{"fld": decimal.RequireFromString("1.0001")}
+	row2 = map[string]any{"fld": decimal.RequireFromString("1")}
+	assertKeyCompare(t, row1, "<", row2, idxDef)
+
+	idxDef.Components[0].FieldType = evalcapi.DecimalFieldType(5, 0)
+	idxDef.Components[0].SortOrder = IdxSortAsc
+	key, err = BuildKey(map[string]any{"fld": decimal.RequireFromString("42")}, &idxDef)
+	assert.Nil(t, err)
+	assert.Equal(t, "000042", key)
+
+	_, err = BuildKey(map[string]any{"fld": decimal.RequireFromString("123456")}, &idxDef)
+	assert.Contains(t, err.Error(), "cannot build key, value 123456 does not fit decimal(5,0)")
+}
+
 func TestGetNUmericValueSign(t *testing.T) {
 	_, _, err := getNumericValueSign(nil, evalcapi.FieldTypeUnknown)
 	assert.Contains(t, err.Error(), "cannot convert value <nil> to type unknown")
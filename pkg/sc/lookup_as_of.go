@@ -0,0 +1,76 @@
+package sc
+
+import (
+	"fmt"
+
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+)
+
+type AsOfDirection string
+
+const (
+	AsOfBackward AsOfDirection = "backward" // Default: latest right row at or before the left datetime
+	AsOfForward  AsOfDirection = "forward"  // Earliest right row at or after the left datetime
+)
+
+// As-of lookup: for each left row, pick the single right row with nearest datetime.
+// Lookup index is an as_of() index: join_on fields followed by one datetime component.
+type AsOfDef struct {
+	RawOn     string        `json:"on" yaml:"on"`
+	Direction AsOfDirection `json:"direction" yaml:"direction"`
+	LeftField FieldRef      `json:"-" yaml:"-"`
+}
+
+func (lkpDef *LookupDef) IsAsOf() bool {
+	return lkpDef.AsOf != nil
+}
+
+func (asOfDef *AsOfDef) resolveLeftField(srcName string, srcFieldRefs *FieldRefs) error {
+	srcFieldRef, err := resolveLeftTableField(asOfDef.RawOn, srcName, srcFieldRefs)
+	if err != nil {
+		return fmt.Errorf("cannot resolve as-of field: %s", err.Error())
+	}
+	if srcFieldRef.FieldType != evalcapi.FieldTypeDateTime {
+		return fmt.Errorf("as-of field %s has type %s, expected %s", srcFieldRef.FieldName, srcFieldRef.FieldType, evalcapi.FieldTypeDateTime)
+	}
+	asOfDef.LeftField = *srcFieldRef
+	return nil
+}
+
+func (lkpDef *LookupDef) ValidateAsOf() error {
+	if !lkpDef.IsAsOf() {
+		return nil
+	}
+	if len(lkpDef.AsOf.Direction) == 0 {
+		lkpDef.AsOf.Direction = AsOfBackward
+	} else if lkpDef.AsOf.Direction != AsOfBackward && lkpDef.AsOf.Direction != AsOfForward {
+		return fmt.Errorf("invalid as-of direction, expected backward or forward, %s is not supported", lkpDef.AsOf.Direction)
+	}
+	if lkpDef.IsGroup {
+		return fmt.Errorf("as-of lookup cannot be grouped")
+	}
+	if lkpDef.UsesFilter() {
+		return fmt.Errorf("as-of lookup does not support filter")
+	}
+	if lkpDef.LookupJoin != LookupJoinInner && lkpDef.LookupJoin != LookupJoinLeft {
+		return fmt.Errorf("as-of lookup supports only inner or left join, %s is not supported", lkpDef.LookupJoin)
+	}
+	return nil
+}
+
+// Returns the as_of() idx key built from join fields and the as-of key built from the left as-of datetime,
+// same way the lookup table rows are indexed
+func (lkpDef *LookupDef) BuildAsOfKeys(leftVars map[string]any) (string, string, error) {
+	idxDef := lkpDef.TableCreator.Indexes[lkpDef.IndexName]
+	fieldMap := make(map[string]any, len(idxDef.Components))
+	for i := 0; i < len(lkpDef.LeftTableFields); i++ {
+		fieldMap[idxDef.Components[i].FieldName] = leftVars[lkpDef.LeftTableFields[i].FieldName]
+	}
+	fieldMap[idxDef.Components[len(idxDef.Components)-1].FieldName] = leftVars[lkpDef.AsOf.LeftField.FieldName]
+	fullKey, err := BuildKey(fieldMap, idxDef)
+	if err != nil {
+		return "", "", err
+	}
+	key, asOfKey := SplitAsOfIdxKey(fullKey)
+	return key, asOfKey, nil
+}
@@ -14,6 +14,8 @@ type LookupJoinType string
 const (
 	LookupJoinInner LookupJoinType = "inner"
 	LookupJoinLeft  LookupJoinType = "left"
+	LookupJoinRight LookupJoinType = "right"
+	LookupJoinFull  LookupJoinType = "full"
 )
 
 type LookupDef struct {
@@ -24,6 +26,7 @@ type LookupDef struct {
 	LookupJoin               LookupJoinType `json:"join_type" yaml:"join_type"`
 	IdxReadBatchSize         int            `json:"idx_read_batch_size" yaml:"idx_read_batch_size"`
 	RightLookupReadBatchSize int            `json:"right_lookup_read_batch_size" yaml:"right_lookup_read_batch_size"`
+	AsOf                     *AsOfDef       `json:"as_of,omitempty" yaml:"as_of,omitempty"`
 
 	LeftTableFields    FieldRefs        // In the same order as lookup idx - important
 	TableCreator       *TableCreatorDef // Populated when walking through al nodes
@@ -59,12 +62,26 @@ func (lkpDef *LookupDef) UsesFilter() bool {
 }
 
 func (lkpDef *LookupDef) ValidateJoinType() error {
-	if lkpDef.LookupJoin != LookupJoinLeft && lkpDef.LookupJoin != LookupJoinInner {
-		return fmt.Errorf("invalid join type, expected inner or left, %s is not supported", lkpDef.LookupJoin)
+	if lkpDef.LookupJoin != LookupJoinLeft && lkpDef.LookupJoin != LookupJoinInner && lkpDef.LookupJoin != LookupJoinRight && lkpDef.LookupJoin != LookupJoinFull {
+		return fmt.Errorf("invalid join type, expected inner, left, right or full, %s is not supported", lkpDef.LookupJoin)
+	}
+	if lkpDef.IsGroup && lkpDef.IncludesUnmatchedRight() {
+		return fmt.Errorf("invalid join type, grouped lookups support only inner or left, %s is not supported", lkpDef.LookupJoin)
 	}
 	return nil
 }
 
+// Left rows without right counterparts are written with right-side fields set to defaults
+func (lkpDef *LookupDef) IncludesUnmatchedLeft() bool {
+	return lkpDef.LookupJoin == LookupJoinLeft || lkpDef.LookupJoin == LookupJoinFull
+}
+
+// Right rows without left counterparts are written with left-side fields set to defaults.
+// This is done by a dedicated batch, see ScriptNodeDef.IsUnmatchedRightBatch()
+func (lkpDef *LookupDef) IncludesUnmatchedRight() bool {
+	return lkpDef.LookupJoin == LookupJoinRight || lkpDef.LookupJoin == LookupJoinFull
+}
+
 func (lkpDef *LookupDef) ParseFilter() error {
 	if !lkpDef.UsesFilter() {
 		return nil
@@ -77,31 +94,56 @@ func (lkpDef *LookupDef) ParseFilter() error {
 	return nil
 }
 
+func resolveLeftTableField(rawFieldExpression string, srcName string, srcFieldRefs *FieldRefs) (*FieldRef, error) {
+	fieldNameParts := strings.Split(strings.TrimSpace(rawFieldExpression), ".")
+	if len(fieldNameParts) != 2 {
+		return nil, fmt.Errorf("expected <table_name>.<field_name>, got [%s]", rawFieldExpression)
+	}
+	tName := strings.TrimSpace(fieldNameParts[0])
+	fName := strings.TrimSpace(fieldNameParts[1])
+	if tName != srcName {
+		return nil, fmt.Errorf("source table name [%s] unknown, expected [%s]", tName, srcName)
+	}
+	srcFieldRef, ok := srcFieldRefs.FindByFieldName(fName)
+	if !ok {
+		return nil, fmt.Errorf("source [%s] does not produce field [%s]", tName, fName)
+	}
+	if srcFieldRef.FieldType == evalcapi.FieldTypeUnknown {
+		return nil, fmt.Errorf("source field [%s.%s] has unknown type", tName, fName)
+	}
+	return srcFieldRef, nil
+}
+
 func (lkpDef *LookupDef) resolveLeftTableFields(srcName string, srcFieldRefs *FieldRefs) error {
 	fieldExpressions := strings.Split(lkpDef.RawJoinOn, ",")
 	lkpDef.LeftTableFields = make(FieldRefs, len(fieldExpressions))
 	for fieldIdx := 0; fieldIdx < len(fieldExpressions); fieldIdx++ {
-		fieldNameParts := strings.Split(strings.TrimSpace(fieldExpressions[fieldIdx]), ".")
-		if len(fieldNameParts) != 2 {
+		if len(strings.Split(strings.TrimSpace(fieldExpressions[fieldIdx]), ".")) != 2 {
 			return fmt.Errorf("expected a comma-separated list of <table_name>.<field_name>, got [%s]", lkpDef.RawJoinOn)
 		}
-		tName := strings.TrimSpace(fieldNameParts[0])
-		fName := strings.TrimSpace(fieldNameParts[1])
-		if tName != srcName {
-			return fmt.Errorf("source table name [%s] unknown, expected [%s]", tName, srcName)
-		}
-		srcFieldRef, ok := srcFieldRefs.FindByFieldName(fName)
-		if !ok {
-			return fmt.Errorf("source [%s] does not produce field [%s]", tName, fName)
-		}
-		if srcFieldRef.FieldType == evalcapi.FieldTypeUnknown {
-			return fmt.Errorf("source field [%s.%s] has unknown type", tName, fName)
+		srcFieldRef, err := resolveLeftTableField(fieldExpressions[fieldIdx], srcName, srcFieldRefs)
+		if err != nil {
+			return err
 		}
 		lkpDef.LeftTableFields[fieldIdx] = *srcFieldRef
 	}
 
 	// Verify lookup idx has this field and the type matches
-	idxFieldRefs := lkpDef.TableCreator.Indexes[lkpDef.IndexName].getComponentFieldRefs(lkpDef.TableCreator.Name)
+	idxDef := lkpDef.TableCreator.Indexes[lkpDef.IndexName]
+	idxFieldRefs := idxDef.getComponentFieldRefs(lkpDef.TableCreator.Name)
+	if lkpDef.IsAsOf() {
+		// As-of lookup index has an extra datetime component at the end
+		if idxDef.Uniqueness != IdxAsOf {
+			return fmt.Errorf("as-of lookup index %s must be an as_of() index, for example as_of(ticker,price_date)", lkpDef.IndexName)
+		}
+		if err := lkpDef.AsOf.resolveLeftField(srcName, srcFieldRefs); err != nil {
+			return err
+		}
+		idxFieldRefs = idxFieldRefs[:len(idxFieldRefs)-1]
+	} else if idxDef.Uniqueness == IdxAsOf {
+		return fmt.Errorf("lookup index %s is an as_of() index, it can be used only by as-of lookups", lkpDef.IndexName)
+	}
+
 	if len(idxFieldRefs) != len(lkpDef.LeftTableFields) {
 		return fmt.Errorf("lookup joins on %d fields, while referenced index %s uses %d fields, these lengths need to be the same", len(lkpDef.LeftTableFields), lkpDef.IndexName, len(idxFieldRefs))
 	}
@@ -128,6 +170,9 @@ func (lkpDef *LookupDef) CheckFilterCondition(varsFromLookup eval.VarValuesMap)
 	if err != nil {
 		return false, fmt.Errorf("cannot evaluate expression: [%s]", err.Error())
 	}
+	if valVolatile == nil {
+		return false, nil
+	}
 	valBool, ok := valVolatile.(bool)
 	if !ok {
 		return false, fmt.Errorf("cannot evaluate lookup filter condition expression, expected bool, got %v(%T) instead", valVolatile, valVolatile)
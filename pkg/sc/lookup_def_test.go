@@ -1,8 +1,11 @@
 package sc
 
 import (
+	"math"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -248,3 +251,106 @@ func TestLookupDef(t *testing.T) {
 		scriptDef.Deserialize([]byte(re.ReplaceAllString(scriptDefJson, `"join_on": "",`)), ScriptJson, nil, nil, "", nil).Error(),
 		"failed to resolve lookup for node order_item_date_inner: [expected a comma-separated list of <table_name>.<field_name>, got []]")
 }
+
+func TestLookupDefRightJoin(t *testing.T) {
+	scriptDef := ScriptDef{}
+	assert.Nil(t, scriptDef.Deserialize([]byte(strings.Replace(scriptDefJson, `"join_type": "inner"`, `"join_type": "right"`, 1)), ScriptJson, nil, nil, "", nil))
+
+	node := scriptDef.ScriptNodes["order_item_date_inner"]
+	assert.True(t, node.Lookup.IncludesUnmatchedRight())
+	assert.False(t, node.Lookup.IncludesUnmatchedLeft())
+
+	// One extra batch for right rows without left counterparts
+	intervals, err := node.GetTokenIntervalsByNumberOfBatches()
+	assert.Nil(t, err)
+	assert.Equal(t, 101, len(intervals))
+	assert.Equal(t, int64(math.MinInt64), intervals[100][0])
+	assert.Equal(t, int64(math.MaxInt64), intervals[100][1])
+	assert.False(t, node.IsUnmatchedRightBatch(99))
+	assert.True(t, node.IsUnmatchedRightBatch(100))
+
+	assert.Nil(t, scriptDef.Deserialize([]byte(strings.Replace(scriptDefJson, `"join_type": "inner"`, `"join_type": "full"`, 1)), ScriptJson, nil, nil, "", nil))
+	node = scriptDef.ScriptNodes["order_item_date_inner"]
+	assert.True(t, node.Lookup.IncludesUnmatchedRight())
+	assert.True(t, node.Lookup.IncludesUnmatchedLeft())
+
+	assert.Nil(t, scriptDef.Deserialize([]byte(scriptDefJson), ScriptJson, nil, nil, "", nil))
+	node = scriptDef.ScriptNodes["order_item_date_inner"]
+	intervals, err = node.GetTokenIntervalsByNumberOfBatches()
+	assert.Nil(t, err)
+	assert.Equal(t, 100, len(intervals))
+	assert.False(t, node.IsUnmatchedRightBatch(100))
+
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(strings.Replace(scriptDefJson, `"join_type": "inner"`, `"join_type": "right"`, 1), `"group": false`, `"group": true`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"invalid join type, grouped lookups support only inner or left, right is not supported")
+}
+
+func TestLookupDefAsOf(t *testing.T) {
+	asOfJson := strings.Replace(scriptDefJson, `"non_unique(order_id(case_sensitive))"`, `"as_of(order_id(case_sensitive),shipping_limit_date)"`, 1)
+	asOfJson = strings.Replace(asOfJson, `"filter": "len(l.product_id) > 0",`, `"as_of": {"on": "r.order_purchase_timestamp"},`, 1)
+
+	scriptDef := ScriptDef{}
+	assert.Nil(t, scriptDef.Deserialize([]byte(asOfJson), ScriptJson, nil, nil, "", nil))
+	node := scriptDef.ScriptNodes["order_item_date_inner"]
+	assert.True(t, node.Lookup.IsAsOf())
+	assert.Equal(t, AsOfBackward, node.Lookup.AsOf.Direction)
+	assert.Equal(t, "order_purchase_timestamp", node.Lookup.AsOf.LeftField.FieldName)
+
+	assert.Nil(t, scriptDef.Deserialize([]byte(strings.Replace(asOfJson, `"as_of": {"on": "r.order_purchase_timestamp"}`, `"as_of": {"on": "r.order_purchase_timestamp", "direction": "forward"}`, 1)), ScriptJson, nil, nil, "", nil))
+	assert.Equal(t, AsOfForward, scriptDef.ScriptNodes["order_item_date_inner"].Lookup.AsOf.Direction)
+
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(asOfJson, `"as_of": {"on": "r.order_purchase_timestamp"}`, `"as_of": {"on": "r.order_purchase_timestamp", "direction": "nearest"}`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"invalid as-of direction, expected backward or forward, nearest is not supported")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(asOfJson, `"as_of": {"on": "r.order_purchase_timestamp"}`, `"as_of": {"on": "r.order_status"}`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"as-of field order_status has type string, expected datetime")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(asOfJson, `"as_of": {"on": "r.order_purchase_timestamp"}`, `"as_of": {"on": "r.order_purchase_timestamp"}, "filter": "len(l.product_id) > 0"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"as-of lookup does not support filter")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(asOfJson, `"group": false`, `"group": true`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"as-of lookup cannot be grouped")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(asOfJson, `"join_type": "inner"`, `"join_type": "full"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"as-of lookup supports only inner or left join, full is not supported")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(scriptDefJson, `"filter": "len(l.product_id) > 0",`, `"as_of": {"on": "r.order_purchase_timestamp"},`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"as-of lookup index idx_order_items_order_id must be an as_of() index, for example as_of(ticker,price_date)")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(asOfJson, `"as_of": {"on": "r.order_purchase_timestamp"},`, ``, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"lookup index idx_order_items_order_id is an as_of() index, it can be used only by as-of lookups")
+}
+
+func TestLookupDefBuildAsOfKeys(t *testing.T) {
+	asOfJson := strings.Replace(scriptDefJson, `"non_unique(order_id(case_sensitive))"`, `"as_of(order_id(case_sensitive),shipping_limit_date)"`, 1)
+	asOfJson = strings.Replace(asOfJson, `"filter": "len(l.product_id) > 0",`, `"as_of": {"on": "r.order_purchase_timestamp"},`, 1)
+	scriptDef := ScriptDef{}
+	assert.Nil(t, scriptDef.Deserialize([]byte(asOfJson), ScriptJson, nil, nil, "", nil))
+	lkpDef := scriptDef.ScriptNodes["order_item_date_inner"].Lookup
+	idxDef := lkpDef.TableCreator.Indexes[lkpDef.IndexName]
+
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	// Left keys match lookup row keys, as-of keys sort the same way as datetimes
+	rightFullKey, err := BuildKey(map[string]any{"order_id": "a", "shipping_limit_date": day(10)}, idxDef)
+	assert.Nil(t, err)
+	rightKey, rightAsOfKey := SplitAsOfIdxKey(rightFullKey)
+
+	key, asOfKey, err := lkpDef.BuildAsOfKeys(map[string]any{"order_id": "a", "order_purchase_timestamp": day(10)})
+	assert.Nil(t, err)
+	assert.Equal(t, rightKey, key)
+	assert.Equal(t, rightAsOfKey, asOfKey)
+
+	_, laterAsOfKey, err := lkpDef.BuildAsOfKeys(map[string]any{"order_id": "a", "order_purchase_timestamp": day(11)})
+	assert.Nil(t, err)
+	assert.Less(t, asOfKey, laterAsOfKey)
+
+	otherKey, _, err := lkpDef.BuildAsOfKeys(map[string]any{"order_id": "b", "order_purchase_timestamp": day(10)})
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, otherKey)
+
+	_, _, err = lkpDef.BuildAsOfKeys(map[string]any{"order_id": "a", "order_purchase_timestamp": nil})
+	assert.NotNil(t, err)
+}
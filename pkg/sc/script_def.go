@@ -88,7 +88,7 @@ func (scriptDef *ScriptDef) checkDependencyPolicyUsage(scriptType ScriptType) er
 	}
 
 	for _, node := range scriptDef.ScriptNodes {
-		if node.HasTableReader() {
+		if node.HasTableReader() || node.HasUnionTableReader() {
 			if len(node.DependencyPolicyName) == 0 {
 				node.DepPolDef = defaultDepPol
 			} else {
@@ -145,6 +145,24 @@ func (scriptDef *ScriptDef) Deserialize(jsonOrYamlBytesScript []byte, scriptType
 		}
 	}
 
+	for _, node := range scriptDef.ScriptNodes {
+		if err := scriptDef.resolveWindow(node); err != nil {
+			return fmt.Errorf("failed to resolve window for node %s: [%s]", node.Name, err.Error())
+		}
+	}
+
+	for _, node := range scriptDef.ScriptNodes {
+		if err := scriptDef.resolveCustomProcessorPartitionIndex(node); err != nil {
+			return fmt.Errorf("failed to resolve custom processor partition index for node %s: [%s]", node.Name, err.Error())
+		}
+	}
+
+	for _, node := range scriptDef.ScriptNodes {
+		if err := scriptDef.resolveDataQuality(node); err != nil {
+			return fmt.Errorf("failed to resolve data quality rules for node %s: [%s]", node.Name, err.Error())
+		}
+	}
+
 	for idxName, creatorNodeDef := range scriptDef.IndexNodeMap {
 		if !scriptDef.isScriptUsesIdx(idxName) {
 			// TODO: this is a hack to allow indexes that are deliberately added to check uniqueness without Capillaries complaining "this idx is not used"
@@ -182,6 +200,18 @@ func (scriptDef *ScriptDef) resolveReader(node *ScriptNodeDef) error {
 			return fmt.Errorf("cannot find the node that creates table [%s]", node.TableReader.TableName)
 		}
 		node.TableReader.TableCreator = &tableCreatorNode.TableCreator
+	} else if node.HasUnionTableReader() {
+		for i := 0; i < len(node.UnionReader.Tables); i++ {
+			tableReader := &node.UnionReader.Tables[i]
+			tableCreatorNode, ok := scriptDef.TableCreatorNodeMap[tableReader.TableName]
+			if !ok {
+				return fmt.Errorf("cannot find the node that creates table [%s]", tableReader.TableName)
+			}
+			tableReader.TableCreator = &tableCreatorNode.TableCreator
+		}
+		if err := node.UnionReader.checkFieldRefsCompatibility(&node.TableCreator.UsedInTargetExpressionsFields); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -214,9 +244,76 @@ func (scriptDef *ScriptDef) resolveLookup(node *ScriptNodeDef) error {
 		return err
 	}
 
+	if err = node.Lookup.ValidateAsOf(); err != nil {
+		return err
+	}
+
 	return node.Lookup.CheckPagedBatchSize()
 }
 
+func (scriptDef *ScriptDef) resolveWindow(node *ScriptNodeDef) error {
+	if !node.HasWindow() {
+		return nil
+	}
+
+	idxCreatorNode, ok := scriptDef.IndexNodeMap[node.Window.IndexName]
+	if !ok {
+		return fmt.Errorf("cannot find the node that creates index [%s]", node.Window.IndexName)
+	}
+	if idxCreatorNode.TableCreator.Name != node.TableReader.TableName {
+		return fmt.Errorf("window index [%s] is created for table [%s], expected an index of the source table [%s]", node.Window.IndexName, idxCreatorNode.TableCreator.Name, node.TableReader.TableName)
+	}
+	if idxCreatorNode.TableCreator.Indexes[node.Window.IndexName].Uniqueness != IdxNonUnique {
+		return fmt.Errorf("window index [%s] must be non-unique, rows with the same key form a partition", node.Window.IndexName)
+	}
+
+	srcFieldRefs, err := node.getSourceFieldRefs()
+	if err != nil {
+		return fmt.Errorf("unexpectedly cannot resolve source field refs: [%s]", err.Error())
+	}
+
+	return node.Window.resolve(srcFieldRefs)
+}
+
+func (scriptDef *ScriptDef) resolveCustomProcessorPartitionIndex(node *ScriptNodeDef) error {
+	if !node.HasCustomProcessor() {
+		return nil
+	}
+	partitionedProcDef, ok := node.CustomProcessor.(PartitionedCustomProcessorDef)
+	if !ok {
+		return nil
+	}
+
+	idxName := partitionedProcDef.GetPartitionIndexName()
+	idxCreatorNode, ok := scriptDef.IndexNodeMap[idxName]
+	if !ok {
+		return fmt.Errorf("cannot find the node that creates index [%s]", idxName)
+	}
+	if idxCreatorNode.TableCreator.Name != node.TableReader.TableName {
+		return fmt.Errorf("partition index [%s] is created for table [%s], expected an index of the source table [%s]", idxName, idxCreatorNode.TableCreator.Name, node.TableReader.TableName)
+	}
+	idxDef := idxCreatorNode.TableCreator.Indexes[idxName]
+	if idxDef.Uniqueness != IdxNonUnique {
+		return fmt.Errorf("partition index [%s] must be non-unique, rows with the same key form a partition", idxName)
+	}
+
+	return partitionedProcDef.CheckPartitionIndex(idxDef)
+}
+
+func (scriptDef *ScriptDef) resolveDataQuality(node *ScriptNodeDef) error {
+	if !node.HasDataQuality() {
+		return nil
+	}
+
+	srcFieldRefs, err := node.getSourceFieldRefs()
+	if err != nil {
+		return fmt.Errorf("unexpectedly cannot resolve source field refs: [%s]", err.Error())
+	}
+
+	// Uniqueness rules can use indexes of the source table only
+	return node.DataQuality.resolve(srcFieldRefs, node.TableReader.TableCreator.Indexes)
+}
+
 func (scriptDef *ScriptDef) checkFieldUsageInCreator(node *ScriptNodeDef) error {
 	srcFieldRefs, err := node.getSourceFieldRefs()
 	if err != nil {
@@ -236,6 +333,16 @@ func (scriptDef *ScriptDef) checkFieldUsageInCreator(node *ScriptNodeDef) error
 		lookupFieldRefs = node.Lookup.TableCreator.GetFieldRefsWithAlias(LookupAlias)
 	}
 
+	var windowFieldRefs *FieldRefs
+	if node.HasWindow() {
+		windowFieldRefs = node.Window.GetFieldRefs()
+	}
+
+	var dataQualityFieldRefs *FieldRefs
+	if node.HasDataQuality() {
+		dataQualityFieldRefs = node.DataQuality.GetFieldRefs()
+	}
+
 	foundErrors := make([]string, 0)
 
 	var targetFieldRefs *FieldRefs
@@ -257,12 +364,12 @@ func (scriptDef *ScriptDef) checkFieldUsageInCreator(node *ScriptNodeDef) error
 
 	// Table creator
 	if node.HasTableCreator() {
-		srcLkpCustomFieldRefs := JoinFieldRefs(srcFieldRefs, lookupFieldRefs, processorFieldRefs)
+		srcLkpCustomFieldRefs := JoinFieldRefs(srcFieldRefs, lookupFieldRefs, processorFieldRefs, windowFieldRefs, dataQualityFieldRefs)
 		// Having: allow tgt fields, prohibit src, lkp
 		if err := checkAllowed(&node.TableCreator.UsedInHavingFields, srcLkpCustomFieldRefs, targetFieldRefs); err != nil {
 			foundErrors = append(foundErrors, fmt.Sprintf("invalid field in table creator 'having' condition: [%s]; only target (w.*) fields allowed, reader (r.*) and lookup (l.*) fields are prohibited", err.Error()))
 		}
-		// Tgt expressions: allow src iterator table (or src file), lkp, custom processor, prohibit target
+		// Tgt expressions: allow src iterator table (or src file), lkp, custom processor, window, data quality, prohibit target
 		// TODO: aggregate functions cannot include fields from group field list
 		if err := checkAllowed(&node.TableCreator.UsedInTargetExpressionsFields, targetFieldRefs, srcLkpCustomFieldRefs); err != nil {
 			foundErrors = append(foundErrors, fmt.Sprintf("invalid field(s) in target table field expression: [%s]", err.Error()))
@@ -314,7 +421,7 @@ func (scriptDef *ScriptDef) addChildrenToManual(rootNode *ScriptNodeDef, manualS
 	_, isRootInManual := manualSet[rootNode.Name]
 	_, isRootInStart := startSet[rootNode.Name]
 	for _, node := range scriptDef.ScriptNodes {
-		if rootNode.HasTableCreator() && node.HasTableReader() && rootNode.TableCreator.Name == node.TableReader.TableName ||
+		if rootNode.HasTableCreator() && node.ReadsFromTable(rootNode.TableCreator.Name) ||
 			rootNode.HasTableCreator() && node.HasLookup() && rootNode.TableCreator.Name == node.Lookup.TableCreator.Name {
 			if isRootInManual && !isRootInStart || node.StartPolicy == NodeStartManual {
 				manualSet[node.Name] = struct{}{}
@@ -327,7 +434,7 @@ func (scriptDef *ScriptDef) addChildrenToManual(rootNode *ScriptNodeDef, manualS
 func (scriptDef *ScriptDef) addChildrenToAffected(rootNode *ScriptNodeDef, affectedSet map[string]struct{}, manualSet map[string]struct{}) {
 	for _, node := range scriptDef.ScriptNodes {
 		_, isCurrentInManual := manualSet[node.Name]
-		if rootNode.HasTableCreator() && node.HasTableReader() && rootNode.TableCreator.Name == node.TableReader.TableName && !isCurrentInManual ||
+		if rootNode.HasTableCreator() && node.ReadsFromTable(rootNode.TableCreator.Name) && !isCurrentInManual ||
 			rootNode.HasTableCreator() && node.HasLookup() && rootNode.TableCreator.Name == node.Lookup.TableCreator.Name && !isCurrentInManual {
 			affectedSet[node.Name] = struct{}{}
 			scriptDef.addChildrenToAffected(node, affectedSet, manualSet)
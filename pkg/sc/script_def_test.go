@@ -882,7 +882,14 @@ func testLookup(t *testing.T, scriptDef *ScriptDef) {
 	err = scriptDef.Deserialize(
 		[]byte(strings.Replace(plainScriptJson, `"join_type": "left"`, `"join_type": "left_bad"`, 1)), ScriptJson,
 		nil, nil, "", nil)
-	assert.Contains(t, err.Error(), "invalid join type, expected inner or left, left_bad is not supported")
+	assert.Contains(t, err.Error(), "invalid join type, expected inner, left, right or full, left_bad is not supported")
+
+	// right/full joins are not allowed for grouped lookups
+
+	err = scriptDef.Deserialize(
+		[]byte(strings.Replace(plainScriptJson, `"join_type": "left"`, `"join_type": "full"`, 1)), ScriptJson,
+		nil, nil, "", nil)
+	assert.Contains(t, err.Error(), "invalid join type, grouped lookups support only inner or left, full is not supported")
 }
 
 func TestLookupJson(t *testing.T) {
@@ -1190,3 +1197,198 @@ func TestDistinct(t *testing.T) {
 		nil, nil, "", nil)
 	assert.Contains(t, err.Error(), "expected exactly one unique idx definition")
 }
+
+const unionScriptJson string = `
+{
+	"nodes": {
+		"read_table1": {
+			"type": "file_table",
+			"r": {
+				"urls": ["file1.csv"],
+				"csv":{
+					"first_data_line_idx": 0
+				},
+				"columns": {
+					"col_field_int": {
+						"csv":{
+							"col_idx": 0
+						},
+						"col_type": "int"
+					},
+					"col_field_string": {
+						"csv":{
+							"col_idx": 1
+						},
+						"col_type": "string"
+					}
+				}
+			},
+			"w": {
+				"name": "table1",
+				"fields": {
+					"field_int": {
+						"expression": "r.col_field_int",
+						"type": "int"
+					},
+					"field_string": {
+						"expression": "r.col_field_string",
+						"type": "string"
+					}
+				}
+			}
+		},
+		"read_table2": {
+			"type": "file_table",
+			"r": {
+				"urls": ["file2.csv"],
+				"csv":{
+					"first_data_line_idx": 0
+				},
+				"columns": {
+					"col_field_int": {
+						"csv":{
+							"col_idx": 0
+						},
+						"col_type": "int"
+					},
+					"col_field_string": {
+						"csv":{
+							"col_idx": 1
+						},
+						"col_type": "string"
+					}
+				}
+			},
+			"w": {
+				"name": "table2",
+				"fields": {
+					"field_int": {
+						"expression": "r.col_field_int",
+						"type": "int"
+					},
+					"field_string": {
+						"expression": "r.col_field_string",
+						"type": "string"
+					},
+					"field_extra": {
+						"expression": "r.col_field_string",
+						"type": "string"
+					}
+				}
+			}
+		},
+		"union_table1_table2": {
+			"type": "table_union_table",
+			"r": {
+				"tables": [
+					{
+						"table": "table1",
+						"expected_batches_total": 2
+					},
+					{
+						"table": "table2",
+						"expected_batches_total": 3
+					}
+				]
+			},
+			"w": {
+				"name": "union_table1_table2",
+				"fields": {
+					"field_int": {
+						"expression": "r.field_int",
+						"type": "int"
+					},
+					"field_string": {
+						"expression": "r.field_string",
+						"type": "string"
+					}
+				}
+			}
+		}
+	},
+	"dependency_policies": {
+		"current_active_first_stopped_nogo":` + DefaultPolicyCheckerConfJson +
+	`		
+	}
+}`
+
+func TestUnion(t *testing.T) {
+	scriptDef := &ScriptDef{}
+	assert.Nil(t, scriptDef.Deserialize([]byte(unionScriptJson), ScriptJson, nil, nil, "", nil))
+
+	node := scriptDef.ScriptNodes["union_table1_table2"]
+	assert.True(t, node.HasUnionTableReader())
+	assert.False(t, node.HasTableReader())
+	assert.True(t, node.HasTableCreator())
+	assert.Equal(t, "table2", node.UnionReader.Tables[1].TableCreator.Name)
+	assert.Equal(t, DefaultRowsetSize, node.UnionReader.Tables[0].RowsetSize)
+	assert.NotNil(t, node.DepPolDef)
+
+	// field_extra is not present in table1
+	srcFieldRefs, err := node.getSourceFieldRefs()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(*srcFieldRefs))
+	_, ok := srcFieldRefs.FindByFieldName("field_extra")
+	assert.False(t, ok)
+
+	intervals, err := node.GetTokenIntervalsByNumberOfBatches()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(intervals))
+	assert.Equal(t, int64(-9223372036854775808), intervals[0][0])
+	assert.Equal(t, int64(9223372036854775807), intervals[1][1])
+	assert.Equal(t, int64(-9223372036854775808), intervals[2][0])
+	assert.Equal(t, int64(9223372036854775807), intervals[4][1])
+
+	for batchIdx, expectedTableReaderIdx := range []int{0, 0, 1, 1, 1} {
+		tableReaderIdx, err := node.UnionReader.GetTableReaderIdxByBatchIdx(batchIdx)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedTableReaderIdx, tableReaderIdx)
+	}
+	_, err = node.UnionReader.GetTableReaderIdxByBatchIdx(5)
+	assert.Contains(t, err.Error(), "union reader batch index 5 is out of range, total batches 5")
+
+	affectedNodes := scriptDef.GetAffectedNodes([]string{"read_table1"})
+	assert.Equal(t, 2, len(affectedNodes))
+	assert.Contains(t, affectedNodes, "union_table1_table2")
+
+	affectedNodes = scriptDef.GetAffectedNodes([]string{"read_table2"})
+	assert.Equal(t, 2, len(affectedNodes))
+	assert.Contains(t, affectedNodes, "union_table1_table2")
+}
+
+func TestUnionBadReader(t *testing.T) {
+	scriptDef := &ScriptDef{}
+	err := scriptDef.Deserialize(
+		[]byte(strings.Replace(unionScriptJson, `"table": "table2",`, `"table": "table1",`, 1)), ScriptJson,
+		nil, nil, "", nil)
+	assert.Contains(t, err.Error(), "union table reader cannot reference table table1 more than once")
+
+	err = scriptDef.Deserialize(
+		[]byte(strings.Replace(unionScriptJson, `"table": "table2",`, `"table": "table3",`, 1)), ScriptJson,
+		nil, nil, "", nil)
+	assert.Contains(t, err.Error(), "cannot find the node that creates table [table3]")
+
+	err = scriptDef.Deserialize(
+		[]byte(strings.Replace(unionScriptJson, `"expression": "r.field_string",`, `"expression": "r.field_extra",`, 1)), ScriptJson,
+		nil, nil, "", nil)
+	assert.Contains(t, err.Error(), "union source table table1 does not have field field_extra")
+
+	err = scriptDef.Deserialize(
+		[]byte(strings.Replace(unionScriptJson, `"expression": "r.col_field_int",
+						"type": "int"
+					},
+					"field_string": {
+						"expression": "r.col_field_string",
+						"type": "string"
+					},
+					"field_extra"`, `"expression": "float(r.col_field_int)",
+						"type": "float"
+					},
+					"field_string": {
+						"expression": "r.col_field_string",
+						"type": "string"
+					},
+					"field_extra"`, 1)), ScriptJson,
+		nil, nil, "", nil)
+	assert.Contains(t, err.Error(), "union source tables have incompatible field field_int: int in table1, float in table2")
+}
@@ -7,6 +7,7 @@ import (
 	"go/ast"
 	"math"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/capillariesio/capillaries/pkg/eval"
@@ -55,6 +56,9 @@ const (
 	NodeTypeTableFile           NodeType = "table_file"
 	NodeTypeTableCustomTfmTable NodeType = "table_custom_tfm_table"
 	NodeTypeDistinctTable       NodeType = "distinct_table"
+	NodeTypeTableUnionTable     NodeType = "table_union_table"
+	NodeTypeTableWindowTable    NodeType = "table_window_table"
+	NodeTypeTableDqTable        NodeType = "table_dq_table"
 )
 
 func ValidateNodeType(nodeType NodeType) error {
@@ -63,7 +67,10 @@ func ValidateNodeType(nodeType NodeType) error {
 		nodeType == NodeTypeTableLookupTable ||
 		nodeType == NodeTypeTableFile ||
 		nodeType == NodeTypeDistinctTable ||
-		nodeType == NodeTypeTableCustomTfmTable {
+		nodeType == NodeTypeTableCustomTfmTable ||
+		nodeType == NodeTypeTableUnionTable ||
+		nodeType == NodeTypeTableWindowTable ||
+		nodeType == NodeTypeTableDqTable {
 		return nil
 	}
 	return fmt.Errorf("invalid node type %s", nodeType)
@@ -116,10 +123,15 @@ type ScriptNodeDef struct {
 
 	RawReader   json.RawMessage `json:"r" yaml:"r"` // This depends on tfm type
 	TableReader TableReaderDef
+	UnionReader UnionTableReaderDef
 	FileReader  FileReaderDef
 
 	Lookup LookupDef `json:"l" yaml:"l"`
 
+	Window WindowDef `json:"win" yaml:"win"`
+
+	DataQuality DataQualityDef `json:"dq" yaml:"dq"`
+
 	RawProcessorDef json.RawMessage    `json:"p" yaml:"p"` // This depends on tfm type
 	CustomProcessor CustomProcessorDef // Also should implement CustomProcessorRunner
 
@@ -136,7 +148,12 @@ func (node *ScriptNodeDef) HasTableReader() bool {
 		node.Type == NodeTypeTableLookupTable ||
 		node.Type == NodeTypeTableFile ||
 		node.Type == NodeTypeDistinctTable ||
-		node.Type == NodeTypeTableCustomTfmTable
+		node.Type == NodeTypeTableCustomTfmTable ||
+		node.Type == NodeTypeTableWindowTable ||
+		node.Type == NodeTypeTableDqTable
+}
+func (node *ScriptNodeDef) HasUnionTableReader() bool {
+	return node.Type == NodeTypeTableUnionTable
 }
 func (node *ScriptNodeDef) HasFileReader() bool {
 	return node.Type == NodeTypeFileTable
@@ -146,6 +163,14 @@ func (node *ScriptNodeDef) HasLookup() bool {
 	return node.Type == NodeTypeTableLookupTable
 }
 
+func (node *ScriptNodeDef) HasWindow() bool {
+	return node.Type == NodeTypeTableWindowTable
+}
+
+func (node *ScriptNodeDef) HasDataQuality() bool {
+	return node.Type == NodeTypeTableDqTable
+}
+
 func (node *ScriptNodeDef) HasCustomProcessor() bool {
 	return node.Type == NodeTypeTableCustomTfmTable
 }
@@ -155,7 +180,10 @@ func (node *ScriptNodeDef) HasTableCreator() bool {
 		node.Type == NodeTypeTableTable ||
 		node.Type == NodeTypeDistinctTable ||
 		node.Type == NodeTypeTableLookupTable ||
-		node.Type == NodeTypeTableCustomTfmTable
+		node.Type == NodeTypeTableCustomTfmTable ||
+		node.Type == NodeTypeTableUnionTable ||
+		node.Type == NodeTypeTableWindowTable ||
+		node.Type == NodeTypeTableDqTable
 }
 func (node *ScriptNodeDef) HasFileCreator() bool {
 	return node.Type == NodeTypeTableFile
@@ -174,22 +202,14 @@ func (node *ScriptNodeDef) initReader() error {
 		if err := json.Unmarshal(node.RawReader, &node.TableReader); err != nil {
 			return fmt.Errorf("cannot unmarshal table reader: [%s]", err.Error())
 		}
-		foundErrors := make([]string, 0)
-		if len(node.TableReader.TableName) == 0 {
-			foundErrors = append(foundErrors, "table reader cannot reference empty table name")
-		}
-		if node.TableReader.ExpectedBatchesTotal == 0 {
-			node.TableReader.ExpectedBatchesTotal = 1
-		} else if node.TableReader.ExpectedBatchesTotal < 0 || node.TableReader.ExpectedBatchesTotal > MaxAcceptedBatchesByTableReader {
-			foundErrors = append(foundErrors, fmt.Sprintf("table reader can accept between 1 and %d batches, %d specified", MaxAcceptedBatchesByTableReader, node.TableReader.ExpectedBatchesTotal))
-		}
-		if node.TableReader.RowsetSize < 0 || MaxRowsetSize < node.TableReader.RowsetSize {
-			foundErrors = append(foundErrors, fmt.Sprintf("invalid rowset size %d, table reader can accept between 0 (defaults to %d) and %d", node.TableReader.RowsetSize, DefaultRowsetSize, MaxRowsetSize))
+		if foundErrors := node.TableReader.checkAndSetDefaults(); len(foundErrors) > 0 {
+			return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
 		}
-		if node.TableReader.RowsetSize == 0 {
-			node.TableReader.RowsetSize = DefaultRowsetSize
+	} else if node.HasUnionTableReader() {
+		if err := json.Unmarshal(node.RawReader, &node.UnionReader); err != nil {
+			return fmt.Errorf("cannot unmarshal union table reader: [%s]", err.Error())
 		}
-		if len(foundErrors) > 0 {
+		if foundErrors := node.UnionReader.checkAndSetDefaults(); len(foundErrors) > 0 {
 			return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
 		}
 	} else if node.HasFileReader() {
@@ -283,6 +303,20 @@ func (node *ScriptNodeDef) Deserialize(customProcessorDefFactory CustomProcessor
 		foundErrors = append(foundErrors, err.Error())
 	}
 
+	// Window
+	if node.HasWindow() {
+		if err := node.Window.parseFunctions(); err != nil {
+			foundErrors = append(foundErrors, err.Error())
+		}
+	}
+
+	// Data quality
+	if node.HasDataQuality() {
+		if err := node.DataQuality.parseRules(); err != nil {
+			foundErrors = append(foundErrors, err.Error())
+		}
+	}
+
 	// Distinct table
 	if node.Type == NodeTypeDistinctTable {
 		if node.RerunPolicy != NodeFail {
@@ -372,18 +406,29 @@ func (node *ScriptNodeDef) getSourceFieldRefs() (*FieldRefs, error) {
 		return node.FileReader.getFieldRefs(), nil
 	} else if node.HasTableReader() {
 		return node.TableReader.TableCreator.GetFieldRefsWithAlias(ReaderAlias), nil
+	} else if node.HasUnionTableReader() {
+		return node.UnionReader.getCommonFieldRefs(), nil
 	}
 
 	return nil, fmt.Errorf("dev error, node of type %s has no file or table reader", node.Type)
 }
 
 func (node *ScriptNodeDef) GetUniqueIndexesFieldRefs() *FieldRefs {
+	return node.getIndexesFieldRefs(IdxUnique)
+}
+
+// Fields needed to build keys of idx rows that are deleted when a batch is restarted
+func (node *ScriptNodeDef) GetUniqueAndAsOfIndexesFieldRefs() *FieldRefs {
+	return node.getIndexesFieldRefs(IdxUnique, IdxAsOf)
+}
+
+func (node *ScriptNodeDef) getIndexesFieldRefs(uniquenesses ...IdxUniqueness) *FieldRefs {
 	if !node.HasTableCreator() {
 		return &FieldRefs{}
 	}
 	fieldTypeMap := map[string]evalcapi.TableFieldType{}
 	for _, idxDef := range node.TableCreator.Indexes {
-		if idxDef.Uniqueness == IdxUnique {
+		if slices.Contains(uniquenesses, idxDef.Uniqueness) {
 			for _, idxComponentDef := range idxDef.Components {
 				fieldTypeMap[idxComponentDef.FieldName] = idxComponentDef.FieldType
 			}
@@ -402,25 +447,34 @@ func (node *ScriptNodeDef) GetUniqueIndexesFieldRefs() *FieldRefs {
 	return &fieldRefs
 }
 
-func (node *ScriptNodeDef) GetTokenIntervalsByNumberOfBatches() ([][]int64, error) {
-	if node.HasTableReader() || node.HasFileCreator() && node.TableReader.ExpectedBatchesTotal > 1 {
-		if node.TableReader.ExpectedBatchesTotal == 1 {
-			return [][]int64{{int64(math.MinInt64), int64(math.MaxInt64)}}, nil
-		}
+func tokenIntervalsByNumberOfBatches(expectedBatchesTotal int) [][]int64 {
+	if expectedBatchesTotal == 1 {
+		return [][]int64{{int64(math.MinInt64), int64(math.MaxInt64)}}
+	}
 
-		tokenIntervalPerBatch := int64(math.MaxInt64/node.TableReader.ExpectedBatchesTotal) - int64(math.MinInt64/node.TableReader.ExpectedBatchesTotal)
+	tokenIntervalPerBatch := int64(math.MaxInt64/expectedBatchesTotal) - int64(math.MinInt64/expectedBatchesTotal)
 
-		intervals := make([][]int64, node.TableReader.ExpectedBatchesTotal)
-		left := int64(math.MinInt64)
-		for i := 0; i < len(intervals); i++ {
-			var right int64
-			if i == len(intervals)-1 {
-				right = math.MaxInt64
-			} else {
-				right = left + tokenIntervalPerBatch - 1
-			}
-			intervals[i] = []int64{left, right}
-			left = right + 1
+	intervals := make([][]int64, expectedBatchesTotal)
+	left := int64(math.MinInt64)
+	for i := 0; i < len(intervals); i++ {
+		var right int64
+		if i == len(intervals)-1 {
+			right = math.MaxInt64
+		} else {
+			right = left + tokenIntervalPerBatch - 1
+		}
+		intervals[i] = []int64{left, right}
+		left = right + 1
+	}
+	return intervals
+}
+
+func (node *ScriptNodeDef) GetTokenIntervalsByNumberOfBatches() ([][]int64, error) {
+	if node.HasTableReader() || node.HasFileCreator() && node.TableReader.ExpectedBatchesTotal > 1 {
+		intervals := tokenIntervalsByNumberOfBatches(node.TableReader.ExpectedBatchesTotal)
+		if node.HasLookup() && node.Lookup.IncludesUnmatchedRight() {
+			// One extra batch that reads all left keys and writes right rows that have no left counterparts
+			intervals = append(intervals, []int64{int64(math.MinInt64), int64(math.MaxInt64)})
 		}
 		return intervals, nil
 		// } else if node.HasFileCreator() && node.TableReader.ExpectedBatchesTotal == 1 {
@@ -428,7 +482,17 @@ func (node *ScriptNodeDef) GetTokenIntervalsByNumberOfBatches() ([][]int64, erro
 		// 	intervals := make([][]int64, 1)
 		// 	intervals[0] = []int64{int64(0), 0}
 		// 	return intervals, nil
+	} else if node.HasUnionTableReader() {
+		// Each source table gets its own token intervals, see GetTableReaderIdxByBatchIdx
+		intervals := make([][]int64, 0, node.UnionReader.GetBatchesTotal())
+		for i := 0; i < len(node.UnionReader.Tables); i++ {
+			intervals = append(intervals, tokenIntervalsByNumberOfBatches(node.UnionReader.Tables[i].ExpectedBatchesTotal)...)
+		}
+		return intervals, nil
 	} else if node.HasFileReader() {
+		if node.FileReader.IsSplit() {
+			return nil, fmt.Errorf("cannot calculate intervals for node %s without file sizes, it splits its files", node.Name)
+		}
 		// One input file - one batch
 		intervals := make([][]int64, len(node.FileReader.SrcFileUrls))
 		for i := 0; i < len(node.FileReader.SrcFileUrls); i++ {
@@ -440,11 +504,51 @@ func (node *ScriptNodeDef) GetTokenIntervalsByNumberOfBatches() ([][]int64, erro
 	return nil, fmt.Errorf("cannot find implementation for intervals for node %s", node.Name)
 }
 
+// Script defs are cached and shared, so do not touch the node itself: return a copy that reads resolved files
+func (node *ScriptNodeDef) WithResolvedFileUrls(resolvedFileUrls []string) *ScriptNodeDef {
+	resolvedNode := *node
+	resolvedNode.FileReader.SrcFileUrls = resolvedFileUrls
+	return &resolvedNode
+}
+
+// Right and full lookup joins have one extra batch (the last one) that handles right rows without left counterparts
+func (node *ScriptNodeDef) IsUnmatchedRightBatch(batchIdx int) bool {
+	return node.HasLookup() && node.Lookup.IncludesUnmatchedRight() && batchIdx == node.TableReader.ExpectedBatchesTotal
+}
+
+// Lookup tables are not considered here, see Lookup.TableCreator
+func (node *ScriptNodeDef) ReadsFromTable(tableName string) bool {
+	if node.HasTableReader() {
+		return node.TableReader.TableName == tableName
+	} else if node.HasUnionTableReader() {
+		for i := 0; i < len(node.UnionReader.Tables); i++ {
+			if node.UnionReader.Tables[i].TableName == tableName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (node *ScriptNodeDef) isNodeUsesIdx(idxName string) bool {
 	if node.HasLookup() && node.Lookup.IndexName == idxName {
 		return true
 	}
 
+	if node.HasWindow() && node.Window.IndexName == idxName {
+		return true
+	}
+
+	if node.HasCustomProcessor() {
+		if partitionedProcDef, ok := node.CustomProcessor.(PartitionedCustomProcessorDef); ok && partitionedProcDef.GetPartitionIndexName() == idxName {
+			return true
+		}
+	}
+
+	if node.HasDataQuality() && node.DataQuality.UsesIdx(idxName) {
+		return true
+	}
+
 	distinctIdxCandidate, ok := node.TableCreator.Indexes[idxName]
 	if ok {
 		if node.Type == NodeTypeDistinctTable && distinctIdxCandidate.Uniqueness == IdxUnique {
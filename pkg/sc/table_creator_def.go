@@ -12,6 +12,7 @@ import (
 
 	"github.com/capillariesio/capillaries/pkg/eval"
 	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/shopspring/decimal"
 	"gopkg.in/inf.v0"
 )
 
@@ -68,7 +69,8 @@ func (tcDef *TableCreatorDef) GetFieldRefsWithAlias(useTableAlias string) *Field
 		fieldRefs[i] = FieldRef{
 			TableName: tName,
 			FieldName: fieldName,
-			FieldType: fieldDef.Type}
+			FieldType: fieldDef.Type,
+			Nullable:  fieldDef.Nullable}
 		i++
 	}
 	return &fieldRefs
@@ -140,9 +142,13 @@ func (tcDef *TableCreatorDef) GetFieldDefaultReadyForDb(fieldName string) (any,
 		return nil, fmt.Errorf("default for unknown field %s", fieldName)
 	}
 	defaultValueString := strings.TrimSpace(writerFieldDef.DefaultValue)
+	if writerFieldDef.Nullable && len(defaultValueString) == 0 {
+		// Unmatched lookup, no rows to aggregate etc
+		return nil, nil
+	}
 
 	var err error
-	switch writerFieldDef.Type {
+	switch evalcapi.FieldTypeKind(writerFieldDef.Type) {
 	case evalcapi.FieldTypeInt:
 		v := DefaultInt
 		if len(defaultValueString) > 0 {
@@ -171,6 +177,15 @@ func (tcDef *TableCreatorDef) GetFieldDefaultReadyForDb(fieldName string) (any,
 		// Set it to Cassandra-accepted value, not decimal.Decimal: https://github.com/gocql/gocql/issues/1578
 		v := inf.NewDec(0, 0)
 		if len(defaultValueString) > 0 {
+			if precision, scale, ok := evalcapi.ParseDecimalFieldType(writerFieldDef.Type); ok {
+				// Exact, decimal(p,s) may hold more digits than float64
+				decVal, err := decimal.NewFromString(defaultValueString)
+				if err != nil {
+					return nil, fmt.Errorf("cannot read decimal(%d,%d) field %s from default value string '%s': %s", precision, scale, fieldName, defaultValueString, err.Error())
+				}
+				decVal = decVal.Round(scale)
+				return inf.NewDecBig(decVal.Coefficient(), inf.Scale(-decVal.Exponent())), nil
+			}
 			f, err := strconv.ParseFloat(defaultValueString, 64)
 			if err != nil {
 				return nil, fmt.Errorf("cannot read decimal2 field %s from default value string '%s': %s", fieldName, defaultValueString, err.Error())
@@ -206,12 +221,13 @@ func CalculateFieldValue(fieldName string, fieldDef *WriteTableFieldDef, srcVars
 	var eCtx *eval.EvalCtx
 	var err error
 	eCtx = eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, srcVars)
-	eCtx.SetRoundDec(2) // decimal2
+	eCtx.SetRoundDec(evalcapi.DecimalFieldTypeScale(fieldDef.Type))
 	valVolatile, err := eCtx.Eval(fieldDef.ParsedExpression)
 	if err != nil {
 		return nil, fmt.Errorf("cannot evaluate expression for field %s: [%s]", fieldName, err.Error())
 	}
-	if err := CheckValueType(valVolatile, fieldDef.Type); err != nil {
+	valVolatile = RoundDecimalValue(valVolatile, fieldDef.Type)
+	if err := CheckNullableValueType(valVolatile, fieldDef.Type, fieldDef.Nullable); err != nil {
 		return nil, fmt.Errorf("invalid field %s type: [%s]", fieldName, err.Error())
 	}
 	return valVolatile, nil
@@ -253,6 +269,10 @@ func (tcDef *TableCreatorDef) CheckTableRecordHavingCondition(tableRecord map[st
 	if err != nil {
 		return false, fmt.Errorf("cannot evaluate 'having' expression: [%s]", err.Error())
 	}
+	if valVolatile == nil {
+		// Null condition is false, like in SQL WHERE
+		return false, nil
+	}
 	valBool, ok := valVolatile.(bool)
 	if !ok {
 		return false, fmt.Errorf("cannot get bool when evaluating having expression, got %v(%T) instead", valVolatile, valVolatile)
@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/inf.v0"
 )
@@ -228,6 +230,63 @@ func TestCreatorIndexes(t *testing.T) {
 	c = TableCreatorDef{}
 	confReplacer = strings.NewReplacer(`unique(field_string(case_sensitive))`, `bla(field_string(case_sensitive))`)
 	err = c.Deserialize([]byte(confReplacer.Replace(tableCreatorNodeJson)))
-	assert.Contains(t, err.Error(), "expected top level unique()) or non_unique() definition")
+	assert.Contains(t, err.Error(), "expected top level unique(), non_unique() or as_of() definition")
 
 }
+
+func TestCreatorNullable(t *testing.T) {
+	c := TableCreatorDef{}
+	confReplacer := strings.NewReplacer(
+		`"default_value": "99",`, `"nullable": true,`,
+		`"having": "len(w.field_string) > 0",`, `"having": "w.field_int > 0",`)
+	assert.Nil(t, c.Deserialize([]byte(confReplacer.Replace(tableCreatorNodeJson))))
+
+	val, err := c.GetFieldDefaultReadyForDb("field_int")
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+
+	val, err = CalculateFieldValue("field_int", c.Fields["field_int"], eval.VarValuesMap{"r": {"field_int": nil}})
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+
+	_, err = CalculateFieldValue("field_float", c.Fields["field_float"], eval.VarValuesMap{"r": {"field_float": nil}})
+	assert.Contains(t, err.Error(), "invalid field field_float type: [expected type float, but got null")
+
+	isPass, err := c.CheckTableRecordHavingCondition(map[string]any{"field_int": nil})
+	assert.Nil(t, err)
+	assert.False(t, isPass)
+
+	c = TableCreatorDef{}
+	err = c.Deserialize([]byte(strings.NewReplacer(
+		`"default_value": "some_string",`, `"nullable": true,`).Replace(tableCreatorNodeJson)))
+	assert.Contains(t, err.Error(), "cannot use nullable field field_string in index component definition")
+}
+
+func TestCreatorDecimalPrecisionScale(t *testing.T) {
+	c := TableCreatorDef{}
+	confReplacer := strings.NewReplacer(
+		`"expression": "r.field_decimal2",`, `"expression": "r.field_decimal2 * r.field_rate",`,
+		`"default_value": "123.00",`, `"default_value": "12345678901234567.1234567",`,
+		`"type": "decimal2"`, `"type": "decimal(30,6)"`)
+	assert.Nil(t, c.Deserialize([]byte(confReplacer.Replace(tableCreatorNodeJson))))
+
+	val, err := c.GetFieldDefaultReadyForDb("field_decimal2")
+	assert.Nil(t, err)
+	assert.Equal(t, "12345678901234567.123457", val.(*inf.Dec).String())
+
+	// Arithmetic is rounded to the field scale, not to 2 digits
+	val, err = CalculateFieldValue("field_decimal2", c.Fields["field_decimal2"], eval.VarValuesMap{"r": {
+		"field_decimal2": decimal.RequireFromString("10.5"),
+		"field_rate":     decimal.RequireFromString("1.12345678")}})
+	assert.Nil(t, err)
+	assert.Equal(t, "11.796296", val.(decimal.Decimal).StringFixed(6))
+
+	_, err = CalculateFieldValue("field_decimal2", c.Fields["field_decimal2"], eval.VarValuesMap{"r": {
+		"field_decimal2": decimal.RequireFromString("1000000000000000000000000"),
+		"field_rate":     decimal.RequireFromString("1")}})
+	assert.Contains(t, err.Error(), "invalid field field_decimal2 type: [expected type decimal(30,6), but got decimal (1000000000000000000000000) with more than 24 digits before the point]")
+
+	c = TableCreatorDef{}
+	err = c.Deserialize([]byte(strings.ReplaceAll(tableCreatorNodeJson, `"type": "decimal2"`, `"type": "decimal(39,2)"`)))
+	assert.Contains(t, err.Error(), "invalid field type [decimal(39,2)]")
+}
@@ -29,7 +29,7 @@ func DefaultCassandraDecimal2() *inf.Dec { return inf.NewDec(0, 0) }
 func DefaultDateTime() time.Time         { return time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC) } // Same as time.Time default
 
 func GetDefaultFieldTypeValue(fieldType evalcapi.TableFieldType) any {
-	switch fieldType {
+	switch evalcapi.FieldTypeKind(fieldType) {
 	case evalcapi.FieldTypeInt:
 		return DefaultInt
 	case evalcapi.FieldTypeFloat:
@@ -49,6 +49,8 @@ func GetDefaultFieldTypeValue(fieldType evalcapi.TableFieldType) any {
 
 func CheckValueType(val any, fieldType evalcapi.TableFieldType) error {
 	switch assertedValue := val.(type) {
+	case nil:
+		return fmt.Errorf("expected type %s, but got null, consider making the field nullable or using coalesce()", fieldType)
 	case int64:
 		if fieldType != evalcapi.FieldTypeInt {
 			return fmt.Errorf("expected type %s, but got int64 (%d)", fieldType, assertedValue)
@@ -70,11 +72,37 @@ func CheckValueType(val any, fieldType evalcapi.TableFieldType) error {
 			return fmt.Errorf("expected type %s, but got datetime (%s)", fieldType, assertedValue.String())
 		}
 	case decimal.Decimal:
-		if fieldType != evalcapi.FieldTypeDecimal2 {
+		if !evalcapi.IsDecimalFieldType(fieldType) {
 			return fmt.Errorf("expected type %s, but got decimal (%s)", fieldType, assertedValue.String())
 		}
+		if precision, scale, ok := evalcapi.ParseDecimalFieldType(fieldType); ok && countDecimalIntDigits(assertedValue) > precision-scale {
+			return fmt.Errorf("expected type %s, but got decimal (%s) with more than %d digits before the point", fieldType, assertedValue.String(), precision-scale)
+		}
 	default:
 		return fmt.Errorf("expected type %s, but got unexpected type %T(%v)", fieldType, assertedValue, assertedValue)
 	}
 	return nil
 }
+
+func countDecimalIntDigits(d decimal.Decimal) int32 {
+	intPart := d.Abs().Truncate(0)
+	if intPart.IsZero() {
+		return 0
+	}
+	return int32(len(intPart.String()))
+}
+
+// Expression results are rounded to the scale of decimal2 and decimal(p,s) fields, other values are returned as is
+func RoundDecimalValue(val any, fieldType evalcapi.TableFieldType) any {
+	if decVal, ok := val.(decimal.Decimal); ok && evalcapi.IsDecimalFieldType(fieldType) {
+		return decVal.Round(evalcapi.DecimalFieldTypeScale(fieldType))
+	}
+	return val
+}
+
+func CheckNullableValueType(val any, fieldType evalcapi.TableFieldType, nullable bool) error {
+	if val == nil && nullable {
+		return nil
+	}
+	return CheckValueType(val, fieldType)
+}
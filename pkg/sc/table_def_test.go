@@ -30,4 +30,18 @@ func TestTableDefCheckValueTypeFailures(t *testing.T) {
 
 	err = CheckValueType([]string{"aaa"}, evalcapi.FieldTypeInt)
 	assert.Contains(t, err.Error(), "expected type int, but got unexpected type []string")
+
+	err = CheckValueType(nil, evalcapi.FieldTypeInt)
+	assert.Contains(t, err.Error(), "expected type int, but got null, consider making the field nullable or using coalesce()")
+
+	assert.Nil(t, CheckValueType(decimal.RequireFromString("-9999.99"), "decimal(6,2)"))
+	err = CheckValueType(decimal.RequireFromString("10000"), "decimal(6,2)")
+	assert.Contains(t, err.Error(), "expected type decimal(6,2), but got decimal (10000) with more than 4 digits before the point")
+	assert.Equal(t, decimal.RequireFromString("1.2346"), RoundDecimalValue(decimal.RequireFromString("1.23456"), "decimal(10,4)"))
+	assert.Equal(t, decimal.RequireFromString("1.23"), RoundDecimalValue(decimal.RequireFromString("1.23456"), evalcapi.FieldTypeDecimal2))
+	assert.Equal(t, 1.23456, RoundDecimalValue(1.23456, evalcapi.FieldTypeFloat))
+
+	assert.Nil(t, CheckNullableValueType(nil, evalcapi.FieldTypeInt, true))
+	err = CheckNullableValueType("aaa", evalcapi.FieldTypeInt, true)
+	assert.Contains(t, err.Error(), "expected type int, but got string")
 }
@@ -1,8 +1,135 @@
 package sc
 
+import (
+	"fmt"
+	"strings"
+)
+
 type TableReaderDef struct {
 	TableName            string           `json:"table" yaml:"table"`
 	ExpectedBatchesTotal int              `json:"expected_batches_total,omitempty" yaml:"expected_batches_total,omitempty"`
 	RowsetSize           int              `json:"rowset_size,omitempty" yaml:"rowset_size,omitempty"` // DefaultRowsetSize = 1000, careful with higher values - watch for OOM
 	TableCreator         *TableCreatorDef `json:"-"`
 }
+
+func (tableReader *TableReaderDef) checkAndSetDefaults() []string {
+	foundErrors := make([]string, 0)
+	if len(tableReader.TableName) == 0 {
+		foundErrors = append(foundErrors, "table reader cannot reference empty table name")
+	}
+	if tableReader.ExpectedBatchesTotal == 0 {
+		tableReader.ExpectedBatchesTotal = 1
+	} else if tableReader.ExpectedBatchesTotal < 0 || tableReader.ExpectedBatchesTotal > MaxAcceptedBatchesByTableReader {
+		foundErrors = append(foundErrors, fmt.Sprintf("table reader can accept between 1 and %d batches, %d specified", MaxAcceptedBatchesByTableReader, tableReader.ExpectedBatchesTotal))
+	}
+	if tableReader.RowsetSize < 0 || MaxRowsetSize < tableReader.RowsetSize {
+		foundErrors = append(foundErrors, fmt.Sprintf("invalid rowset size %d, table reader can accept between 0 (defaults to %d) and %d", tableReader.RowsetSize, DefaultRowsetSize, MaxRowsetSize))
+	}
+	if tableReader.RowsetSize == 0 {
+		tableReader.RowsetSize = DefaultRowsetSize
+	}
+	return foundErrors
+}
+
+// Union reader: each source table is read by its own set of batches, all batches write to the same target table
+type UnionTableReaderDef struct {
+	Tables []TableReaderDef `json:"tables" yaml:"tables"`
+}
+
+func (unionReader *UnionTableReaderDef) checkAndSetDefaults() []string {
+	foundErrors := make([]string, 0)
+	if len(unionReader.Tables) < 2 {
+		foundErrors = append(foundErrors, fmt.Sprintf("union table reader requires at least two tables, %d specified", len(unionReader.Tables)))
+	}
+	usedTableNames := map[string]struct{}{}
+	for i := range unionReader.Tables {
+		tableReader := &unionReader.Tables[i]
+		foundErrors = append(foundErrors, tableReader.checkAndSetDefaults()...)
+		if _, ok := usedTableNames[tableReader.TableName]; ok {
+			foundErrors = append(foundErrors, fmt.Sprintf("union table reader cannot reference table %s more than once", tableReader.TableName))
+		}
+		usedTableNames[tableReader.TableName] = struct{}{}
+	}
+	return foundErrors
+}
+
+func (unionReader *UnionTableReaderDef) GetBatchesTotal() int {
+	batchesTotal := 0
+	for i := range unionReader.Tables {
+		batchesTotal += unionReader.Tables[i].ExpectedBatchesTotal
+	}
+	return batchesTotal
+}
+
+// Batches are allocated to source tables in the order the tables are listed: first ExpectedBatchesTotal batches read
+// from the first table, next ExpectedBatchesTotal batches read from the second table etc
+func (unionReader *UnionTableReaderDef) GetTableReaderIdxByBatchIdx(batchIdx int) (int, error) {
+	if batchIdx < 0 {
+		return -1, fmt.Errorf("invalid union reader batch index %d", batchIdx)
+	}
+	firstBatchIdx := 0
+	for i := range unionReader.Tables {
+		firstBatchIdx += unionReader.Tables[i].ExpectedBatchesTotal
+		if batchIdx < firstBatchIdx {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("union reader batch index %d is out of range, total batches %d", batchIdx, firstBatchIdx)
+}
+
+// Fields present, with the same type, in all source tables
+func (unionReader *UnionTableReaderDef) getCommonFieldRefs() *FieldRefs {
+	commonFieldRefs := FieldRefs{}
+	if len(unionReader.Tables) == 0 || unionReader.Tables[0].TableCreator == nil {
+		return &commonFieldRefs
+	}
+	for _, fieldRef := range *unionReader.Tables[0].TableCreator.GetFieldRefsWithAlias(ReaderAlias) {
+		isCommon := true
+		for i := 1; i < len(unionReader.Tables); i++ {
+			tableCreator := unionReader.Tables[i].TableCreator
+			if tableCreator == nil {
+				isCommon = false
+				break
+			}
+			fieldDef, ok := tableCreator.Fields[fieldRef.FieldName]
+			if !ok || fieldDef.Type != fieldRef.FieldType {
+				isCommon = false
+				break
+			}
+		}
+		if isCommon {
+			commonFieldRefs = append(commonFieldRefs, fieldRef)
+		}
+	}
+	return &commonFieldRefs
+}
+
+// All reader fields used by the node must be present in all source tables and have the same type
+func (unionReader *UnionTableReaderDef) checkFieldRefsCompatibility(usedFieldRefs *FieldRefs) error {
+	foundErrors := make([]string, 0)
+	for _, usedFieldRef := range *usedFieldRefs {
+		if usedFieldRef.TableName != ReaderAlias {
+			continue
+		}
+		var firstTableName string
+		var firstFieldDef *WriteTableFieldDef
+		for i := range unionReader.Tables {
+			tableReader := &unionReader.Tables[i]
+			fieldDef, ok := tableReader.TableCreator.Fields[usedFieldRef.FieldName]
+			if !ok {
+				foundErrors = append(foundErrors, fmt.Sprintf("union source table %s does not have field %s", tableReader.TableName, usedFieldRef.FieldName))
+				continue
+			}
+			if firstFieldDef == nil {
+				firstTableName = tableReader.TableName
+				firstFieldDef = fieldDef
+			} else if fieldDef.Type != firstFieldDef.Type {
+				foundErrors = append(foundErrors, fmt.Sprintf("union source tables have incompatible field %s: %s in %s, %s in %s", usedFieldRef.FieldName, firstFieldDef.Type, firstTableName, fieldDef.Type, tableReader.TableName))
+			}
+		}
+	}
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package sc
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/shopspring/decimal"
+)
+
+const WindowAlias string = "win"
+
+type WindowFuncType string
+
+const (
+	WindowFuncRowNumber  WindowFuncType = "row_number"  // row_number()
+	WindowFuncRank       WindowFuncType = "rank"        // rank(), rows with the same order key get the same rank
+	WindowFuncLag        WindowFuncType = "lag"         // lag(expr,n), value of expr n rows before the current one
+	WindowFuncLead       WindowFuncType = "lead"        // lead(expr,n), value of expr n rows after the current one
+	WindowFuncRunningSum WindowFuncType = "running_sum" // running_sum(expr), sum of expr from the first row to the current one
+	WindowFuncMovingAvg  WindowFuncType = "moving_avg"  // moving_avg(expr,n), average of expr over the last n rows including the current one
+)
+
+type WindowFuncDef struct {
+	RawExpression string                  `json:"expression" yaml:"expression"`
+	Type          evalcapi.TableFieldType `json:"type" yaml:"type"`
+	FuncType      WindowFuncType          `json:"-"`
+	ArgExpression ast.Expr                `json:"-"` // Evaluated for each row, nil for row_number and rank
+	Offset        int                     `json:"-"` // Row offset for lag/lead, window size for moving_avg
+	UsedFields    FieldRefs               `json:"-"`
+}
+
+// Window node: rows with the same key in a non-unique index of the source table form a partition,
+// rows within a partition are ordered by the order definition
+type WindowDef struct {
+	IndexName             string                    `json:"index_name" yaml:"index_name"`
+	RawOrder              string                    `json:"order" yaml:"order"`
+	Functions             map[string]*WindowFuncDef `json:"functions" yaml:"functions"`
+	OrderIdxDef           IdxDef                    `json:"-"` // Not an index really, we just re-use IdxDef infrastructure
+	UsedInFunctionsFields FieldRefs                 `json:"-"`
+}
+
+func parseWindowFuncOffset(exp ast.Expr) (int, error) {
+	lit, ok := exp.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return 0, errors.New("expected positive integer literal")
+	}
+	offset, err := strconv.Atoi(lit.Value)
+	if err != nil || offset <= 0 {
+		return 0, fmt.Errorf("expected positive integer literal, got %s", lit.Value)
+	}
+	return offset, nil
+}
+
+func (funcDef *WindowFuncDef) parse() error {
+	exp, err := parser.ParseExpr(funcDef.RawExpression)
+	if err != nil {
+		return fmt.Errorf("cannot parse window function expression: [%s]", err.Error())
+	}
+	callExp, ok := exp.(*ast.CallExpr)
+	if !ok {
+		return errors.New("expected window function call: row_number(), rank(), lag(expr,n), lead(expr,n), running_sum(expr), moving_avg(expr,n)")
+	}
+	identExp, ok := callExp.Fun.(*ast.Ident)
+	if !ok {
+		return fmt.Errorf("cannot parse call exp %v, expected ident", callExp.Fun)
+	}
+
+	funcDef.FuncType = WindowFuncType(identExp.Name)
+	switch funcDef.FuncType {
+	case WindowFuncRowNumber, WindowFuncRank:
+		if len(callExp.Args) != 0 {
+			return fmt.Errorf("%s() does not accept arguments", funcDef.FuncType)
+		}
+		if funcDef.Type != evalcapi.FieldTypeInt {
+			return fmt.Errorf("%s() returns %s, %s specified", funcDef.FuncType, evalcapi.FieldTypeInt, funcDef.Type)
+		}
+		return nil
+
+	case WindowFuncLag, WindowFuncLead:
+		// Offset is optional, default 1
+		if len(callExp.Args) != 1 && len(callExp.Args) != 2 {
+			return fmt.Errorf("%s() expects expression and optional offset", funcDef.FuncType)
+		}
+		funcDef.Offset = 1
+		if len(callExp.Args) == 2 {
+			if funcDef.Offset, err = parseWindowFuncOffset(callExp.Args[1]); err != nil {
+				return fmt.Errorf("invalid %s() offset: %s", funcDef.FuncType, err.Error())
+			}
+		}
+
+	case WindowFuncRunningSum:
+		if len(callExp.Args) != 1 {
+			return fmt.Errorf("%s() expects one expression", funcDef.FuncType)
+		}
+		if funcDef.Type != evalcapi.FieldTypeInt && funcDef.Type != evalcapi.FieldTypeFloat && !evalcapi.IsDecimalFieldType(funcDef.Type) {
+			return fmt.Errorf("%s() supports only int, float, decimal2 and decimal(p,s), %s specified", funcDef.FuncType, funcDef.Type)
+		}
+
+	case WindowFuncMovingAvg:
+		if len(callExp.Args) != 2 {
+			return fmt.Errorf("%s() expects expression and window size", funcDef.FuncType)
+		}
+		if funcDef.Offset, err = parseWindowFuncOffset(callExp.Args[1]); err != nil {
+			return fmt.Errorf("invalid %s() window size: %s", funcDef.FuncType, err.Error())
+		}
+		if funcDef.Type != evalcapi.FieldTypeFloat && !evalcapi.IsDecimalFieldType(funcDef.Type) {
+			return fmt.Errorf("%s() supports only float, decimal2 and decimal(p,s), %s specified", funcDef.FuncType, funcDef.Type)
+		}
+
+	default:
+		return fmt.Errorf("unknown window function %s", identExp.Name)
+	}
+
+	funcDef.ArgExpression = callExp.Args[0]
+	v := AggFinderVisitor{}
+	ast.Walk(&v, funcDef.ArgExpression)
+	if v.Error != nil {
+		return fmt.Errorf("cannot use agg functions in window function argument: [%s]", v.Error.Error())
+	}
+	return harvestFieldRefsFromParsedExpression(funcDef.ArgExpression, &funcDef.UsedFields, FieldRefStrict)
+}
+
+func (winDef *WindowDef) parseFunctions() error {
+	foundErrors := make([]string, 0)
+	if len(winDef.IndexName) == 0 {
+		foundErrors = append(foundErrors, "window index_name cannot be empty")
+	}
+	if len(strings.TrimSpace(winDef.RawOrder)) == 0 {
+		foundErrors = append(foundErrors, "window order cannot be empty")
+	}
+	if len(winDef.Functions) == 0 {
+		foundErrors = append(foundErrors, "window must have at least one function")
+	}
+	for funcName, funcDef := range winDef.Functions {
+		if !evalcapi.IsValidFieldType(funcDef.Type) {
+			foundErrors = append(foundErrors, fmt.Sprintf("window function %s has invalid type [%s]", funcName, funcDef.Type))
+			continue
+		}
+		if err := funcDef.parse(); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot parse window function %s expression [%s]: [%s]", funcName, funcDef.RawExpression, err.Error()))
+		}
+	}
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
+
+// Called when source table fields are known
+func (winDef *WindowDef) resolve(srcFieldRefs *FieldRefs) error {
+	idxDefMap := IdxDefMap{}
+	rawIndexes := map[string]string{"order": fmt.Sprintf("non_unique(%s)", winDef.RawOrder)}
+	if err := idxDefMap.parseRawIndexDefMap(rawIndexes, srcFieldRefs); err != nil {
+		return fmt.Errorf("cannot parse window order: %s", err.Error())
+	}
+	winDef.OrderIdxDef = *idxDefMap["order"]
+
+	foundErrors := make([]string, 0)
+	winDef.UsedInFunctionsFields = FieldRefs{}
+	for funcName, funcDef := range winDef.Functions {
+		if funcDef.ArgExpression == nil {
+			continue
+		}
+		// Only reader fields are allowed in window function arguments
+		if err := checkAllowed(&funcDef.UsedFields, nil, srcFieldRefs); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("invalid field(s) in window function %s expression [%s]: [%s]", funcName, funcDef.RawExpression, err.Error()))
+			continue
+		}
+		// Field types are known now
+		winDef.UsedInFunctionsFields.Append(funcDef.UsedFields)
+		if err := evalExpressionWithFieldRefsAndCheckType(funcDef.ArgExpression, funcDef.UsedFields, funcDef.Type); err != nil {
+			foundErrors = append(foundErrors, fmt.Sprintf("cannot evaluate window function %s expression [%s]: [%s]", funcName, funcDef.RawExpression, err.Error()))
+		}
+	}
+	if len(foundErrors) > 0 {
+		return fmt.Errorf("%s", strings.Join(foundErrors, "; "))
+	}
+	return nil
+}
+
+func (winDef *WindowDef) GetFieldRefs() *FieldRefs {
+	fieldRefs := make(FieldRefs, 0, len(winDef.Functions))
+	for funcName, funcDef := range winDef.Functions {
+		fieldRefs = append(fieldRefs, FieldRef{TableName: WindowAlias, FieldName: funcName, FieldType: funcDef.Type})
+	}
+	return &fieldRefs
+}
+
+// Reader fields required to calculate order keys
+func (winDef *WindowDef) GetOrderFieldRefs() FieldRefs {
+	return winDef.OrderIdxDef.getComponentFieldRefs(ReaderAlias)
+}
+
+func (winDef *WindowDef) BuildOrderKey(readerVars map[string]any) (string, error) {
+	return BuildKey(readerVars, &winDef.OrderIdxDef)
+}
+
+func addWindowValues(a any, b any) (any, error) {
+	switch typedA := a.(type) {
+	case int64:
+		return typedA + b.(int64), nil
+	case float64:
+		return typedA + b.(float64), nil
+	case decimal.Decimal:
+		return typedA.Add(b.(decimal.Decimal)), nil
+	default:
+		return nil, fmt.Errorf("cannot add window values of unsupported type %T", a)
+	}
+}
+
+func avgWindowValues(vals []any, fieldType evalcapi.TableFieldType) (any, error) {
+	sum := GetDefaultFieldTypeValue(fieldType)
+	var err error
+	for _, v := range vals {
+		if sum, err = addWindowValues(sum, v); err != nil {
+			return nil, err
+		}
+	}
+	switch typedSum := sum.(type) {
+	case float64:
+		return typedSum / float64(len(vals)), nil
+	case decimal.Decimal:
+		return typedSum.Div(decimal.NewFromInt(int64(len(vals)))).Round(evalcapi.DecimalFieldTypeScale(fieldType)), nil
+	default:
+		return nil, fmt.Errorf("cannot average window values of unsupported type %T", sum)
+	}
+}
+
+// Partition rows must be sorted by order key, orderKeys[i] is the order key of partitionVars[i].
+// Returns window function values for each row.
+func (winDef *WindowDef) CalculateWindowValues(partitionVars []eval.VarValuesMap, orderKeys []string) ([]map[string]any, error) {
+	rowCount := len(partitionVars)
+	winValues := make([]map[string]any, rowCount)
+	for i := 0; i < rowCount; i++ {
+		winValues[i] = make(map[string]any, len(winDef.Functions))
+	}
+
+	for funcName, funcDef := range winDef.Functions {
+		// Evaluate the argument for each row
+		var argValues []any
+		if funcDef.ArgExpression != nil {
+			argValues = make([]any, rowCount)
+			for i := 0; i < rowCount; i++ {
+				eCtx := eval.NewPlainEvalCtx(evalcapi.CapillariesEvalFunctions, evalcapi.CapillariesEvalConstants, partitionVars[i])
+				eCtx.SetRoundDec(evalcapi.DecimalFieldTypeScale(funcDef.Type))
+				val, err := eCtx.Eval(funcDef.ArgExpression)
+				if err != nil {
+					return nil, fmt.Errorf("cannot evaluate window function %s expression [%s]: [%s]", funcName, funcDef.RawExpression, err.Error())
+				}
+				if err := CheckValueType(val, funcDef.Type); err != nil {
+					return nil, fmt.Errorf("invalid window function %s type: [%s]", funcName, err.Error())
+				}
+				argValues[i] = val
+			}
+		}
+
+		var runningSum any
+		for i := 0; i < rowCount; i++ {
+			switch funcDef.FuncType {
+			case WindowFuncRowNumber:
+				winValues[i][funcName] = int64(i + 1)
+			case WindowFuncRank:
+				if i > 0 && orderKeys[i] == orderKeys[i-1] {
+					winValues[i][funcName] = winValues[i-1][funcName]
+				} else {
+					winValues[i][funcName] = int64(i + 1)
+				}
+			case WindowFuncLag:
+				if i-funcDef.Offset >= 0 {
+					winValues[i][funcName] = argValues[i-funcDef.Offset]
+				} else {
+					winValues[i][funcName] = GetDefaultFieldTypeValue(funcDef.Type)
+				}
+			case WindowFuncLead:
+				if i+funcDef.Offset < rowCount {
+					winValues[i][funcName] = argValues[i+funcDef.Offset]
+				} else {
+					winValues[i][funcName] = GetDefaultFieldTypeValue(funcDef.Type)
+				}
+			case WindowFuncRunningSum:
+				if i == 0 {
+					runningSum = argValues[i]
+				} else {
+					var err error
+					if runningSum, err = addWindowValues(runningSum, argValues[i]); err != nil {
+						return nil, err
+					}
+				}
+				winValues[i][funcName] = runningSum
+			case WindowFuncMovingAvg:
+				first := max(0, i-funcDef.Offset+1)
+				avg, err := avgWindowValues(argValues[first:i+1], funcDef.Type)
+				if err != nil {
+					return nil, err
+				}
+				winValues[i][funcName] = avg
+			default:
+				return nil, fmt.Errorf("dev error, unknown window function type %s", funcDef.FuncType)
+			}
+		}
+	}
+	return winValues, nil
+}
@@ -0,0 +1,207 @@
+package sc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/capillariesio/capillaries/pkg/eval"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const windowScriptJson string = `
+{
+	"nodes": {
+		"read_txns": {
+			"type": "file_table",
+			"r": {
+				"urls": ["txns.csv"],
+				"csv":{
+					"first_data_line_idx": 0
+				},
+				"columns": {
+					"col_account_id": {
+						"csv":{
+							"col_idx": 0
+						},
+						"col_type": "string"
+					},
+					"col_txn_date": {
+						"csv":{
+							"col_idx": 1,
+							"col_format": "2006-01-02"
+						},
+						"col_type": "datetime"
+					},
+					"col_amount": {
+						"csv":{
+							"col_idx": 2,
+							"col_format": "%f"
+						},
+						"col_type": "decimal2"
+					}
+				}
+			},
+			"w": {
+				"name": "txns",
+				"fields": {
+					"account_id": {
+						"expression": "r.col_account_id",
+						"type": "string"
+					},
+					"txn_date": {
+						"expression": "r.col_txn_date",
+						"type": "datetime"
+					},
+					"amount": {
+						"expression": "r.col_amount",
+						"type": "decimal2"
+					}
+				},
+				"indexes": {
+					"idx_txns_account_id": "non_unique(account_id)"
+				}
+			}
+		},
+		"txns_window": {
+			"type": "table_window_table",
+			"r": {
+				"table": "txns",
+				"expected_batches_total": 2
+			},
+			"win": {
+				"index_name": "idx_txns_account_id",
+				"order": "txn_date",
+				"functions": {
+					"rn": {"expression": "row_number()", "type": "int"},
+					"rnk": {"expression": "rank()", "type": "int"},
+					"prev_amount": {"expression": "lag(r.amount, 1)", "type": "decimal2"},
+					"next_amount": {"expression": "lead(r.amount)", "type": "decimal2"},
+					"balance": {"expression": "running_sum(r.amount)", "type": "decimal2"},
+					"avg2": {"expression": "moving_avg(r.amount, 2)", "type": "decimal2"}
+				}
+			},
+			"w": {
+				"name": "txns_window",
+				"fields": {
+					"account_id": {
+						"expression": "r.account_id",
+						"type": "string"
+					},
+					"txn_date": {
+						"expression": "r.txn_date",
+						"type": "datetime"
+					},
+					"rn": {
+						"expression": "win.rn",
+						"type": "int"
+					},
+					"rnk": {
+						"expression": "win.rnk",
+						"type": "int"
+					},
+					"change": {
+						"expression": "r.amount - win.prev_amount",
+						"type": "decimal2"
+					},
+					"next_amount": {
+						"expression": "win.next_amount",
+						"type": "decimal2"
+					},
+					"balance": {
+						"expression": "win.balance",
+						"type": "decimal2"
+					},
+					"avg2": {
+						"expression": "win.avg2",
+						"type": "decimal2"
+					}
+				}
+			}
+		}
+	},
+	"dependency_policies": {
+		"current_active_first_stopped_nogo":` + DefaultPolicyCheckerConfJson +
+	`
+	}
+}`
+
+func TestWindow(t *testing.T) {
+	scriptDef := &ScriptDef{}
+	assert.Nil(t, scriptDef.Deserialize([]byte(windowScriptJson), ScriptJson, nil, nil, "", nil))
+
+	node := scriptDef.ScriptNodes["txns_window"]
+	assert.True(t, node.HasWindow())
+	assert.True(t, node.HasTableReader())
+	assert.True(t, node.HasTableCreator())
+	assert.Equal(t, WindowFuncLag, node.Window.Functions["prev_amount"].FuncType)
+	assert.Equal(t, 1, node.Window.Functions["next_amount"].Offset)
+	assert.Equal(t, 2, node.Window.Functions["avg2"].Offset)
+	assert.Equal(t, 1, len(node.Window.UsedInFunctionsFields))
+	assert.Equal(t, FieldRefs{{TableName: ReaderAlias, FieldName: "txn_date", FieldType: "datetime"}}, node.Window.GetOrderFieldRefs())
+
+	intervals, err := node.GetTokenIntervalsByNumberOfBatches()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(intervals))
+
+	d := func(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+	amounts := []decimal.Decimal{d("10"), d("20"), d("5"), d("-1")}
+	partitionVars := make([]eval.VarValuesMap, len(amounts))
+	for i, amount := range amounts {
+		partitionVars[i] = eval.VarValuesMap{ReaderAlias: {"amount": amount}}
+	}
+	winValues, err := node.Window.CalculateWindowValues(partitionVars, []string{"a", "b", "b", "c"})
+	assert.Nil(t, err)
+
+	expected := []map[string]any{
+		{"rn": int64(1), "rnk": int64(1), "prev_amount": d("0"), "next_amount": d("20"), "balance": d("10"), "avg2": d("10")},
+		{"rn": int64(2), "rnk": int64(2), "prev_amount": d("10"), "next_amount": d("5"), "balance": d("30"), "avg2": d("15")},
+		{"rn": int64(3), "rnk": int64(2), "prev_amount": d("20"), "next_amount": d("-1"), "balance": d("35"), "avg2": d("12.5")},
+		{"rn": int64(4), "rnk": int64(4), "prev_amount": d("5"), "next_amount": d("0"), "balance": d("34"), "avg2": d("2")},
+	}
+	for i := range expected {
+		for funcName, expectedVal := range expected[i] {
+			switch typedVal := expectedVal.(type) {
+			case decimal.Decimal:
+				assert.True(t, typedVal.Equal(winValues[i][funcName].(decimal.Decimal)), "row %d %s: expected %v, got %v", i, funcName, typedVal, winValues[i][funcName])
+			default:
+				assert.Equal(t, typedVal, winValues[i][funcName], "row %d %s", i, funcName)
+			}
+		}
+	}
+}
+
+func TestWindowBadDefs(t *testing.T) {
+	scriptDef := &ScriptDef{}
+
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `"row_number()"`, `"row_count()"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"cannot parse window function rn expression [row_count()]: [unknown window function row_count]")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `{"expression": "rank()", "type": "int"}`, `{"expression": "rank()", "type": "float"}`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"rank() returns int, float specified")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `"lag(r.amount, 1)"`, `"lag(r.amount, 0)"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"invalid lag() offset: expected positive integer literal, got 0")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `"moving_avg(r.amount, 2)"`, `"moving_avg(r.amount)"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"moving_avg() expects expression and window size")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `"running_sum(r.amount)"`, `"running_sum(sum(r.amount))"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"cannot use agg functions in window function argument: [found aggregate function sum()]")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `"running_sum(r.amount)"`, `"running_sum(r.bad_field)"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"invalid field(s) in window function balance expression [running_sum(r.bad_field)]: [unknown field r.bad_field]")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `"running_sum(r.amount)"`, `"running_sum(r.account_id)"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"cannot evaluate window function balance expression [running_sum(r.account_id)]")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `"order": "txn_date"`, `"order": "bad_field"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"cannot parse window order")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `"non_unique(account_id)"`, `"unique(account_id)"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"window index [idx_txns_account_id] must be non-unique, rows with the same key form a partition")
+	assert.Contains(t,
+		scriptDef.Deserialize([]byte(strings.Replace(windowScriptJson, `"expression": "win.rn"`, `"expression": "win.bad_func"`, 1)), ScriptJson, nil, nil, "", nil).Error(),
+		"unknown field win.bad_func")
+}
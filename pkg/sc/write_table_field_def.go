@@ -11,6 +11,7 @@ type WriteTableFieldDef struct {
 	RawExpression    string                  `json:"expression" yaml:"expression"`
 	Type             evalcapi.TableFieldType `json:"type" yaml:"type"`
 	DefaultValue     string                  `json:"default_value,omitempty" yaml:"default_value,omitempty"` // Optional. If omitted, default zero value is used
+	Nullable         bool                    `json:"nullable,omitempty" yaml:"nullable,omitempty"`           // Expression may return null, written as Cassandra null
 	ParsedExpression ast.Expr                `json:"-"`
 	UsedFields       FieldRefs               `json:"-"`
 }
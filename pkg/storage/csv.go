@@ -1,8 +1,6 @@
 package storage
 
 import (
-	"bufio"
-	"encoding/csv"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/capillariesio/capillaries/pkg/sc"
 )
 
 type GuessedField struct {
@@ -17,6 +16,67 @@ type GuessedField struct {
 	CapiName       string
 	Type           evalcapi.TableFieldType
 	Format         string
+	IsRepeated     bool // Parquet only: list, map or repeated field
+}
+
+// If we find something more generic than on previous steps, make data type more generic (eventually, string)
+var generalizeMap = map[evalcapi.TableFieldType]map[evalcapi.TableFieldType]evalcapi.TableFieldType{
+	evalcapi.FieldTypeUnknown: {
+		evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeUnknown,
+		evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
+		evalcapi.FieldTypeBool:     evalcapi.FieldTypeBool,
+		evalcapi.FieldTypeInt:      evalcapi.FieldTypeInt,
+		evalcapi.FieldTypeFloat:    evalcapi.FieldTypeFloat,
+		evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeDecimal2,
+		evalcapi.FieldTypeDateTime: evalcapi.FieldTypeDateTime},
+	evalcapi.FieldTypeBool: {
+		evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeBool,
+		evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
+		evalcapi.FieldTypeBool:     evalcapi.FieldTypeBool,
+		evalcapi.FieldTypeInt:      evalcapi.FieldTypeString,
+		evalcapi.FieldTypeFloat:    evalcapi.FieldTypeString,
+		evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeString,
+		evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
+	evalcapi.FieldTypeString: {
+		evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeString,
+		evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
+		evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
+		evalcapi.FieldTypeInt:      evalcapi.FieldTypeString,
+		evalcapi.FieldTypeFloat:    evalcapi.FieldTypeString,
+		evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeString,
+		evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
+	evalcapi.FieldTypeInt: {
+		evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeInt,
+		evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
+		evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
+		evalcapi.FieldTypeInt:      evalcapi.FieldTypeInt,
+		evalcapi.FieldTypeFloat:    evalcapi.FieldTypeFloat,
+		evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeDecimal2,
+		evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
+	evalcapi.FieldTypeFloat: {
+		evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeFloat,
+		evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
+		evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
+		evalcapi.FieldTypeInt:      evalcapi.FieldTypeFloat,
+		evalcapi.FieldTypeFloat:    evalcapi.FieldTypeFloat,
+		evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeFloat,
+		evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
+	evalcapi.FieldTypeDecimal2: {
+		evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeDecimal2,
+		evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
+		evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
+		evalcapi.FieldTypeInt:      evalcapi.FieldTypeDecimal2,
+		evalcapi.FieldTypeFloat:    evalcapi.FieldTypeFloat,
+		evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeDecimal2,
+		evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
+	evalcapi.FieldTypeDateTime: {
+		evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeDateTime,
+		evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
+		evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
+		evalcapi.FieldTypeInt:      evalcapi.FieldTypeString,
+		evalcapi.FieldTypeFloat:    evalcapi.FieldTypeString,
+		evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeString,
+		evalcapi.FieldTypeDateTime: evalcapi.FieldTypeDateTime},
 }
 
 func guessCsvType(strVal string) (evalcapi.TableFieldType, string) {
@@ -76,8 +136,25 @@ func guessCsvType(strVal string) (evalcapi.TableFieldType, string) {
 	return evalcapi.FieldTypeString, "" // Capillaries does not accept format specifier for string fields
 }
 
-func CsvGuessFields(filePath string, csvHeaderLineIdx int, csvFirstDataLineIdx int, csvSeparator string) ([]*GuessedField, error) {
+// Separator and encoding not specified in settings are detected and written back to settings,
+// BOM detected at the beginning of the file sets StripBom
+func CsvGuessFields(filePath string, settings *sc.CsvReaderSettings) ([]*GuessedField, error) {
 	var guessedFields []*GuessedField
+
+	dialect, err := guessCsvDialect(filePath)
+	if err != nil {
+		return guessedFields, err
+	}
+	if settings.Separator == "" {
+		settings.Separator = dialect.Separator
+	}
+	if settings.Encoding == "" && dialect.Encoding != sc.CsvEncodingUtf8 {
+		settings.Encoding = dialect.Encoding
+	}
+	if dialect.StripBom {
+		settings.StripBom = true
+	}
+
 	f, err := os.Open(filePath)
 	if err != nil {
 		return guessedFields, fmt.Errorf("cannot open csv file %s: %s", filePath, err.Error())
@@ -87,75 +164,16 @@ func CsvGuessFields(filePath string, csvHeaderLineIdx int, csvFirstDataLineIdx i
 	}
 	defer f.Close()
 
-	fileReader := bufio.NewReader(f)
-	r := csv.NewReader(fileReader)
-	r.Comma = rune(csvSeparator[0])
-	// To avoid bare \" error: https://stackoverflow.com/questions/31326659/golang-csv-error-bare-in-non-quoted-field
-	r.LazyQuotes = true
+	r, err := NewCsvRecordReader(f, settings)
+	if err != nil {
+		return guessedFields, fmt.Errorf("cannot read csv file %s: %s", filePath, err.Error())
+	}
+	csvHeaderLineIdx := settings.SrcFileHdrLineIdx
+	csvFirstDataLineIdx := settings.SrcFileFirstDataLineIdx
 
 	lineIdx := 0
 	reNonAlphanum := regexp.MustCompile("[^a-zA-Z0-9_]")
 
-	// If we find something more generic than on previous steps, make data type more generic (eventually, string)
-	generalizeMap := map[evalcapi.TableFieldType]map[evalcapi.TableFieldType]evalcapi.TableFieldType{
-		evalcapi.FieldTypeUnknown: {
-			evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeUnknown,
-			evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
-			evalcapi.FieldTypeBool:     evalcapi.FieldTypeBool,
-			evalcapi.FieldTypeInt:      evalcapi.FieldTypeInt,
-			evalcapi.FieldTypeFloat:    evalcapi.FieldTypeFloat,
-			evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeDecimal2,
-			evalcapi.FieldTypeDateTime: evalcapi.FieldTypeDateTime},
-		evalcapi.FieldTypeBool: {
-			evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeBool,
-			evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
-			evalcapi.FieldTypeBool:     evalcapi.FieldTypeBool,
-			evalcapi.FieldTypeInt:      evalcapi.FieldTypeString,
-			evalcapi.FieldTypeFloat:    evalcapi.FieldTypeString,
-			evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeString,
-			evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
-		evalcapi.FieldTypeString: {
-			evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeString,
-			evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
-			evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
-			evalcapi.FieldTypeInt:      evalcapi.FieldTypeString,
-			evalcapi.FieldTypeFloat:    evalcapi.FieldTypeString,
-			evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeString,
-			evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
-		evalcapi.FieldTypeInt: {
-			evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeInt,
-			evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
-			evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
-			evalcapi.FieldTypeInt:      evalcapi.FieldTypeInt,
-			evalcapi.FieldTypeFloat:    evalcapi.FieldTypeFloat,
-			evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeDecimal2,
-			evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
-		evalcapi.FieldTypeFloat: {
-			evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeFloat,
-			evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
-			evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
-			evalcapi.FieldTypeInt:      evalcapi.FieldTypeFloat,
-			evalcapi.FieldTypeFloat:    evalcapi.FieldTypeFloat,
-			evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeFloat,
-			evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
-		evalcapi.FieldTypeDecimal2: {
-			evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeDecimal2,
-			evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
-			evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
-			evalcapi.FieldTypeInt:      evalcapi.FieldTypeDecimal2,
-			evalcapi.FieldTypeFloat:    evalcapi.FieldTypeFloat,
-			evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeDecimal2,
-			evalcapi.FieldTypeDateTime: evalcapi.FieldTypeString},
-		evalcapi.FieldTypeDateTime: {
-			evalcapi.FieldTypeUnknown:  evalcapi.FieldTypeDateTime,
-			evalcapi.FieldTypeString:   evalcapi.FieldTypeString,
-			evalcapi.FieldTypeBool:     evalcapi.FieldTypeString,
-			evalcapi.FieldTypeInt:      evalcapi.FieldTypeString,
-			evalcapi.FieldTypeFloat:    evalcapi.FieldTypeString,
-			evalcapi.FieldTypeDecimal2: evalcapi.FieldTypeString,
-			evalcapi.FieldTypeDateTime: evalcapi.FieldTypeDateTime},
-	}
-
 	for {
 		line, err := r.Read()
 		if err == io.EOF {
@@ -177,7 +195,6 @@ func CsvGuessFields(filePath string, csvHeaderLineIdx int, csvFirstDataLineIdx i
 			}
 		} else if lineIdx < csvFirstDataLineIdx {
 			// Still some header stuff
-			continue
 		} else {
 			// It's a data line
 
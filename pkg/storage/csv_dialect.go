@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"golang.org/x/text/transform"
+)
+
+const csvDialectSampleSize int = 64 * 1024
+
+// Separators we try to detect, multi-character separators have to be specified explicitly
+var csvSeparatorCandidates = []string{",", ";", "\t", "|"}
+
+type guessedCsvDialect struct {
+	Encoding  string
+	StripBom  bool
+	Separator string
+}
+
+// BOM wins. Without BOM: valid UTF-8, or UTF-16 if there are many zero bytes, or ISO-8859-1 as the last resort.
+func guessCsvEncoding(sample []byte, isComplete bool) (string, bool) {
+	switch {
+	case bytes.HasPrefix(sample, utf8Bom):
+		return sc.CsvEncodingUtf8, true
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}) || bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return sc.CsvEncodingUtf16, false
+	}
+
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b == 0 {
+			if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+	}
+	if oddZeros > len(sample)/4 {
+		return "utf-16le", false
+	}
+	if evenZeros > len(sample)/4 {
+		return "utf-16be", false
+	}
+
+	// Sample may end in the middle of a multi-byte character
+	if !isComplete {
+		if i := bytes.LastIndexByte(sample, '\n'); i >= 0 {
+			sample = sample[:i]
+		}
+	}
+	if utf8.Valid(sample) {
+		return sc.CsvEncodingUtf8, false
+	}
+	return "iso-8859-1", false
+}
+
+func countCsvSeparatorOutsideQuotes(line string, separator string) int {
+	count := 0
+	inQuotes := false
+	for i := 0; i < len(line); i++ {
+		if line[i] == '"' {
+			inQuotes = !inQuotes
+		} else if !inQuotes && strings.HasPrefix(line[i:], separator) {
+			count++
+		}
+	}
+	return count
+}
+
+// The separator that splits most lines into the same non-zero number of fields; comma if nothing fits
+func guessCsvSeparator(lines []string) string {
+	bestSeparator := ","
+	bestLineCount := 0
+	bestFieldCount := 0
+	for _, separator := range csvSeparatorCandidates {
+		lineCountBySepCount := map[int]int{}
+		for _, line := range lines {
+			if sepCount := countCsvSeparatorOutsideQuotes(line, separator); sepCount > 0 {
+				lineCountBySepCount[sepCount]++
+			}
+		}
+		for sepCount, lineCount := range lineCountBySepCount {
+			if lineCount > bestLineCount || (lineCount == bestLineCount && sepCount > bestFieldCount) {
+				bestSeparator = separator
+				bestLineCount = lineCount
+				bestFieldCount = sepCount
+			}
+		}
+	}
+	return bestSeparator
+}
+
+// Looks at the beginning of the file only, see CsvGuessFields
+func guessCsvDialect(filePath string) (*guessedCsvDialect, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open csv file %s: %s", filePath, err.Error())
+	}
+	defer f.Close()
+
+	sample := make([]byte, csvDialectSampleSize)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("cannot read csv file %s: %s", filePath, err.Error())
+	}
+	sample = sample[:n]
+	isComplete := n < csvDialectSampleSize
+
+	dialect := guessedCsvDialect{}
+	dialect.Encoding, dialect.StripBom = guessCsvEncoding(sample, isComplete)
+
+	enc, err := sc.GetTextEncoding(dialect.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	text := sample
+	if enc != nil {
+		if text, _, err = transform.Bytes(enc.NewDecoder(), sample); err != nil {
+			return nil, fmt.Errorf("cannot decode csv file %s as %s: %s", filePath, dialect.Encoding, err.Error())
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(text), "\r\n", "\n"), "\n")
+	if !isComplete && len(lines) > 1 {
+		// The last line is probably incomplete
+		lines = lines[:len(lines)-1]
+	}
+	dialect.Separator = guessCsvSeparator(lines)
+
+	return &dialect, nil
+}
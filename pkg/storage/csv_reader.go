@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"golang.org/x/text/transform"
+)
+
+var utf8Bom = []byte{0xEF, 0xBB, 0xBF}
+
+// Implemented by encoding/csv Reader. Errors for malformed records are returned as *csv.ParseError.
+type CsvRecordReader interface {
+	Read() ([]string, error)
+	InputOffset() int64
+}
+
+type csvRecordReader struct {
+	r          CsvRecordReader
+	bomLength  int64
+	trimSpaces bool
+}
+
+func (cr *csvRecordReader) Read() ([]string, error) {
+	record, err := cr.r.Read()
+	if cr.trimSpaces {
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+	}
+	return record, err
+}
+
+// Offset in the source stream, stripped BOM included
+func (cr *csvRecordReader) InputOffset() int64 {
+	return cr.r.InputOffset() + cr.bomLength
+}
+
+// Decodes the source stream and reads CSV records from it according to file reader csv settings.
+// Standard dialect is read by encoding/csv with LazyQuotes, anything else by csvDialectReader.
+func NewCsvRecordReader(fileReader io.Reader, settings *sc.CsvReaderSettings) (CsvRecordReader, error) {
+	if settings.Separator == "" {
+		return nil, errors.New("csv separator cannot be empty")
+	}
+	enc, err := sc.GetTextEncoding(settings.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if enc != nil {
+		fileReader = transform.NewReader(fileReader, enc.NewDecoder())
+	}
+
+	br := bufio.NewReader(fileReader)
+	cr := csvRecordReader{trimSpaces: settings.TrimSpaces}
+	if settings.StripBom {
+		// UTF-16 BOM is consumed by the decoder, or decoded to UTF-8 BOM if the byte order is explicit
+		if prefix, err := br.Peek(len(utf8Bom)); err == nil && bytes.Equal(prefix, utf8Bom) {
+			if _, err := br.Discard(len(utf8Bom)); err != nil {
+				return nil, err
+			}
+			cr.bomLength = int64(len(utf8Bom))
+		}
+	}
+
+	if settings.IsStandardDialect() {
+		r := csv.NewReader(br)
+		r.Comma, _ = utf8.DecodeRuneInString(settings.Separator)
+		if settings.CommentPrefix != "" {
+			r.Comment, _ = utf8.DecodeRuneInString(settings.CommentPrefix)
+		}
+		// To avoid bare \" error: https://stackoverflow.com/questions/31326659/golang-csv-error-bare-in-non-quoted-field
+		r.LazyQuotes = true
+		cr.r = r
+	} else {
+		cr.r = &csvDialectReader{
+			r:             br,
+			separator:     settings.Separator,
+			quote:         settings.GetQuoteChar(),
+			commentPrefix: settings.CommentPrefix}
+	}
+	return &cr, nil
+}
+
+// Mimics encoding/csv with LazyQuotes: empty and comment lines are skipped, quotes inside quoted fields are doubled,
+// a stray quote is taken literally, quoted fields may contain line breaks, all records must have the same number of fields.
+type csvDialectReader struct {
+	r               *bufio.Reader
+	separator       string
+	quote           string
+	commentPrefix   string
+	offset          int64
+	lineCount       int
+	fieldsPerRecord int
+}
+
+func (dr *csvDialectReader) InputOffset() int64 {
+	return dr.offset
+}
+
+// Returns the line without line break, and io.EOF only when there are no more lines
+func (dr *csvDialectReader) readLine() (string, error) {
+	line, err := dr.r.ReadString('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return "", err
+	}
+	dr.offset += int64(len(line))
+	dr.lineCount++
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+func (dr *csvDialectReader) Read() ([]string, error) {
+	var s string
+	for {
+		line, err := dr.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) > 0 && (dr.commentPrefix == "" || !strings.HasPrefix(line, dr.commentPrefix)) {
+			s = line
+			break
+		}
+	}
+	startLine := dr.lineCount
+
+	record := make([]string, 0, max(dr.fieldsPerRecord, 1))
+	pos := 0
+	for {
+		if strings.HasPrefix(s[pos:], dr.quote) {
+			field := strings.Builder{}
+			pos += len(dr.quote)
+			for {
+				i := strings.Index(s[pos:], dr.quote)
+				if i < 0 {
+					// Line break inside quotes, unless there are no more lines
+					field.WriteString(s[pos:])
+					next, err := dr.readLine()
+					if err == io.EOF {
+						pos = len(s)
+						break
+					}
+					if err != nil {
+						return nil, err
+					}
+					field.WriteString("\n")
+					s = next
+					pos = 0
+					continue
+				}
+				field.WriteString(s[pos : pos+i])
+				pos += i + len(dr.quote)
+				if strings.HasPrefix(s[pos:], dr.quote) {
+					field.WriteString(dr.quote)
+					pos += len(dr.quote)
+				} else if pos == len(s) || strings.HasPrefix(s[pos:], dr.separator) {
+					break
+				} else {
+					field.WriteString(dr.quote)
+				}
+			}
+			record = append(record, field.String())
+			if pos == len(s) {
+				break
+			}
+			pos += len(dr.separator)
+		} else {
+			i := strings.Index(s[pos:], dr.separator)
+			if i < 0 {
+				record = append(record, s[pos:])
+				break
+			}
+			record = append(record, s[pos:pos+i])
+			pos += i + len(dr.separator)
+		}
+	}
+
+	if dr.fieldsPerRecord == 0 {
+		dr.fieldsPerRecord = len(record)
+	} else if len(record) != dr.fieldsPerRecord {
+		return record, &csv.ParseError{StartLine: startLine, Line: dr.lineCount, Column: 1, Err: csv.ErrFieldCount}
+	}
+	return record, nil
+}
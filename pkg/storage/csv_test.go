@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	"github.com/capillariesio/capillaries/pkg/sc"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCsvTestFile(t *testing.T, content string) string {
+	filePath := filepath.Join(t.TempDir(), "test.csv")
+	assert.Nil(t, os.WriteFile(filePath, []byte(content), 0644))
+	return filePath
+}
+
+func TestCsvGuessFieldsSkipsLinesBeforeFirstDataLine(t *testing.T) {
+	// Line 1 is neither header nor data
+	filePath := writeCsvTestFile(t, "id,name\nexported,today\n1,abc\n2,def\n")
+
+	guessedFields, err := CsvGuessFields(filePath, &sc.CsvReaderSettings{SrcFileHdrLineIdx: 0, SrcFileFirstDataLineIdx: 2, Separator: ","})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(guessedFields))
+	assert.Equal(t, "col_id", guessedFields[0].CapiName)
+	assert.Equal(t, evalcapi.FieldTypeInt, guessedFields[0].Type)
+	assert.Equal(t, "col_name", guessedFields[1].CapiName)
+	assert.Equal(t, evalcapi.FieldTypeString, guessedFields[1].Type)
+}
+
+func TestCsvGuessFieldsDetectsDialect(t *testing.T) {
+	filePath := writeCsvTestFile(t, "\xEF\xBB\xBFid;name\n1;abc def\n2;ghi\n")
+
+	settings := sc.CsvReaderSettings{SrcFileHdrLineIdx: 0, SrcFileFirstDataLineIdx: 1}
+	guessedFields, err := CsvGuessFields(filePath, &settings)
+	assert.Nil(t, err)
+	assert.Equal(t, ";", settings.Separator)
+	assert.Equal(t, "", settings.Encoding)
+	assert.True(t, settings.StripBom)
+	assert.Equal(t, 2, len(guessedFields))
+	assert.Equal(t, "id", guessedFields[0].OriginalHeader)
+	assert.Equal(t, evalcapi.FieldTypeInt, guessedFields[0].Type)
+
+	// Specified separator wins
+	settings = sc.CsvReaderSettings{SrcFileHdrLineIdx: 0, SrcFileFirstDataLineIdx: 1, Separator: ","}
+	_, err = CsvGuessFields(filePath, &settings)
+	assert.Nil(t, err)
+	assert.Equal(t, ",", settings.Separator)
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+)
+
+// Nested objects are flattened into dotted paths, arrays are treated as JSON text
+func jsonlGuessDocFields(prefix string, doc map[string]any, fieldMap map[string]*GuessedField, reNonAlphanum *regexp.Regexp) {
+	for key, val := range doc {
+		path := key
+		if len(prefix) > 0 {
+			path = prefix + "." + key
+		}
+		if nestedDoc, ok := val.(map[string]any); ok {
+			jsonlGuessDocFields(path, nestedDoc, fieldMap, reNonAlphanum)
+			continue
+		}
+
+		gf, ok := fieldMap[path]
+		if !ok {
+			gf = &GuessedField{
+				OriginalHeader: path,
+				CapiName:       "col_" + reNonAlphanum.ReplaceAllString(path, "_"),
+				Type:           evalcapi.FieldTypeUnknown,
+				Format:         ""}
+			fieldMap[path] = gf
+		}
+
+		var guessedType evalcapi.TableFieldType
+		var guessedFmt string
+		switch typedVal := val.(type) {
+		case nil:
+			// Null tells us nothing about the type
+			continue
+		case bool:
+			guessedType = evalcapi.FieldTypeBool
+		case json.Number:
+			guessedType, _ = guessCsvType(typedVal.String())
+		case string:
+			guessedType, guessedFmt = guessCsvType(typedVal)
+			if guessedType != evalcapi.FieldTypeDateTime {
+				// Numbers and bools in quotes are still strings in JSON
+				guessedType = evalcapi.FieldTypeString
+			}
+		default:
+			guessedType = evalcapi.FieldTypeString
+		}
+		gf.Type = generalizeMap[gf.Type][guessedType]
+		if gf.Type == evalcapi.FieldTypeDateTime {
+			gf.Format = guessedFmt
+		} else {
+			gf.Format = ""
+		}
+	}
+}
+
+func JsonlGuessFields(filePath string) ([]*GuessedField, error) {
+	var guessedFields []*GuessedField
+	f, err := os.Open(filePath)
+	if err != nil {
+		return guessedFields, fmt.Errorf("cannot open jsonl file %s: %s", filePath, err.Error())
+	}
+	defer f.Close()
+
+	reNonAlphanum := regexp.MustCompile("[^a-zA-Z0-9_]")
+	fieldMap := map[string]*GuessedField{}
+
+	fileReader := bufio.NewReader(f)
+	lineIdx := 0
+	for lineIdx < 100 {
+		line, err := fileReader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return guessedFields, fmt.Errorf("cannot read file [%s]: [%s]", filePath, err.Error())
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(line))
+			decoder.UseNumber()
+			var doc map[string]any
+			if errDecode := decoder.Decode(&doc); errDecode != nil {
+				return guessedFields, fmt.Errorf("cannot parse line %d of file [%s]: [%s]", lineIdx, filePath, errDecode.Error())
+			}
+			jsonlGuessDocFields("", doc, fieldMap, reNonAlphanum)
+			lineIdx++
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if len(fieldMap) == 0 {
+		return guessedFields, fmt.Errorf("cannot find any fields in file [%s]", filePath)
+	}
+
+	paths := make([]string, 0, len(fieldMap))
+	for path := range fieldMap {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	guessedFields = make([]*GuessedField, len(paths))
+	for i, path := range paths {
+		guessedFields[i] = fieldMap[path]
+		if guessedFields[i].Type == evalcapi.FieldTypeUnknown {
+			// Always null in the sample
+			guessedFields[i].Type = evalcapi.FieldTypeString
+		}
+	}
+
+	return guessedFields, nil
+}
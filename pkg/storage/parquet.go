@@ -1,11 +1,12 @@
 package storage
 
 import (
-	"encoding/binary"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/capillariesio/capillaries/pkg/evalcapi"
@@ -16,39 +17,53 @@ import (
 )
 
 type ParquetWriter struct {
-	FileWriter *gp.FileWriter
-	StoreMap   map[string]*gp.ColumnStore // TODO: consider using w.FileWriter.GetColumnByName() instead and abandon ParquetWriter
+	FileWriter      *gp.FileWriter
+	StoreMap        map[string]*gp.ColumnStore // TODO: consider using w.FileWriter.GetColumnByName() instead and abandon ParquetWriter
+	Settings        sc.ParquetCreatorSettings
+	out             *parquetFooterWriter
+	columns         []*parquetWriterColumn
+	rowGroupRows    int64
+	rowGroupsMinMax []map[string]*parquetMinMax // Per flushed row group: string and bool columns, see parquet_writer_stats.go
 }
 
-func NewParquetWriter(ioWriter io.Writer, codec sc.ParquetCodecType) (*ParquetWriter, error) {
+func NewParquetWriter(ioWriter io.Writer, settings sc.ParquetCreatorSettings) (*ParquetWriter, error) {
 	codecMap := map[sc.ParquetCodecType]pgparquet.CompressionCodec{
 		sc.ParquetCodecGzip:         pgparquet.CompressionCodec_GZIP,
 		sc.ParquetCodecSnappy:       pgparquet.CompressionCodec_SNAPPY,
+		sc.ParquetCodecZstd:         pgparquet.CompressionCodec_ZSTD,
 		sc.ParquetCodecUncompressed: pgparquet.CompressionCodec_UNCOMPRESSED,
 	}
-	gpCodec, ok := codecMap[codec]
+	gpCodec, ok := codecMap[settings.Codec]
 	if !ok {
-		return nil, fmt.Errorf("unsupported parquet codec %s", codec)
+		return nil, fmt.Errorf("unsupported parquet codec %s", settings.Codec)
 	}
+	// Row groups are flushed by AddData, not by gp.FileWriter, so we know which rows each row group has
+	out := &parquetFooterWriter{w: ioWriter}
 	return &ParquetWriter{
-		StoreMap:   map[string]*gp.ColumnStore{},
-		FileWriter: gp.NewFileWriter(ioWriter, gp.WithCompressionCodec(gpCodec), gp.WithCreator("capillaries")),
+		StoreMap:        map[string]*gp.ColumnStore{},
+		Settings:        settings,
+		out:             out,
+		columns:         []*parquetWriterColumn{},
+		rowGroupsMinMax: []map[string]*parquetMinMax{},
+		FileWriter:      gp.NewFileWriter(out, gp.WithCompressionCodec(gpCodec), gp.WithCreator("capillaries"), gp.WithMaxPageSize(settings.GetPageSizeBytes())),
 	}, nil
 }
 
-func (w *ParquetWriter) AddColumn(name string, fieldType evalcapi.TableFieldType) error {
+// Nil colSettings: creator-level dictionary and statistics settings
+func (w *ParquetWriter) AddColumn(name string, fieldType evalcapi.TableFieldType, colSettings *sc.WriteParquetColumnSettings) error {
 	if _, ok := w.StoreMap[name]; ok {
 		return fmt.Errorf("cannot add duplicate column %s", name)
 	}
 
+	useDict := w.Settings.UsesDictionary(colSettings)
 	var s *gp.ColumnStore
 	var err error
-	switch fieldType {
+	switch evalcapi.FieldTypeKind(fieldType) {
 	case evalcapi.FieldTypeString:
 		params := &gp.ColumnParameters{LogicalType: pgparquet.NewLogicalType()}
 		params.LogicalType.STRING = pgparquet.NewStringType()
 		params.ConvertedType = pgparquet.ConvertedTypePtr(pgparquet.ConvertedType_UTF8)
-		s, err = gp.NewByteArrayStore(pgparquet.Encoding_PLAIN, true, params)
+		s, err = gp.NewByteArrayStore(pgparquet.Encoding_PLAIN, useDict, params)
 	case evalcapi.FieldTypeDateTime:
 		params := &gp.ColumnParameters{LogicalType: pgparquet.NewLogicalType()}
 		params.LogicalType.TIMESTAMP = pgparquet.NewTimestampType()
@@ -56,23 +71,35 @@ func (w *ParquetWriter) AddColumn(name string, fieldType evalcapi.TableFieldType
 		// Go and Parquet support nanoseconds. Unfortunately, Cassandra supports only milliseconds. Millis are our lingua franca.
 		params.LogicalType.TIMESTAMP.Unit.MILLIS = pgparquet.NewMilliSeconds()
 		params.ConvertedType = pgparquet.ConvertedTypePtr(pgparquet.ConvertedType_TIMESTAMP_MILLIS)
-		s, err = gp.NewInt64Store(pgparquet.Encoding_PLAIN, true, params)
+		s, err = gp.NewInt64Store(pgparquet.Encoding_PLAIN, useDict, params)
 	case evalcapi.FieldTypeInt:
-		s, err = gp.NewInt64Store(pgparquet.Encoding_PLAIN, true, &gp.ColumnParameters{})
+		s, err = gp.NewInt64Store(pgparquet.Encoding_PLAIN, useDict, &gp.ColumnParameters{})
 	case evalcapi.FieldTypeDecimal2:
 		params := &gp.ColumnParameters{LogicalType: pgparquet.NewLogicalType()}
 		params.LogicalType.DECIMAL = pgparquet.NewDecimalType()
 		params.LogicalType.DECIMAL.Scale = 2
 		params.LogicalType.DECIMAL.Precision = 2
+		precision, scale, isDecimalPS := evalcapi.ParseDecimalFieldType(fieldType)
+		if isDecimalPS {
+			params.LogicalType.DECIMAL.Scale = scale
+			params.LogicalType.DECIMAL.Precision = precision
+		}
 		// This is to make fraugster/go-parquet happy so it writes this metadata,
 		// see buildElement() implementation in schema.go
 		params.Scale = &params.LogicalType.DECIMAL.Scale
 		params.Precision = &params.LogicalType.DECIMAL.Precision
 		params.ConvertedType = pgparquet.ConvertedTypePtr(pgparquet.ConvertedType_DECIMAL)
-		s, err = gp.NewInt64Store(pgparquet.Encoding_PLAIN, true, params)
+		if isDecimalPS && precision > parquetMaxInt64DecimalPrecision {
+			typeLength := parquetDecimalByteLength(precision)
+			params.TypeLength = &typeLength
+			s, err = gp.NewFixedByteArrayStore(pgparquet.Encoding_PLAIN, useDict, params)
+		} else {
+			s, err = gp.NewInt64Store(pgparquet.Encoding_PLAIN, useDict, params)
+		}
 	case evalcapi.FieldTypeFloat:
-		s, err = gp.NewDoubleStore(pgparquet.Encoding_PLAIN, true, &gp.ColumnParameters{})
+		s, err = gp.NewDoubleStore(pgparquet.Encoding_PLAIN, useDict, &gp.ColumnParameters{})
 	case evalcapi.FieldTypeBool:
+		// No dictionary for bools
 		s, err = gp.NewBooleanStore(pgparquet.Encoding_PLAIN, &gp.ColumnParameters{})
 	default:
 		return fmt.Errorf("cannot add %s column %s: unsupported field type", fieldType, name)
@@ -84,21 +111,62 @@ func (w *ParquetWriter) AddColumn(name string, fieldType evalcapi.TableFieldType
 		return fmt.Errorf("cannot add %s column %s: %s", fieldType, name, err.Error())
 	}
 	w.StoreMap[name] = s
+	w.columns = append(w.columns, &parquetWriterColumn{name: name, fieldType: fieldType, statistics: w.Settings.WritesStatistics(colSettings)})
+	return nil
+}
+
+// Flushes the row group when it reaches row_group_size_bytes
+func (w *ParquetWriter) AddData(d map[string]any) error {
+	if err := w.FileWriter.AddData(d); err != nil {
+		return err
+	}
+	for _, col := range w.columns {
+		col.trackMinMax(d[col.name])
+	}
+	w.rowGroupRows++
+	if w.FileWriter.CurrentRowGroupSize() >= w.Settings.GetRowGroupSizeBytes() {
+		return w.FlushRowGroup()
+	}
+	return nil
+}
+
+func (w *ParquetWriter) FlushRowGroup() error {
+	if w.rowGroupRows == 0 {
+		return nil
+	}
+	if err := w.FileWriter.FlushRowGroup(); err != nil {
+		return fmt.Errorf("cannot flush row group: %s", err.Error())
+	}
+	rowGroupMinMax := map[string]*parquetMinMax{}
+	for _, col := range w.columns {
+		if col.minMax != nil {
+			rowGroupMinMax[col.name] = col.minMax
+			col.minMax = nil
+		}
+	}
+	w.rowGroupsMinMax = append(w.rowGroupsMinMax, rowGroupMinMax)
+	w.rowGroupRows = 0
 	return nil
 }
 
 func (w *ParquetWriter) Close() error {
 	if w.FileWriter != nil {
-		if err := w.FileWriter.FlushRowGroup(); err != nil {
-			return fmt.Errorf("cannot flush row group: %s", err.Error())
+		if err := w.FlushRowGroup(); err != nil {
+			return err
 		}
 
+		// Footer is held back and patched: gp.FileWriter does not write string/bool statistics and column orders
+		w.out.holdFooter = true
 		if err := w.FileWriter.Close(); err != nil {
 			return fmt.Errorf("cannot close writer: %s", err.Error())
 		}
+		if err := w.writePatchedFooter(); err != nil {
+			return fmt.Errorf("cannot write footer: %s", err.Error())
+		}
 	}
 	return nil
 }
+
 func ParquetWriterMilliTs(t time.Time) any {
 	if t.Equal(sc.DefaultDateTime()) {
 		return nil
@@ -112,6 +180,44 @@ func ParquetWriterDecimal2(dec decimal.Decimal) any {
 	return dec.Mul(decimal.NewFromInt(100)).IntPart()
 }
 
+// Unscaled decimal(p,s) value: int64 up to 18 digits, fixed length big-endian two's complement beyond that
+func ParquetWriterDecimal(dec decimal.Decimal, fieldType evalcapi.TableFieldType) (any, error) {
+	precision, scale, ok := evalcapi.ParseDecimalFieldType(fieldType)
+	if !ok {
+		return ParquetWriterDecimal2(dec), nil
+	}
+	unscaled := dec.Round(scale).Coefficient()
+	if precision <= parquetMaxInt64DecimalPrecision {
+		return unscaled.Int64(), nil
+	}
+	byteLength := parquetDecimalByteLength(precision)
+	if unscaled.Sign() < 0 {
+		// Two's complement: 2^(8*byteLength) + unscaled
+		unscaled.Add(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*byteLength)))
+	}
+	unscaledBytes := unscaled.Bytes()
+	if len(unscaledBytes) > int(byteLength) {
+		return nil, fmt.Errorf("cannot write %s value %s to parquet, too many digits", fieldType, dec.String())
+	}
+	fixedBytes := make([]byte, byteLength)
+	if dec.Sign() < 0 {
+		for i := range fixedBytes {
+			fixedBytes[i] = 0xFF
+		}
+	}
+	copy(fixedBytes[int(byteLength)-len(unscaledBytes):], unscaledBytes)
+	return fixedBytes, nil
+}
+
+// Parquet spec: int64 holds up to 18 decimal digits
+const parquetMaxInt64DecimalPrecision int32 = 18
+
+// Minimal number of bytes that holds a signed value with this many decimal digits: 16 for 38
+func parquetDecimalByteLength(precision int32) int32 {
+	maxVal := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+	return int32(maxVal.BitLen()/8 + 1)
+}
+
 func isType(se *pgparquet.SchemaElement, t pgparquet.Type) bool {
 	return se.Type != nil && *se.Type == t
 }
@@ -139,7 +245,7 @@ func isParquetIntDecimal2(se *pgparquet.SchemaElement) bool {
 	return isLogicalOrConvertedDecimal(se) &&
 		(isType(se, pgparquet.Type_INT32) || isType(se, pgparquet.Type_INT64)) &&
 		se.Scale != nil && *se.Scale > -20 && *se.Scale < 20 &&
-		se.Precision != nil && *se.Precision >= 0 && *se.Precision < 18
+		se.Precision != nil && *se.Precision >= 0 && *se.Precision <= parquetMaxInt64DecimalPrecision
 }
 
 func isParquetFixedLengthByteArrayDecimal2(se *pgparquet.SchemaElement) bool {
@@ -182,6 +288,10 @@ func ParquetGuessCapiType(se *pgparquet.SchemaElement) (evalcapi.TableFieldType,
 	if isParquetString(se) {
 		return evalcapi.FieldTypeString, nil
 	} else if isParquetIntDecimal2(se) || isParquetFixedLengthByteArrayDecimal2(se) {
+		// Keep the scale of the file unless it is decimal2 already
+		if *se.Scale != 2 && *se.Scale >= 0 && *se.Scale <= *se.Precision && *se.Precision > 0 {
+			return evalcapi.DecimalFieldType(*se.Precision, *se.Scale), nil
+		}
 		return evalcapi.FieldTypeDecimal2, nil
 	} else if isParquetDateTime(se) || isParquetInt96Date(se) || isParquetInt32Date(se) {
 		return evalcapi.FieldTypeDateTime, nil
@@ -287,30 +397,12 @@ func ParquetReadDecimal2(val any, se *pgparquet.SchemaElement) (decimal.Decimal,
 		if len(typedVal) == 0 {
 			return sc.DefaultDecimal2(), fmt.Errorf("cannot read parquet decimal2 from byte array of zero length, schema %v", se)
 		}
-		var uintVal uint64
-		if len(typedVal) < 8 {
-			// Pad with zeroes or ones
-			padByte := byte(0)
-			if (typedVal[0] & 0x10) != 0 {
-				padByte = 0xFF
-			}
-			paddedVal := make([]byte, 8)
-			firstActualByteIdx := 8 - len(typedVal)
-			for i := 0; i < 8; i++ {
-				if i < firstActualByteIdx {
-					paddedVal[i] = padByte
-				} else {
-					paddedVal[i] = typedVal[i-firstActualByteIdx]
-				}
-			}
-			uintVal = binary.BigEndian.Uint64(paddedVal)
-		} else {
-			// TODO: handle first len-8 bytes, they should be either all be 0xFF (negative number) or all 0x00 (positive),
-			// otherwise, we are losing data. Also, pay attention to the first bit of the resulting uint64 -
-			// we either lose that bit to the int64 sign (throw then), or we have to adjust the sign
-			uintVal = binary.BigEndian.Uint64(typedVal[len(typedVal)-8:])
+		// Big-endian two's complement of any length, decimal(38,s) takes 16 bytes
+		unscaled := new(big.Int).SetBytes(typedVal)
+		if typedVal[0]&0x80 != 0 {
+			unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(typedVal))))
 		}
-		return decimal.New(int64(uintVal), -*se.Scale), nil
+		return decimal.NewFromBigInt(unscaled, -*se.Scale), nil
 	default:
 		return sc.DefaultDecimal2(), fmt.Errorf("cannot read parquet decimal2 from %T, schema %v", se, typedVal)
 	}
@@ -359,20 +451,28 @@ func ParquetGuessFields(filePath string) ([]*GuessedField, error) {
 		return guessedFields, err
 	}
 
-	// Digest schema
+	// Digest schema: nested fields get dotted names, list, map and repeated fields are read as joined strings
 	reNonAlphanum := regexp.MustCompile("[^a-zA-Z0-9_]")
-	schemaDef := reader.GetSchemaDefinition()
-	guessedFields = make([]*GuessedField, len(schemaDef.RootColumn.Children))
-	for i, column := range schemaDef.RootColumn.Children {
+	columns, err := ParquetListColumns(reader.GetSchemaDefinition().RootColumn)
+	if err != nil {
+		return guessedFields, err
+	}
+	guessedFields = make([]*GuessedField, len(columns))
+	for i, column := range columns {
+		colName := strings.Join(column.LogicalPath, ".")
 		t, err := ParquetGuessCapiType(column.SchemaElement)
 		if err != nil {
-			return guessedFields, fmt.Errorf("cannot read parquet column %s: %s", column.SchemaElement.Name, err.Error())
+			return guessedFields, fmt.Errorf("cannot read parquet column %s: %s", colName, err.Error())
+		}
+		if column.IsRepeated() {
+			t = evalcapi.FieldTypeString
 		}
 		guessedFields[i] = &GuessedField{
-			OriginalHeader: column.SchemaElement.Name,
-			CapiName:       "col_" + reNonAlphanum.ReplaceAllString(column.SchemaElement.Name, "_"),
+			OriginalHeader: colName,
+			CapiName:       "col_" + reNonAlphanum.ReplaceAllString(colName, "_"),
 			Type:           t,
-			Format:         ""} // unused for Parquet
+			Format:         "", // unused for Parquet
+			IsRepeated:     column.IsRepeated()}
 	}
 
 	return guessedFields, nil
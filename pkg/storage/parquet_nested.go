@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	gp "github.com/fraugster/parquet-go"
+	pgparquet "github.com/fraugster/parquet-go/parquet"
+	"github.com/fraugster/parquet-go/parquetschema"
+)
+
+// Primitive (leaf) Parquet column found by a logical path like items.sku, with the physical path to it, like items.list.element.sku
+type ParquetResolvedColumn struct {
+	LogicalPath   []string
+	PhysicalPath  gp.ColumnPath
+	RepeatedSteps []bool // Per physical path element: true if it's a repeated field, its value is a slice
+	SchemaElement *pgparquet.SchemaElement
+}
+
+func (c *ParquetResolvedColumn) IsRepeated() bool {
+	for _, isRepeated := range c.RepeatedSteps {
+		if isRepeated {
+			return true
+		}
+	}
+	return false
+}
+
+// Physical path up to the innermost repeated field: exploded columns with the same prefix have the same number of values in each row
+func (c *ParquetResolvedColumn) RepeatedPrefix() string {
+	for i := len(c.RepeatedSteps) - 1; i >= 0; i-- {
+		if c.RepeatedSteps[i] {
+			return strings.Join(c.PhysicalPath[:i+1], ".")
+		}
+	}
+	return ""
+}
+
+func isParquetRepeated(cd *parquetschema.ColumnDefinition) bool {
+	return cd.SchemaElement.RepetitionType != nil && *cd.SchemaElement.RepetitionType == pgparquet.FieldRepetitionType_REPEATED
+}
+
+func isParquetList(cd *parquetschema.ColumnDefinition) bool {
+	se := cd.SchemaElement
+	return se.LogicalType != nil && se.LogicalType.LIST != nil ||
+		se.ConvertedType != nil && *se.ConvertedType == pgparquet.ConvertedType_LIST
+}
+
+func isParquetMap(cd *parquetschema.ColumnDefinition) bool {
+	se := cd.SchemaElement
+	return se.LogicalType != nil && se.LogicalType.MAP != nil ||
+		se.ConvertedType != nil && (*se.ConvertedType == pgparquet.ConvertedType_MAP || *se.ConvertedType == pgparquet.ConvertedType_MAP_KEY_VALUE)
+}
+
+func findParquetChild(cd *parquetschema.ColumnDefinition, name string) *parquetschema.ColumnDefinition {
+	for _, child := range cd.Children {
+		if child.SchemaElement.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// LIST and MAP annotated groups wrap their contents in a repeated group (list, key_value).
+// Returns that group and, for standard 3-level lists, the element inside it.
+// Legacy 2-level lists (repeated group with several fields, or repeated primitive) have no separate element.
+func unwrapParquetListOrMap(cd *parquetschema.ColumnDefinition) ([]*parquetschema.ColumnDefinition, error) {
+	if len(cd.Children) != 1 || !isParquetRepeated(cd.Children[0]) {
+		return nil, fmt.Errorf("parquet list/map %s is expected to have exactly one repeated field", cd.SchemaElement.Name)
+	}
+	repeated := cd.Children[0]
+	if isParquetList(cd) && len(repeated.Children) == 1 && repeated.SchemaElement.Name != "array" && repeated.SchemaElement.Name != cd.SchemaElement.Name+"_tuple" {
+		return []*parquetschema.ColumnDefinition{repeated, repeated.Children[0]}, nil
+	}
+	return []*parquetschema.ColumnDefinition{repeated}, nil
+}
+
+func childNames(cd *parquetschema.ColumnDefinition) string {
+	names := make([]string, len(cd.Children))
+	for i, child := range cd.Children {
+		names[i] = child.SchemaElement.Name
+	}
+	return strings.Join(names, ",")
+}
+
+// Finds a primitive column by its logical or physical path
+func ParquetResolveColumn(root *parquetschema.ColumnDefinition, path []string) (*ParquetResolvedColumn, error) {
+	c := ParquetResolvedColumn{LogicalPath: path, PhysicalPath: gp.ColumnPath{}, RepeatedSteps: []bool{}}
+	appendStep := func(cd *parquetschema.ColumnDefinition) {
+		c.PhysicalPath = append(c.PhysicalPath, cd.SchemaElement.Name)
+		c.RepeatedSteps = append(c.RepeatedSteps, isParquetRepeated(cd))
+	}
+
+	cur := root
+	for i, name := range path {
+		child := findParquetChild(cur, name)
+		if child == nil && (isParquetList(cur) || isParquetMap(cur)) {
+			wrappers, err := unwrapParquetListOrMap(cur)
+			if err != nil {
+				return nil, err
+			}
+			for _, wrapper := range wrappers {
+				appendStep(wrapper)
+			}
+			cur = wrappers[len(wrappers)-1]
+			child = findParquetChild(cur, name)
+		}
+		if child == nil {
+			if i == 0 {
+				return nil, fmt.Errorf("cannot find parquet column %s, available columns: %s", name, childNames(cur))
+			}
+			return nil, fmt.Errorf("cannot find %s in parquet column %s, available fields: %s", name, strings.Join(path[:i], "."), childNames(cur))
+		}
+		appendStep(child)
+		cur = child
+	}
+
+	// List of primitives: tags means tags.list.element
+	if isParquetList(cur) {
+		wrappers, err := unwrapParquetListOrMap(cur)
+		if err != nil {
+			return nil, err
+		}
+		for _, wrapper := range wrappers {
+			appendStep(wrapper)
+		}
+		cur = wrappers[len(wrappers)-1]
+	}
+
+	if isParquetMap(cur) {
+		return nil, fmt.Errorf("parquet column %s is a map, specify %s.key or %s.value", strings.Join(path, "."), strings.Join(path, "."), strings.Join(path, "."))
+	}
+	if len(cur.Children) > 0 {
+		return nil, fmt.Errorf("parquet column %s is a group, specify one of its fields: %s", strings.Join(path, "."), childNames(cur))
+	}
+
+	c.SchemaElement = cur.SchemaElement
+	return &c, nil
+}
+
+// Returns the single value of a non-repeated column, or all values of a repeated column, in file order.
+// Nulls are returned as nil. Null or empty lists produce no values.
+func (c *ParquetResolvedColumn) Values(d map[string]any, values []any) ([]any, error) {
+	return c.collectValues(d, 0, values)
+}
+
+func (c *ParquetResolvedColumn) hasRepeatedStepsFrom(stepIdx int) bool {
+	for _, isRepeated := range c.RepeatedSteps[stepIdx:] {
+		if isRepeated {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ParquetResolvedColumn) collectValues(cur any, stepIdx int, values []any) ([]any, error) {
+	if stepIdx == len(c.PhysicalPath) {
+		return append(values, cur), nil
+	}
+
+	var next any
+	if m, ok := cur.(map[string]any); ok {
+		next = m[c.PhysicalPath[stepIdx]]
+	} else if cur != nil {
+		return values, fmt.Errorf("cannot read parquet column %s: expected a group at %s, got %T", strings.Join(c.LogicalPath, "."), strings.Join(c.PhysicalPath[:stepIdx], "."), cur)
+	}
+
+	if next == nil {
+		// Null struct: one null value. Null list: no values.
+		if c.hasRepeatedStepsFrom(stepIdx) {
+			return values, nil
+		}
+		return append(values, nil), nil
+	}
+
+	if !c.RepeatedSteps[stepIdx] {
+		return c.collectValues(next, stepIdx+1, values)
+	}
+
+	// Repeated groups are []map[string]any, repeated primitives are typed slices: []int64, [][]byte etc
+	v := reflect.ValueOf(next)
+	if v.Kind() != reflect.Slice {
+		return values, fmt.Errorf("cannot read parquet column %s: expected repeated values at %s, got %T", strings.Join(c.LogicalPath, "."), strings.Join(c.PhysicalPath[:stepIdx+1], "."), next)
+	}
+	var err error
+	for i := 0; i < v.Len(); i++ {
+		if values, err = c.collectValues(v.Index(i).Interface(), stepIdx+1, values); err != nil {
+			return values, err
+		}
+	}
+	return values, nil
+}
+
+// All primitive columns of the file with their logical paths, the way ParquetResolveColumn accepts them
+func ParquetListColumns(root *parquetschema.ColumnDefinition) ([]*ParquetResolvedColumn, error) {
+	result := make([]*ParquetResolvedColumn, 0)
+	var walk func(cd *parquetschema.ColumnDefinition, logicalPath []string) error
+	walk = func(cd *parquetschema.ColumnDefinition, logicalPath []string) error {
+		if len(cd.Children) == 0 || len(logicalPath) > 0 && isParquetList(cd) {
+			if isParquetList(cd) {
+				wrappers, err := unwrapParquetListOrMap(cd)
+				if err != nil {
+					return err
+				}
+				if inner := wrappers[len(wrappers)-1]; len(inner.Children) > 0 {
+					// List of structs: items.sku, items.qty
+					for _, child := range inner.Children {
+						if err := walk(child, append(append([]string{}, logicalPath...), child.SchemaElement.Name)); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+			}
+			c, err := ParquetResolveColumn(root, logicalPath)
+			if err != nil {
+				return err
+			}
+			result = append(result, c)
+			return nil
+		}
+
+		children := cd.Children
+		if isParquetMap(cd) {
+			wrappers, err := unwrapParquetListOrMap(cd)
+			if err != nil {
+				return err
+			}
+			children = wrappers[len(wrappers)-1].Children
+		}
+		for _, child := range children {
+			if err := walk(child, append(append([]string{}, logicalPath...), child.SchemaElement.Name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root, []string{}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+
+	pgparquet "github.com/fraugster/parquet-go/parquet"
+)
+
+// Row group min/max of a column chunk, decoded into values ParquetRead* functions accept
+type ParquetChunkStats struct {
+	Min       any
+	Max       any
+	NullCount *int64 // Nil if unknown
+}
+
+func isParquetUnsigned(se *pgparquet.SchemaElement) bool {
+	if se.LogicalType != nil && se.LogicalType.INTEGER != nil && !se.LogicalType.INTEGER.IsSigned {
+		return true
+	}
+	if se.ConvertedType != nil {
+		switch *se.ConvertedType {
+		case pgparquet.ConvertedType_UINT_8, pgparquet.ConvertedType_UINT_16, pgparquet.ConvertedType_UINT_32, pgparquet.ConvertedType_UINT_64:
+			return true
+		}
+	}
+	return false
+}
+
+func decodeParquetStatsValue(b []byte, se *pgparquet.SchemaElement) (any, bool) {
+	switch *se.Type {
+	case pgparquet.Type_BOOLEAN:
+		if len(b) != 1 {
+			return nil, false
+		}
+		return b[0] != 0, true
+	case pgparquet.Type_INT32:
+		if len(b) != 4 {
+			return nil, false
+		}
+		return int32(binary.LittleEndian.Uint32(b)), true
+	case pgparquet.Type_INT64:
+		if len(b) != 8 {
+			return nil, false
+		}
+		return int64(binary.LittleEndian.Uint64(b)), true
+	case pgparquet.Type_FLOAT:
+		if len(b) != 4 {
+			return nil, false
+		}
+		f := math.Float32frombits(binary.LittleEndian.Uint32(b))
+		if math.IsNaN(float64(f)) {
+			return nil, false
+		}
+		return f, true
+	case pgparquet.Type_DOUBLE:
+		if len(b) != 8 {
+			return nil, false
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		if math.IsNaN(f) {
+			return nil, false
+		}
+		return f, true
+	case pgparquet.Type_BYTE_ARRAY:
+		return b, true
+	default:
+		return nil, false
+	}
+}
+
+// Returns nil if the chunk has no statistics, or their order cannot be trusted:
+// unsigned ints, int96 timestamps and byte array decimals (some writers compare them as unsigned bytes).
+func ParquetColumnChunkStats(rowGroup *pgparquet.RowGroup, col *ParquetResolvedColumn) *ParquetChunkStats {
+	se := col.SchemaElement
+	if se.Type == nil || isParquetUnsigned(se) || *se.Type == pgparquet.Type_BYTE_ARRAY && !isParquetString(se) {
+		return nil
+	}
+
+	for _, chunk := range rowGroup.Columns {
+		if chunk.MetaData == nil || !slices.Equal(chunk.MetaData.PathInSchema, col.PhysicalPath) {
+			continue
+		}
+		stats := chunk.MetaData.Statistics
+		if stats == nil {
+			return nil
+		}
+		minBytes, maxBytes := stats.MinValue, stats.MaxValue
+		if minBytes == nil || maxBytes == nil {
+			// Deprecated min/max use signed comparison, fine for numbers and bools only
+			if *se.Type == pgparquet.Type_BYTE_ARRAY {
+				return nil
+			}
+			minBytes, maxBytes = stats.Min, stats.Max
+		}
+		// Empty max: no statistics, or only empty strings in the chunk, cannot tell
+		if minBytes == nil || len(maxBytes) == 0 {
+			return nil
+		}
+		minVal, minOk := decodeParquetStatsValue(minBytes, se)
+		maxVal, maxOk := decodeParquetStatsValue(maxBytes, se)
+		if !minOk || !maxOk {
+			return nil
+		}
+		return &ParquetChunkStats{Min: minVal, Max: maxVal, NullCount: stats.NullCount}
+	}
+	return nil
+}
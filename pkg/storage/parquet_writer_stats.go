@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/capillariesio/capillaries/pkg/evalcapi"
+	pgparquet "github.com/fraugster/parquet-go/parquet"
+)
+
+// gp.FileWriter writes column chunk statistics for numbers and datetimes only.
+// String and bool min/max are tracked here and put into the footer on Close.
+
+const parquetMagic string = "PAR1"
+
+// Encoded the way Parquet statistics store them: raw bytes for strings, one byte for bools
+type parquetMinMax struct {
+	min string
+	max string
+}
+
+type parquetWriterColumn struct {
+	name       string
+	fieldType  evalcapi.TableFieldType
+	statistics bool
+	minMax     *parquetMinMax // Current row group
+}
+
+func (col *parquetWriterColumn) trackMinMax(val any) {
+	// Fixed length byte array decimals are not strings
+	if !col.statistics || evalcapi.IsDecimalFieldType(col.fieldType) {
+		return
+	}
+	var encoded string
+	switch typedVal := val.(type) {
+	case string:
+		encoded = typedVal
+	case []byte:
+		// Rows copied from another parquet file
+		encoded = string(typedVal)
+	case bool:
+		encoded = "\x00"
+		if typedVal {
+			encoded = "\x01"
+		}
+	default:
+		return
+	}
+	if col.minMax == nil {
+		col.minMax = &parquetMinMax{min: encoded, max: encoded}
+		return
+	}
+	if encoded < col.minMax.min {
+		col.minMax.min = encoded
+	} else if encoded > col.minMax.max {
+		col.minMax.max = encoded
+	}
+}
+
+// Passes everything through until holdFooter is set. After that, keeps what gp.FileWriter writes on Close:
+// the part of the last row group left in its buffer, the footer, footer length and magic.
+type parquetFooterWriter struct {
+	w          io.Writer
+	holdFooter bool
+	held       bytes.Buffer
+}
+
+func (fw *parquetFooterWriter) Write(p []byte) (int, error) {
+	if fw.holdFooter {
+		return fw.held.Write(p)
+	}
+	return fw.w.Write(p)
+}
+
+func (w *ParquetWriter) writePatchedFooter() error {
+	held := w.out.held.Bytes()
+	if len(held) < 8 || string(held[len(held)-4:]) != parquetMagic {
+		return fmt.Errorf("unexpected parquet file tail")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(held[len(held)-8 : len(held)-4]))
+	footerStart := len(held) - 8 - footerLen
+	if footerStart < 0 {
+		return fmt.Errorf("unexpected parquet footer length %d", footerLen)
+	}
+
+	meta := pgparquet.NewFileMetaData()
+	if err := meta.Read(context.Background(), thrift.NewTCompactProtocolConf(&thrift.StreamTransport{Reader: bytes.NewReader(held[footerStart : len(held)-8])}, &thrift.TConfiguration{})); err != nil {
+		return fmt.Errorf("cannot read footer: %s", err.Error())
+	}
+
+	colMap := make(map[string]*parquetWriterColumn, len(w.columns))
+	for _, col := range w.columns {
+		colMap[col.name] = col
+	}
+	for rowGroupIdx, rowGroup := range meta.RowGroups {
+		for _, chunk := range rowGroup.Columns {
+			if chunk.MetaData == nil || len(chunk.MetaData.PathInSchema) != 1 {
+				continue
+			}
+			col, ok := colMap[chunk.MetaData.PathInSchema[0]]
+			if !ok {
+				continue
+			}
+			if !col.statistics {
+				chunk.MetaData.Statistics = nil
+				continue
+			}
+			// Rows added with FileWriter.AddData bypass min/max tracking
+			if rowGroupIdx >= len(w.rowGroupsMinMax) {
+				continue
+			}
+			minMax, ok := w.rowGroupsMinMax[rowGroupIdx][col.name]
+			if !ok {
+				continue
+			}
+			if chunk.MetaData.Statistics == nil {
+				chunk.MetaData.Statistics = pgparquet.NewStatistics()
+			}
+			chunk.MetaData.Statistics.MinValue = []byte(minMax.min)
+			chunk.MetaData.Statistics.MaxValue = []byte(minMax.max)
+		}
+	}
+
+	// Without column orders, some readers ignore min_value/max_value of string columns
+	meta.ColumnOrders = make([]*pgparquet.ColumnOrder, len(w.columns))
+	for i := range meta.ColumnOrders {
+		meta.ColumnOrders[i] = pgparquet.NewColumnOrder()
+		meta.ColumnOrders[i].TYPE_ORDER = pgparquet.NewTypeDefinedOrder()
+	}
+
+	footer := bytes.Buffer{}
+	proto := thrift.NewTCompactProtocolConf(&thrift.StreamTransport{Writer: &footer}, &thrift.TConfiguration{})
+	if err := meta.Write(context.Background(), proto); err != nil {
+		return fmt.Errorf("cannot serialize footer: %s", err.Error())
+	}
+	if err := proto.Flush(context.Background()); err != nil {
+		return fmt.Errorf("cannot serialize footer: %s", err.Error())
+	}
+
+	if _, err := w.out.w.Write(held[:footerStart]); err != nil {
+		return err
+	}
+	if _, err := w.out.w.Write(footer.Bytes()); err != nil {
+		return err
+	}
+	if err := binary.Write(w.out.w, binary.LittleEndian, int32(footer.Len())); err != nil {
+		return err
+	}
+	_, err := w.out.w.Write([]byte(parquetMagic))
+	return err
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	gp "github.com/fraugster/parquet-go"
+	pgparquet "github.com/fraugster/parquet-go/parquet"
+	"github.com/klauspost/compress/zstd"
+)
+
+// gp supports gzip and snappy out of the box, zstd is registered here for both writer and reader
+type parquetZstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (c *parquetZstdCompressor) CompressBlock(block []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(block, nil), nil
+}
+
+func (c *parquetZstdCompressor) DecompressBlock(block []byte) ([]byte, error) {
+	return c.decoder.DecodeAll(block, nil)
+}
+
+func init() {
+	// Without options, these never fail
+	encoder, _ := zstd.NewWriter(nil)
+	decoder, _ := zstd.NewReader(nil)
+	gp.RegisterBlockCompressor(pgparquet.CompressionCodec_ZSTD, &parquetZstdCompressor{encoder: encoder, decoder: decoder})
+}
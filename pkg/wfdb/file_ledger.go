@@ -0,0 +1,40 @@
+package wfdb
+
+import (
+	"fmt"
+
+	"github.com/capillariesio/capillaries/pkg/cql"
+	"github.com/capillariesio/capillaries/pkg/db"
+	"github.com/capillariesio/capillaries/pkg/gocqlshims"
+	"github.com/capillariesio/capillaries/pkg/wfmodel"
+)
+
+// Used by api.StartRun to find out which files incremental file readers have already ingested
+func GetFileLedger(cqlSession gocqlshims.Session, keyspace string, nodeNames []string) (wfmodel.FileLedger, error) {
+	q := (&cql.QueryBuilder{}).
+		Keyspace(keyspace).
+		CondInString("script_node", nodeNames).
+		Select(wfmodel.TableNameFileLedger, wfmodel.FileLedgerEntryAllFields())
+	rows, err := cqlSession.Query(q).Iter().SliceMap()
+	if err != nil {
+		return nil, db.WrapDbErrorWithQuery(fmt.Sprintf("cannot get file ledger for %s, %v", keyspace, nodeNames), q, err)
+	}
+	return wfmodel.FileLedgerRowsToLedger(rows)
+}
+
+// Used in daemon after an incremental file reader batch has successfully ingested the file
+func WriteFileLedgerEntry(cqlSession gocqlshims.Session, msg *wfmodel.Message, fileUrl string, fileSize int64, fileVersion string) error {
+	q := (&cql.QueryBuilder{}).
+		Keyspace(msg.DataKeyspace).
+		WriteForceUnquote("ts", "toTimestamp(now())").
+		Write("script_node", msg.TargetNodeName).
+		Write("file_url", fileUrl).
+		Write("file_size", fileSize).
+		Write("file_version", fileVersion).
+		Write("run_id", msg.RunId).
+		InsertUnpreparedQuery(wfmodel.TableNameFileLedger, cql.IfExistsOverwrite) // Re-ingested file overwrites the entry
+	if err := cqlSession.Query(q).Exec(); err != nil {
+		return db.WrapDbErrorWithQuery(fmt.Sprintf("cannot write file ledger entry for %s, processing batch %s", fileUrl, msg.FullBatchId()), q, err)
+	}
+	return nil
+}
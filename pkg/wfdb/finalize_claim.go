@@ -0,0 +1,35 @@
+package wfdb
+
+import (
+	"fmt"
+
+	"github.com/capillariesio/capillaries/pkg/cql"
+	"github.com/capillariesio/capillaries/pkg/db"
+	"github.com/capillariesio/capillaries/pkg/gocqlshims"
+	"github.com/capillariesio/capillaries/pkg/wfmodel"
+)
+
+// Used in daemon before merging file creator batch files. Two daemons finishing the last batches,
+// or a redelivered message, may both see all batches complete: only the one that gets the claim merges.
+func ClaimNodeFinalize(cqlSession gocqlshims.Session, msg *wfmodel.Message) (bool, error) {
+	q := (&cql.QueryBuilder{}).
+		Keyspace(msg.DataKeyspace).
+		WriteForceUnquote("ts", "toTimestamp(now())").
+		Write("run_id", msg.RunId).
+		Write("script_node", msg.TargetNodeName).
+		Write("claimed_by_batch_idx", msg.BatchIdx).
+		InsertUnpreparedQuery(wfmodel.TableNameFinalizeClaim, cql.IfNotExistsLwt) // Only one batch gets it
+	existingRow := map[string]any{}
+	isApplied, err := cqlSession.Query(q).MapScanCAS(existingRow)
+	if err != nil {
+		return false, db.WrapDbErrorWithQuery(fmt.Sprintf("cannot claim node finalize, processing batch %s", msg.FullBatchId()), q, err)
+	}
+	if isApplied {
+		return true, nil
+	}
+	isClaimed, err := wfmodel.IsFinalizeClaimedByBatch(existingRow, msg.BatchIdx)
+	if err != nil {
+		return false, fmt.Errorf("cannot read existing node finalize claim, processing batch %s: %s", msg.FullBatchId(), err.Error())
+	}
+	return isClaimed, nil
+}
@@ -40,7 +40,12 @@ func GetAllRunsProperties(cqlSession gocqlshims.Session, keyspace string, runPro
 	return rows, nil
 }
 
-func WriteRunProperties(cqlSession gocqlshims.Session, keyspace string, runId int16, startNodes []string, affectedNodes []string, scriptUrl string, scriptParamsUrl string, runDescription string) error {
+func WriteRunProperties(cqlSession gocqlshims.Session, keyspace string, runId int16, startNodes []string, affectedNodes []string, scriptUrl string, scriptParamsUrl string, runDescription string, resolvedFileUrls map[string][]string) error {
+	resolvedFileUrlsString, err := wfmodel.ResolvedFileUrlsToString(resolvedFileUrls)
+	if err != nil {
+		return err
+	}
+
 	q := (&cql.QueryBuilder{}).
 		Keyspace(keyspace).
 		Write("run_id", runId).
@@ -49,8 +54,9 @@ func WriteRunProperties(cqlSession gocqlshims.Session, keyspace string, runId in
 		Write("script_url", scriptUrl).
 		Write("script_params_url", scriptParamsUrl).
 		Write("run_description", runDescription).
+		Write("resolved_file_urls", resolvedFileUrlsString).
 		InsertUnpreparedQuery(wfmodel.TableNameRunProperties, cql.IfNotExistsLwt) // If not exists. First one wins. Potential contention
-	err := cqlSession.Query(q).Exec()
+	err = cqlSession.Query(q).Exec()
 	if err != nil {
 		return db.WrapDbErrorWithQuery("cannot write affected nodes", q, err)
 	}
@@ -0,0 +1,78 @@
+package wfmodel
+
+import (
+	"fmt"
+	"time"
+)
+
+const TableNameFileLedger = "wf_file_ledger"
+
+// Object model with tags that allow to create cql CREATE TABLE queries and to print object.
+// One entry per source file ingested by a file_table node, overwritten when the file is ingested again.
+type FileLedgerEntry struct {
+	Ts          time.Time `header:"ts" format:"%-33v" column:"ts" type:"timestamp" json:"ts"`
+	ScriptNode  string    `header:"script_node" format:"%20v" column:"script_node" type:"text" key:"true" json:"script_node"` // Partitioning key, used in IN()
+	FileUrl     string    `header:"file_url" format:"%40v" column:"file_url" type:"text" key:"true" json:"file_url"`
+	FileSize    int64     `header:"file_size" format:"%12d" column:"file_size" type:"bigint" json:"file_size"`
+	FileVersion string    `header:"file_version" format:"%20v" column:"file_version" type:"text" json:"file_version"` // ETag or modification time
+	RunId       int16     `header:"run_id" format:"%6d" column:"run_id" type:"int" json:"run_id"`
+}
+
+func FileLedgerEntryAllFields() []string {
+	return []string{"ts", "script_node", "file_url", "file_size", "file_version", "run_id"}
+}
+
+func NewFileLedgerEntryFromMap(r map[string]any, fields []string) (*FileLedgerEntry, error) {
+	res := &FileLedgerEntry{}
+	for _, fieldName := range fields {
+		var err error
+		switch fieldName {
+		case "ts":
+			res.Ts, err = ReadTimeFromRow(fieldName, r)
+		case "script_node":
+			res.ScriptNode, err = ReadStringFromRow(fieldName, r)
+		case "file_url":
+			res.FileUrl, err = ReadStringFromRow(fieldName, r)
+		case "file_size":
+			res.FileSize, err = ReadInt64FromRow(fieldName, r)
+		case "file_version":
+			res.FileVersion, err = ReadStringFromRow(fieldName, r)
+		case "run_id":
+			res.RunId, err = ReadInt16FromRow(fieldName, r)
+		default:
+			return nil, fmt.Errorf("unknown %s field %s", fieldName, TableNameFileLedger)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Node name -> file url -> ledger entry
+type FileLedger map[string]map[string]*FileLedgerEntry
+
+func FileLedgerRowsToLedger(rows []map[string]any) (FileLedger, error) {
+	ledger := FileLedger{}
+	fields := FileLedgerEntryAllFields()
+	for _, r := range rows {
+		rec, err := NewFileLedgerEntryFromMap(r, fields)
+		if err != nil {
+			return nil, fmt.Errorf("cannot deserialize file ledger row %v: %s", r, err.Error())
+		}
+		if _, ok := ledger[rec.ScriptNode]; !ok {
+			ledger[rec.ScriptNode] = map[string]*FileLedgerEntry{}
+		}
+		ledger[rec.ScriptNode][rec.FileUrl] = rec
+	}
+	return ledger, nil
+}
+
+// A file is ingested again only if the node never ingested it, or its size or version changed since
+func (ledger FileLedger) IsNewOrChanged(nodeName string, fileUrl string, fileSize int64, fileVersion string) bool {
+	entry, ok := ledger[nodeName][fileUrl]
+	if !ok {
+		return true
+	}
+	return entry.FileSize != fileSize || entry.FileVersion != fileVersion
+}
@@ -0,0 +1,40 @@
+package wfmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (e *FileLedgerEntry) ToMap() map[string]any {
+	return map[string]any{
+		"ts":           e.Ts,
+		"script_node":  e.ScriptNode,
+		"file_url":     e.FileUrl,
+		"file_size":    e.FileSize,
+		"file_version": e.FileVersion,
+		"run_id":       e.RunId,
+	}
+}
+
+func TestFileLedger(t *testing.T) {
+	rows := []map[string]any{
+		(&FileLedgerEntry{Ts: time.Now(), ScriptNode: "read_orders", FileUrl: "s3://bucket/orders/1.csv", FileSize: 100, FileVersion: `"etag1"`, RunId: 1}).ToMap(),
+		(&FileLedgerEntry{Ts: time.Now(), ScriptNode: "read_orders", FileUrl: "s3://bucket/orders/2.csv", FileSize: 200, FileVersion: `"etag2"`, RunId: 2}).ToMap(),
+	}
+
+	ledger, err := FileLedgerRowsToLedger(rows)
+	assert.Nil(t, err)
+	assert.Equal(t, int16(2), ledger["read_orders"]["s3://bucket/orders/2.csv"].RunId)
+
+	assert.False(t, ledger.IsNewOrChanged("read_orders", "s3://bucket/orders/1.csv", 100, `"etag1"`))
+	assert.True(t, ledger.IsNewOrChanged("read_orders", "s3://bucket/orders/1.csv", 101, `"etag1"`))
+	assert.True(t, ledger.IsNewOrChanged("read_orders", "s3://bucket/orders/2.csv", 200, `"etag3"`))
+	assert.True(t, ledger.IsNewOrChanged("read_orders", "s3://bucket/orders/3.csv", 300, `"etag3"`))
+	assert.True(t, ledger.IsNewOrChanged("read_items", "s3://bucket/orders/1.csv", 100, `"etag1"`))
+
+	rows[0]["file_size"] = "a"
+	_, err = FileLedgerRowsToLedger(rows)
+	assert.Contains(t, err.Error(), "cannot read int64 file_size")
+}
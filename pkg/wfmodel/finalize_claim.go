@@ -0,0 +1,27 @@
+package wfmodel
+
+import (
+	"time"
+)
+
+const TableNameFinalizeClaim = "wf_finalize_claim"
+
+// Object model with tags that allow to create cql CREATE TABLE queries and to print object.
+// One row per run/node that merges file creator batch files, inserted with IF NOT EXISTS before the merge:
+// only the batch that inserted it finalizes the node.
+type FinalizeClaim struct {
+	Ts                time.Time `header:"ts" format:"%-33v" column:"ts" type:"timestamp" json:"ts"`
+	RunId             int16     `header:"run_id" format:"%6d" column:"run_id" type:"int" key:"true" json:"run_id"`
+	ScriptNode        string    `header:"script_node" format:"%20v" column:"script_node" type:"text" key:"true" json:"script_node"`
+	ClaimedByBatchIdx int16     `header:"claimed_by_batch_idx" format:"%5v" column:"claimed_by_batch_idx" type:"int" json:"claimed_by_batch_idx"`
+}
+
+// Row returned by a failed IF NOT EXISTS insert holds the existing claim.
+// The claiming batch owns it: its redelivered message may finalize again after a daemon crash.
+func IsFinalizeClaimedByBatch(existingRow map[string]any, batchIdx int16) (bool, error) {
+	claimedByBatchIdx, err := ReadInt16FromRow("claimed_by_batch_idx", existingRow)
+	if err != nil {
+		return false, err
+	}
+	return claimedByBatchIdx == batchIdx, nil
+}
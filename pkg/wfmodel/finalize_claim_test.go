@@ -0,0 +1,22 @@
+package wfmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsFinalizeClaimedByBatch(t *testing.T) {
+	existingRow := map[string]any{"run_id": int16(1), "script_node": "file_totals", "claimed_by_batch_idx": int16(3)}
+
+	isClaimed, err := IsFinalizeClaimedByBatch(existingRow, 3)
+	assert.Nil(t, err)
+	assert.True(t, isClaimed)
+
+	isClaimed, err = IsFinalizeClaimedByBatch(existingRow, 4)
+	assert.Nil(t, err)
+	assert.False(t, isClaimed)
+
+	_, err = IsFinalizeClaimedByBatch(map[string]any{}, 3)
+	assert.NotNil(t, err)
+}
@@ -1,6 +1,7 @@
 package wfmodel
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -9,16 +10,17 @@ const TableNameRunProperties = "wf_run_properties"
 
 // Object model with tags that allow to create cql CREATE TABLE queries and to print object
 type RunProperties struct {
-	RunId           int16  `header:"run_id" format:"%6d" column:"run_id" type:"int" key:"true" json:"run_id"`
-	StartNodes      string `header:"start_nodes" format:"%20v" column:"start_nodes" type:"text" json:"start_nodes"`
-	AffectedNodes   string `header:"affected_nodes" format:"%20v" column:"affected_nodes" type:"text" json:"affected_nodes"`
-	ScriptUrl       string `header:"script_url" format:"%20v" column:"script_url" type:"text" json:"script_url"`
-	ScriptParamsUrl string `header:"script_params_url" format:"%20v" column:"script_params_url" type:"text" json:"script_params_url"`
-	RunDescription  string `header:"run_desc" format:"%20v" column:"run_description" type:"text" json:"run_description"`
+	RunId            int16  `header:"run_id" format:"%6d" column:"run_id" type:"int" key:"true" json:"run_id"`
+	StartNodes       string `header:"start_nodes" format:"%20v" column:"start_nodes" type:"text" json:"start_nodes"`
+	AffectedNodes    string `header:"affected_nodes" format:"%20v" column:"affected_nodes" type:"text" json:"affected_nodes"`
+	ScriptUrl        string `header:"script_url" format:"%20v" column:"script_url" type:"text" json:"script_url"`
+	ScriptParamsUrl  string `header:"script_params_url" format:"%20v" column:"script_params_url" type:"text" json:"script_params_url"`
+	RunDescription   string `header:"run_desc" format:"%20v" column:"run_description" type:"text" json:"run_description"`
+	ResolvedFileUrls string `header:"resolved_file_urls" format:"%20v" column:"resolved_file_urls" type:"text" json:"resolved_file_urls"` // JSON node->urls map for file readers with url patterns
 }
 
 func RunPropertiesAllFields() []string {
-	return []string{"run_id", "start_nodes", "affected_nodes", "script_url", "script_params_url", "run_description"}
+	return []string{"run_id", "start_nodes", "affected_nodes", "script_url", "script_params_url", "run_description", "resolved_file_urls"}
 }
 
 func NewRunPropertiesFromMap(r map[string]any, fields []string) (*RunProperties, error) {
@@ -38,6 +40,8 @@ func NewRunPropertiesFromMap(r map[string]any, fields []string) (*RunProperties,
 			res.ScriptParamsUrl, err = ReadStringFromRow(fieldName, r)
 		case "run_description":
 			res.RunDescription, err = ReadStringFromRow(fieldName, r)
+		case "resolved_file_urls":
+			res.ResolvedFileUrls, err = ReadStringFromRow(fieldName, r)
 		default:
 			return nil, fmt.Errorf("unknown %s field %s", fieldName, TableNameRunProperties)
 		}
@@ -48,6 +52,31 @@ func NewRunPropertiesFromMap(r map[string]any, fields []string) (*RunProperties,
 	return res, nil
 }
 
+func ResolvedFileUrlsToString(resolvedFileUrls map[string][]string) (string, error) {
+	if len(resolvedFileUrls) == 0 {
+		return "", nil
+	}
+	jsonBytes, err := json.Marshal(resolvedFileUrls)
+	if err != nil {
+		return "", fmt.Errorf("cannot serialize resolved file urls: %s", err.Error())
+	}
+	return string(jsonBytes), nil
+}
+
+func (rp *RunProperties) GetResolvedFileUrls(nodeName string) ([]string, error) {
+	resolvedFileUrls := map[string][]string{}
+	if len(rp.ResolvedFileUrls) > 0 {
+		if err := json.Unmarshal([]byte(rp.ResolvedFileUrls), &resolvedFileUrls); err != nil {
+			return nil, fmt.Errorf("cannot deserialize resolved file urls for run %d: %s", rp.RunId, err.Error())
+		}
+	}
+	urls, ok := resolvedFileUrls[nodeName]
+	if !ok {
+		return nil, fmt.Errorf("cannot find resolved file urls for node %s in run %d properties", nodeName, rp.RunId)
+	}
+	return urls, nil
+}
+
 func intersectTwoSlicesOfStrings(slice1, slice2 []string) []string {
 	map1 := make(map[string]bool)
 	for _, v := range slice1 {
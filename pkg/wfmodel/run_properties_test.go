@@ -24,6 +24,8 @@ func (e *RunProperties) ToMap() map[string]any {
 			m[fieldName] = e.ScriptParamsUrl
 		case "run_description":
 			m[fieldName] = e.RunDescription
+		case "resolved_file_urls":
+			m[fieldName] = e.ResolvedFileUrls
 		default:
 		}
 	}
@@ -66,12 +68,13 @@ func TestMultipleRunsPropertiesToDependencies(t *testing.T) {
 
 func TestNewRunPropertiesFromMap(t *testing.T) {
 	row := (&RunProperties{
-		RunId:           int16(1),
-		StartNodes:      "startNode11,startNode12",
-		AffectedNodes:   "affNode11,affNode12",
-		ScriptUrl:       "scripturl",
-		ScriptParamsUrl: "scriptparamsurl",
-		RunDescription:  "rundesc",
+		RunId:            int16(1),
+		StartNodes:       "startNode11,startNode12",
+		AffectedNodes:    "affNode11,affNode12",
+		ScriptUrl:        "scripturl",
+		ScriptParamsUrl:  "scriptparamsurl",
+		RunDescription:   "rundesc",
+		ResolvedFileUrls: `{"read_orders":["orders1.csv"]}`,
 	}).ToMap()
 
 	runProps, err := NewRunPropertiesFromMap(row, RunPropertiesAllFields())
@@ -82,4 +85,26 @@ func TestNewRunPropertiesFromMap(t *testing.T) {
 	assert.Equal(t, row["script_url"], runProps.ScriptUrl)
 	assert.Equal(t, row["script_params_url"], runProps.ScriptParamsUrl)
 	assert.Equal(t, row["run_description"], runProps.RunDescription)
+	assert.Equal(t, row["resolved_file_urls"], runProps.ResolvedFileUrls)
+}
+
+func TestResolvedFileUrls(t *testing.T) {
+	s, err := ResolvedFileUrlsToString(map[string][]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", s)
+
+	s, err = ResolvedFileUrlsToString(map[string][]string{"read_orders": {"/tmp/orders1.csv", "/tmp/orders2.csv"}})
+	assert.Nil(t, err)
+
+	runProps := &RunProperties{RunId: int16(1), ResolvedFileUrls: s}
+	urls, err := runProps.GetResolvedFileUrls("read_orders")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/tmp/orders1.csv", "/tmp/orders2.csv"}, urls)
+
+	_, err = runProps.GetResolvedFileUrls("read_items")
+	assert.Contains(t, err.Error(), "cannot find resolved file urls for node read_items in run 1 properties")
+
+	runProps.ResolvedFileUrls = "not json"
+	_, err = runProps.GetResolvedFileUrls("read_orders")
+	assert.Contains(t, err.Error(), "cannot deserialize resolved file urls for run 1")
 }
@@ -0,0 +1,85 @@
+package xfer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Used by file creator finalize step to delete merged batch files
+func DeleteFile(fileUrl string, privateKeys map[string]string) error {
+	u, err := url.Parse(fileUrl)
+	if err != nil {
+		return fmt.Errorf("cannot parse url %s: %s", fileUrl, err.Error())
+	}
+
+	switch u.Scheme {
+	case UrlSchemeFile, "":
+		if err := os.Remove(fileUrlLocalPath(fileUrl, u)); err != nil {
+			return fmt.Errorf("cannot delete file %s: %s", fileUrl, err.Error())
+		}
+		return nil
+	case UrlSchemeS3:
+		return deleteS3File(u)
+	case UrlSchemeSftp:
+		return deleteSftpFile(fileUrl, privateKeys)
+	default:
+		return fmt.Errorf("cannot delete %s: url scheme %s not supported", fileUrl, u.Scheme)
+	}
+}
+
+func deleteS3File(u *url.URL) error {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return err
+	}
+	client := s3.NewFromConfig(cfg)
+
+	if _, err := client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(u.Host),
+		Key:    aws.String(strings.TrimLeft(u.Path, "/")),
+	}); err != nil {
+		return fmt.Errorf("cannot delete s3 object %s: %s", u.String(), err.Error())
+	}
+	return nil
+}
+
+func deleteSftpFile(fileUrl string, privateKeys map[string]string) error {
+	parsedUrl, err := parseSftpUrl(fileUrl, privateKeys)
+	if err != nil {
+		return err
+	}
+
+	// Assume empty key password ""
+	sshClientConfig, err := NewSshClientConfig(parsedUrl.User, parsedUrl.PrivateKeyPath, "")
+	if err != nil {
+		return err
+	}
+
+	sshUrl := fmt.Sprintf("%s:%d", parsedUrl.Host, parsedUrl.Port)
+
+	sshClient, err := ssh.Dial("tcp", sshUrl, sshClientConfig)
+	if err != nil {
+		return fmt.Errorf("dial to %s failed: %s", fileUrl, err.Error())
+	}
+	defer sshClient.Close()
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		return fmt.Errorf("cannot create sftp client to %s: %s", fileUrl, err.Error())
+	}
+	defer sftpClient.Close()
+
+	if err := sftpClient.Remove(parsedUrl.RemotePath); err != nil {
+		return fmt.Errorf("cannot delete sftp file %s: %s", fileUrl, err.Error())
+	}
+	return nil
+}
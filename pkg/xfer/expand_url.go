@@ -0,0 +1,176 @@
+package xfer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const urlPatternMetaChars string = "*?["
+
+// Only file, s3 and sftp urls can be patterns; http urls are never expanded, '?' is a legit query separator there
+func IsUrlPattern(fileUrl string) bool {
+	u, err := url.Parse(fileUrl)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case UrlSchemeFile, "":
+		return strings.ContainsAny(fileUrlLocalPath(fileUrl, u), urlPatternMetaChars)
+	case UrlSchemeS3, UrlSchemeSftp:
+		return strings.ContainsAny(u.Path, urlPatternMetaChars)
+	default:
+		return false
+	}
+}
+
+func fileUrlLocalPath(fileUrl string, u *url.URL) string {
+	if u.Scheme == UrlSchemeFile {
+		return u.Path
+	}
+	return fileUrl
+}
+
+// Returns a sorted list of urls matching the pattern (path.Match syntax), fails if nothing matches
+func ExpandUrlPattern(fileUrl string, privateKeys map[string]string) ([]string, error) {
+	u, err := url.Parse(fileUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse url pattern %s: %s", fileUrl, err.Error())
+	}
+
+	var urls []string
+	switch u.Scheme {
+	case UrlSchemeFile, "":
+		urls, err = expandLocalPattern(fileUrl, u)
+	case UrlSchemeS3:
+		urls, err = expandS3Pattern(u)
+	case UrlSchemeSftp:
+		urls, err = expandSftpPattern(fileUrl, u, privateKeys)
+	default:
+		return nil, fmt.Errorf("url scheme %s does not support patterns: %s", u.Scheme, fileUrl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no files match url pattern %s", fileUrl)
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+func expandLocalPattern(fileUrl string, u *url.URL) ([]string, error) {
+	matches, err := filepath.Glob(fileUrlLocalPath(fileUrl, u))
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand url pattern %s: %s", fileUrl, err.Error())
+	}
+	if u.Scheme == UrlSchemeFile {
+		for i := range matches {
+			matches[i] = UrlSchemeFile + "://" + matches[i]
+		}
+	}
+	return matches, nil
+}
+
+// S3 has no directories: list all keys under the longest literal prefix and match them against the pattern
+func expandS3Pattern(u *url.URL) ([]string, error) {
+	keyPattern := strings.TrimLeft(u.Path, "/")
+	keyPrefix := keyPattern
+	if metaIdx := strings.IndexAny(keyPattern, urlPatternMetaChars); metaIdx >= 0 {
+		keyPrefix = keyPattern[:metaIdx]
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(cfg)
+
+	urls := make([]string, 0)
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(u.Host),
+		Prefix: aws.String(keyPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("cannot list s3 objects in %s with prefix %s: %s", u.Host, keyPrefix, err.Error())
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			isMatch, err := path.Match(keyPattern, key)
+			if err != nil {
+				return nil, fmt.Errorf("cannot match s3 key %s against pattern %s: %s", key, keyPattern, err.Error())
+			}
+			if isMatch {
+				urls = append(urls, fmt.Sprintf("%s://%s/%s", UrlSchemeS3, u.Host, key))
+			}
+		}
+	}
+	return urls, nil
+}
+
+// Only the file name part of an sftp url can be a pattern, the directory is listed
+func expandSftpPattern(fileUrl string, u *url.URL, privateKeys map[string]string) ([]string, error) {
+	remoteDir, namePattern := path.Split(u.Path)
+	if strings.ContainsAny(remoteDir, urlPatternMetaChars) {
+		return nil, fmt.Errorf("sftp url pattern %s can use wildcards in file name only", fileUrl)
+	}
+
+	parsedUrl, err := parseSftpUrl(fileUrl, privateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assume empty key password ""
+	sshClientConfig, err := NewSshClientConfig(parsedUrl.User, parsedUrl.PrivateKeyPath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sshUrl := fmt.Sprintf("%s:%d", parsedUrl.Host, parsedUrl.Port)
+
+	sshClient, err := ssh.Dial("tcp", sshUrl, sshClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("dial to %s failed: %s", fileUrl, err.Error())
+	}
+	defer sshClient.Close()
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create sftp client to %s: %s", fileUrl, err.Error())
+	}
+	defer sftpClient.Close()
+
+	fileInfos, err := sftpClient.ReadDir(remoteDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list sftp directory for %s: %s", fileUrl, err.Error())
+	}
+
+	urls := make([]string, 0)
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() {
+			continue
+		}
+		isMatch, err := path.Match(namePattern, fileInfo.Name())
+		if err != nil {
+			return nil, fmt.Errorf("cannot match sftp file %s against pattern %s: %s", fileInfo.Name(), namePattern, err.Error())
+		}
+		if isMatch {
+			matchedUrl := *u
+			matchedUrl.Path = path.Join(remoteDir, fileInfo.Name())
+			urls = append(urls, matchedUrl.String())
+		}
+	}
+	return urls, nil
+}
@@ -17,7 +17,7 @@ const UrlSchemeHttps string = "https"
 const UrlSchemeSftp string = "sftp"
 const UrlSchemeS3 string = "s3"
 
-func GetHttpReadCloser(fileUrl string, scheme string, certDir string) (io.ReadCloser, error) {
+func newHttpClient(scheme string, certDir string) (*http.Client, error) {
 	var caCertPool *x509.CertPool
 	// tls.Config doc: If RootCAs is nil, TLS uses the host's root CA set.
 	if certDir != "" {
@@ -38,15 +38,40 @@ func GetHttpReadCloser(fileUrl string, scheme string, certDir string) (io.ReadCl
 		}
 	}
 	t := &http.Transport{TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{}, RootCAs: caCertPool}}
-	client := http.Client{Transport: t, Timeout: 30 * time.Second}
+	return &http.Client{Transport: t, Timeout: 30 * time.Second}, nil
+}
+
+func GetHttpReadCloser(fileUrl string, scheme string, certDir string) (io.ReadCloser, error) {
+	return GetHttpRangeReadCloser(fileUrl, scheme, certDir, 0)
+}
+
+// Reads from the byte offset to the end of the file, the server must support range requests if offset is not zero
+func GetHttpRangeReadCloser(fileUrl string, scheme string, certDir string, offset int64) (io.ReadCloser, error) {
+	client, err := newHttpClient(scheme, certDir)
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := client.Get(fileUrl)
+	req, err := http.NewRequest(http.MethodGet, fileUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request for %s: %s", fileUrl, err.Error())
+	}
+	expectedStatus := http.StatusOK
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		expectedStatus = http.StatusPartialContent
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get %s: %s", fileUrl, err.Error())
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != expectedStatus {
 		resp.Body.Close()
+		if offset > 0 && resp.StatusCode == http.StatusOK {
+			return nil, fmt.Errorf("cannot get %s from offset %d: server does not support range requests", fileUrl, offset)
+		}
 		return nil, fmt.Errorf("cannot get %s, bad status: %s", fileUrl, resp.Status)
 	}
 
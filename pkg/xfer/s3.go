@@ -16,6 +16,11 @@ import (
 )
 
 func GetS3ReadCloser(fileUrl string) (io.ReadCloser, error) {
+	return GetS3RangeReadCloser(fileUrl, 0)
+}
+
+// Reads from the byte offset to the end of the object
+func GetS3RangeReadCloser(fileUrl string, offset int64) (io.ReadCloser, error) {
 	parsedUrl, _ := url.Parse(fileUrl)
 
 	// Assuming ~/.aws/credentials:
@@ -43,10 +48,14 @@ func GetS3ReadCloser(fileUrl string) (io.ReadCloser, error) {
 	// operation error S3: GetObject, get identity: get credentials: failed to refresh cached credentials, no EC2 IMDS role found, operation error ec2imds: GetMetadata, canceled, context deadline exceeded
 	maxRetries := 5
 	for retryCount := 0; retryCount < maxRetries; retryCount++ {
-		resp, err := client.GetObject(context.TODO(), &s3.GetObjectInput{
+		input := &s3.GetObjectInput{
 			Bucket: aws.String(parsedUrl.Host),
 			Key:    aws.String(strings.TrimLeft(parsedUrl.Path, "/")),
-		})
+		}
+		if offset > 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := client.GetObject(context.TODO(), input)
 		if err == nil {
 			return resp.Body, nil
 		}
@@ -0,0 +1,121 @@
+package xfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Minimum size of a ranged GET: small reads (page headers, footer) are served from the last fetched block.
+// Kept small, so reading a column chunk does not pull much of its unselected neighbours.
+const S3ReadSeekerBlockSize int64 = 64 * 1024
+
+// io.ReadSeeker over an S3 object that downloads only the byte ranges actually read.
+// Parquet reader seeks over unselected column chunks and skipped row groups, those bytes never leave S3.
+type S3ReadSeeker struct {
+	client          *s3.Client
+	bucket          string
+	key             string
+	size            int64
+	pos             int64
+	buf             []byte
+	bufStart        int64
+	BytesDownloaded int64
+}
+
+func NewS3ReadSeeker(fileUrl string) (*S3ReadSeeker, error) {
+	u, err := url.Parse(fileUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse s3 url %s: %s", fileUrl, err.Error())
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	rs := S3ReadSeeker{
+		client: s3.NewFromConfig(cfg),
+		bucket: u.Host,
+		key:    strings.TrimLeft(u.Path, "/")}
+
+	resp, err := rs.client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(rs.bucket),
+		Key:    aws.String(rs.key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get s3 object info for %s: %s", fileUrl, err.Error())
+	}
+	rs.size = aws.ToInt64(resp.ContentLength)
+	return &rs, nil
+}
+
+func (rs *S3ReadSeeker) Size() int64 {
+	return rs.size
+}
+
+func (rs *S3ReadSeeker) fetch(length int64) error {
+	end := min(rs.pos+max(length, S3ReadSeekerBlockSize), rs.size)
+	resp, err := rs.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(rs.bucket),
+		Key:    aws.String(rs.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", rs.pos, end-1)),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot get s3://%s/%s range %d-%d: %s", rs.bucket, rs.key, rs.pos, end-1, err.Error())
+	}
+	defer resp.Body.Close()
+
+	rs.buf, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("cannot read s3://%s/%s range %d-%d: %s", rs.bucket, rs.key, rs.pos, end-1, err.Error())
+	}
+	rs.bufStart = rs.pos
+	rs.BytesDownloaded += int64(len(rs.buf))
+	return nil
+}
+
+func (rs *S3ReadSeeker) Read(p []byte) (int, error) {
+	if rs.pos >= rs.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if rs.pos < rs.bufStart || rs.pos >= rs.bufStart+int64(len(rs.buf)) {
+		if err := rs.fetch(int64(len(p))); err != nil {
+			return 0, err
+		}
+		if len(rs.buf) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	n := copy(p, rs.buf[rs.pos-rs.bufStart:])
+	rs.pos += int64(n)
+	return n, nil
+}
+
+func (rs *S3ReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var newPos int64
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = rs.pos + offset
+	case io.SeekEnd:
+		newPos = rs.size + offset
+	default:
+		return rs.pos, fmt.Errorf("invalid whence %d", whence)
+	}
+	if newPos < 0 {
+		return rs.pos, fmt.Errorf("cannot seek s3://%s/%s to negative position %d", rs.bucket, rs.key, newPos)
+	}
+	rs.pos = newPos
+	return rs.pos, nil
+}
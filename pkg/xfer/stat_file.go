@@ -0,0 +1,125 @@
+package xfer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Size and version of a source file, used to tell whether the file changed since it was ingested.
+// Version is the ETag when the server provides one, modification time otherwise, empty if http server provides neither.
+type FileStat struct {
+	Size    int64
+	Version string
+}
+
+func mtimeVersion(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func StatFile(fileUrl string, certPath string, privateKeys map[string]string) (*FileStat, error) {
+	u, err := url.Parse(fileUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse url %s: %s", fileUrl, err.Error())
+	}
+
+	switch u.Scheme {
+	case UrlSchemeFile, "":
+		fileInfo, err := os.Stat(fileUrl)
+		if err != nil {
+			return nil, fmt.Errorf("cannot stat file %s: %s", fileUrl, err.Error())
+		}
+		return &FileStat{Size: fileInfo.Size(), Version: mtimeVersion(fileInfo.ModTime())}, nil
+	case UrlSchemeHttp, UrlSchemeHttps:
+		return statHttpFile(fileUrl, u.Scheme, certPath)
+	case UrlSchemeS3:
+		return statS3File(u)
+	case UrlSchemeSftp:
+		return statSftpFile(fileUrl, privateKeys)
+	default:
+		return nil, fmt.Errorf("url scheme %s not supported: %s", u.Scheme, fileUrl)
+	}
+}
+
+func statHttpFile(fileUrl string, scheme string, certDir string) (*FileStat, error) {
+	client, err := newHttpClient(scheme, certDir)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Head(fileUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get headers for %s: %s", fileUrl, err.Error())
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot get headers for %s, bad status: %s", fileUrl, resp.Status)
+	}
+
+	version := resp.Header.Get("ETag")
+	if len(version) == 0 {
+		version = resp.Header.Get("Last-Modified")
+	}
+	return &FileStat{Size: resp.ContentLength, Version: version}, nil
+}
+
+func statS3File(u *url.URL) (*FileStat, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(cfg)
+
+	resp, err := client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(u.Host),
+		Key:    aws.String(strings.TrimLeft(u.Path, "/")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get s3 object info for %s: %s", u.String(), err.Error())
+	}
+	return &FileStat{Size: aws.ToInt64(resp.ContentLength), Version: aws.ToString(resp.ETag)}, nil
+}
+
+func statSftpFile(fileUrl string, privateKeys map[string]string) (*FileStat, error) {
+	parsedUrl, err := parseSftpUrl(fileUrl, privateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assume empty key password ""
+	sshClientConfig, err := NewSshClientConfig(parsedUrl.User, parsedUrl.PrivateKeyPath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sshUrl := fmt.Sprintf("%s:%d", parsedUrl.Host, parsedUrl.Port)
+
+	sshClient, err := ssh.Dial("tcp", sshUrl, sshClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("dial to %s failed: %s", fileUrl, err.Error())
+	}
+	defer sshClient.Close()
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create sftp client to %s: %s", fileUrl, err.Error())
+	}
+	defer sftpClient.Close()
+
+	fileInfo, err := sftpClient.Stat(parsedUrl.RemotePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat sftp file %s: %s", fileUrl, err.Error())
+	}
+	return &FileStat{Size: fileInfo.Size(), Version: mtimeVersion(fileInfo.ModTime())}, nil
+}
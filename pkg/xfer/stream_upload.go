@@ -0,0 +1,221 @@
+package xfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Sends data to the destination while it's being written, no temp files involved.
+// Close makes the file visible at its destination, Abort discards whatever was sent so far.
+type StreamUploadWriter interface {
+	io.Writer
+	Close() error
+	Abort(cause error)
+}
+
+var ErrUploadAborted = errors.New("upload aborted")
+
+func NewStreamUploadWriter(fileUrl string, privateKeys map[string]string) (StreamUploadWriter, error) {
+	u, err := url.Parse(fileUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse url %s: %s", fileUrl, err.Error())
+	}
+	switch u.Scheme {
+	case "":
+		return newLocalUploadWriter(fileUrl)
+	case UrlSchemeFile:
+		return newLocalUploadWriter(u.Path)
+	case UrlSchemeS3:
+		return newS3UploadWriter(u)
+	case UrlSchemeSftp:
+		return newSftpUploadWriter(fileUrl, privateKeys)
+	default:
+		return nil, fmt.Errorf("cannot write to %s: url scheme %s not supported", fileUrl, u.Scheme)
+	}
+}
+
+// Local files are written in place, Abort removes what was written
+type localUploadWriter struct {
+	*os.File
+}
+
+func newLocalUploadWriter(filePath string) (*localUploadWriter, error) {
+	// Partitioned url templates may point to a dir nobody created yet
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("cannot create target dir for %s: %s", filePath, err.Error())
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &localUploadWriter{f}, nil
+}
+
+func (w *localUploadWriter) Abort(_ error) {
+	w.File.Close()
+	os.Remove(w.File.Name())
+}
+
+// Multipart upload fed from a pipe: transfermanager buffers a limited number of parts,
+// and aborts the multipart upload when the pipe is closed with an error, so no partial object is left.
+type s3UploadWriter struct {
+	pw     *io.PipeWriter
+	done   chan error
+	closed bool
+}
+
+func newS3UploadWriter(u *url.URL) (*s3UploadWriter, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	w := s3UploadWriter{pw: pw, done: make(chan error, 1)}
+
+	tmClient := transfermanager.New(s3.NewFromConfig(cfg), func(_ *transfermanager.Options) {})
+	go func() {
+		_, err := tmClient.UploadObject(context.TODO(), &transfermanager.UploadObjectInput{
+			Bucket: aws.String(u.Host),
+			Key:    aws.String(strings.TrimLeft(u.Path, "/")),
+			Body:   pr,
+		})
+		// Unblock the writer if the upload failed before reading everything
+		pr.CloseWithError(ErrUploadAborted)
+		w.done <- err
+	}()
+
+	return &w, nil
+}
+
+func (w *s3UploadWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *s3UploadWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.pw.Close()
+	return <-w.done
+}
+
+func (w *s3UploadWriter) Abort(cause error) {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	if cause == nil {
+		cause = ErrUploadAborted
+	}
+	w.pw.CloseWithError(cause)
+	<-w.done
+}
+
+// Writes to a .part file next to the target and renames it on Close
+type sftpUploadWriter struct {
+	fileUrl    string
+	remotePath string
+	tempPath   string
+	sshClient  *ssh.Client
+	sftpClient *sftp.Client
+	f          *sftp.File
+}
+
+func newSftpUploadWriter(fileUrl string, privateKeys map[string]string) (*sftpUploadWriter, error) {
+	parsedUrl, err := parseSftpUrl(fileUrl, privateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assume empty key password ""
+	sshClientConfig, err := NewSshClientConfig(parsedUrl.User, parsedUrl.PrivateKeyPath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sshUrl := fmt.Sprintf("%s:%d", parsedUrl.Host, parsedUrl.Port)
+
+	w := sftpUploadWriter{fileUrl: fileUrl, remotePath: parsedUrl.RemotePath, tempPath: parsedUrl.RemotePath + ".part"}
+	w.sshClient, err = ssh.Dial("tcp", sshUrl, sshClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("dial to %s failed: %s", fileUrl, err.Error())
+	}
+
+	w.sftpClient, err = sftp.NewClient(w.sshClient)
+	if err != nil {
+		w.sshClient.Close()
+		return nil, fmt.Errorf("cannot create sftp client to %s: %s", fileUrl, err.Error())
+	}
+
+	if err := w.sftpClient.MkdirAll(filepath.Dir(w.remotePath)); err != nil {
+		w.closeClients()
+		return nil, fmt.Errorf("cannot create target dir for %s: %s", fileUrl, err.Error())
+	}
+
+	w.f, err = w.sftpClient.Create(w.tempPath)
+	if err != nil {
+		w.closeClients()
+		return nil, fmt.Errorf("cannot create on upload %s: %s", fileUrl, err.Error())
+	}
+
+	return &w, nil
+}
+
+func (w *sftpUploadWriter) closeClients() {
+	w.sftpClient.Close()
+	w.sshClient.Close()
+}
+
+func (w *sftpUploadWriter) Write(p []byte) (int, error) {
+	return w.f.Write(p)
+}
+
+func (w *sftpUploadWriter) Close() error {
+	if w.f == nil {
+		return nil
+	}
+	defer w.closeClients()
+
+	err := w.f.Close()
+	w.f = nil
+	if err != nil {
+		w.sftpClient.Remove(w.tempPath)
+		return fmt.Errorf("cannot flush upload to %s: %s", w.fileUrl, err.Error())
+	}
+
+	// Plain sftp rename fails if the target exists
+	if err := w.sftpClient.PosixRename(w.tempPath, w.remotePath); err != nil {
+		w.sftpClient.Remove(w.remotePath)
+		if err := w.sftpClient.Rename(w.tempPath, w.remotePath); err != nil {
+			w.sftpClient.Remove(w.tempPath)
+			return fmt.Errorf("cannot rename %s to %s on upload %s: %s", w.tempPath, w.remotePath, w.fileUrl, err.Error())
+		}
+	}
+	return nil
+}
+
+func (w *sftpUploadWriter) Abort(_ error) {
+	if w.f == nil {
+		return
+	}
+	defer w.closeClients()
+
+	w.f.Close()
+	w.f = nil
+	w.sftpClient.Remove(w.tempPath)
+}
@@ -367,14 +367,14 @@ func fannieMaeCsvToParquet(dealName string, files []string, colIdxMap map[string
 				newElCounter = 0
 				newElCounterIncludingIrrelevant = 0
 
-				w, err = storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+				w, err = storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 				if err != nil {
 					return err
 				}
 
 				for colName, colType := range colTypeMap {
 					if _, isSupported := colSupportedMap[colName]; isSupported {
-						if err := w.AddColumn(colName, colType); err != nil {
+						if err := w.AddColumn(colName, colType, nil); err != nil {
 							return err
 						}
 					}
@@ -493,7 +493,7 @@ func fannieMaeCsvToParquet(dealName string, files []string, colIdxMap map[string
 
 			newElCounter++
 
-			if err := w.FileWriter.AddData(valMap); err != nil {
+			if err := w.AddData(valMap); err != nil {
 				return fmt.Errorf("cannot write %v: %s", valMap, err.Error())
 			}
 
@@ -139,36 +139,36 @@ func shuffleAndSaveInOrders(inOrders []*Order, totalChunks int, basePath string,
 				if err != nil {
 					log.Fatalf("cannot create in file [%s]: %s", finalFilePath, err.Error())
 				}
-				parquetWriter, err = storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+				parquetWriter, err = storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 				if err != nil {
 					log.Fatalf("cannot create parquet writer: %s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("order_id", evalcapi.FieldTypeString); err != nil {
+				if err := parquetWriter.AddColumn("order_id", evalcapi.FieldTypeString, nil); err != nil {
 					log.Fatalf("cannot add column order_id %s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("customer_id", evalcapi.FieldTypeString); err != nil {
+				if err := parquetWriter.AddColumn("customer_id", evalcapi.FieldTypeString, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("order_status", evalcapi.FieldTypeString); err != nil {
+				if err := parquetWriter.AddColumn("order_status", evalcapi.FieldTypeString, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("order_purchase_timestamp", evalcapi.FieldTypeDateTime); err != nil {
+				if err := parquetWriter.AddColumn("order_purchase_timestamp", evalcapi.FieldTypeDateTime, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("order_approved_at", evalcapi.FieldTypeDateTime); err != nil {
+				if err := parquetWriter.AddColumn("order_approved_at", evalcapi.FieldTypeDateTime, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("order_delivered_carrier_date", evalcapi.FieldTypeDateTime); err != nil {
+				if err := parquetWriter.AddColumn("order_delivered_carrier_date", evalcapi.FieldTypeDateTime, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("order_delivered_customer_date", evalcapi.FieldTypeDateTime); err != nil {
+				if err := parquetWriter.AddColumn("order_delivered_customer_date", evalcapi.FieldTypeDateTime, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("order_estimated_delivery_date", evalcapi.FieldTypeDateTime); err != nil {
+				if err := parquetWriter.AddColumn("order_estimated_delivery_date", evalcapi.FieldTypeDateTime, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
 				// Test only
-				// if err := w.AddColumn("is_sent", evalcapi.FieldTypeBool); err != nil {
+				// if err := w.AddColumn("is_sent", evalcapi.FieldTypeBool, nil); err != nil {
 				// 	log.Fatalf("%s", err.Error())
 				// }
 			}
@@ -187,7 +187,7 @@ func shuffleAndSaveInOrders(inOrders []*Order, totalChunks int, basePath string,
 		}
 
 		if strings.Contains(formats, "parquet") {
-			if err := parquetWriter.FileWriter.AddData(map[string]any{
+			if err := parquetWriter.AddData(map[string]any{
 				"order_id":                      item.OrderId,
 				"customer_id":                   item.CustomerId,
 				"order_status":                  item.OrderStatus,
@@ -263,29 +263,29 @@ func shuffleAndSaveInOrderItems(inOrderItems []*OrderItem, totalChunks int, base
 				if err != nil {
 					log.Fatalf("cannot create in file [%s]: %s", finalFilePath, err.Error())
 				}
-				parquetWriter, err = storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+				parquetWriter, err = storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 				if err != nil {
 					log.Fatalf("cannot create parquet writer: %s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("order_id", evalcapi.FieldTypeString); err != nil {
+				if err := parquetWriter.AddColumn("order_id", evalcapi.FieldTypeString, nil); err != nil {
 					log.Fatalf("cannot add column order_id %s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("order_item_id", evalcapi.FieldTypeInt); err != nil {
+				if err := parquetWriter.AddColumn("order_item_id", evalcapi.FieldTypeInt, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("product_id", evalcapi.FieldTypeString); err != nil {
+				if err := parquetWriter.AddColumn("product_id", evalcapi.FieldTypeString, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("seller_id", evalcapi.FieldTypeString); err != nil {
+				if err := parquetWriter.AddColumn("seller_id", evalcapi.FieldTypeString, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("shipping_limit_date", evalcapi.FieldTypeDateTime); err != nil {
+				if err := parquetWriter.AddColumn("shipping_limit_date", evalcapi.FieldTypeDateTime, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("price", evalcapi.FieldTypeDecimal2); err != nil {
+				if err := parquetWriter.AddColumn("price", evalcapi.FieldTypeDecimal2, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
-				if err := parquetWriter.AddColumn("freight_value", evalcapi.FieldTypeDecimal2); err != nil {
+				if err := parquetWriter.AddColumn("freight_value", evalcapi.FieldTypeDecimal2, nil); err != nil {
 					log.Fatalf("%s", err.Error())
 				}
 			}
@@ -301,7 +301,7 @@ func shuffleAndSaveInOrderItems(inOrderItems []*OrderItem, totalChunks int, base
 				item.FreightValue))
 		}
 		if strings.Contains(formats, "parquet") {
-			if err := parquetWriter.FileWriter.AddData(map[string]any{
+			if err := parquetWriter.AddData(map[string]any{
 				"order_id":            item.OrderId,
 				"order_item_id":       item.OrderItemId,
 				"product_id":          item.ProductId,
@@ -386,32 +386,32 @@ func sortAndSaveNoGroup(items []*NoGroupItem, fileBase string, formats string) {
 			log.Fatalf("cannot create file '%s': %s", parquetFilePath, err.Error())
 		}
 
-		w, err := storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+		w, err := storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 		if err != nil {
 			log.Fatalf("cannot create parquet writer: %s", err.Error())
 		}
 
-		if err := w.AddColumn("order_id", evalcapi.FieldTypeString); err != nil {
+		if err := w.AddColumn("order_id", evalcapi.FieldTypeString, nil); err != nil {
 			log.Fatalf("cannot add column order_id %s", err.Error())
 		}
-		if err := w.AddColumn("order_item_id", evalcapi.FieldTypeInt); err != nil {
+		if err := w.AddColumn("order_item_id", evalcapi.FieldTypeInt, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("product_id", evalcapi.FieldTypeString); err != nil {
+		if err := w.AddColumn("product_id", evalcapi.FieldTypeString, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("seller_id", evalcapi.FieldTypeString); err != nil {
+		if err := w.AddColumn("seller_id", evalcapi.FieldTypeString, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("shipping_limit_date", evalcapi.FieldTypeDateTime); err != nil {
+		if err := w.AddColumn("shipping_limit_date", evalcapi.FieldTypeDateTime, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("value", evalcapi.FieldTypeDecimal2); err != nil {
+		if err := w.AddColumn("value", evalcapi.FieldTypeDecimal2, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
 
 		for _, item := range items {
-			if err := w.FileWriter.AddData(map[string]any{
+			if err := w.AddData(map[string]any{
 				"order_id":            item.OrderId,
 				"order_item_id":       item.OrderItemId,
 				"product_id":          item.ProductId,
@@ -496,41 +496,41 @@ func sortAndSaveGroup(items []*GroupItem, fileBase string, formats string) {
 			log.Fatalf("cannot create file '%s': %s", parquetFilePath, err.Error())
 		}
 
-		w, err := storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+		w, err := storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 		if err != nil {
 			log.Fatalf("cannot create parquet writer: %s", err.Error())
 		}
 
-		if err := w.AddColumn("total_value", evalcapi.FieldTypeDecimal2); err != nil {
+		if err := w.AddColumn("total_value", evalcapi.FieldTypeDecimal2, nil); err != nil {
 			log.Fatalf("cannot add column total_value %s", err.Error())
 		}
-		if err := w.AddColumn("order_purchase_timestamp", evalcapi.FieldTypeDateTime); err != nil {
+		if err := w.AddColumn("order_purchase_timestamp", evalcapi.FieldTypeDateTime, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("order_id", evalcapi.FieldTypeString); err != nil {
+		if err := w.AddColumn("order_id", evalcapi.FieldTypeString, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("avg_value", evalcapi.FieldTypeDecimal2); err != nil {
+		if err := w.AddColumn("avg_value", evalcapi.FieldTypeDecimal2, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("min_value", evalcapi.FieldTypeDecimal2); err != nil {
+		if err := w.AddColumn("min_value", evalcapi.FieldTypeDecimal2, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("max_value", evalcapi.FieldTypeDecimal2); err != nil {
+		if err := w.AddColumn("max_value", evalcapi.FieldTypeDecimal2, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("min_product_id", evalcapi.FieldTypeString); err != nil {
+		if err := w.AddColumn("min_product_id", evalcapi.FieldTypeString, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("max_product_id", evalcapi.FieldTypeString); err != nil {
+		if err := w.AddColumn("max_product_id", evalcapi.FieldTypeString, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
-		if err := w.AddColumn("item_count", evalcapi.FieldTypeInt); err != nil {
+		if err := w.AddColumn("item_count", evalcapi.FieldTypeInt, nil); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
 
 		for _, item := range items {
-			if err := w.FileWriter.AddData(map[string]any{
+			if err := w.AddData(map[string]any{
 				"total_value":              storage.ParquetWriterDecimal2(item.TotalOrderValue),
 				"order_purchase_timestamp": storage.ParquetWriterMilliTs(item.OrderPurchaseTs),
 				"order_id":                 item.OrderId,
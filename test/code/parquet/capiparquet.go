@@ -478,19 +478,19 @@ func sortFile(path string, idxDef *sc.IdxDef) error {
 
 	f.Truncate(0)
 
-	parquetWriter, err := storage.NewParquetWriter(f, sc.ParquetCodecGzip)
+	parquetWriter, err := storage.NewParquetWriter(f, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 	if err != nil {
 		return err
 	}
 
 	for i, column := range fields {
-		if err := parquetWriter.AddColumn(column, types[i]); err != nil {
+		if err := parquetWriter.AddColumn(column, types[i], nil); err != nil {
 			return fmt.Errorf("cannot add column %s: %s", column, err.Error())
 		}
 	}
 
 	for _, indexedRow := range indexedRows {
-		if err := parquetWriter.FileWriter.AddData(indexedRow.Row); err != nil {
+		if err := parquetWriter.AddData(indexedRow.Row); err != nil {
 			return fmt.Errorf("cannot add row %v: %s", indexedRow.Row, err.Error())
 		}
 	}
@@ -95,26 +95,26 @@ func generateHoldings(fileQuickHoldingsPath string, fileInHoldingsPath string, b
 				fileCounter++
 				newElCounter = 0
 
-				w, err = storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+				w, err = storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 				if err != nil {
 					return err
 				}
 
-				if err := w.AddColumn("account_id", evalcapi.FieldTypeString); err != nil {
+				if err := w.AddColumn("account_id", evalcapi.FieldTypeString, nil); err != nil {
 					return err
 				}
-				if err := w.AddColumn("d", evalcapi.FieldTypeDateTime); err != nil {
+				if err := w.AddColumn("d", evalcapi.FieldTypeDateTime, nil); err != nil {
 					return err
 				}
-				if err := w.AddColumn("ticker", evalcapi.FieldTypeString); err != nil {
+				if err := w.AddColumn("ticker", evalcapi.FieldTypeString, nil); err != nil {
 					return err
 				}
-				if err := w.AddColumn("qty", evalcapi.FieldTypeInt); err != nil {
+				if err := w.AddColumn("qty", evalcapi.FieldTypeInt, nil); err != nil {
 					return err
 				}
 			}
 
-			if err := w.FileWriter.AddData(map[string]any{
+			if err := w.AddData(map[string]any{
 				"account_id": accId,
 				"d":          storage.ParquetWriterMilliTs(d),
 				"ticker":     line[2],
@@ -207,28 +207,28 @@ func generateTxns(fileQuickTxnsPath string, fileInTxnsPath string, bigAccountsMa
 				fileCounter++
 				newElCounter = 0
 
-				w, err = storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+				w, err = storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 				if err != nil {
 					return err
 				}
-				if err := w.AddColumn("ts", evalcapi.FieldTypeDateTime); err != nil {
+				if err := w.AddColumn("ts", evalcapi.FieldTypeDateTime, nil); err != nil {
 					return err
 				}
-				if err := w.AddColumn("account_id", evalcapi.FieldTypeString); err != nil {
+				if err := w.AddColumn("account_id", evalcapi.FieldTypeString, nil); err != nil {
 					return err
 				}
-				if err := w.AddColumn("ticker", evalcapi.FieldTypeString); err != nil {
+				if err := w.AddColumn("ticker", evalcapi.FieldTypeString, nil); err != nil {
 					return err
 				}
-				if err := w.AddColumn("qty", evalcapi.FieldTypeInt); err != nil {
+				if err := w.AddColumn("qty", evalcapi.FieldTypeInt, nil); err != nil {
 					return err
 				}
-				if err := w.AddColumn("price", evalcapi.FieldTypeFloat); err != nil {
+				if err := w.AddColumn("price", evalcapi.FieldTypeFloat, nil); err != nil {
 					return err
 				}
 			}
 
-			if err := w.FileWriter.AddData(map[string]any{
+			if err := w.AddData(map[string]any{
 				"ts":         storage.ParquetWriterMilliTs(d),
 				"account_id": accId,
 				"ticker":     line[2],
@@ -279,20 +279,20 @@ func generateOutTotals(fileQuickAccountYearPath string, fileOutAccountYearPath s
 		return fmt.Errorf("cannot create file '%s': %s", fileOutAccountYearPath, err.Error())
 	}
 
-	w, err := storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+	w, err := storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 	if err != nil {
 		return err
 	}
-	if err := w.AddColumn("ARK fund", evalcapi.FieldTypeString); err != nil {
+	if err := w.AddColumn("ARK fund", evalcapi.FieldTypeString, nil); err != nil {
 		return err
 	}
-	if err := w.AddColumn("Period", evalcapi.FieldTypeString); err != nil {
+	if err := w.AddColumn("Period", evalcapi.FieldTypeString, nil); err != nil {
 		return err
 	}
-	if err := w.AddColumn("Sector", evalcapi.FieldTypeString); err != nil {
+	if err := w.AddColumn("Sector", evalcapi.FieldTypeString, nil); err != nil {
 		return err
 	}
-	if err := w.AddColumn("Time-weighted annualized return %", evalcapi.FieldTypeFloat); err != nil {
+	if err := w.AddColumn("Time-weighted annualized return %", evalcapi.FieldTypeFloat, nil); err != nil {
 		return err
 	}
 
@@ -319,7 +319,7 @@ func generateOutTotals(fileQuickAccountYearPath string, fileOutAccountYearPath s
 				return fmt.Errorf("cannot parse ret '%s' in account_year_perf_baseline: %s", line[3], err.Error())
 			}
 
-			if err := w.FileWriter.AddData(map[string]any{
+			if err := w.AddData(map[string]any{
 				"ARK fund":                          accId,
 				"Period":                            line[1],
 				"Sector":                            line[2],
@@ -352,20 +352,20 @@ func generateOutBySector(fileQuickAccountPeriodSectorPath string, fileOutAccount
 		return fmt.Errorf("cannot create file '%s': %s", fileOutAccountPeriodSectorPath, err.Error())
 	}
 
-	w, err := storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+	w, err := storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 	if err != nil {
 		return err
 	}
-	if err := w.AddColumn("ARK fund", evalcapi.FieldTypeString); err != nil {
+	if err := w.AddColumn("ARK fund", evalcapi.FieldTypeString, nil); err != nil {
 		return err
 	}
-	if err := w.AddColumn("Period", evalcapi.FieldTypeString); err != nil {
+	if err := w.AddColumn("Period", evalcapi.FieldTypeString, nil); err != nil {
 		return err
 	}
-	if err := w.AddColumn("Sector", evalcapi.FieldTypeString); err != nil {
+	if err := w.AddColumn("Sector", evalcapi.FieldTypeString, nil); err != nil {
 		return err
 	}
-	if err := w.AddColumn("Time-weighted annualized return %", evalcapi.FieldTypeFloat); err != nil {
+	if err := w.AddColumn("Time-weighted annualized return %", evalcapi.FieldTypeFloat, nil); err != nil {
 		return err
 	}
 
@@ -392,7 +392,7 @@ func generateOutBySector(fileQuickAccountPeriodSectorPath string, fileOutAccount
 				return fmt.Errorf("cannot parse ret '%s' in account_period_sector_perf_baseline: %s", line[3], err.Error())
 			}
 
-			if err := w.FileWriter.AddData(map[string]any{
+			if err := w.AddData(map[string]any{
 				"ARK fund":                          accId,
 				"Period":                            line[1],
 				"Sector":                            line[2],
@@ -420,15 +420,15 @@ func generateAccounts(fileInAccountsPath string, quickAccountsMap map[string]str
 		return fmt.Errorf("cannot create file '%s': %s", fileInAccountsPath, err.Error())
 	}
 
-	w, err := storage.NewParquetWriter(fParquet, sc.ParquetCodecGzip)
+	w, err := storage.NewParquetWriter(fParquet, sc.ParquetCreatorSettings{Codec: sc.ParquetCodecGzip})
 	if err != nil {
 		return err
 	}
 
-	if err := w.AddColumn("account_id", evalcapi.FieldTypeString); err != nil {
+	if err := w.AddColumn("account_id", evalcapi.FieldTypeString, nil); err != nil {
 		return err
 	}
-	if err := w.AddColumn("earliest_period_start", evalcapi.FieldTypeDateTime); err != nil {
+	if err := w.AddColumn("earliest_period_start", evalcapi.FieldTypeDateTime, nil); err != nil {
 		return err
 	}
 
@@ -442,7 +442,7 @@ func generateAccounts(fileInAccountsPath string, quickAccountsMap map[string]str
 			if err != nil {
 				return fmt.Errorf("cannot parse account earliest_period_start '%s': %s", eps, err.Error())
 			}
-			if err := w.FileWriter.AddData(map[string]any{
+			if err := w.AddData(map[string]any{
 				"account_id":            accId,
 				"earliest_period_start": storage.ParquetWriterMilliTs(d),
 			}); err != nil {